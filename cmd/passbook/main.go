@@ -3,16 +3,21 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
+	"passbook/internal/backup"
+	"passbook/internal/cli"
 	"passbook/internal/config"
+	"passbook/internal/exporter"
 	"passbook/internal/importer"
+	"passbook/internal/store"
 	"passbook/internal/ui"
-
-	"golang.org/x/term"
+	"passbook/internal/utils"
 )
 
 var version = "3.0.9"
@@ -22,7 +27,14 @@ const iCloudDataDir = "~/Library/Mobile Documents/com~apple~CloudDocs/PassBook"
 func main() {
 	showVersion := flag.Bool("version", false, "print version and exit")
 	importSource := flag.String("import", "", "import entries from an external source (e.g. bitwarden)")
+	importInto := flag.String("into", "", "with --import, place the imported folders and entries under this folder")
+	importDryRun := flag.Bool("dry-run", false, "with --import, show what would be imported without writing anything")
+	importOnConflict := flag.String("on-conflict", "skip", "with --import, what to do with entries already in the vault: skip, rename, overwrite or merge")
 	enableICloud := flag.Bool("icloud", false, "set vault data directory to iCloud Drive (macOS only)")
+	exportPath := flag.String("export", "", "write an encrypted backup archive of the vault to this file")
+	exportFormat := flag.String("export-format", "passbook", "format for --export: passbook, bitwarden, lastpass or keepass")
+	restorePath := flag.String("restore", "", "rebuild a vault from an encrypted backup archive")
+	flag.StringVar(&keyfileFlag, "keyfile", "", "keyfile to unlock the vault with, instead of the one in the config")
 	flag.Parse()
 
 	if *showVersion {
@@ -36,20 +48,47 @@ func main() {
 	}
 
 	if *importSource != "" {
-		runImport(*importSource, flag.Args())
+		onConflict, err := importer.ParseConflict(*importOnConflict)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		runImport(*importSource, flag.Args(), importer.Options{Into: *importInto, DryRun: *importDryRun, OnConflict: onConflict})
 		return
 	}
 
-	cfg := config.LoadOrInit()
+	if *exportPath != "" {
+		runExport(*exportPath, *exportFormat)
+		return
+	}
+
+	if *restorePath != "" {
+		runRestore(*restorePath)
+		return
+	}
+
+	if args := flag.Args(); len(args) > 0 {
+		if err := cli.Run(args, loadConfig()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	cfg := loadConfig()
 
 	h, err := ui.NewApp(cfg)
 	if err != nil {
-		panic(err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
 	go func() {
 		for range time.Tick(1 * time.Second) {
-			h.QueueUpdateDraw(func() { h.DrawTOTP() })
+			h.QueueUpdateDraw(func() {
+				h.DrawTOTP()
+				h.CheckIdle()
+			})
 		}
 	}()
 
@@ -58,21 +97,31 @@ func main() {
 	}
 }
 
-func runImport(source string, args []string) {
-	supported := map[string]string{
-		"bitwarden":  ".json",
-		"1password":  ".1pux",
-		"lastpass":   ".csv",
+// keyfileFlag is the --keyfile flag; it overrides the keyfile in the
+// config for this run only.
+var keyfileFlag string
+
+func loadConfig() config.AppConfig {
+	cfg := config.LoadOrInit()
+	if keyfileFlag != "" {
+		cfg.Keyfile = keyfileFlag
 	}
+	return cfg
+}
 
-	ext, ok := supported[source]
+func runImport(source string, args []string, opts importer.Options) {
+	src, ok := importer.Lookup(source)
 	if !ok {
-		fmt.Fprintf(os.Stderr, "Unsupported import source: %q (supported: bitwarden, 1password, lastpass)\n", source)
+		fmt.Fprintf(os.Stderr, "Unsupported import source: %q (supported: %s)\n", source, strings.Join(importer.Names(), ", "))
 		os.Exit(1)
 	}
 
 	if len(args) < 1 {
-		fmt.Fprintf(os.Stderr, "Usage: passbook --import %s <path_to_%s_file>\n", source, ext)
+		if src.Ext == "" {
+			fmt.Fprintf(os.Stderr, "Usage: passbook --import %s [--into folder] [--dry-run] [--on-conflict mode] <directory>\n", source)
+		} else {
+			fmt.Fprintf(os.Stderr, "Usage: passbook --import %s [--into folder] [--dry-run] [--on-conflict mode] <path_to_%s_file>\n", source, src.Ext)
+		}
 		os.Exit(1)
 	}
 	filePath := args[0]
@@ -82,30 +131,186 @@ func runImport(source string, args []string) {
 		os.Exit(1)
 	}
 
-	fmt.Print("Master Password: ")
-	pwdBytes, err := term.ReadPassword(int(os.Stdin.Fd()))
-	fmt.Println()
+	var sourcePassword string
+	if src.PasswordPrompt != "" {
+		pwd, err := cli.ReadSecret(src.PasswordPrompt)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading password: %v\n", err)
+			os.Exit(1)
+		}
+		sourcePassword = pwd
+	}
+
+	s, err := cli.Unlock(loadConfig())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer s.Close()
+
+	if err := importer.ImportInto(src, filePath, sourcePassword, s, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Import failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runExport(path, format string) {
+	plaintext := map[string]func(*store.Store, io.Writer) (exporter.Stats, error){
+		"bitwarden": exporter.ExportBitwarden,
+		"lastpass":  exporter.ExportLastPass,
+	}
+	exportPlain, isPlain := plaintext[format]
+	if !isPlain && format != "passbook" && format != "keepass" {
+		fmt.Fprintf(os.Stderr, "Unsupported export format: %q (supported: passbook, bitwarden, lastpass, keepass)\n", format)
+		os.Exit(1)
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		fmt.Fprintf(os.Stderr, "File already exists: %s\n", path)
+		os.Exit(1)
+	}
+
+	s, err := cli.Unlock(loadConfig())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer s.Close()
+
+	if isPlain {
+		runPlainExport(s, path, exportPlain)
+		return
+	}
+	if format == "keepass" {
+		runKeePassExport(s, path)
+		return
+	}
+
+	archivePwd, err := cli.ReadNewPassword("Archive Password: ", "Confirm Archive Password: ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Creating archive: %v\n", err)
+		os.Exit(1)
+	}
+	stats, err := backup.Export(s, f, archivePwd)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		fmt.Fprintf(os.Stderr, "Export failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Export complete: %d folders, %d entries, %d attachments → %s\n",
+		stats.Folders, stats.Entries, stats.Attachments, path)
+}
+
+// runPlainExport writes an unencrypted export for another password manager.
+func runPlainExport(s *store.Store, path string, export func(*store.Store, io.Writer) (exporter.Stats, error)) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Creating export file: %v\n", err)
+		os.Exit(1)
+	}
+	stats, err := export(s, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		fmt.Fprintf(os.Stderr, "Export failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Export complete: %d exported, %d skipped → %s\n", stats.Exported, stats.Skipped, path)
+	fmt.Println("This file is NOT encrypted. Delete it once it has been imported.")
+}
+
+// runKeePassExport writes the vault as a KeePass database protected by a
+// new password.
+func runKeePassExport(s *store.Store, path string) {
+	kdbxPwd, err := cli.ReadNewPassword("KeePass Password: ", "Confirm KeePass Password: ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Creating export file: %v\n", err)
+		os.Exit(1)
+	}
+	stats, err := exporter.ExportKeePass(s, f, kdbxPwd)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		fmt.Fprintf(os.Stderr, "Export failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Export complete: %d exported → %s\n", stats.Exported, path)
+}
+
+func runRestore(path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Opening archive: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	cfg := loadConfig()
+	dbPath := filepath.Join(config.ExpandPath(cfg.DataDir), "passbook.db")
+	if store.DBExists(dbPath) {
+		fmt.Fprintf(os.Stderr, "A vault already exists at %s; move it away before restoring.\n", dbPath)
+		os.Exit(1)
+	}
+
+	archivePwd, err := cli.ReadSecret("Archive Password: ")
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error reading password: %v\n", err)
 		os.Exit(1)
 	}
-	password := string(pwdBytes)
 
-	cfg := config.LoadOrInit()
+	masterPwd, err := cli.ReadNewPassword("New Master Password: ", "Confirm Master Password: ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if _, level, _ := utils.PasswordStrength(masterPwd); level < utils.StrengthGood {
+		fmt.Fprintln(os.Stderr, "Master password is too weak.")
+		os.Exit(1)
+	}
 
-	switch source {
-	case "bitwarden":
-		err = importer.ImportBitwarden(filePath, password, cfg)
-	case "1password":
-		err = importer.Import1Password(filePath, password, cfg)
-	case "lastpass":
-		err = importer.ImportLastPass(filePath, password, cfg)
+	creds, err := cfg.Credentials(masterPwd, "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	s, err := store.CreateWith(dbPath, creds, cfg.CipherParams())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Creating vault: %v\n", err)
+		os.Exit(1)
 	}
 
+	stats, err := backup.Restore(f, archivePwd, s)
+	s.Close()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Import failed: %v\n", err)
+		_ = store.RemoveVault(dbPath)
+		fmt.Fprintf(os.Stderr, "Restore failed: %v\n", err)
 		os.Exit(1)
 	}
+
+	fmt.Printf("Restore complete: %d folders, %d entries, %d attachments\n",
+		stats.Folders, stats.Entries, stats.Attachments)
 }
 
 func setupICloud() {
@@ -145,7 +350,7 @@ func setupICloud() {
 }
 
 func moveDBFiles(oldDir, newDir string) error {
-	dbFiles := []string{"passbook.db", "passbook.db-wal", "passbook.db-shm"}
+	dbFiles := []string{"passbook.db", "passbook.db-wal", "passbook.db-shm", "passbook.db.kdf"}
 	moved := 0
 	for _, name := range dbFiles {
 		src := filepath.Join(oldDir, name)
@@ -0,0 +1,423 @@
+// Package backup reads and writes PassBook's native encrypted archive
+// format, a complete copy of a vault that can be restored into a new
+// database.
+//
+// Layout (all integers big-endian):
+//
+//	magic     "PBBACKUP"  8 bytes
+//	version   uint16      format version, currently 1
+//	iter      uint32      PBKDF2-SHA256 iterations
+//	salt      16 bytes
+//	nonce     12 bytes
+//	payload   AES-256-GCM(gzip(JSON)), authenticated with the header above
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"passbook/internal/crypto"
+	"passbook/internal/store"
+)
+
+const (
+	magic         = "PBBACKUP"
+	formatVersion = 1
+	saltSize      = 16
+	nonceSize     = 12
+	headerSize    = len(magic) + 2 + 4 + saltSize + nonceSize
+
+	// DefaultIterations follows the OWASP recommendation for PBKDF2-SHA256.
+	DefaultIterations = 600_000
+
+	// The header is not authenticated until the key is derived, so the
+	// iteration count it asks for is bounded first: a crafted archive
+	// could otherwise make restoring run for hours.
+	minIterations = 100_000
+	maxIterations = 10 * DefaultIterations
+)
+
+var (
+	ErrNotArchive  = errors.New("not a PassBook backup archive")
+	ErrDecryption  = errors.New("wrong archive password or corrupt archive")
+	ErrVaultInUse  = errors.New("target vault already contains entries")
+	ErrIterations  = errors.New("archive asks for an unsupported number of key derivation iterations")
+	errUnsupported = errors.New("unsupported archive version")
+)
+
+type archive struct {
+	Version   int               `json:"version"`
+	CreatedAt string            `json:"created_at"`
+	Folders   []archiveFolder   `json:"folders"`
+	Entries   []archiveEntry    `json:"entries"`
+	Searches  []archiveSearch   `json:"saved_searches,omitempty"`
+	PinConfig *archivePinConfig `json:"pin_config,omitempty"`
+}
+
+// archiveFolder is a folder. Folders are listed parents first, and
+// archives from before nested folders have no parent_id.
+type archiveFolder struct {
+	ID       int64  `json:"id"`
+	ParentID int64  `json:"parent_id,omitempty"`
+	Name     string `json:"name"`
+}
+
+type archiveEntry struct {
+	FolderID    int64               `json:"folder_id"`
+	Type        string              `json:"type"`
+	Title       string              `json:"title"`
+	Username    string              `json:"username,omitempty"`
+	Password    string              `json:"password,omitempty"`
+	Link        string              `json:"link,omitempty"` // archives from before uris
+	URIs        []archiveURI        `json:"uris,omitempty"`
+	TotpSecret  string              `json:"totp_secret,omitempty"`
+	CardNumber  string              `json:"card_number,omitempty"`
+	Expiry      string              `json:"expiry,omitempty"`
+	CVV         string              `json:"cvv,omitempty"`
+	Notes       string              `json:"notes,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	FileName    string              `json:"file_name,omitempty"`
+	FileData    []byte              `json:"file_data,omitempty"`
+	History     []archiveHistory    `json:"history,omitempty"`
+	Fields      []archiveField      `json:"fields,omitempty"`
+	Attachments []archiveAttachment `json:"attachments,omitempty"`
+}
+
+type archiveHistory struct {
+	Password string `json:"password"`
+	Date     string `json:"date"`
+}
+
+type archiveURI struct {
+	URI   string `json:"uri"`
+	Match string `json:"match,omitempty"`
+}
+
+type archiveField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Type  string `json:"type"`
+}
+
+type archiveAttachment struct {
+	ID       string `json:"id"`
+	FileName string `json:"file_name"`
+	Data     []byte `json:"data"`
+}
+
+type archiveSearch struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+}
+
+type archivePinConfig struct {
+	Mode       string `json:"mode"`
+	PinKey     []byte `json:"pin_key,omitempty"`
+	PinTag     string `json:"pin_tag,omitempty"`
+	TotpSecret string `json:"totp_secret,omitempty"`
+}
+
+// Stats summarises what was written or restored.
+type Stats struct {
+	Folders     int
+	Entries     int
+	Attachments int
+}
+
+// Export writes every folder, entry, password history item, attachment and
+// the 2FA configuration in s to w, encrypted with password.
+func Export(s *store.Store, w io.Writer, password string) (Stats, error) {
+	a, stats, err := collect(s)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	var plain bytes.Buffer
+	zw := gzip.NewWriter(&plain)
+	if err := json.NewEncoder(zw).Encode(a); err != nil {
+		return Stats{}, fmt.Errorf("encoding archive: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return Stats{}, fmt.Errorf("compressing archive: %w", err)
+	}
+	defer crypto.WipeBytes(plain.Bytes())
+
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	binary.BigEndian.PutUint16(header[8:], formatVersion)
+	binary.BigEndian.PutUint32(header[10:], DefaultIterations)
+	salt := header[14 : 14+saltSize]
+	nonce := header[14+saltSize:]
+	if _, err := rand.Read(salt); err != nil {
+		return Stats{}, fmt.Errorf("generating salt: %w", err)
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		return Stats{}, fmt.Errorf("generating nonce: %w", err)
+	}
+
+	gcm, err := newCipher(password, salt, DefaultIterations)
+	if err != nil {
+		return Stats{}, err
+	}
+	sealed := gcm.Seal(nil, nonce, plain.Bytes(), header)
+
+	if _, err := w.Write(header); err != nil {
+		return Stats{}, err
+	}
+	if _, err := w.Write(sealed); err != nil {
+		return Stats{}, err
+	}
+	return stats, nil
+}
+
+// Restore decrypts the archive in r and recreates its contents in s, which
+// must not contain any entries yet.
+func Restore(r io.Reader, password string, s *store.Store) (Stats, error) {
+	a, err := read(r, password)
+	if err != nil {
+		return Stats{}, err
+	}
+	if s.HasEntries() {
+		return Stats{}, ErrVaultInUse
+	}
+
+	var stats Stats
+	folderIDs := map[int64]int64{0: 0}
+	for _, f := range a.Folders {
+		parentID, ok := folderIDs[f.ParentID]
+		if !ok {
+			return stats, fmt.Errorf("folder %q references unknown parent %d", f.Name, f.ParentID)
+		}
+		existing, err := s.GetFolderByName(parentID, f.Name)
+		if err != nil {
+			return stats, err
+		}
+		if existing != nil {
+			folderIDs[f.ID] = existing.ID
+			continue
+		}
+		id, err := s.CreateFolder(parentID, f.Name)
+		if err != nil {
+			return stats, fmt.Errorf("creating folder %q: %w", f.Name, err)
+		}
+		folderIDs[f.ID] = id
+		stats.Folders++
+	}
+
+	for _, ae := range a.Entries {
+		folderID, ok := folderIDs[ae.FolderID]
+		if !ok {
+			return stats, fmt.Errorf("entry %q references unknown folder %d", ae.Title, ae.FolderID)
+		}
+		e := &store.EntryFull{
+			Type:       ae.Type,
+			Title:      ae.Title,
+			Username:   ae.Username,
+			Password:   ae.Password,
+			TotpSecret: ae.TotpSecret,
+			CardNumber: ae.CardNumber,
+			Expiry:     ae.Expiry,
+			CVV:        ae.CVV,
+			CustomText: ae.Notes,
+			Tags:       ae.Tags,
+			FileName:   ae.FileName,
+			FileData:   ae.FileData,
+		}
+		e.AddURI(ae.Link)
+		for _, u := range ae.URIs {
+			e.URIs = append(e.URIs, store.EntryURI{URI: u.URI, Match: store.MatchMode(u.Match)})
+		}
+		for _, h := range ae.History {
+			e.History = append(e.History, store.PasswordHistory{Password: h.Password, Date: h.Date})
+		}
+		for _, f := range ae.Fields {
+			e.Fields = append(e.Fields, store.CustomField{Name: f.Name, Value: f.Value, Type: store.FieldType(f.Type)})
+		}
+		id, err := s.SaveEntry(folderID, e)
+		if err != nil {
+			return stats, fmt.Errorf("restoring %q: %w", ae.Title, err)
+		}
+		stats.Entries++
+
+		for _, att := range ae.Attachments {
+			if err := s.WriteAttachment(att.ID, id, att.FileName, int64(len(att.Data)), att.Data); err != nil {
+				return stats, fmt.Errorf("restoring attachment %q: %w", att.FileName, err)
+			}
+			stats.Attachments++
+		}
+	}
+
+	for _, ss := range a.Searches {
+		if _, err := s.SaveSearch(ss.Name, ss.Query); err != nil {
+			return stats, fmt.Errorf("restoring saved search %q: %w", ss.Name, err)
+		}
+	}
+
+	// A PIN without a tag was part of the old vault's key and cannot be
+	// carried over; the restored vault asks for a new one.
+	if a.PinConfig != nil && !(a.PinConfig.Mode == "pin" && a.PinConfig.PinTag == "") {
+		if err := s.WritePinConfig(&store.PinConfig{
+			Mode:       a.PinConfig.Mode,
+			PinKey:     a.PinConfig.PinKey,
+			PinTag:     a.PinConfig.PinTag,
+			TotpSecret: a.PinConfig.TotpSecret,
+		}); err != nil {
+			return stats, fmt.Errorf("restoring 2FA config: %w", err)
+		}
+	}
+
+	return stats, nil
+}
+
+func collect(s *store.Store) (*archive, Stats, error) {
+	var stats Stats
+	a := &archive{
+		Version:   formatVersion,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	folders, err := s.ListFolders()
+	if err != nil {
+		return nil, stats, fmt.Errorf("listing folders: %w", err)
+	}
+	for _, f := range folders {
+		a.Folders = append(a.Folders, archiveFolder{ID: f.ID, ParentID: f.ParentID, Name: f.Name})
+	}
+	stats.Folders = len(folders)
+
+	metas, err := s.ListAllEntries()
+	if err != nil {
+		return nil, stats, fmt.Errorf("listing entries: %w", err)
+	}
+	for _, m := range metas {
+		e, err := s.LoadEntry(m.ID)
+		if err != nil {
+			return nil, stats, fmt.Errorf("loading %q: %w", m.Title, err)
+		}
+		ae := archiveEntry{
+			FolderID:   e.FolderID,
+			Type:       e.Type,
+			Title:      e.Title,
+			Username:   e.Username,
+			Password:   e.Password,
+			TotpSecret: e.TotpSecret,
+			CardNumber: e.CardNumber,
+			Expiry:     e.Expiry,
+			CVV:        e.CVV,
+			Notes:      e.CustomText,
+			Tags:       e.Tags,
+			FileName:   e.FileName,
+			FileData:   e.FileData,
+		}
+		for _, u := range e.URIs {
+			ae.URIs = append(ae.URIs, archiveURI{URI: u.URI, Match: string(u.Match)})
+		}
+		for _, h := range e.History {
+			ae.History = append(ae.History, archiveHistory{Password: h.Password, Date: h.Date})
+		}
+		for _, f := range e.Fields {
+			ae.Fields = append(ae.Fields, archiveField{Name: f.Name, Value: f.Value, Type: string(f.Type)})
+		}
+		for _, att := range e.Attachments {
+			data, err := s.ReadAttachment(att.ID)
+			if err != nil {
+				return nil, stats, fmt.Errorf("reading attachment %q: %w", att.FileName, err)
+			}
+			ae.Attachments = append(ae.Attachments, archiveAttachment{ID: att.ID, FileName: att.FileName, Data: data})
+			stats.Attachments++
+		}
+		a.Entries = append(a.Entries, ae)
+	}
+	stats.Entries = len(metas)
+
+	searches, err := s.ListSavedSearches()
+	if err != nil {
+		return nil, stats, fmt.Errorf("listing saved searches: %w", err)
+	}
+	for _, ss := range searches {
+		a.Searches = append(a.Searches, archiveSearch{Name: ss.Name, Query: ss.Query})
+	}
+
+	pinCfg, err := s.ReadPinConfig()
+	if err != nil {
+		return nil, stats, fmt.Errorf("reading 2FA config: %w", err)
+	}
+	if pinCfg != nil {
+		a.PinConfig = &archivePinConfig{
+			Mode:       pinCfg.Mode,
+			PinKey:     pinCfg.PinKey,
+			PinTag:     pinCfg.PinTag,
+			TotpSecret: pinCfg.TotpSecret,
+		}
+	}
+
+	return a, stats, nil
+}
+
+func read(r io.Reader, password string) (*archive, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading archive: %w", err)
+	}
+	if len(data) < headerSize || string(data[:len(magic)]) != magic {
+		return nil, ErrNotArchive
+	}
+	header := data[:headerSize]
+	if v := binary.BigEndian.Uint16(header[8:]); v != formatVersion {
+		return nil, fmt.Errorf("%w: %d", errUnsupported, v)
+	}
+	iter := binary.BigEndian.Uint32(header[10:])
+	if iter < minIterations || iter > maxIterations {
+		return nil, fmt.Errorf("%w: %d (between %d and %d)", ErrIterations, iter, minIterations, maxIterations)
+	}
+	salt := header[14 : 14+saltSize]
+	nonce := header[14+saltSize:]
+
+	gcm, err := newCipher(password, salt, int(iter))
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, nonce, data[headerSize:], header)
+	if err != nil {
+		return nil, ErrDecryption
+	}
+	defer crypto.WipeBytes(plain)
+
+	zr, err := gzip.NewReader(bytes.NewReader(plain))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing archive: %w", err)
+	}
+	var a archive
+	if err := json.NewDecoder(zr).Decode(&a); err != nil {
+		return nil, fmt.Errorf("decoding archive: %w", err)
+	}
+	if a.Version != formatVersion {
+		return nil, fmt.Errorf("%w: %d", errUnsupported, a.Version)
+	}
+	return &a, nil
+}
+
+func newCipher(password string, salt []byte, iter int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, password, salt, iter, 32)
+	if err != nil {
+		return nil, fmt.Errorf("deriving key: %w", err)
+	}
+	defer crypto.WipeBytes(key)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
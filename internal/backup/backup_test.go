@@ -0,0 +1,196 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"passbook/internal/store"
+)
+
+func openTestStore(t *testing.T) *store.Store {
+	t.Helper()
+	s, err := store.Open(filepath.Join(t.TempDir(), "passbook.db"), "testpass")
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func populate(t *testing.T, s *store.Store) {
+	t.Helper()
+	folderID, err := s.CreateFolderPath("Work/Dev")
+	if err != nil {
+		t.Fatalf("CreateFolder: %v", err)
+	}
+	if _, err := s.SaveEntry(folderID, &store.EntryFull{
+		Type:     "Login",
+		Title:    "GitHub",
+		Username: "octocat",
+		Password: "current",
+		History:  []store.PasswordHistory{{Password: "old", Date: "2025-01-01 10:00"}},
+		Fields:   []store.CustomField{{Name: "Recovery code", Value: "abcd-efgh", Type: store.FieldHidden}},
+		Tags:     []string{"dev", "work"},
+		URIs:     []store.EntryURI{{URI: "https://github.com"}, {URI: "https://github.com/login", Match: store.MatchExact}},
+	}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	fileID, err := s.SaveEntry(0, &store.EntryFull{Type: "File", Title: "Keys"})
+	if err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	if err := s.WriteAttachment("att-1", fileID, "id_rsa", 3, []byte{1, 2, 3}); err != nil {
+		t.Fatalf("WriteAttachment: %v", err)
+	}
+	if _, err := s.SaveSearch("Work logins", "tag:work"); err != nil {
+		t.Fatalf("SaveSearch: %v", err)
+	}
+	if err := s.WritePinConfig(&store.PinConfig{Mode: "totp", TotpSecret: "JBSWY3DPEHPK3PXP"}); err != nil {
+		t.Fatalf("WritePinConfig: %v", err)
+	}
+}
+
+func TestExportRestoreRoundTrip(t *testing.T) {
+	src := openTestStore(t)
+	populate(t, src)
+
+	var buf bytes.Buffer
+	stats, err := Export(src, &buf, "archive-pass")
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if stats.Folders != 2 || stats.Entries != 2 || stats.Attachments != 1 {
+		t.Fatalf("unexpected export stats: %+v", stats)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("octocat")) {
+		t.Fatalf("archive contains plaintext")
+	}
+
+	dst := openTestStore(t)
+	if _, err := Restore(bytes.NewReader(buf.Bytes()), "archive-pass", dst); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	folder, err := dst.GetFolderByPath("Work/Dev")
+	if err != nil || folder == nil {
+		t.Fatalf("expected folder to be restored: %v", err)
+	}
+	entries, err := dst.ListEntries(folder.ID)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected 1 entry in folder, got %d (%v)", len(entries), err)
+	}
+	login, err := dst.LoadEntry(entries[0].ID)
+	if err != nil {
+		t.Fatalf("LoadEntry: %v", err)
+	}
+	if login.Password != "current" || len(login.History) != 1 || login.History[0].Password != "old" {
+		t.Fatalf("unexpected login after restore: %+v", login)
+	}
+	if len(login.Fields) != 1 || login.Fields[0] != (store.CustomField{Name: "Recovery code", Value: "abcd-efgh", Type: store.FieldHidden}) {
+		t.Fatalf("unexpected fields after restore: %+v", login.Fields)
+	}
+	if len(login.URIs) != 2 || login.URIs[1].Match != store.MatchExact {
+		t.Fatalf("unexpected URIs after restore: %+v", login.URIs)
+	}
+	if len(login.Tags) != 2 || login.Tags[0] != "dev" || login.Tags[1] != "work" {
+		t.Fatalf("unexpected tags after restore: %v", login.Tags)
+	}
+	if searches, _ := dst.ListSavedSearches(); len(searches) != 1 || searches[0].Query != "tag:work" {
+		t.Fatalf("unexpected saved searches after restore: %+v", searches)
+	}
+
+	roots, err := dst.ListEntries(0)
+	if err != nil || len(roots) != 1 {
+		t.Fatalf("expected 1 root entry, got %d (%v)", len(roots), err)
+	}
+	file, err := dst.LoadEntry(roots[0].ID)
+	if err != nil {
+		t.Fatalf("LoadEntry: %v", err)
+	}
+	if len(file.Attachments) != 1 {
+		t.Fatalf("expected attachment to be restored")
+	}
+	data, err := dst.ReadAttachment(file.Attachments[0].ID)
+	if err != nil || !bytes.Equal(data, []byte{1, 2, 3}) {
+		t.Fatalf("unexpected attachment data %v (%v)", data, err)
+	}
+
+	pinCfg, err := dst.ReadPinConfig()
+	if err != nil || pinCfg == nil || pinCfg.TotpSecret != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("expected 2FA config to be restored: %+v (%v)", pinCfg, err)
+	}
+}
+
+func TestRestoreWrongPassword(t *testing.T) {
+	src := openTestStore(t)
+	populate(t, src)
+
+	var buf bytes.Buffer
+	if _, err := Export(src, &buf, "archive-pass"); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	dst := openTestStore(t)
+	if _, err := Restore(&buf, "wrong", dst); !errors.Is(err, ErrDecryption) {
+		t.Fatalf("expected ErrDecryption, got %v", err)
+	}
+}
+
+func TestRestoreTamperedArchive(t *testing.T) {
+	src := openTestStore(t)
+	populate(t, src)
+
+	var buf bytes.Buffer
+	if _, err := Export(src, &buf, "archive-pass"); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	data := buf.Bytes()
+	data[len(data)-1] ^= 0xFF
+
+	dst := openTestStore(t)
+	if _, err := Restore(bytes.NewReader(data), "archive-pass", dst); !errors.Is(err, ErrDecryption) {
+		t.Fatalf("expected ErrDecryption, got %v", err)
+	}
+}
+
+func TestRestoreRejectsIterationsOutOfRange(t *testing.T) {
+	src := openTestStore(t)
+	populate(t, src)
+
+	var buf bytes.Buffer
+	if _, err := Export(src, &buf, "archive-pass"); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	for _, iter := range []uint32{0, minIterations - 1, maxIterations + 1, 1<<32 - 1} {
+		data := bytes.Clone(buf.Bytes())
+		binary.BigEndian.PutUint32(data[10:], iter)
+		dst := openTestStore(t)
+		if _, err := Restore(bytes.NewReader(data), "archive-pass", dst); !errors.Is(err, ErrIterations) {
+			t.Fatalf("iter %d: expected ErrIterations, got %v", iter, err)
+		}
+	}
+}
+
+func TestRestoreRejectsNonArchive(t *testing.T) {
+	dst := openTestStore(t)
+	if _, err := Restore(bytes.NewReader([]byte("hello")), "x", dst); !errors.Is(err, ErrNotArchive) {
+		t.Fatalf("expected ErrNotArchive, got %v", err)
+	}
+}
+
+func TestRestoreRefusesNonEmptyVault(t *testing.T) {
+	src := openTestStore(t)
+	populate(t, src)
+
+	var buf bytes.Buffer
+	if _, err := Export(src, &buf, "archive-pass"); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	if _, err := Restore(&buf, "archive-pass", src); !errors.Is(err, ErrVaultInUse) {
+		t.Fatalf("expected ErrVaultInUse, got %v", err)
+	}
+}
@@ -0,0 +1,407 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"passbook/internal/config"
+	"passbook/internal/crypto"
+	"passbook/internal/store"
+
+	"golang.org/x/term"
+)
+
+// Environment variables that let scripts unlock the vault without a TTY.
+const (
+	envMasterPassword = "PASSBOOK_MASTER_PASSWORD"
+	envSecondFactor   = "PASSBOOK_2FA_CODE"
+)
+
+type command struct {
+	usage string
+	run   func(c *session, args []string) error
+}
+
+var commands = map[string]command{
+	"get":   {usage: getUsage, run: runGet},
+	"ls":    {usage: lsUsage, run: runLs},
+	"match": {usage: matchUsage, run: runMatch},
+	"add":   {usage: addUsage, run: runAdd},
+	"edit":  {usage: editUsage, run: runEdit},
+	"rm":    {usage: rmUsage, run: runRm},
+	"vault": {usage: vaultUsage, run: runVault},
+}
+
+// session carries the configuration and I/O streams for a single command.
+type session struct {
+	cfg    config.AppConfig
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// readSecret prompts for a secret value. It is a variable so tests can
+// supply answers without a terminal.
+var readSecret = promptSecret
+
+// Run executes the subcommand named by args[0].
+func Run(args []string, cfg config.AppConfig) error {
+	return run(args, cfg, os.Stdout, os.Stderr)
+}
+
+// Unlock prompts for the master password and second factor and opens the
+// vault configured in cfg, for commands that live outside this package.
+func Unlock(cfg config.AppConfig) (*store.Store, error) {
+	c := &session{cfg: cfg, stdout: os.Stdout, stderr: os.Stderr}
+	return c.unlock()
+}
+
+// ReadSecret prompts on the terminal for a value without echoing it.
+func ReadSecret(prompt string) (string, error) {
+	return readSecret(prompt)
+}
+
+// ReadNewPassword prompts twice and returns the password once both match.
+func ReadNewPassword(prompt, confirmPrompt string) (string, error) {
+	pwd, err := readSecret(prompt)
+	if err != nil {
+		return "", fmt.Errorf("reading password: %w", err)
+	}
+	if pwd == "" {
+		return "", fmt.Errorf("password must not be empty")
+	}
+	confirm, err := readSecret(confirmPrompt)
+	if err != nil {
+		return "", fmt.Errorf("reading password: %w", err)
+	}
+	if pwd != confirm {
+		return "", fmt.Errorf("passwords do not match")
+	}
+	return pwd, nil
+}
+
+func run(args []string, cfg config.AppConfig, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no command given\n%s", usage())
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage())
+	}
+	c := &session{cfg: cfg, stdout: stdout, stderr: stderr}
+	return cmd.run(c, args[1:])
+}
+
+func usage() string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("Commands:\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "  passbook %s\n", commands[name].usage)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func (c *session) dbPath() string {
+	return filepath.Join(config.ExpandPath(c.cfg.DataDir), "passbook.db")
+}
+
+// unlock opens the vault with the master password and performs the same
+// PIN / authenticator check the TUI does before handing out the store.
+func (c *session) unlock() (*store.Store, error) {
+	s, _, err := c.unlockWithPassword()
+	return s, err
+}
+
+// unlockWithPassword is unlock for commands that need the master password
+// itself as well.
+func (c *session) unlockWithPassword() (*store.Store, string, error) {
+	dbPath := c.dbPath()
+	if !store.DBExists(dbPath) {
+		return nil, "", fmt.Errorf("no vault found at %s", dbPath)
+	}
+
+	attempts := store.LoadAttempts(dbPath, store.AttemptPassword)
+	if err := c.checkAttempts(attempts); err != nil {
+		return nil, "", err
+	}
+
+	password := os.Getenv(envMasterPassword)
+	if password == "" {
+		var err error
+		password, err = readSecret("Master Password: ")
+		if err != nil {
+			return nil, "", fmt.Errorf("reading password: %w", err)
+		}
+	}
+
+	creds, err := c.cfg.Credentials(password, "")
+	if err != nil {
+		return nil, "", err
+	}
+	s, err := store.OpenWith(dbPath, creds)
+	switch {
+	case errors.Is(err, store.ErrKeyfileRequired):
+		return nil, "", fmt.Errorf("%w; pass --keyfile or set keyfile in the config", err)
+	case errors.Is(err, store.ErrPINRequired):
+		if s, err = c.openWithPIN(creds, attempts); err != nil {
+			return nil, "", err
+		}
+	case errors.Is(err, store.ErrSchemaTooNew):
+		return nil, "", err
+	case errors.Is(err, store.ErrWrongKey):
+		msg := "wrong password"
+		if creds.Keyfile != nil {
+			msg = "wrong password or keyfile"
+		}
+		return nil, "", c.recordFailure(attempts, msg)
+	case err != nil:
+		return nil, "", fmt.Errorf("opening vault: %w", err)
+	default:
+		_ = attempts.Reset()
+	}
+
+	if err := c.verifySecondFactor(s); err != nil {
+		s.Close()
+		return nil, "", err
+	}
+	return s, password, nil
+}
+
+// openWithPIN asks for the PIN of a vault whose key includes it and opens
+// the vault. A wrong password cannot be told from a wrong PIN, so either
+// counts as a failed PIN.
+func (c *session) openWithPIN(creds store.Credentials, pwAttempts *store.Attempts) (*store.Store, error) {
+	attempts := store.LoadAttempts(c.dbPath(), store.AttemptPIN)
+	if err := c.checkAttempts(attempts); err != nil {
+		return nil, err
+	}
+	pin, err := readCode("PIN: ")
+	if err != nil {
+		return nil, err
+	}
+	creds.PIN = pin
+
+	s, err := store.OpenWith(c.dbPath(), creds)
+	if errors.Is(err, store.ErrWrongKey) {
+		return nil, c.recordFailure(attempts, "wrong password or PIN")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening vault: %w", err)
+	}
+	_ = attempts.Reset()
+	_ = pwAttempts.Reset()
+	return s, nil
+}
+
+// readCode returns the PIN or authenticator code from the environment or,
+// failing that, asks for it.
+func readCode(prompt string) (string, error) {
+	code := os.Getenv(envSecondFactor)
+	if code == "" {
+		var err error
+		if code, err = readSecret(prompt); err != nil {
+			return "", fmt.Errorf("reading code: %w", err)
+		}
+	}
+	return strings.TrimSpace(code), nil
+}
+
+// checkAttempts refuses to try again while earlier failures of the same
+// kind still call for a wait.
+func (c *session) checkAttempts(a *store.Attempts) error {
+	if wait := a.Wait(c.cfg.MaxFailedUnlocks, time.Now()); wait > 0 {
+		return fmt.Errorf("too many failed attempts; try again in %s", wait.Round(time.Second))
+	}
+	return nil
+}
+
+// recordFailure counts a failed attempt and returns the error to report.
+// Once the configured maximum is reached with wiping on, the vault is
+// erased; the store must be closed by then.
+func (c *session) recordFailure(a *store.Attempts, msg string) error {
+	_ = a.Fail(time.Now())
+	if a.Exceeded(c.cfg.MaxFailedUnlocks) && c.cfg.WipeAfterMaxFailures {
+		if err := store.RemoveVault(c.dbPath()); err != nil {
+			return fmt.Errorf("%s; erasing the vault after too many failed attempts: %w", msg, err)
+		}
+		return fmt.Errorf("%s; too many failed attempts, the vault was erased", msg)
+	}
+	if n := a.Remaining(c.cfg.MaxFailedUnlocks); n >= 0 {
+		return fmt.Errorf("%s (attempts left: %d)", msg, n)
+	}
+	return errors.New(msg)
+}
+
+func (c *session) verifySecondFactor(s *store.Store) error {
+	pinCfg, err := s.ReadPinConfig()
+	if err != nil {
+		return fmt.Errorf("reading 2FA config: %w", err)
+	}
+	if pinCfg == nil {
+		return fmt.Errorf("two-factor authentication is not set up; open the vault in the TUI first")
+	}
+	switch {
+	case pinCfg.Mode == "off":
+		return nil
+	case pinCfg.KeyedPIN():
+		// The PIN is part of the vault key, so opening the vault checked it.
+		return nil
+	case pinCfg.Mode != "pin" && pinCfg.Mode != "totp":
+		return fmt.Errorf("unknown 2FA mode %q", pinCfg.Mode)
+	}
+
+	attempts := store.LoadAttempts(c.dbPath(), store.AttemptPIN)
+	if err := c.checkAttempts(attempts); err != nil {
+		return err
+	}
+
+	// The prompt names the primary method, but either one is accepted
+	// when both are set up.
+	prompt, failed := "Authenticator Code: ", "invalid authenticator code"
+	if pinCfg.Mode == "pin" {
+		prompt, failed = "PIN: ", "wrong PIN"
+	}
+	code, err := readCode(prompt)
+	if err != nil {
+		return err
+	}
+
+	ok := pinCfg.HasPIN() && crypto.VerifyPinTag(pinCfg.PinKey, code, pinCfg.PinTag)
+	if !ok && pinCfg.HasTOTP() {
+		ok = crypto.ValidateTOTP(code, pinCfg.TotpSecret)
+		if !ok {
+			if ok, _ = s.UseBackupCode(code); ok {
+				if n, err := s.BackupCodesLeft(); err == nil {
+					fmt.Fprintf(c.stderr, "Backup code used; %d left.\n", n)
+				}
+			}
+		}
+	}
+	if !ok {
+		s.Close()
+		return c.recordFailure(attempts, failed)
+	}
+	return attempts.Reset()
+}
+
+// promptSecret reads a line without echo. Prompts go to the controlling
+// terminal so stdout stays clean for pipes, and stdin stays free for data.
+func promptSecret(prompt string) (string, error) {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprint(os.Stderr, prompt)
+		b, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		return string(b), err
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("no terminal available (set %s / %s for non-interactive use)",
+			envMasterPassword, envSecondFactor)
+	}
+	defer tty.Close()
+
+	fmt.Fprint(tty, prompt)
+	b, err := term.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(tty)
+	return string(b), err
+}
+
+// parseFlags parses fs allowing flags and positional arguments to be
+// interleaved (e.g. "get Work/GitHub --field password").
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+func newFlagSet(c *session, usage string) *flag.FlagSet {
+	name, _, _ := strings.Cut(usage, " ")
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: passbook %s\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// ── Path resolution ─────────────────────────────────────────────────
+
+// resolveFolder returns the folder ID for a path such as "Work/AWS"; an
+// empty path is the root.
+func resolveFolder(s *store.Store, name string) (int64, error) {
+	name = strings.Trim(name, "/")
+	if name == "" {
+		return 0, nil
+	}
+	f, err := s.GetFolderByPath(name)
+	if err != nil {
+		return 0, err
+	}
+	if f == nil {
+		return 0, fmt.Errorf("folder not found: %s", name)
+	}
+	return f.ID, nil
+}
+
+// resolveEntry looks up an entry by "<folder path>/<title>" or, for
+// entries at the root, by "<title>". Titles may themselves contain slashes,
+// so each folder prefix is tried, longest first, and only honoured when
+// such a folder exists.
+func resolveEntry(s *store.Store, path string) (*store.EntryMeta, error) {
+	for i := strings.LastIndex(path, "/"); i > 0; i = strings.LastIndex(path[:i], "/") {
+		f, err := s.GetFolderByPath(path[:i])
+		if err != nil {
+			return nil, err
+		}
+		if f != nil {
+			if e, err := findEntry(s, f.ID, path[i+1:]); e != nil || err != nil {
+				return e, err
+			}
+		}
+	}
+
+	e, err := findEntry(s, 0, path)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, fmt.Errorf("entry not found: %s", path)
+	}
+	return e, nil
+}
+
+func findEntry(s *store.Store, folderID int64, title string) (*store.EntryMeta, error) {
+	entries, err := s.ListEntries(folderID)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.Title == title {
+			return &e, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,305 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"passbook/internal/config"
+	"passbook/internal/crypto"
+	"passbook/internal/store"
+)
+
+const (
+	testPassword = "testpass"
+	testPin      = "123456"
+)
+
+func setupTestVault(t *testing.T) (config.AppConfig, *store.Store) {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := config.AppConfig{DataDir: dir}
+
+	s, err := store.Open(filepath.Join(dir, "passbook.db"), testPassword)
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	pinKey, err := crypto.GeneratePinKey()
+	if err != nil {
+		t.Fatalf("GeneratePinKey: %v", err)
+	}
+	if err := s.WritePinConfig(&store.PinConfig{
+		Mode:   "pin",
+		PinKey: pinKey,
+		PinTag: crypto.ComputePinTag(pinKey, testPin),
+	}); err != nil {
+		t.Fatalf("WritePinConfig: %v", err)
+	}
+	return cfg, s
+}
+
+func stubSecrets(t *testing.T, answers map[string]string) {
+	t.Helper()
+	prev := readSecret
+	readSecret = func(prompt string) (string, error) { return answers[prompt], nil }
+	t.Cleanup(func() { readSecret = prev })
+}
+
+func runCLI(t *testing.T, cfg config.AppConfig, args ...string) (string, error) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	err := run(args, cfg, &out, &errOut)
+	return out.String(), err
+}
+
+func TestGetField(t *testing.T) {
+	cfg, s := setupTestVault(t)
+	folderID, err := s.CreateFolder(0, "Work")
+	if err != nil {
+		t.Fatalf("CreateFolder: %v", err)
+	}
+	if _, err := s.SaveEntry(folderID, &store.EntryFull{
+		Type: "Login", Title: "GitHub", Username: "octocat", Password: "s3cret",
+	}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	stubSecrets(t, map[string]string{"Master Password: ": testPassword, "PIN: ": testPin})
+
+	out, err := runCLI(t, cfg, "get", "Work/GitHub", "--field", "password")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if out != "s3cret\n" {
+		t.Fatalf("expected password, got %q", out)
+	}
+
+	out, err = runCLI(t, cfg, "get", "--field=username", "Work/GitHub")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if out != "octocat\n" {
+		t.Fatalf("expected username, got %q", out)
+	}
+}
+
+func TestGetRootEntryWithSlashInTitle(t *testing.T) {
+	cfg, s := setupTestVault(t)
+	if _, err := s.SaveEntry(0, &store.EntryFull{Type: "Note", Title: "a/b", CustomText: "hello"}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	stubSecrets(t, map[string]string{"Master Password: ": testPassword, "PIN: ": testPin})
+
+	out, err := runCLI(t, cfg, "get", "a/b", "--field", "notes")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if out != "hello\n" {
+		t.Fatalf("expected notes, got %q", out)
+	}
+}
+
+func TestGetWrongPin(t *testing.T) {
+	cfg, s := setupTestVault(t)
+	if _, err := s.SaveEntry(0, &store.EntryFull{Type: "Login", Title: "GitHub"}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	stubSecrets(t, map[string]string{"Master Password: ": testPassword, "PIN: ": "000000"})
+
+	if _, err := runCLI(t, cfg, "get", "GitHub"); err == nil {
+		t.Fatalf("expected wrong PIN to be rejected")
+	}
+}
+
+func TestEitherSecondFactorUnlocks(t *testing.T) {
+	cfg, s := setupTestVault(t)
+	pinCfg, err := s.ReadPinConfig()
+	if err != nil {
+		t.Fatalf("ReadPinConfig: %v", err)
+	}
+	pinCfg.Mode, pinCfg.TotpSecret = "totp", "JBSWY3DPEHPK3PXP"
+	if err := s.WritePinConfig(pinCfg); err != nil {
+		t.Fatalf("WritePinConfig: %v", err)
+	}
+
+	// The PIN stands in for the authenticator, which is asked for first.
+	stubSecrets(t, map[string]string{"Master Password: ": testPassword, "Authenticator Code: ": testPin})
+	if _, err := runCLI(t, cfg, "ls"); err != nil {
+		t.Fatalf("expected the PIN to unlock: %v", err)
+	}
+
+	if err := s.WritePinConfig(&store.PinConfig{Mode: "off"}); err != nil {
+		t.Fatalf("WritePinConfig: %v", err)
+	}
+	stubSecrets(t, map[string]string{"Master Password: ": testPassword})
+	if _, err := runCLI(t, cfg, "ls"); err != nil {
+		t.Fatalf("expected the password alone to unlock: %v", err)
+	}
+}
+
+func TestFailedUnlocksBackOff(t *testing.T) {
+	cfg, _ := setupTestVault(t)
+	cfg.MaxFailedUnlocks = 10
+	stubSecrets(t, map[string]string{"Master Password: ": "wrong", "PIN: ": testPin})
+
+	for i := 0; i < 4; i++ {
+		if _, err := runCLI(t, cfg, "ls"); err == nil || !strings.Contains(err.Error(), "wrong password") {
+			t.Fatalf("attempt %d: expected wrong password, got %v", i+1, err)
+		}
+	}
+	// Even the right password has to wait now, also after a restart.
+	stubSecrets(t, map[string]string{"Master Password: ": testPassword, "PIN: ": testPin})
+	if _, err := runCLI(t, cfg, "ls"); err == nil || !strings.Contains(err.Error(), "try again in") {
+		t.Fatalf("expected the attempt to be refused, got %v", err)
+	}
+}
+
+func TestFailedPinsWipeVault(t *testing.T) {
+	cfg, _ := setupTestVault(t)
+	cfg.MaxFailedUnlocks = 2
+	cfg.WipeAfterMaxFailures = true
+	stubSecrets(t, map[string]string{"Master Password: ": testPassword, "PIN: ": "000000"})
+
+	if _, err := runCLI(t, cfg, "ls"); err == nil || !strings.Contains(err.Error(), "attempts left: 1") {
+		t.Fatalf("expected the remaining attempts, got %v", err)
+	}
+	if _, err := runCLI(t, cfg, "ls"); err == nil || !strings.Contains(err.Error(), "erased") {
+		t.Fatalf("expected the vault to be erased, got %v", err)
+	}
+	if store.DBExists(filepath.Join(cfg.DataDir, "passbook.db")) {
+		t.Fatalf("expected the database to be removed")
+	}
+}
+
+func TestOpenErrorsAreNotFailedUnlocks(t *testing.T) {
+	cfg, s := setupTestVault(t)
+	s.Close()
+	cfg.MaxFailedUnlocks = 1
+	cfg.WipeAfterMaxFailures = true
+	dbPath := filepath.Join(cfg.DataDir, "passbook.db")
+	if err := os.WriteFile(dbPath+".kdf", []byte("{not json"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	stubSecrets(t, map[string]string{"Master Password: ": testPassword, "PIN: ": testPin})
+
+	for i := 0; i < 3; i++ {
+		if _, err := runCLI(t, cfg, "ls"); err == nil || strings.Contains(err.Error(), "wrong password") {
+			t.Fatalf("attempt %d: expected the damaged header to be reported, got %v", i+1, err)
+		}
+	}
+	if !store.DBExists(dbPath) {
+		t.Fatalf("expected the vault to be kept")
+	}
+}
+
+func TestGetUnknownField(t *testing.T) {
+	cfg, _ := setupTestVault(t)
+	if _, err := runCLI(t, cfg, "get", "GitHub", "--field", "nope"); err == nil {
+		t.Fatalf("expected unknown field error")
+	}
+}
+
+func TestLs(t *testing.T) {
+	cfg, s := setupTestVault(t)
+	folderID, err := s.CreateFolder(0, "Work")
+	if err != nil {
+		t.Fatalf("CreateFolder: %v", err)
+	}
+	if _, err := s.SaveEntry(folderID, &store.EntryFull{Type: "Login", Title: "GitHub"}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	if _, err := s.SaveEntry(0, &store.EntryFull{Type: "Note", Title: "Root Note"}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	stubSecrets(t, map[string]string{"Master Password: ": testPassword, "PIN: ": testPin})
+
+	out, err := runCLI(t, cfg, "ls")
+	if err != nil {
+		t.Fatalf("ls: %v", err)
+	}
+	if out != "Work/\nRoot Note\n" {
+		t.Fatalf("unexpected root listing: %q", out)
+	}
+
+	out, err = runCLI(t, cfg, "ls", "Work")
+	if err != nil {
+		t.Fatalf("ls Work: %v", err)
+	}
+	if strings.TrimSpace(out) != "GitHub" {
+		t.Fatalf("unexpected folder listing: %q", out)
+	}
+}
+
+func TestNestedFolderPaths(t *testing.T) {
+	cfg, s := setupTestVault(t)
+	prodID, err := s.CreateFolderPath("Work/AWS/Prod")
+	if err != nil {
+		t.Fatalf("CreateFolderPath: %v", err)
+	}
+	if _, err := s.SaveEntry(prodID, &store.EntryFull{Type: "Login", Title: "Console", Username: "root"}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	stubSecrets(t, map[string]string{"Master Password: ": testPassword, "PIN: ": testPin})
+
+	out, err := runCLI(t, cfg, "ls", "Work/AWS")
+	if err != nil {
+		t.Fatalf("ls Work/AWS: %v", err)
+	}
+	if out != "Prod/\n" {
+		t.Fatalf("unexpected listing: %q", out)
+	}
+
+	out, err = runCLI(t, cfg, "get", "Work/AWS/Prod/Console", "--field", "username")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if strings.TrimSpace(out) != "root" {
+		t.Fatalf("unexpected username: %q", out)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	cfg, s := setupTestVault(t)
+	folderID, err := s.CreateFolder(0, "Work")
+	if err != nil {
+		t.Fatalf("CreateFolder: %v", err)
+	}
+	if _, err := s.SaveEntry(folderID, &store.EntryFull{
+		Type: "Login", Title: "GitHub", Username: "octocat",
+		URIs: []store.EntryURI{{URI: "https://github.com"}},
+	}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	if _, err := s.SaveEntry(0, &store.EntryFull{
+		Type: "Login", Title: "Gist",
+		URIs: []store.EntryURI{{URI: "https://gist.github.com", Match: store.MatchHost}},
+	}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	stubSecrets(t, map[string]string{"Master Password: ": testPassword, "PIN: ": testPin})
+
+	out, err := runCLI(t, cfg, "match", "https://github.com/login")
+	if err != nil {
+		t.Fatalf("match: %v", err)
+	}
+	if out != "Work/GitHub\n" {
+		t.Fatalf("unexpected matches: %q", out)
+	}
+
+	out, err = runCLI(t, cfg, "match", "--json", "https://gist.github.com/x")
+	if err != nil {
+		t.Fatalf("match --json: %v", err)
+	}
+	if !strings.Contains(out, `"path": "Gist"`) || !strings.Contains(out, `"username": "octocat"`) {
+		t.Fatalf("unexpected JSON matches: %s", out)
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	if _, err := runCLI(t, config.AppConfig{}, "frobnicate"); err == nil {
+		t.Fatalf("expected unknown command error")
+	}
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"passbook/internal/store"
+
+	"github.com/pquerna/otp/totp"
+)
+
+const getUsage = "get <folder>/<title> [--field name] [--json]"
+
+// entryFields maps the names accepted by --field to entry values.
+var entryFields = map[string]func(e *store.EntryFull) (string, error){
+	"title":       func(e *store.EntryFull) (string, error) { return e.Title, nil },
+	"type":        func(e *store.EntryFull) (string, error) { return e.Type, nil },
+	"username":    func(e *store.EntryFull) (string, error) { return e.Username, nil },
+	"password":    func(e *store.EntryFull) (string, error) { return e.Password, nil },
+	"link":        func(e *store.EntryFull) (string, error) { return e.Link(), nil },
+	"totp_secret": func(e *store.EntryFull) (string, error) { return e.TotpSecret, nil },
+	"card_number": func(e *store.EntryFull) (string, error) { return e.CardNumber, nil },
+	"expiry":      func(e *store.EntryFull) (string, error) { return e.Expiry, nil },
+	"cvv":         func(e *store.EntryFull) (string, error) { return e.CVV, nil },
+	"notes":       func(e *store.EntryFull) (string, error) { return e.CustomText, nil },
+	"totp":        currentTOTP,
+}
+
+// fieldOrder is the display order for `get` without --field.
+var fieldOrder = []string{
+	"title", "type", "username", "password", "link", "totp",
+	"card_number", "expiry", "cvv", "notes",
+}
+
+func currentTOTP(e *store.EntryFull) (string, error) {
+	secret := strings.ReplaceAll(e.TotpSecret, " ", "")
+	if secret == "" {
+		return "", nil
+	}
+	code, err := totp.GenerateCode(secret, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("generating TOTP code: %w", err)
+	}
+	return code, nil
+}
+
+func fieldNames() string {
+	names := make([]string, 0, len(entryFields))
+	for name := range entryFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func runGet(c *session, args []string) error {
+	fs := newFlagSet(c, getUsage)
+	field := fs.String("field", "", "print a single field ("+fieldNames()+")")
+	asJSON := fs.Bool("json", false, "print the entry as a JSON object")
+	pos, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one entry path")
+	}
+
+	var getter func(e *store.EntryFull) (string, error)
+	if *field != "" {
+		var ok bool
+		getter, ok = entryFields[*field]
+		if !ok {
+			return fmt.Errorf("unknown field %q (valid: %s)", *field, fieldNames())
+		}
+	}
+
+	s, err := c.unlock()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	meta, err := resolveEntry(s, pos[0])
+	if err != nil {
+		return err
+	}
+	e, err := s.LoadEntry(meta.ID)
+	if err != nil {
+		return fmt.Errorf("loading entry: %w", err)
+	}
+
+	if getter != nil {
+		val, err := getter(e)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(c.stdout, val)
+		return nil
+	}
+
+	values := make(map[string]string)
+	for _, name := range fieldOrder {
+		val, err := entryFields[name](e)
+		if err != nil {
+			return err
+		}
+		if val != "" {
+			values[name] = val
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(values)
+	}
+
+	for _, name := range fieldOrder {
+		if val, ok := values[name]; ok {
+			fmt.Fprintf(c.stdout, "%s: %s\n", name, val)
+		}
+	}
+	return nil
+}
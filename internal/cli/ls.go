@@ -0,0 +1,54 @@
+package cli
+
+import "fmt"
+
+const lsUsage = "ls [-l] [folder]"
+
+func runLs(c *session, args []string) error {
+	fs := newFlagSet(c, lsUsage)
+	long := fs.Bool("l", false, "show entry types")
+	pos, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) > 1 {
+		fs.Usage()
+		return fmt.Errorf("expected at most one folder")
+	}
+
+	s, err := c.unlock()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	var folderName string
+	if len(pos) == 1 {
+		folderName = pos[0]
+	}
+	folderID, err := resolveFolder(s, folderName)
+	if err != nil {
+		return err
+	}
+
+	folders, err := s.ListSubfolders(folderID)
+	if err != nil {
+		return fmt.Errorf("listing folders: %w", err)
+	}
+	for _, f := range folders {
+		fmt.Fprintf(c.stdout, "%s/\n", f.Name)
+	}
+
+	entries, err := s.ListEntries(folderID)
+	if err != nil {
+		return fmt.Errorf("listing entries: %w", err)
+	}
+	for _, e := range entries {
+		if *long {
+			fmt.Fprintf(c.stdout, "%-5s %s\n", e.EntryType, e.Title)
+		} else {
+			fmt.Fprintln(c.stdout, e.Title)
+		}
+	}
+	return nil
+}
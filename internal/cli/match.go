@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const matchUsage = "match <url> [--json]"
+
+// matchResult is one login printed by `match --json`.
+type matchResult struct {
+	Path     string `json:"path"`
+	Username string `json:"username,omitempty"`
+}
+
+// runMatch lists the logins whose URIs match a URL under their match
+// rules, as "<folder>/<title>" paths that get and edit accept.
+func runMatch(c *session, args []string) error {
+	fs := newFlagSet(c, matchUsage)
+	asJSON := fs.Bool("json", false, "print the matches as a JSON array with usernames")
+	pos, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one URL")
+	}
+
+	s, err := c.unlock()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	matches, err := s.MatchLogins(pos[0])
+	if err != nil {
+		return fmt.Errorf("matching logins: %w", err)
+	}
+	folders, err := s.ListFolders()
+	if err != nil {
+		return fmt.Errorf("listing folders: %w", err)
+	}
+	folderPaths := make(map[int64]string, len(folders))
+	for _, f := range folders {
+		folderPaths[f.ID] = f.Path
+	}
+
+	results := make([]matchResult, 0, len(matches))
+	for _, m := range matches {
+		path := m.Title
+		if folder, ok := folderPaths[m.FolderID]; ok {
+			path = folder + "/" + m.Title
+		}
+		r := matchResult{Path: path}
+		if *asJSON {
+			e, err := s.LoadEntry(m.ID)
+			if err != nil {
+				return fmt.Errorf("loading %q: %w", m.Title, err)
+			}
+			r.Username = e.Username
+		}
+		results = append(results, r)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+	for _, r := range results {
+		fmt.Fprintln(c.stdout, r.Path)
+	}
+	return nil
+}
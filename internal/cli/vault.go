@@ -0,0 +1,269 @@
+package cli
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"passbook/internal/config"
+	"passbook/internal/crypto"
+	"passbook/internal/store"
+	"passbook/internal/utils"
+)
+
+const (
+	upgradeKDFUsage  = "vault upgrade-kdf [--memory MiB] [--time n] [--threads n] [--page-size bytes] [--kdf-iter n]"
+	keyfileUsage     = "vault keyfile generate|set <path> | vault keyfile clear"
+	recoveryKitUsage = "vault recovery-kit [--out file]"
+	recoverUsage     = "vault recover"
+	backupCodesUsage = "vault backup-codes"
+
+	// vaultUsage lists every form, each on a line of its own in usage().
+	vaultUsage = upgradeKDFUsage + "\n  passbook " + keyfileUsage +
+		"\n  passbook " + recoveryKitUsage + "\n  passbook " + recoverUsage +
+		"\n  passbook " + backupCodesUsage
+)
+
+func runVault(c *session, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "upgrade-kdf":
+			return runUpgradeKDF(c, args[1:])
+		case "keyfile":
+			return runKeyfile(c, args[1:])
+		case "recovery-kit":
+			return runRecoveryKit(c, args[1:])
+		case "recover":
+			return runRecover(c, args[1:])
+		case "backup-codes":
+			return runBackupCodes(c, args[1:])
+		}
+	}
+	return fmt.Errorf("usage: passbook %s", vaultUsage)
+}
+
+func runUpgradeKDF(c *session, args []string) error {
+	p := c.cfg.CipherParams()
+	fs := newFlagSet(c, upgradeKDFUsage)
+	memory := fs.Uint("memory", uint(p.KDF.Memory/1024), "Argon2id memory in MiB")
+	passes := fs.Uint("time", uint(p.KDF.Time), "Argon2id passes")
+	threads := fs.Uint("threads", uint(p.KDF.Threads), "Argon2id threads")
+	fs.IntVar(&p.PageSize, "page-size", p.PageSize, "SQLCipher page size in bytes")
+	fs.IntVar(&p.KDFIter, "kdf-iter", p.KDFIter, "SQLCipher PBKDF2 iterations")
+	pos, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) > 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected argument %q", pos[0])
+	}
+	// Validate has the real limits; these only keep the conversions from
+	// wrapping around.
+	if *memory > math.MaxUint32/1024 || *passes > math.MaxUint32 || *threads > math.MaxUint8 {
+		return fmt.Errorf("kdf settings out of range")
+	}
+	p.KDF.Memory, p.KDF.Time, p.KDF.Threads = uint32(uint64(*memory)*1024), uint32(*passes), uint8(*threads)
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	s, password, err := c.unlockWithPassword()
+	if err != nil {
+		return err
+	}
+	before, upgraded := s.CipherParams()
+	if err := s.UpgradeKDF(password, p); err != nil {
+		return err
+	}
+
+	if upgraded {
+		fmt.Fprintf(c.stdout, "Was: %s\n", describeCipher(before))
+	} else {
+		fmt.Fprintln(c.stdout, "Was: SQLCipher key derivation only")
+	}
+	fmt.Fprintf(c.stdout, "Now: %s\n", describeCipher(p))
+	return nil
+}
+
+func describeCipher(p store.CipherParams) string {
+	return fmt.Sprintf("Argon2id %d MiB, %d passes, %d threads; page size %d, kdf_iter %d",
+		p.KDF.Memory/1024, p.KDF.Time, p.KDF.Threads, p.PageSize, p.KDFIter)
+}
+
+// runKeyfile writes a new keyfile, or re-keys the vault to need a keyfile
+// or no longer need one. The keyfile in use is remembered in the config.
+func runKeyfile(c *session, args []string) error {
+	var sub, path string
+	switch {
+	case len(args) == 2 && (args[0] == "generate" || args[0] == "set"):
+		sub, path = args[0], args[1]
+	case len(args) == 1 && args[0] == "clear":
+		sub = args[0]
+	default:
+		return fmt.Errorf("usage: passbook %s", keyfileUsage)
+	}
+	if path != "" && !strings.HasPrefix(path, "~/") {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		path = abs
+	}
+
+	if sub == "generate" {
+		if err := crypto.GenerateKeyfile(config.ExpandPath(path)); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "Keyfile written to %s\n", path)
+		fmt.Fprintf(c.stdout, "Run \"passbook vault keyfile set %s\" to require it, and keep a copy apart from the vault.\n", path)
+		return nil
+	}
+
+	var keyfile []byte
+	if sub == "set" {
+		var err error
+		if keyfile, err = crypto.ReadKeyfile(config.ExpandPath(path)); err != nil {
+			return err
+		}
+	}
+
+	s, password, err := c.unlockWithPassword()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if err := s.SetKeyfile(password, keyfile); err != nil {
+		return err
+	}
+
+	c.cfg.Keyfile = path
+	if err := config.Save(c.cfg); err != nil {
+		return fmt.Errorf("the vault was re-keyed, but saving the config failed: %w", err)
+	}
+	if keyfile == nil {
+		fmt.Fprintln(c.stdout, "The vault no longer needs a keyfile.")
+	} else {
+		fmt.Fprintf(c.stdout, "The vault now needs %s to open.\n", path)
+	}
+	return nil
+}
+
+// runRecoveryKit makes a new recovery key and prints the recovery sheet,
+// or writes it to --out.
+func runRecoveryKit(c *session, args []string) error {
+	fs := newFlagSet(c, recoveryKitUsage)
+	out := fs.String("out", "", "write the recovery sheet to this file instead of stdout")
+	pos, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) > 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected argument %q", pos[0])
+	}
+
+	s, password, err := c.unlockWithPassword()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	key, err := s.SetupRecovery(password)
+	if err != nil {
+		return err
+	}
+
+	sheet := utils.RecoverySheet(key, time.Now())
+	if *out == "" {
+		fmt.Fprint(c.stdout, sheet)
+		return nil
+	}
+	f, err := os.OpenFile(config.ExpandPath(*out), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("the recovery key was made, but writing the sheet failed: %w; run this again", err)
+	}
+	_, err = f.WriteString(sheet)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("the recovery key was made, but writing the sheet failed: %w; run this again", err)
+	}
+	fmt.Fprintf(c.stdout, "Recovery sheet written to %s; print it, then delete the file.\n", *out)
+	return nil
+}
+
+// runRecover resets the master password with the recovery key. The
+// keyfile, PIN and authenticator app are dropped; the app asks for a new
+// second factor at the next login.
+func runRecover(c *session, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: passbook %s", recoverUsage)
+	}
+	dbPath := c.dbPath()
+	if !store.DBExists(dbPath) {
+		return fmt.Errorf("no vault found at %s", dbPath)
+	}
+
+	key, err := readSecret("Recovery Key: ")
+	if err != nil {
+		return fmt.Errorf("reading recovery key: %w", err)
+	}
+	password, err := ReadNewPassword("New Master Password: ", "Confirm Master Password: ")
+	if err != nil {
+		return err
+	}
+	if _, level, _ := utils.PasswordStrength(password); level < utils.StrengthGood {
+		return fmt.Errorf("master password is too weak")
+	}
+	if err := store.Recover(dbPath, key, password); err != nil {
+		return err
+	}
+
+	_ = store.LoadAttempts(dbPath, store.AttemptPassword).Reset()
+	_ = store.LoadAttempts(dbPath, store.AttemptPIN).Reset()
+	if c.cfg.Keyfile != "" {
+		c.cfg.Keyfile = ""
+		if err := config.Save(c.cfg); err != nil {
+			return fmt.Errorf("the vault was reset, but saving the config failed: %w", err)
+		}
+	}
+	fmt.Fprintln(c.stdout, "The master password was reset and the vault no longer needs a keyfile or PIN.")
+	fmt.Fprintln(c.stdout, "Open PassBook to set up a PIN or authenticator app again.")
+	return nil
+}
+
+// runBackupCodes replaces the authenticator backup codes and prints the
+// new ones.
+func runBackupCodes(c *session, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: passbook %s", backupCodesUsage)
+	}
+	s, err := c.unlock()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	pinCfg, err := s.ReadPinConfig()
+	if err != nil {
+		return fmt.Errorf("reading 2FA config: %w", err)
+	}
+	if !pinCfg.HasTOTP() {
+		return fmt.Errorf("backup codes are only for the authenticator app, and this vault has none set up")
+	}
+	codes, err := crypto.GenerateBackupCodes(crypto.BackupCodeCount)
+	if err != nil {
+		return err
+	}
+	if err := s.SetBackupCodes(codes); err != nil {
+		return err
+	}
+	for _, code := range codes {
+		fmt.Fprintln(c.stdout, code)
+	}
+	return nil
+}
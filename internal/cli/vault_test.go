@@ -0,0 +1,199 @@
+package cli
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"passbook/internal/config"
+	"passbook/internal/store"
+)
+
+func TestVaultUpgradeKDF(t *testing.T) {
+	cfg, s := setupTestVault(t)
+	if _, err := s.SaveEntry(0, &store.EntryFull{Type: "Login", Title: "GitHub", Password: "s3cret"}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	s.Close()
+	stubSecrets(t, map[string]string{"Master Password: ": testPassword, "PIN: ": testPin})
+
+	out, err := runCLI(t, cfg, "vault", "upgrade-kdf",
+		"--memory", "1", "--time", "1", "--threads", "1", "--page-size", "8192", "--kdf-iter", "1000")
+	if err != nil {
+		t.Fatalf("upgrade-kdf: %v", err)
+	}
+	if !strings.Contains(out, "Now: Argon2id 1 MiB, 1 passes, 1 threads; page size 8192, kdf_iter 1000") {
+		t.Fatalf("unexpected output %q", out)
+	}
+
+	s, err = store.Open(filepath.Join(cfg.DataDir, "passbook.db"), testPassword)
+	if err != nil {
+		t.Fatalf("Open after upgrade: %v", err)
+	}
+	defer s.Close()
+	if p, _ := s.CipherParams(); p.PageSize != 8192 || p.KDFIter != 1000 || p.KDF.Memory != 1024 {
+		t.Fatalf("unexpected cipher params %+v", p)
+	}
+	if out, err := runCLI(t, cfg, "get", "GitHub", "--field", "password"); err != nil || out != "s3cret\n" {
+		t.Fatalf("expected the entry to survive, got %q, %v", out, err)
+	}
+}
+
+func TestVaultUpgradeKDFRejectsBadParams(t *testing.T) {
+	cfg, _ := setupTestVault(t)
+	stubSecrets(t, map[string]string{"Master Password: ": testPassword, "PIN: ": testPin})
+
+	if _, err := runCLI(t, cfg, "vault", "upgrade-kdf", "--page-size", "1000"); err == nil {
+		t.Fatalf("expected a bad page size to be rejected")
+	}
+	for _, memory := range []string{"4194304", "4097", "18446744073709551615"} {
+		if _, err := runCLI(t, cfg, "vault", "upgrade-kdf", "--memory", memory); err == nil {
+			t.Fatalf("expected --memory %s to be rejected", memory)
+		}
+	}
+	if _, err := runCLI(t, cfg, "vault", "upgrade-kdf", "--time", "65"); err == nil {
+		t.Fatalf("expected --time 65 to be rejected")
+	}
+	if _, err := runCLI(t, cfg, "vault", "rekey"); err == nil {
+		t.Fatalf("expected an unknown vault command to be rejected")
+	}
+}
+
+func TestVaultKeyfile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cfg, s := setupTestVault(t)
+	if _, err := s.SaveEntry(0, &store.EntryFull{Type: "Login", Title: "GitHub", Password: "s3cret"}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	s.Close()
+	stubSecrets(t, map[string]string{"Master Password: ": testPassword, "PIN: ": testPin})
+
+	keyfile := filepath.Join(t.TempDir(), "vault.key")
+	if _, err := runCLI(t, cfg, "vault", "keyfile", "generate", keyfile); err != nil {
+		t.Fatalf("keyfile generate: %v", err)
+	}
+	if _, err := runCLI(t, cfg, "vault", "keyfile", "set", keyfile); err != nil {
+		t.Fatalf("keyfile set: %v", err)
+	}
+	if saved := config.LoadOrInit(); saved.Keyfile != keyfile {
+		t.Fatalf("expected the keyfile to be saved in the config, got %q", saved.Keyfile)
+	}
+
+	if _, err := runCLI(t, cfg, "get", "GitHub", "--field", "password"); !errors.Is(err, store.ErrKeyfileRequired) {
+		t.Fatalf("expected the keyfile to be required, got %v", err)
+	}
+	cfg.Keyfile = keyfile
+	if out, err := runCLI(t, cfg, "get", "GitHub", "--field", "password"); err != nil || out != "s3cret\n" {
+		t.Fatalf("get with keyfile: %q, %v", out, err)
+	}
+
+	if _, err := runCLI(t, cfg, "vault", "keyfile", "clear"); err != nil {
+		t.Fatalf("keyfile clear: %v", err)
+	}
+	cfg.Keyfile = ""
+	if _, err := runCLI(t, cfg, "get", "GitHub"); err != nil {
+		t.Fatalf("get without keyfile: %v", err)
+	}
+}
+
+func TestUnlockWithPINInKey(t *testing.T) {
+	cfg, s := setupTestVault(t)
+	if err := s.KeyWithPIN(testPassword, testPin); err != nil {
+		t.Fatalf("KeyWithPIN: %v", err)
+	}
+	s.Close()
+
+	prompts := 0
+	stubSecrets(t, map[string]string{"Master Password: ": testPassword, "PIN: ": testPin})
+	prev := readSecret
+	readSecret = func(prompt string) (string, error) {
+		if prompt == "PIN: " {
+			prompts++
+		}
+		return prev(prompt)
+	}
+	if _, err := runCLI(t, cfg, "ls"); err != nil {
+		t.Fatalf("ls: %v", err)
+	}
+	if prompts != 1 {
+		t.Fatalf("expected the PIN to be asked for once, got %d", prompts)
+	}
+
+	stubSecrets(t, map[string]string{"Master Password: ": testPassword, "PIN: ": "000000"})
+	if _, err := runCLI(t, cfg, "ls"); err == nil || !strings.Contains(err.Error(), "wrong password or PIN") {
+		t.Fatalf("expected a wrong PIN to fail, got %v", err)
+	}
+	if a := store.LoadAttempts(filepath.Join(cfg.DataDir, "passbook.db"), store.AttemptPIN); a.Failures != 1 {
+		t.Fatalf("expected one failed PIN, got %d", a.Failures)
+	}
+}
+
+func TestVaultRecoveryKitAndRecover(t *testing.T) {
+	cfg, s := setupTestVault(t)
+	if _, err := s.SaveEntry(0, &store.EntryFull{Type: "Login", Title: "GitHub", Password: "s3cret"}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	s.Close()
+	stubSecrets(t, map[string]string{"Master Password: ": testPassword, "PIN: ": testPin})
+
+	out, err := runCLI(t, cfg, "vault", "recovery-kit")
+	if err != nil {
+		t.Fatalf("recovery-kit: %v", err)
+	}
+	var key string
+	for _, line := range strings.Split(out, "\n") {
+		if rest, ok := strings.CutPrefix(line, "Recovery key:"); ok {
+			key = strings.TrimSpace(rest)
+		}
+	}
+	if key == "" {
+		t.Fatalf("expected a recovery key on the sheet, got %q", out)
+	}
+
+	const newPassword = "Correct-Horse-Battery-9"
+	stubSecrets(t, map[string]string{
+		"Recovery Key: ":            "AAAA-AAAA-AAAA-AAAA-AAAA-AAAA-AAAA-AAAA",
+		"New Master Password: ":     newPassword,
+		"Confirm Master Password: ": newPassword,
+	})
+	if _, err := runCLI(t, cfg, "vault", "recover"); !errors.Is(err, store.ErrWrongRecoveryKey) {
+		t.Fatalf("expected a wrong recovery key to fail, got %v", err)
+	}
+	stubSecrets(t, map[string]string{
+		"Recovery Key: ":            strings.ToLower(key),
+		"New Master Password: ":     newPassword,
+		"Confirm Master Password: ": newPassword,
+	})
+	if _, err := runCLI(t, cfg, "vault", "recover"); err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+
+	s, err = store.Open(filepath.Join(cfg.DataDir, "passbook.db"), newPassword)
+	if err != nil {
+		t.Fatalf("expected the new password to open the vault: %v", err)
+	}
+	defer s.Close()
+	if s.PinConfigExists() {
+		t.Fatalf("expected the PIN to be reset")
+	}
+}
+
+func TestBackupCodeUnlocks(t *testing.T) {
+	cfg, s := setupTestVault(t)
+	if err := s.WritePinConfig(&store.PinConfig{Mode: "totp", TotpSecret: "JBSWY3DPEHPK3PXP"}); err != nil {
+		t.Fatalf("WritePinConfig: %v", err)
+	}
+	if err := s.SetBackupCodes([]string{"12345-67890"}); err != nil {
+		t.Fatalf("SetBackupCodes: %v", err)
+	}
+	s.Close()
+
+	stubSecrets(t, map[string]string{"Master Password: ": testPassword, "Authenticator Code: ": "12345-67890"})
+	if _, err := runCLI(t, cfg, "ls"); err != nil {
+		t.Fatalf("expected the backup code to unlock: %v", err)
+	}
+	if _, err := runCLI(t, cfg, "ls"); err == nil {
+		t.Fatalf("expected a used backup code to be refused")
+	}
+}
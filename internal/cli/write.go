@@ -0,0 +1,325 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"passbook/internal/store"
+	"passbook/internal/utils"
+)
+
+const (
+	addUsage  = "add <folder>/<title> [--type login|card|note] [field flags] [--json]"
+	editUsage = "edit <folder>/<title> [--title new] [--folder name] [field flags] [--json]"
+	rmUsage   = "rm <folder>/<title>"
+)
+
+// stdin is where --json input is read from; tests replace it.
+var stdin io.Reader = os.Stdin
+
+// entryInput holds the values supplied for add/edit. A nil field means
+// "not provided", so edit only touches what the caller set. The JSON keys
+// match the names accepted by `get --field`.
+type entryInput struct {
+	Type       *string `json:"type"`
+	Folder     *string `json:"folder"`
+	Title      *string `json:"title"`
+	Username   *string `json:"username"`
+	Password   *string `json:"password"`
+	Link       *string `json:"link"`
+	TotpSecret *string `json:"totp_secret"`
+	CardNumber *string `json:"card_number"`
+	Expiry     *string `json:"expiry"`
+	CVV        *string `json:"cvv"`
+	Notes      *string `json:"notes"`
+}
+
+// fields returns the settable entry fields keyed by their flag/JSON name.
+func (in *entryInput) fields() map[string]**string {
+	return map[string]**string{
+		"type":        &in.Type,
+		"folder":      &in.Folder,
+		"title":       &in.Title,
+		"username":    &in.Username,
+		"password":    &in.Password,
+		"link":        &in.Link,
+		"totp_secret": &in.TotpSecret,
+		"card_number": &in.CardNumber,
+		"expiry":      &in.Expiry,
+		"cvv":         &in.CVV,
+		"notes":       &in.Notes,
+	}
+}
+
+// typeFields lists the type-specific fields each entry type accepts.
+var typeFields = map[string][]string{
+	"Login": {"username", "password", "link", "totp_secret"},
+	"Card":  {"card_number", "expiry", "cvv"},
+	"Note":  {},
+}
+
+// inputFlags registers one string flag per entry field and returns a
+// function that collects the flags that were set, layered over any JSON
+// read from stdin when --json is given.
+func inputFlags(fs *flag.FlagSet, skip ...string) func() (*entryInput, error) {
+	values := make(map[string]*string)
+	for name := range (&entryInput{}).fields() {
+		if contains(skip, name) {
+			continue
+		}
+		values[name] = fs.String(name, "", "set the "+strings.ReplaceAll(name, "_", " "))
+	}
+	fromJSON := fs.Bool("json", false, "read field values as a JSON object from stdin")
+
+	return func() (*entryInput, error) {
+		in := &entryInput{}
+		if *fromJSON {
+			dec := json.NewDecoder(stdin)
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(in); err != nil {
+				return nil, fmt.Errorf("parsing JSON from stdin: %w", err)
+			}
+		}
+		targets := in.fields()
+		fs.Visit(func(f *flag.Flag) {
+			if v, ok := values[f.Name]; ok {
+				*targets[f.Name] = v
+			}
+		})
+		return in, nil
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeType(t string) (string, error) {
+	for name := range typeFields {
+		if strings.EqualFold(name, strings.TrimSpace(t)) {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported entry type %q (valid: login, card, note)", t)
+}
+
+// apply copies the provided values onto e, rejecting fields that do not
+// belong to the entry's type.
+func (in *entryInput) apply(e *store.EntryFull) error {
+	allowed := typeFields[e.Type]
+	for name, ptr := range in.fields() {
+		if *ptr == nil {
+			continue
+		}
+		switch name {
+		case "type", "folder", "title", "notes":
+			continue
+		}
+		if !contains(allowed, name) {
+			return fmt.Errorf("field %q does not apply to %s entries", name, e.Type)
+		}
+	}
+
+	set := func(dst *string, src *string) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	set(&e.Username, in.Username)
+	set(&e.Password, in.Password)
+	if in.Link != nil {
+		e.SetLink(*in.Link)
+	}
+	set(&e.TotpSecret, in.TotpSecret)
+	set(&e.CardNumber, in.CardNumber)
+	set(&e.Expiry, in.Expiry)
+	set(&e.CVV, in.CVV)
+	set(&e.CustomText, in.Notes)
+
+	e.CardNumber = strings.TrimSpace(e.CardNumber)
+	e.Expiry = strings.TrimSpace(e.Expiry)
+	e.CVV = strings.TrimSpace(e.CVV)
+	return nil
+}
+
+// validateEntry applies the same rules as the TUI editor.
+func validateEntry(e *store.EntryFull) error {
+	if strings.TrimSpace(e.Title) == "" {
+		return fmt.Errorf("title is required")
+	}
+	if e.Type == "Card" {
+		return utils.ValidateCard(e.CardNumber, e.Expiry, e.CVV)
+	}
+	return nil
+}
+
+// splitPath splits "<folder path>/<title>" for new entries at the last
+// slash. Unlike resolveEntry it does not consult the store; the folder must
+// exist when saving.
+func splitPath(path string) (folder, title string) {
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return path[:i], path[i+1:]
+	}
+	return "", path
+}
+
+func runAdd(c *session, args []string) error {
+	fs := newFlagSet(c, addUsage)
+	collect := inputFlags(fs)
+	pos, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) > 1 {
+		fs.Usage()
+		return fmt.Errorf("expected at most one entry path")
+	}
+	in, err := collect()
+	if err != nil {
+		return err
+	}
+
+	var folder, title string
+	if len(pos) == 1 {
+		folder, title = splitPath(pos[0])
+	}
+	if in.Folder != nil {
+		folder = *in.Folder
+	}
+	if in.Title != nil {
+		title = *in.Title
+	}
+
+	entryType := "Login"
+	if in.Type != nil {
+		if entryType, err = normalizeType(*in.Type); err != nil {
+			return err
+		}
+	}
+
+	e := &store.EntryFull{Type: entryType, Title: strings.TrimSpace(title)}
+	if err := in.apply(e); err != nil {
+		return err
+	}
+	if err := validateEntry(e); err != nil {
+		return err
+	}
+
+	s, err := c.unlock()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	folderID, err := resolveFolder(s, folder)
+	if err != nil {
+		return err
+	}
+	if s.EntryExistsInFolder(folderID, e.Title) {
+		return fmt.Errorf("title already exists in this folder: %s", e.Title)
+	}
+	if _, err := s.SaveEntry(folderID, e); err != nil {
+		return fmt.Errorf("saving entry: %w", err)
+	}
+	fmt.Fprintf(c.stderr, "Added %s\n", e.Title)
+	return nil
+}
+
+func runEdit(c *session, args []string) error {
+	fs := newFlagSet(c, editUsage)
+	collect := inputFlags(fs, "type")
+	pos, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one entry path")
+	}
+	in, err := collect()
+	if err != nil {
+		return err
+	}
+	if in.Type != nil {
+		return fmt.Errorf("the entry type cannot be changed")
+	}
+
+	s, err := c.unlock()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	meta, err := resolveEntry(s, pos[0])
+	if err != nil {
+		return err
+	}
+	e, err := s.LoadEntry(meta.ID)
+	if err != nil {
+		return fmt.Errorf("loading entry: %w", err)
+	}
+
+	priorPassword := e.Password
+	if err := in.apply(e); err != nil {
+		return err
+	}
+	if in.Title != nil {
+		e.Title = strings.TrimSpace(*in.Title)
+	}
+	if err := validateEntry(e); err != nil {
+		return err
+	}
+	e.TrackPasswordChange(priorPassword)
+
+	folderID := e.FolderID
+	if in.Folder != nil {
+		if folderID, err = resolveFolder(s, *in.Folder); err != nil {
+			return err
+		}
+	}
+	if s.EntryExistsInFolderExcluding(folderID, e.Title, meta.ID) {
+		return fmt.Errorf("title already exists in this folder: %s", e.Title)
+	}
+	if err := s.UpdateEntryFull(meta.ID, folderID, e); err != nil {
+		return fmt.Errorf("saving entry: %w", err)
+	}
+	fmt.Fprintf(c.stderr, "Updated %s\n", e.Title)
+	return nil
+}
+
+func runRm(c *session, args []string) error {
+	fs := newFlagSet(c, rmUsage)
+	pos, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one entry path")
+	}
+
+	s, err := c.unlock()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	meta, err := resolveEntry(s, pos[0])
+	if err != nil {
+		return err
+	}
+	if err := s.DeleteEntry(meta.ID); err != nil {
+		return fmt.Errorf("deleting entry: %w", err)
+	}
+	fmt.Fprintf(c.stderr, "Removed %s\n", meta.Title)
+	return nil
+}
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"passbook/internal/store"
+)
+
+func loadByTitle(t *testing.T, s *store.Store, folderID int64, title string) *store.EntryFull {
+	t.Helper()
+	meta, err := findEntry(s, folderID, title)
+	if err != nil {
+		t.Fatalf("findEntry: %v", err)
+	}
+	if meta == nil {
+		t.Fatalf("entry %q not found", title)
+	}
+	e, err := s.LoadEntry(meta.ID)
+	if err != nil {
+		t.Fatalf("LoadEntry: %v", err)
+	}
+	return e
+}
+
+func stubStdin(t *testing.T, data string) {
+	t.Helper()
+	prev := stdin
+	stdin = strings.NewReader(data)
+	t.Cleanup(func() { stdin = prev })
+}
+
+func TestAddFromFlags(t *testing.T) {
+	cfg, s := setupTestVault(t)
+	folderID, err := s.CreateFolder(0, "Work")
+	if err != nil {
+		t.Fatalf("CreateFolder: %v", err)
+	}
+	stubSecrets(t, map[string]string{"Master Password: ": testPassword, "PIN: ": testPin})
+
+	if _, err := runCLI(t, cfg, "add", "Work/GitHub", "--username", "octocat", "--password", "s3cret"); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	e := loadByTitle(t, s, folderID, "GitHub")
+	if e.Type != "Login" || e.Username != "octocat" || e.Password != "s3cret" {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+
+	if _, err := runCLI(t, cfg, "add", "Work/GitHub"); err == nil {
+		t.Fatalf("expected duplicate title to be rejected")
+	}
+}
+
+func TestAddFromJSON(t *testing.T) {
+	cfg, s := setupTestVault(t)
+	stubSecrets(t, map[string]string{"Master Password: ": testPassword, "PIN: ": testPin})
+	stubStdin(t, `{"type":"card","title":"Visa","card_number":"4111111111111111","expiry":"12/30","cvv":"123"}`)
+
+	if _, err := runCLI(t, cfg, "add", "--json"); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	e := loadByTitle(t, s, 0, "Visa")
+	if e.Type != "Card" || e.CardNumber != "4111111111111111" || e.Expiry != "12/30" {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+}
+
+func TestAddRejectsInvalidCard(t *testing.T) {
+	cfg, _ := setupTestVault(t)
+	if _, err := runCLI(t, cfg, "add", "Visa", "--type", "card", "--expiry", "13/30"); err == nil {
+		t.Fatalf("expected invalid expiry to be rejected")
+	}
+	if _, err := runCLI(t, cfg, "add", "Memo", "--type", "note", "--password", "x"); err == nil {
+		t.Fatalf("expected login field on a note to be rejected")
+	}
+}
+
+func TestEditTracksPasswordHistory(t *testing.T) {
+	cfg, s := setupTestVault(t)
+	if _, err := s.SaveEntry(0, &store.EntryFull{Type: "Login", Title: "GitHub", Password: "old"}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	stubSecrets(t, map[string]string{"Master Password: ": testPassword, "PIN: ": testPin})
+
+	if _, err := runCLI(t, cfg, "edit", "GitHub", "--password", "new", "--title", "GitHub.com"); err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+	e := loadByTitle(t, s, 0, "GitHub.com")
+	if e.Password != "new" {
+		t.Fatalf("expected new password, got %q", e.Password)
+	}
+	if len(e.History) != 1 || e.History[0].Password != "old" {
+		t.Fatalf("expected old password in history, got %+v", e.History)
+	}
+}
+
+func TestEditLinkKeepsOtherURIs(t *testing.T) {
+	cfg, s := setupTestVault(t)
+	if _, err := s.SaveEntry(0, &store.EntryFull{Type: "Login", Title: "GitHub", URIs: []store.EntryURI{
+		{URI: "https://github.com", Match: store.MatchHost},
+		{URI: "https://gist.github.com"},
+	}}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	stubSecrets(t, map[string]string{"Master Password: ": testPassword, "PIN: ": testPin})
+
+	if _, err := runCLI(t, cfg, "edit", "GitHub", "--link", "https://github.com/login"); err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+	e := loadByTitle(t, s, 0, "GitHub")
+	want := []store.EntryURI{{URI: "https://github.com/login", Match: store.MatchHost}, {URI: "https://gist.github.com"}}
+	if len(e.URIs) != 2 || e.URIs[0] != want[0] || e.URIs[1] != want[1] {
+		t.Fatalf("unexpected URIs: %+v", e.URIs)
+	}
+}
+
+func TestRm(t *testing.T) {
+	cfg, s := setupTestVault(t)
+	if _, err := s.SaveEntry(0, &store.EntryFull{Type: "Note", Title: "Memo"}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	stubSecrets(t, map[string]string{"Master Password: ": testPassword, "PIN: ": testPin})
+
+	if _, err := runCLI(t, cfg, "rm", "Memo"); err != nil {
+		t.Fatalf("rm: %v", err)
+	}
+	if s.EntryExistsInFolder(0, "Memo") {
+		t.Fatalf("expected entry to be removed")
+	}
+}
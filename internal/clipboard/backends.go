@@ -0,0 +1,293 @@
+package clipboard
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+
+	"passbook/internal/crypto"
+
+	system "github.com/atotto/clipboard"
+)
+
+// Names lists the backends Detect knows, besides "auto".
+var Names = []string{"wl-copy", "xclip", "xsel", "osc52", "system"}
+
+// Detect returns the backend called name, or for "" or "auto" the first
+// one that works here: OSC 52 over SSH, wl-copy on Wayland, xclip or xsel
+// on X11, OSC 52 on other Unix systems with no display, and the system
+// clipboard on macOS and Windows. OSC 52 writes its escape sequences to
+// term.
+func Detect(name string, term io.Writer) (Backend, error) {
+	var b *command
+	switch name {
+	case "", "auto":
+		return detect(term), nil
+	case "wl-copy":
+		b = wlCopy
+	case "xclip":
+		b = xclip
+	case "xsel":
+		b = xsel
+	case "osc52":
+		return OSC52(term), nil
+	case "system":
+		return systemBackend{}, nil
+	default:
+		return nil, fmt.Errorf("unknown clipboard backend %q (use auto, %s)", name, strings.Join(Names, ", "))
+	}
+	if !b.available() {
+		return nil, fmt.Errorf("clipboard backend %s: %s not found", name, strings.Join(b.tools, " or "))
+	}
+	return b, nil
+}
+
+func detect(term io.Writer) Backend {
+	// A clipboard on the remote host would not reach the user, even
+	// with a forwarded display.
+	if os.Getenv("SSH_TTY") != "" {
+		return OSC52(term)
+	}
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		return systemBackend{}
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" && wlCopy.available() {
+		return wlCopy
+	}
+	if os.Getenv("DISPLAY") != "" {
+		for _, b := range []*command{xclip, xsel} {
+			if b.available() {
+				return b
+			}
+		}
+	}
+	return OSC52(term)
+}
+
+// ── Clipboard tools ─────────────────────────────────────────────────
+
+// command is a backend that runs a clipboard tool with the arguments its
+// functions return.
+type command struct {
+	name  string
+	tools []string
+	write func(sel Selection, sensitive bool) []string
+	read  func(sel Selection) []string
+	// clear is nil for tools that are cleared by writing nothing.
+	clear func(sel Selection) []string
+	// unmarked is set for X11 tools that serve a selection as a single
+	// type, so they cannot offer x-kde-passwordManagerHint next to the
+	// text and clipboard managers keep sensitive copies.
+	unmarked bool
+}
+
+var wlCopy = &command{
+	name:  "wl-copy",
+	tools: []string{"wl-copy", "wl-paste"},
+	write: func(sel Selection, sensitive bool) []string {
+		args := append([]string{"wl-copy"}, wlPrimary(sel)...)
+		if sensitive {
+			// Offers x-kde-passwordManagerHint, which clipboard
+			// managers take as a request not to keep the copy.
+			args = append(args, "--sensitive")
+		}
+		return args
+	},
+	read: func(sel Selection) []string {
+		return append([]string{"wl-paste", "--no-newline"}, wlPrimary(sel)...)
+	},
+	clear: func(sel Selection) []string {
+		return append([]string{"wl-copy", "--clear"}, wlPrimary(sel)...)
+	},
+}
+
+func wlPrimary(sel Selection) []string {
+	if sel == Primary {
+		return []string{"--primary"}
+	}
+	return nil
+}
+
+var xclip = &command{
+	name:  "xclip",
+	tools: []string{"xclip"},
+	write: func(sel Selection, _ bool) []string {
+		return []string{"xclip", "-in", "-selection", xSelection(sel)}
+	},
+	read: func(sel Selection) []string {
+		return []string{"xclip", "-out", "-selection", xSelection(sel)}
+	},
+	unmarked: true,
+}
+
+var xsel = &command{
+	name:  "xsel",
+	tools: []string{"xsel"},
+	write: func(sel Selection, _ bool) []string {
+		return []string{"xsel", "--input", "--" + xSelection(sel)}
+	},
+	read: func(sel Selection) []string {
+		return []string{"xsel", "--output", "--" + xSelection(sel)}
+	},
+	clear: func(sel Selection) []string {
+		return []string{"xsel", "--clear", "--" + xSelection(sel)}
+	},
+	unmarked: true,
+}
+
+// Unmarked reports whether b is an X11 tool that leaves sensitive copies
+// unmarked, so a clipboard manager running there keeps them in its
+// history.
+func Unmarked(b Backend) bool {
+	c, ok := b.(*command)
+	return ok && c.unmarked
+}
+
+func xSelection(sel Selection) string {
+	if sel == Primary {
+		return "primary"
+	}
+	return "clipboard"
+}
+
+func (c *command) Name() string { return c.name }
+
+func (c *command) available() bool {
+	for _, tool := range c.tools {
+		if _, err := exec.LookPath(tool); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *command) Write(sel Selection, text string, sensitive bool) error {
+	err := run(c.write(sel, sensitive), text)
+	if err != nil && sensitive {
+		// Versions of the tool that cannot mark copies refuse the flag.
+		err = run(c.write(sel, false), text)
+	}
+	return err
+}
+
+func (c *command) Read(sel Selection) (string, error) {
+	args := c.read(sel)
+	out, err := exec.Command(args[0], args[1:]...).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+func (c *command) Clear(sel Selection) error {
+	if c.clear == nil {
+		return c.Write(sel, "", false)
+	}
+	return run(c.clear(sel), "")
+}
+
+// run runs args with text as its input. Output is not captured: the
+// tools stay in the background to serve the selection, and would keep
+// a pipe open.
+func run(args []string, text string) error {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	return nil
+}
+
+// ── System clipboard ────────────────────────────────────────────────
+
+// systemBackend is the macOS or Windows clipboard, or on other systems
+// whichever tool github.com/atotto/clipboard finds. It has no PRIMARY
+// selection and cannot mark copies.
+type systemBackend struct{}
+
+func (systemBackend) Name() string { return "system" }
+
+func (systemBackend) Write(sel Selection, text string, _ bool) error {
+	if sel != Clipboard {
+		return ErrUnsupported
+	}
+	return system.WriteAll(text)
+}
+
+func (systemBackend) Read(sel Selection) (string, error) {
+	if sel != Clipboard {
+		return "", ErrUnsupported
+	}
+	return system.ReadAll()
+}
+
+func (b systemBackend) Clear(sel Selection) error {
+	return b.Write(sel, "", false)
+}
+
+// ── OSC 52 ──────────────────────────────────────────────────────────
+
+// osc52 sets the clipboard of the terminal itself with the OSC 52 escape
+// sequence, which also works over SSH. Terminals do not let it be read.
+type osc52 struct {
+	mu   sync.Mutex
+	term io.Writer
+	tmux bool
+}
+
+// OSC52 returns a backend writing OSC 52 sequences to term. Inside tmux
+// they are wrapped for passthrough to the terminal tmux runs in.
+func OSC52(term io.Writer) Backend {
+	return &osc52{term: term, tmux: os.Getenv("TMUX") != ""}
+}
+
+func (*osc52) Name() string { return "osc52" }
+
+func (o *osc52) Write(sel Selection, text string, _ bool) error {
+	target := byte('c')
+	if sel == Primary {
+		target = 'p'
+	}
+	seq := []byte{0x1b, ']', '5', '2', ';', target, ';'}
+	seq = base64.StdEncoding.AppendEncode(seq, []byte(text))
+	seq = append(seq, '\a')
+	if o.tmux {
+		seq = tmuxPassthrough(seq)
+	}
+	defer crypto.WipeBytes(seq)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, err := o.term.Write(seq)
+	return err
+}
+
+func (*osc52) Read(Selection) (string, error) {
+	return "", ErrUnsupported
+}
+
+func (o *osc52) Clear(sel Selection) error {
+	return o.Write(sel, "", false)
+}
+
+// tmuxPassthrough wraps seq in a DCS sequence that tmux hands on to the
+// outer terminal as it is, with every ESC in it doubled. tmux 3.3 and
+// later only do so with allow-passthrough turned on.
+func tmuxPassthrough(seq []byte) []byte {
+	out := make([]byte, 0, len(seq)+16)
+	out = append(out, "\x1bPtmux;"...)
+	for _, c := range seq {
+		if c == 0x1b {
+			out = append(out, 0x1b)
+		}
+		out = append(out, c)
+	}
+	out = append(out, "\x1b\\"...)
+	crypto.WipeBytes(seq)
+	return out
+}
@@ -0,0 +1,166 @@
+// Package clipboard copies values to the system clipboard through one of
+// several backends, and takes sensitive copies off it again after a
+// while.
+package clipboard
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"passbook/internal/crypto"
+)
+
+// Selection is the clipboard to use. X11 and Wayland also have the
+// PRIMARY selection, which holds whatever was last selected.
+type Selection int
+
+const (
+	Clipboard Selection = iota
+	Primary
+)
+
+// ErrUnsupported is returned by backends that cannot do an operation,
+// such as reading the clipboard over OSC 52.
+var ErrUnsupported = errors.New("not supported by this clipboard backend")
+
+// Backend reads and writes a clipboard.
+type Backend interface {
+	Name() string
+
+	// Write puts text on sel. A sensitive copy is marked, where the
+	// backend can, so clipboard managers keep it out of their history.
+	Write(sel Selection, text string, sensitive bool) error
+
+	Read(sel Selection) (string, error)
+
+	Clear(sel Selection) error
+}
+
+// Manager copies through a backend. A sensitive copy is cleared after
+// the configured time, and whatever the clipboard held before is put
+// back.
+type Manager struct {
+	backend    Backend
+	clearAfter time.Duration
+
+	mu      sync.Mutex
+	pending *pending
+}
+
+// pending is a sensitive copy that has not been cleared yet.
+type pending struct {
+	value   *crypto.Secret
+	prev    *crypto.Secret
+	timer   *time.Timer
+	onClear func()
+}
+
+// New returns a Manager copying through b. Sensitive copies are cleared
+// after clearAfter, or never if it is 0.
+func New(b Backend, clearAfter time.Duration) *Manager {
+	return &Manager{backend: b, clearAfter: clearAfter}
+}
+
+// Backend returns the backend m copies through.
+func (m *Manager) Backend() Backend {
+	return m.backend
+}
+
+// ClearAfter returns how long a sensitive copy stays on the clipboard, or
+// 0 if it stays.
+func (m *Manager) ClearAfter() time.Duration {
+	return m.clearAfter
+}
+
+// Copy puts text that is not secret on the clipboard.
+func (m *Manager) Copy(text string) error {
+	return m.backend.Write(Clipboard, text, false)
+}
+
+// CopySensitive puts text on the clipboard marked as sensitive. After
+// the configured time, if the clipboard still holds text, what it held
+// before is restored, or it is cleared; the PRIMARY selection is
+// cleared too if it holds text by then. onClear, if not nil, is then
+// called from another goroutine, unless the copy was already gone.
+// Without a time set the copy stays.
+func (m *Manager) CopySensitive(text string, onClear func()) error {
+	if m.clearAfter <= 0 {
+		return m.backend.Write(Clipboard, text, true)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// A copy made while another is pending restores what was there
+	// before the first one.
+	var prev *crypto.Secret
+	if m.pending == nil {
+		if cur, err := m.backend.Read(Clipboard); err == nil {
+			prev = crypto.SecretString(cur)
+		}
+	}
+	if err := m.backend.Write(Clipboard, text, true); err != nil {
+		prev.Release()
+		return err
+	}
+	if old := m.pending; old != nil {
+		old.timer.Stop()
+		old.value.Release()
+		prev, old.prev = old.prev, nil
+	}
+
+	p := &pending{value: crypto.SecretString(text), prev: prev, onClear: onClear}
+	p.timer = time.AfterFunc(m.clearAfter, func() { m.expire(p) })
+	m.pending = p
+	return nil
+}
+
+// Flush clears a pending sensitive copy now, as when PassBook exits. Its
+// onClear is not called.
+func (m *Manager) Flush() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if p := m.pending; p != nil {
+		p.timer.Stop()
+		m.pending = nil
+		m.clear(p)
+	}
+}
+
+func (m *Manager) expire(p *pending) {
+	m.mu.Lock()
+	if m.pending != p {
+		m.mu.Unlock()
+		return
+	}
+	m.pending = nil
+	cleared := m.clear(p)
+	m.mu.Unlock()
+
+	if cleared && p.onClear != nil {
+		p.onClear()
+	}
+}
+
+// clear takes p off the clipboard, unless something else was copied
+// since, and reports whether it did. Backends that cannot read the
+// clipboard are cleared blindly.
+func (m *Manager) clear(p *pending) bool {
+	defer p.value.Release()
+	defer p.prev.Release()
+
+	cleared := false
+	cur, err := m.backend.Read(Clipboard)
+	if err != nil || p.value.Equal([]byte(cur)) {
+		if p.prev != nil {
+			err = m.backend.Write(Clipboard, p.prev.String(), false)
+		} else {
+			err = m.backend.Clear(Clipboard)
+		}
+		cleared = err == nil
+	}
+	if cur, err := m.backend.Read(Primary); err == nil && p.value.Equal([]byte(cur)) {
+		cleared = m.backend.Clear(Primary) == nil || cleared
+	}
+	return cleared
+}
@@ -0,0 +1,234 @@
+package clipboard
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBackend keeps both selections in memory.
+type fakeBackend struct {
+	mu     sync.Mutex
+	sel    [2]string
+	marked string // the last copy marked sensitive
+	noRead bool
+}
+
+func (f *fakeBackend) Name() string { return "fake" }
+
+func (f *fakeBackend) Write(sel Selection, text string, sensitive bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sel[sel] = text
+	if sensitive {
+		f.marked = text
+	}
+	return nil
+}
+
+func (f *fakeBackend) Read(sel Selection) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.noRead {
+		return "", ErrUnsupported
+	}
+	return f.sel[sel], nil
+}
+
+func (f *fakeBackend) Clear(sel Selection) error {
+	return f.Write(sel, "", false)
+}
+
+func (f *fakeBackend) get(sel Selection) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.sel[sel]
+}
+
+// waitCleared copies secret and waits for the manager to clear it.
+func waitCleared(t *testing.T, m *Manager, secret string) bool {
+	t.Helper()
+	done := make(chan struct{})
+	if err := m.CopySensitive(secret, func() { close(done) }); err != nil {
+		t.Fatalf("CopySensitive: %v", err)
+	}
+	select {
+	case <-done:
+		return true
+	case <-time.After(500 * time.Millisecond):
+		return false
+	}
+}
+
+func TestCopySensitiveRestoresPrevious(t *testing.T) {
+	b := &fakeBackend{}
+	b.sel[Clipboard] = "shopping list"
+	b.sel[Primary] = "hunter2"
+	m := New(b, 10*time.Millisecond)
+
+	if !waitCleared(t, m, "hunter2") {
+		t.Fatalf("expected the copy to be cleared")
+	}
+	if b.marked != "hunter2" {
+		t.Fatalf("expected the copy to be marked sensitive")
+	}
+	if got := b.get(Clipboard); got != "shopping list" {
+		t.Fatalf("expected the previous contents back, got %q", got)
+	}
+	if got := b.get(Primary); got != "" {
+		t.Fatalf("expected PRIMARY to be cleared, got %q", got)
+	}
+}
+
+func TestCopySensitiveKeepsNewerCopy(t *testing.T) {
+	b := &fakeBackend{}
+	m := New(b, 10*time.Millisecond)
+	done := make(chan struct{})
+	if err := m.CopySensitive("hunter2", func() { close(done) }); err != nil {
+		t.Fatalf("CopySensitive: %v", err)
+	}
+	_ = b.Write(Clipboard, "copied elsewhere", false)
+
+	select {
+	case <-done:
+		t.Fatalf("expected no clear once something else was copied")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if got := b.get(Clipboard); got != "copied elsewhere" {
+		t.Fatalf("expected the newer copy to stay, got %q", got)
+	}
+}
+
+func TestCopySensitiveTwiceRestoresFirstPrevious(t *testing.T) {
+	b := &fakeBackend{}
+	b.sel[Clipboard] = "shopping list"
+	m := New(b, time.Hour)
+	if err := m.CopySensitive("hunter2", nil); err != nil {
+		t.Fatalf("CopySensitive: %v", err)
+	}
+	if err := m.CopySensitive("123456", nil); err != nil {
+		t.Fatalf("CopySensitive: %v", err)
+	}
+	m.Flush()
+	if got := b.get(Clipboard); got != "shopping list" {
+		t.Fatalf("expected the contents from before both copies, got %q", got)
+	}
+	m.Flush()
+}
+
+func TestCopySensitiveBlindClear(t *testing.T) {
+	b := &fakeBackend{noRead: true}
+	b.sel[Clipboard] = "shopping list"
+	m := New(b, 10*time.Millisecond)
+
+	if !waitCleared(t, m, "hunter2") {
+		t.Fatalf("expected the copy to be cleared")
+	}
+	if got := b.get(Clipboard); got != "" {
+		t.Fatalf("expected an unreadable clipboard to be cleared, got %q", got)
+	}
+}
+
+func TestCopySensitiveWithoutTimeout(t *testing.T) {
+	b := &fakeBackend{}
+	m := New(b, 0)
+	if err := m.CopySensitive("hunter2", nil); err != nil {
+		t.Fatalf("CopySensitive: %v", err)
+	}
+	m.Flush()
+	if got := b.get(Clipboard); got != "hunter2" || b.marked != "hunter2" {
+		t.Fatalf("expected a marked copy that stays, got %q", got)
+	}
+}
+
+func TestOSC52(t *testing.T) {
+	t.Setenv("TMUX", "")
+	var term bytes.Buffer
+	b := OSC52(&term)
+	if err := b.Write(Clipboard, "hunter2", true); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := b.Clear(Primary); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+	if want := "\x1b]52;c;aHVudGVyMg==\a\x1b]52;p;\a"; term.String() != want {
+		t.Fatalf("expected %q, got %q", want, term.String())
+	}
+	if _, err := b.Read(Clipboard); err != ErrUnsupported {
+		t.Fatalf("expected OSC 52 to be write-only, got %v", err)
+	}
+}
+
+func TestOSC52InTmux(t *testing.T) {
+	t.Setenv("TMUX", "/tmp/tmux-1000/default,1234,0")
+	var term bytes.Buffer
+	if err := OSC52(&term).Write(Clipboard, "hunter2", true); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if want := "\x1bPtmux;\x1b\x1b]52;c;aHVudGVyMg==\a\x1b\\"; term.String() != want {
+		t.Fatalf("expected %q, got %q", want, term.String())
+	}
+}
+
+func TestDetect(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake tools are shell scripts")
+	}
+	bin := t.TempDir()
+	for _, tool := range []string{"wl-copy", "wl-paste", "xsel"} {
+		if err := os.WriteFile(filepath.Join(bin, tool), []byte("#!/bin/sh\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin)
+
+	if _, err := Detect("pbcopy", nil); err == nil {
+		t.Fatalf("expected an unknown backend to be refused")
+	}
+	if _, err := Detect("xclip", nil); err == nil {
+		t.Fatalf("expected a missing tool to be refused")
+	}
+	if b, err := Detect("xsel", nil); err != nil || b.Name() != "xsel" || !Unmarked(b) {
+		t.Fatalf("expected xsel, flagged as leaving copies unmarked, got %v", err)
+	}
+	if b, _ := Detect("wl-copy", nil); Unmarked(b) {
+		t.Fatalf("expected wl-copy to mark sensitive copies")
+	}
+	if b, _ := Detect("osc52", nil); b.Name() != "osc52" || Unmarked(b) {
+		t.Fatalf("expected osc52, got %s", b.Name())
+	}
+
+	if runtime.GOOS == "darwin" {
+		return
+	}
+	t.Setenv("SSH_TTY", "")
+	t.Setenv("WAYLAND_DISPLAY", "wayland-0")
+	t.Setenv("DISPLAY", ":0")
+	if b, _ := Detect("auto", nil); b.Name() != "wl-copy" {
+		t.Fatalf("expected wl-copy on Wayland, got %s", b.Name())
+	}
+	t.Setenv("WAYLAND_DISPLAY", "")
+	if b, _ := Detect("", nil); b.Name() != "xsel" {
+		t.Fatalf("expected xsel on X11 without xclip, got %s", b.Name())
+	}
+	t.Setenv("DISPLAY", "")
+	if b, _ := Detect("", nil); b.Name() != "osc52" {
+		t.Fatalf("expected OSC 52 without a display, got %s", b.Name())
+	}
+}
+
+func TestDetectOverSSH(t *testing.T) {
+	t.Setenv("SSH_TTY", "/dev/pts/3")
+	t.Setenv("WAYLAND_DISPLAY", "wayland-0")
+	t.Setenv("DISPLAY", "localhost:10.0")
+	if b, _ := Detect("auto", nil); b.Name() != "osc52" {
+		t.Fatalf("expected OSC 52 over SSH, got %s", b.Name())
+	}
+	if b, _ := Detect("system", nil); b.Name() != "system" {
+		t.Fatalf("expected a named backend to be kept over SSH, got %s", b.Name())
+	}
+}
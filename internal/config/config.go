@@ -2,13 +2,103 @@ package config
 
 import (
 	"encoding/json"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"passbook/internal/crypto"
+	"passbook/internal/store"
 )
 
 type AppConfig struct {
 	DataDir string `json:"data_dir"`
+
+	// LockAfterMinutes locks the vault after that many minutes without a
+	// key press or mouse event. 0 turns the idle lock off.
+	LockAfterMinutes int `json:"lock_after_minutes"`
+
+	// PinUnlock lets the PIN or authenticator code alone unlock a vault
+	// that was locked, instead of the master password. The master password
+	// then stays in memory while the vault is locked.
+	PinUnlock bool `json:"pin_unlock"`
+
+	// MaxFailedUnlocks is how many wrong master passwords, or wrong PINs or
+	// codes, are allowed in a row before unlocking is locked out for an
+	// hour after each further failure. 0 means no limit; failures are
+	// still slowed down.
+	MaxFailedUnlocks int `json:"max_failed_unlocks"`
+
+	// WipeAfterMaxFailures erases the vault instead of locking out once
+	// MaxFailedUnlocks is reached.
+	WipeAfterMaxFailures bool `json:"wipe_after_max_failures"`
+
+	// Keyfile is the path of the keyfile to unlock with, for vaults whose
+	// key needs one. The --keyfile flag overrides it.
+	Keyfile string `json:"keyfile"`
+
+	// ClipboardBackend is how copies reach the clipboard: "auto", or one
+	// of "wl-copy", "xclip", "xsel", "osc52" and "system". "auto" uses
+	// OSC 52 over SSH and where there is no local clipboard.
+	ClipboardBackend string `json:"clipboard_backend"`
+
+	// ClipboardClearSeconds is how long a copied password, code or other
+	// secret stays on the clipboard before the previous contents are put
+	// back. 0 leaves copies there.
+	ClipboardClearSeconds int `json:"clipboard_clear_seconds"`
+
+	// How new vaults, and vaults upgraded with "passbook vault
+	// upgrade-kdf", derive and encrypt with their key: the Argon2id memory
+	// in MiB, passes and threads, then SQLCipher's page size and PBKDF2
+	// rounds. A vault keeps the settings it was encrypted with.
+	KDFMemoryMiB   int `json:"kdf_memory_mib"`
+	KDFTime        int `json:"kdf_time"`
+	KDFThreads     int `json:"kdf_threads"`
+	CipherPageSize int `json:"cipher_page_size"`
+	KDFIter        int `json:"kdf_iter"`
+}
+
+// DefaultLockAfterMinutes is the idle timeout of a new config.
+const DefaultLockAfterMinutes = 5
+
+// DefaultClipboardClearSeconds is the clipboard timeout of a new config.
+const DefaultClipboardClearSeconds = 30
+
+// CipherParams returns the vault encryption settings, with the defaults
+// for any left at 0.
+func (c AppConfig) CipherParams() store.CipherParams {
+	p := store.DefaultCipherParams()
+	if c.KDFMemoryMiB > 0 {
+		// Too much is clamped to what uint32 holds, which Validate then
+		// rejects, rather than wrapping around to a small value.
+		p.KDF.Memory = uint32(min(uint64(c.KDFMemoryMiB), math.MaxUint32/1024) * 1024)
+	}
+	if c.KDFTime > 0 {
+		p.KDF.Time = uint32(min(uint64(c.KDFTime), math.MaxUint32))
+	}
+	if c.KDFThreads > 0 {
+		p.KDF.Threads = uint8(min(c.KDFThreads, 255))
+	}
+	if c.CipherPageSize > 0 {
+		p.PageSize = c.CipherPageSize
+	}
+	if c.KDFIter > 0 {
+		p.KDFIter = c.KDFIter
+	}
+	return p
+}
+
+// Credentials returns what unlocks the vault with password and pin: the
+// hash of the configured keyfile is added when one is set.
+func (c AppConfig) Credentials(password, pin string) (store.Credentials, error) {
+	creds := store.Credentials{Password: password, PIN: pin}
+	if c.Keyfile != "" {
+		var err error
+		if creds.Keyfile, err = crypto.ReadKeyfile(ExpandPath(c.Keyfile)); err != nil {
+			return store.Credentials{}, err
+		}
+	}
+	return creds, nil
 }
 
 func ExpandPath(path string) string {
@@ -25,13 +115,37 @@ func configPath() string {
 }
 
 func LoadOrInit() AppConfig {
-	cfg := AppConfig{DataDir: "~/.passbook/data"}
+	cipher := store.DefaultCipherParams()
+	cfg := AppConfig{
+		DataDir:               "~/.passbook/data",
+		LockAfterMinutes:      DefaultLockAfterMinutes,
+		ClipboardBackend:      "auto",
+		ClipboardClearSeconds: DefaultClipboardClearSeconds,
+		KDFMemoryMiB:          int(cipher.KDF.Memory / 1024),
+		KDFTime:               int(cipher.KDF.Time),
+		KDFThreads:            int(cipher.KDF.Threads),
+		CipherPageSize:        cipher.PageSize,
+		KDFIter:               cipher.KDFIter,
+	}
 
 	data, err := os.ReadFile(configPath())
 	if err == nil {
-		var loaded AppConfig
-		if json.Unmarshal(data, &loaded) == nil && loaded.DataDir != "" {
-			cfg.DataDir = loaded.DataDir
+		// Settings missing from an older config keep their defaults.
+		loaded := cfg
+		if json.Unmarshal(data, &loaded) == nil {
+			if loaded.DataDir == "" {
+				loaded.DataDir = cfg.DataDir
+			}
+			if loaded.LockAfterMinutes < 0 {
+				loaded.LockAfterMinutes = 0
+			}
+			if loaded.MaxFailedUnlocks < 0 {
+				loaded.MaxFailedUnlocks = 0
+			}
+			if loaded.ClipboardClearSeconds < 0 {
+				loaded.ClipboardClearSeconds = 0
+			}
+			cfg = loaded
 		}
 	}
 
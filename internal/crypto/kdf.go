@@ -0,0 +1,61 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// KDFParams are the Argon2id costs used to derive a vault key from the
+// master password.
+type KDFParams struct {
+	Memory  uint32 // KiB
+	Time    uint32 // passes over the memory
+	Threads uint8
+}
+
+// DefaultKDFParams takes about a quarter of a second on a current laptop.
+var DefaultKDFParams = KDFParams{Memory: 64 * 1024, Time: 3, Threads: 4}
+
+// Upper limits on the costs, so that neither a typo in the settings nor
+// an edited cipher header can make unlocking allocate memory until the
+// process is killed or run for hours.
+const (
+	MaxKDFMemory = 4 << 20 // KiB, 4 GiB
+	MaxKDFTime   = 64
+)
+
+// SaltSize is the length of the salt NewSalt returns.
+const SaltSize = 16
+
+// Validate reports costs Argon2id cannot run with.
+func (p KDFParams) Validate() error {
+	switch {
+	case p.Time < 1:
+		return fmt.Errorf("kdf time must be at least 1")
+	case p.Time > MaxKDFTime:
+		return fmt.Errorf("kdf time must be at most %d", MaxKDFTime)
+	case p.Threads < 1:
+		return fmt.Errorf("kdf threads must be at least 1")
+	case p.Memory < 8*uint32(p.Threads):
+		return fmt.Errorf("kdf memory must be at least 8 KiB per thread")
+	case p.Memory > MaxKDFMemory:
+		return fmt.Errorf("kdf memory must be at most %d MiB", MaxKDFMemory/1024)
+	}
+	return nil
+}
+
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("generating salt: %w", err)
+	}
+	return salt, nil
+}
+
+// DeriveKey stretches secret, see CompositeKey, into a 32-byte key with
+// Argon2id.
+func DeriveKey(secret, salt []byte, p KDFParams) []byte {
+	return argon2.IDKey(secret, salt, p.Time, p.Memory, p.Threads, 32)
+}
@@ -0,0 +1,50 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+var testKDFParams = KDFParams{Memory: 64, Time: 1, Threads: 1}
+
+func TestDeriveKey(t *testing.T) {
+	salt, err := NewSalt()
+	if err != nil {
+		t.Fatalf("NewSalt error: %v", err)
+	}
+	k1 := DeriveKey([]byte("hunter2"), salt, testKDFParams)
+	if len(k1) != 32 {
+		t.Fatalf("expected 32-byte key, got %d", len(k1))
+	}
+	if k2 := DeriveKey([]byte("hunter2"), salt, testKDFParams); !bytes.Equal(k1, k2) {
+		t.Fatalf("expected the same key for the same inputs")
+	}
+
+	other, _ := NewSalt()
+	if bytes.Equal(k1, DeriveKey([]byte("hunter2"), other, testKDFParams)) {
+		t.Fatalf("expected a different key for a different salt")
+	}
+	if bytes.Equal(k1, DeriveKey([]byte("hunter3"), salt, testKDFParams)) {
+		t.Fatalf("expected a different key for a different password")
+	}
+	if bytes.Equal(k1, DeriveKey([]byte("hunter2"), salt, KDFParams{Memory: 64, Time: 2, Threads: 1})) {
+		t.Fatalf("expected a different key for different costs")
+	}
+}
+
+func TestKDFParamsValidate(t *testing.T) {
+	if err := DefaultKDFParams.Validate(); err != nil {
+		t.Fatalf("default params: %v", err)
+	}
+	for _, p := range []KDFParams{
+		{Memory: 64, Time: 0, Threads: 1},
+		{Memory: 64, Time: 1, Threads: 0},
+		{Memory: 16, Time: 1, Threads: 4},
+		{Memory: MaxKDFMemory + 1, Time: 1, Threads: 1},
+		{Memory: 64, Time: MaxKDFTime + 1, Threads: 1},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Fatalf("expected %+v to be rejected", p)
+		}
+	}
+}
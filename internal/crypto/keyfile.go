@@ -0,0 +1,64 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"os"
+)
+
+// keyfileSize is the length of the random data GenerateKeyfile writes.
+const keyfileSize = 64
+
+// GenerateKeyfile writes a new keyfile of random bytes to path. An
+// existing file is never overwritten.
+func GenerateKeyfile(path string) error {
+	data := make([]byte, keyfileSize)
+	if _, err := rand.Read(data); err != nil {
+		return fmt.Errorf("generating keyfile: %w", err)
+	}
+	defer WipeBytes(data)
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// ReadKeyfile returns the SHA-256 hash of the file at path, which is what
+// CompositeKey mixes in. Any non-empty file can serve as a keyfile.
+func ReadKeyfile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading keyfile: %w", err)
+	}
+	defer WipeBytes(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("keyfile %s is empty", path)
+	}
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
+
+// CompositeKey joins the master password with a keyfile hash and a PIN
+// into the secret DeriveKey stretches, so that each of them is needed to
+// open the vault. Without a keyfile or PIN it is the password itself.
+func CompositeKey(password string, keyfile []byte, pin string) []byte {
+	if keyfile == nil && pin == "" {
+		return []byte(password)
+	}
+	pw := sha256.Sum256([]byte("passbook:password:" + password))
+	secret := append([]byte(nil), pw[:]...)
+	secret = append(secret, keyfile...)
+	if pin != "" {
+		p := sha256.Sum256([]byte("passbook:pin:" + pin))
+		secret = append(secret, p[:]...)
+	}
+	return secret
+}
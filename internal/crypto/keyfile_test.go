@@ -0,0 +1,63 @@
+package crypto
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateKeyfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.key")
+	if err := GenerateKeyfile(path); err != nil {
+		t.Fatalf("GenerateKeyfile error: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat error: %v", err)
+	}
+	if info.Size() != keyfileSize || info.Mode().Perm() != 0600 {
+		t.Fatalf("unexpected keyfile: %d bytes, mode %v", info.Size(), info.Mode())
+	}
+	if err := GenerateKeyfile(path); err == nil {
+		t.Fatalf("expected an existing keyfile not to be overwritten")
+	}
+
+	h1, err := ReadKeyfile(path)
+	if err != nil || len(h1) != 32 {
+		t.Fatalf("ReadKeyfile: %x, %v", h1, err)
+	}
+	other := filepath.Join(t.TempDir(), "other.key")
+	if err := GenerateKeyfile(other); err != nil {
+		t.Fatalf("GenerateKeyfile error: %v", err)
+	}
+	if h2, _ := ReadKeyfile(other); bytes.Equal(h1, h2) {
+		t.Fatalf("expected unique keyfiles")
+	}
+}
+
+func TestReadKeyfileRejectsEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+	if _, err := ReadKeyfile(path); err == nil {
+		t.Fatalf("expected an empty keyfile to be rejected")
+	}
+}
+
+func TestCompositeKey(t *testing.T) {
+	if got := CompositeKey("hunter2", nil, ""); string(got) != "hunter2" {
+		t.Fatalf("expected the bare password, got %x", got)
+	}
+	keyfile := bytes.Repeat([]byte{7}, 32)
+	withKeyfile := CompositeKey("hunter2", keyfile, "")
+	withPIN := CompositeKey("hunter2", nil, "123456")
+	withBoth := CompositeKey("hunter2", keyfile, "123456")
+	if bytes.Equal(withKeyfile, withPIN) || bytes.Equal(withKeyfile, withBoth) || bytes.Equal(withPIN, withBoth) {
+		t.Fatalf("expected every combination to differ")
+	}
+	if bytes.Equal(withPIN, CompositeKey("hunter2", nil, "654321")) {
+		t.Fatalf("expected the PIN to change the key")
+	}
+}
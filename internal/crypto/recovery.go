@@ -0,0 +1,122 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// recoveryKeySize is the random part of a recovery key: 160 bits, which
+// is why a plain hash is enough to turn it into a sealing key.
+const recoveryKeySize = 20
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// ErrNotRecoveryKey is returned for text that cannot be a recovery key.
+var ErrNotRecoveryKey = errors.New("not a recovery key")
+
+// GenerateRecoveryKey returns a new recovery key: 32 base32 characters in
+// dash-separated groups of four.
+func GenerateRecoveryKey() (string, error) {
+	raw := make([]byte, recoveryKeySize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("generating recovery key: %w", err)
+	}
+	defer WipeBytes(raw)
+	return groupChars(recoveryEncoding.EncodeToString(raw), 4, "-"), nil
+}
+
+// RecoveryKeyHash returns the 32-byte sealing key for a recovery key.
+// Case, spaces and dashes do not matter.
+func RecoveryKeyHash(key string) ([]byte, error) {
+	raw, err := recoveryEncoding.DecodeString(normalizeCode(strings.ToUpper(key)))
+	if err != nil || len(raw) != recoveryKeySize {
+		return nil, ErrNotRecoveryKey
+	}
+	defer WipeBytes(raw)
+	sum := sha256.Sum256(append([]byte("passbook:recovery:"), raw...))
+	return sum[:], nil
+}
+
+// Seal encrypts plaintext under a 32-byte key with AES-256-GCM. The nonce
+// goes in front of the result.
+func Seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generating nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Unseal decrypts what Seal returned. It fails if key is not the key it
+// was sealed with or sealed was changed.
+func Unseal(key, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("sealed data too short")
+	}
+	n := gcm.NonceSize()
+	return gcm.Open(nil, sealed[:n], sealed[n:], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// BackupCodeCount is how many backup codes are handed out at a time.
+const BackupCodeCount = 10
+
+// GenerateBackupCodes returns n single-use codes of ten digits, written as
+// two groups of five.
+func GenerateBackupCodes(n int) ([]string, error) {
+	limit := big.NewInt(10_000_000_000)
+	codes := make([]string, n)
+	for i := range codes {
+		v, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return nil, fmt.Errorf("generating backup codes: %w", err)
+		}
+		codes[i] = groupChars(fmt.Sprintf("%010d", v), 5, "-")
+	}
+	return codes, nil
+}
+
+// BackupCodeHash is what is kept of a backup code to check it later.
+// Spaces and dashes do not matter.
+func BackupCodeHash(code string) string {
+	sum := sha256.Sum256([]byte("passbook:backup-code:" + normalizeCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeCode(s string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(s))
+}
+
+func groupChars(s string, size int, sep string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i += size {
+		if i > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteString(s[i:min(i+size, len(s))])
+	}
+	return b.String()
+}
@@ -0,0 +1,73 @@
+package crypto
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRecoveryKey(t *testing.T) {
+	key, err := GenerateRecoveryKey()
+	if err != nil {
+		t.Fatalf("GenerateRecoveryKey error: %v", err)
+	}
+	if !regexp.MustCompile(`^([A-Z2-7]{4}-){7}[A-Z2-7]{4}$`).MatchString(key) {
+		t.Fatalf("unexpected recovery key %q", key)
+	}
+
+	h1, err := RecoveryKeyHash(key)
+	if err != nil || len(h1) != 32 {
+		t.Fatalf("RecoveryKeyHash: %x, %v", h1, err)
+	}
+	loose := strings.ToLower(strings.ReplaceAll(key, "-", " "))
+	if h2, err := RecoveryKeyHash(loose); err != nil || !bytes.Equal(h1, h2) {
+		t.Fatalf("expected case and separators not to matter: %v", err)
+	}
+	if _, err := RecoveryKeyHash("ABCD-EFGH"); err != ErrNotRecoveryKey {
+		t.Fatalf("expected a short key to be rejected, got %v", err)
+	}
+}
+
+func TestSealUnseal(t *testing.T) {
+	key, _ := RecoveryKeyHash(strings.Repeat("A", 32))
+	sealed, err := Seal(key, []byte("vault key"))
+	if err != nil {
+		t.Fatalf("Seal error: %v", err)
+	}
+	if plain, err := Unseal(key, sealed); err != nil || string(plain) != "vault key" {
+		t.Fatalf("Unseal: %q, %v", plain, err)
+	}
+
+	other, _ := RecoveryKeyHash(strings.Repeat("B", 32))
+	if _, err := Unseal(other, sealed); err == nil {
+		t.Fatalf("expected the wrong key to fail")
+	}
+	sealed[len(sealed)-1] ^= 1
+	if _, err := Unseal(key, sealed); err == nil {
+		t.Fatalf("expected changed data to fail")
+	}
+}
+
+func TestBackupCodes(t *testing.T) {
+	codes, err := GenerateBackupCodes(BackupCodeCount)
+	if err != nil {
+		t.Fatalf("GenerateBackupCodes error: %v", err)
+	}
+	if len(codes) != BackupCodeCount {
+		t.Fatalf("expected %d codes, got %d", BackupCodeCount, len(codes))
+	}
+	seen := map[string]bool{}
+	for _, c := range codes {
+		if !regexp.MustCompile(`^\d{5}-\d{5}$`).MatchString(c) {
+			t.Fatalf("unexpected backup code %q", c)
+		}
+		seen[BackupCodeHash(c)] = true
+	}
+	if len(seen) != len(codes) {
+		t.Fatalf("expected unique codes")
+	}
+	if BackupCodeHash(codes[0]) != BackupCodeHash(strings.ReplaceAll(codes[0], "-", "")) {
+		t.Fatalf("expected the dash not to matter")
+	}
+}
@@ -0,0 +1,78 @@
+package crypto
+
+import "crypto/subtle"
+
+// Secret holds a password, PIN or key in pages of its own, locked so they
+// are never swapped to disk, and wipes it when released. Unlike a string
+// it is never copied behind the caller's back. A nil or released Secret
+// is empty.
+type Secret struct {
+	mem    []byte
+	locked bool
+	free   func()
+}
+
+// NewSecret copies b into a new Secret. b itself is left as it is; wipe
+// it with WipeBytes if it is no longer needed.
+func NewSecret(b []byte) *Secret {
+	if len(b) == 0 {
+		return nil
+	}
+	mem, locked, free := allocSecret(len(b))
+	copy(mem, b)
+	return &Secret{mem: mem, locked: locked, free: free}
+}
+
+// SecretString copies s into a new Secret. Go strings cannot be wiped,
+// so the caller should drop s as soon as it can.
+func SecretString(s string) *Secret {
+	if s == "" {
+		return nil
+	}
+	mem, locked, free := allocSecret(len(s))
+	copy(mem, s)
+	return &Secret{mem: mem, locked: locked, free: free}
+}
+
+// Bytes returns the secret itself, not a copy. It is only valid until
+// Release, and must not be kept.
+func (s *Secret) Bytes() []byte {
+	if s == nil {
+		return nil
+	}
+	return s.mem
+}
+
+// String returns the secret as a string, for APIs that take one. The
+// string is an ordinary heap copy; keep it no longer than the call.
+func (s *Secret) String() string {
+	return string(s.Bytes())
+}
+
+// Len returns the length of the secret.
+func (s *Secret) Len() int {
+	return len(s.Bytes())
+}
+
+// Equal reports, in constant time, whether the secret is b.
+func (s *Secret) Equal(b []byte) bool {
+	return subtle.ConstantTimeCompare(s.Bytes(), b) == 1
+}
+
+// Locked reports whether the memory holding the secret is locked against
+// swapping. Locking can fail, for instance past RLIMIT_MEMLOCK; the
+// secret is still wiped on release then.
+func (s *Secret) Locked() bool {
+	return s != nil && s.locked
+}
+
+// Release wipes the secret and frees its memory. Releasing a nil or
+// released Secret does nothing.
+func (s *Secret) Release() {
+	if s == nil || s.mem == nil {
+		return
+	}
+	WipeBytes(s.mem)
+	s.free()
+	s.mem, s.locked, s.free = nil, false, nil
+}
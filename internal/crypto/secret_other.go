@@ -0,0 +1,9 @@
+//go:build !unix && !windows
+
+package crypto
+
+// allocSecret keeps the secret on the heap where memory cannot be locked;
+// it is still wiped on release.
+func allocSecret(n int) (mem []byte, locked bool, free func()) {
+	return make([]byte, n), false, func() {}
+}
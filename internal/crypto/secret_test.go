@@ -0,0 +1,31 @@
+package crypto
+
+import "testing"
+
+func TestSecret(t *testing.T) {
+	src := []byte("correct horse")
+	s := NewSecret(src)
+	src[0] = 'X'
+	if s.String() != "correct horse" || s.Len() != 13 {
+		t.Fatalf("expected a copy of the input, got %q", s.String())
+	}
+	if !s.Equal([]byte("correct horse")) || s.Equal([]byte("correct")) {
+		t.Fatalf("Equal gave the wrong answer")
+	}
+	t.Logf("locked: %v", s.Locked())
+
+	s.Release()
+	if s.Bytes() != nil || s.Len() != 0 || s.Locked() {
+		t.Fatalf("expected a released secret to be empty")
+	}
+	s.Release()
+
+	var empty *Secret
+	if empty.String() != "" || empty.Len() != 0 || !empty.Equal(nil) {
+		t.Fatalf("expected a nil secret to be empty")
+	}
+	empty.Release()
+	if SecretString("") != nil || NewSecret(nil) != nil {
+		t.Fatalf("expected no secret for empty input")
+	}
+}
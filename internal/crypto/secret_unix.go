@@ -0,0 +1,23 @@
+//go:build unix
+
+package crypto
+
+import "golang.org/x/sys/unix"
+
+// allocSecret maps n bytes of anonymous memory, rounded up to whole
+// pages, and locks them. Should mapping fail it falls back to the heap.
+func allocSecret(n int) (mem []byte, locked bool, free func()) {
+	page := unix.Getpagesize()
+	size := (n + page - 1) / page * page
+	region, err := unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+	if err != nil {
+		return make([]byte, n), false, func() {}
+	}
+	locked = unix.Mlock(region) == nil
+	return region[:n], locked, func() {
+		if locked {
+			_ = unix.Munlock(region)
+		}
+		_ = unix.Munmap(region)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build windows
+
+package crypto
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// allocSecret locks whole pages of a heap buffer, which the garbage
+// collector never moves, and returns n bytes of them.
+func allocSecret(n int) (mem []byte, locked bool, free func()) {
+	const page = 4096
+	size := (n + page - 1) / page * page
+	buf := make([]byte, size+page)
+	off := page - int(uintptr(unsafe.Pointer(&buf[0]))%page)
+	region := buf[off : off+size]
+	addr := uintptr(unsafe.Pointer(&region[0]))
+	locked = windows.VirtualLock(addr, uintptr(size)) == nil
+	return region[:n], locked, func() {
+		if locked {
+			_ = windows.VirtualUnlock(addr, uintptr(size))
+		}
+	}
+}
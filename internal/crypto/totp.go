@@ -0,0 +1,20 @@
+package crypto
+
+import (
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+// ValidateTOTP checks a 6-digit authenticator code against secret,
+// allowing two periods of clock skew in either direction.
+func ValidateTOTP(code, secret string) bool {
+	ok, _ := totp.ValidateCustom(code, secret, time.Now().UTC(), totp.ValidateOpts{
+		Period:    30,
+		Skew:      2,
+		Digits:    otp.DigitsSix,
+		Algorithm: otp.AlgorithmSHA1,
+	})
+	return ok
+}
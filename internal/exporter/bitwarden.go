@@ -0,0 +1,206 @@
+package exporter
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"passbook/internal/store"
+)
+
+type bitwardenExport struct {
+	Encrypted bool              `json:"encrypted"`
+	Folders   []bitwardenFolder `json:"folders"`
+	Items     []bitwardenItem   `json:"items"`
+}
+
+type bitwardenFolder struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type bitwardenItem struct {
+	Type            int                        `json:"type"`
+	Name            string                     `json:"name"`
+	Notes           string                     `json:"notes,omitempty"`
+	FolderID        *string                    `json:"folderId"`
+	Favorite        bool                       `json:"favorite"`
+	Login           *bitwardenLogin            `json:"login,omitempty"`
+	Card            *bitwardenCard             `json:"card,omitempty"`
+	SecureNote      *bitwardenSecureNote       `json:"secureNote,omitempty"`
+	Fields          []bitwardenField           `json:"fields,omitempty"`
+	PasswordHistory []bitwardenPasswordHistory `json:"passwordHistory,omitempty"`
+}
+
+type bitwardenPasswordHistory struct {
+	LastUsedDate string `json:"lastUsedDate"`
+	Password     string `json:"password"`
+}
+
+type bitwardenLogin struct {
+	Username string         `json:"username,omitempty"`
+	Password string         `json:"password,omitempty"`
+	Totp     string         `json:"totp,omitempty"`
+	URIs     []bitwardenURI `json:"uris,omitempty"`
+}
+
+type bitwardenURI struct {
+	Match *int   `json:"match"`
+	URI   string `json:"uri"`
+}
+
+type bitwardenCard struct {
+	CardholderName string `json:"cardholderName,omitempty"`
+	Number         string `json:"number,omitempty"`
+	ExpMonth       string `json:"expMonth,omitempty"`
+	ExpYear        string `json:"expYear,omitempty"`
+	Code           string `json:"code,omitempty"`
+}
+
+type bitwardenSecureNote struct {
+	Type int `json:"type"`
+}
+
+type bitwardenField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Type  int    `json:"type"`
+}
+
+// Bitwarden field types. Bitwarden has no URL, date or TOTP fields; they
+// are written as text, or hidden for TOTP secrets.
+const (
+	bitwardenFieldText   = 0
+	bitwardenFieldHidden = 1
+)
+
+// bitwardenMatches maps match modes to Bitwarden's URI match numbers. The
+// default mode is written as null so Bitwarden applies its own default.
+var bitwardenMatches = map[store.MatchMode]int{
+	store.MatchDomain:     0,
+	store.MatchHost:       1,
+	store.MatchStartsWith: 2,
+	store.MatchExact:      3,
+	store.MatchRegex:      4,
+	store.MatchNever:      5,
+}
+
+func bitwardenMatch(m store.MatchMode) *int {
+	n, ok := bitwardenMatches[m]
+	if !ok {
+		return nil
+	}
+	return &n
+}
+
+// ExportBitwarden writes the logins, cards and notes in s to w as an
+// unencrypted Bitwarden JSON export. File entries have no Bitwarden
+// equivalent and are skipped; attachments are not included.
+func ExportBitwarden(s *store.Store, w io.Writer) (Stats, error) {
+	entries, folders, err := loadEntries(s)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	export := bitwardenExport{
+		Folders: []bitwardenFolder{},
+		Items:   []bitwardenItem{},
+	}
+	folderIDs := make(map[int64]string)
+	for _, f := range folders {
+		id, err := newUUID()
+		if err != nil {
+			return Stats{}, err
+		}
+		folderIDs[f.ID] = id
+		export.Folders = append(export.Folders, bitwardenFolder{ID: id, Name: f.Path})
+	}
+
+	var stats Stats
+	for _, e := range entries {
+		item, ok := convertToBitwardenItem(e)
+		if !ok {
+			stats.Skipped++
+			continue
+		}
+		if id, ok := folderIDs[e.FolderID]; ok {
+			item.FolderID = &id
+		}
+		export.Items = append(export.Items, item)
+		stats.Exported++
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(export); err != nil {
+		return stats, fmt.Errorf("writing JSON: %w", err)
+	}
+	return stats, nil
+}
+
+func convertToBitwardenItem(e *store.EntryFull) (bitwardenItem, bool) {
+	notes := e.CustomText
+	item := bitwardenItem{Name: e.Title}
+
+	switch e.Type {
+	case "Login":
+		item.Type = 1
+		item.Login = &bitwardenLogin{
+			Username: e.Username,
+			Password: e.Password,
+			Totp:     e.TotpSecret,
+		}
+		for _, u := range e.URIs {
+			item.Login.URIs = append(item.Login.URIs, bitwardenURI{URI: u.URI, Match: bitwardenMatch(u.Match)})
+		}
+		for _, h := range e.History {
+			item.PasswordHistory = append(item.PasswordHistory, bitwardenPasswordHistory{
+				LastUsedDate: h.Date,
+				Password:     h.Password,
+			})
+		}
+
+	case "Note":
+		item.Type = 2
+		item.SecureNote = &bitwardenSecureNote{}
+
+	case "Card":
+		item.Type = 3
+		item.Card = &bitwardenCard{
+			Number: e.CardNumber,
+			Code:   e.CVV,
+		}
+		if month, year, ok := strings.Cut(e.Expiry, "/"); ok {
+			item.Card.ExpMonth, item.Card.ExpYear = month, year
+		} else {
+			item.Card.ExpMonth = e.Expiry
+		}
+		notes, item.Card.CardholderName = splitCardholder(notes)
+
+	default:
+		return item, false
+	}
+
+	item.Notes = notes
+	for _, f := range e.Fields {
+		field := bitwardenField{Name: f.Name, Value: f.Value, Type: bitwardenFieldText}
+		if sensitiveField(f) {
+			field.Type = bitwardenFieldHidden
+		}
+		item.Fields = append(item.Fields, field)
+	}
+	return item, true
+}
+
+// newUUID returns a random version 4 UUID, the ID format Bitwarden uses.
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("generating ID: %w", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,248 @@
+package exporter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"passbook/internal/config"
+	"passbook/internal/importer"
+	"passbook/internal/store"
+)
+
+const testPassword = "testpass"
+
+func openTestStore(t *testing.T, dir string) *store.Store {
+	t.Helper()
+	s, err := store.Open(filepath.Join(dir, "passbook.db"), testPassword)
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// seedVault fills s with one entry of every type, with custom fields and
+// with notes shaped the way the importers write cardholder names. The
+// login is in Work and the card in the nested folder Finance/Cards.
+func seedVault(t *testing.T, s *store.Store) map[string]*store.EntryFull {
+	t.Helper()
+	folderID, err := s.CreateFolderPath("Work")
+	if err != nil {
+		t.Fatalf("CreateFolderPath: %v", err)
+	}
+	cardsID, err := s.CreateFolderPath("Finance/Cards")
+	if err != nil {
+		t.Fatalf("CreateFolderPath: %v", err)
+	}
+	entries := map[string]*store.EntryFull{
+		"GitHub": {
+			Type:     "Login",
+			Title:    "GitHub",
+			Username: "octocat",
+			Password: "s3cret",
+			URIs: []store.EntryURI{
+				{URI: "https://github.com"},
+				{URI: "https://github.com/login", Match: store.MatchExact},
+			},
+			TotpSecret: "JBSWY3DPEHPK3PXP",
+			CustomText: "Work account",
+			History:    []store.PasswordHistory{{Password: "old", Date: "2025-01-01 10:00"}},
+			Fields: []store.CustomField{
+				{Name: "Recovery", Value: "abc: 123", Type: store.FieldText},
+				{Name: "PIN", Value: "0000", Type: store.FieldHidden},
+			},
+		},
+		"Visa": {
+			Type:       "Card",
+			Title:      "Visa",
+			CardNumber: "4111111111111111",
+			Expiry:     "12/30",
+			CVV:        "123",
+			CustomText: "Backup card\nline two\n\nCardholder: Jane Doe",
+			Fields:     []store.CustomField{{Name: "Bank", Value: "Example", Type: store.FieldText}},
+		},
+		"Wifi": {
+			Type:       "Note",
+			Title:      "Wifi",
+			CustomText: "SSID: home\npassword, with \"quotes\"",
+		},
+	}
+	for _, e := range entries {
+		fid := int64(0)
+		switch e.Type {
+		case "Login":
+			fid = folderID
+		case "Card":
+			fid = cardsID
+		}
+		if _, err := s.SaveEntry(fid, e); err != nil {
+			t.Fatalf("SaveEntry: %v", err)
+		}
+	}
+	if _, err := s.SaveEntry(0, &store.EntryFull{Type: "File", Title: "Keys", FileName: "id_rsa", FileData: []byte{1}}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	return entries
+}
+
+// exportFile writes the export produced by fn to a temp file.
+func exportFile(t *testing.T, s *store.Store, name string, fn func(*store.Store, *os.File) (Stats, error)) (string, Stats) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer f.Close()
+	stats, err := fn(s, f)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	return path, stats
+}
+
+// keeps lists what a format carries beyond the fields every format has.
+type keeps struct {
+	history    bool
+	fieldTypes bool
+	extraURIs  bool
+	matchModes bool
+}
+
+// assertRoundTrip checks that every field of want survived the export and
+// re-import into s. Password history, custom field types, URIs after the
+// first and their match modes are only compared when the format keeps them.
+func assertRoundTrip(t *testing.T, s *store.Store, want map[string]*store.EntryFull, k keeps) {
+	t.Helper()
+	metas, err := s.ListAllEntries()
+	if err != nil {
+		t.Fatalf("ListAllEntries: %v", err)
+	}
+	if len(metas) != len(want) {
+		t.Fatalf("expected %d entries after re-import, got %d", len(want), len(metas))
+	}
+	for _, m := range metas {
+		w, ok := want[m.Title]
+		if !ok {
+			t.Fatalf("unexpected entry %q", m.Title)
+		}
+		got, err := s.LoadEntry(m.ID)
+		if err != nil {
+			t.Fatalf("LoadEntry: %v", err)
+		}
+		if got.Type != w.Type || got.Username != w.Username || got.Password != w.Password ||
+			got.Link() != w.Link() || got.TotpSecret != w.TotpSecret || got.CardNumber != w.CardNumber ||
+			got.Expiry != w.Expiry || got.CVV != w.CVV {
+			t.Fatalf("%s: fields changed:\n got %+v\nwant %+v", m.Title, got, w)
+		}
+		if got.CustomText != w.CustomText {
+			t.Fatalf("%s: notes changed:\n got %q\nwant %q", m.Title, got.CustomText, w.CustomText)
+		}
+		if len(got.Fields) != len(w.Fields) {
+			t.Fatalf("%s: custom fields changed:\n got %+v\nwant %+v", m.Title, got.Fields, w.Fields)
+		}
+		for i, f := range got.Fields {
+			if f.Name != w.Fields[i].Name || f.Value != w.Fields[i].Value || (k.fieldTypes && f.Type != w.Fields[i].Type) {
+				t.Fatalf("%s: custom fields changed:\n got %+v\nwant %+v", m.Title, got.Fields, w.Fields)
+			}
+		}
+		if k.extraURIs && len(got.URIs) != len(w.URIs) {
+			t.Fatalf("%s: URIs changed:\n got %+v\nwant %+v", m.Title, got.URIs, w.URIs)
+		}
+		for i := range got.URIs {
+			if got.URIs[i].URI != w.URIs[i].URI || (k.matchModes && got.URIs[i].Match != w.URIs[i].Match) {
+				t.Fatalf("%s: URIs changed:\n got %+v\nwant %+v", m.Title, got.URIs, w.URIs)
+			}
+		}
+		if k.history && len(got.History) != len(w.History) {
+			t.Fatalf("%s: expected %d history items, got %d", m.Title, len(w.History), len(got.History))
+		}
+		for i := range got.History {
+			if got.History[i] != w.History[i] {
+				t.Fatalf("%s: history changed: %+v", m.Title, got.History)
+			}
+		}
+	}
+}
+
+// assertFolder checks that the folder at path name holds n entries.
+func assertFolder(t *testing.T, s *store.Store, name string, n int) {
+	t.Helper()
+	f, err := s.GetFolderByPath(name)
+	if err != nil || f == nil {
+		t.Fatalf("expected folder %q, got %v %v", name, f, err)
+	}
+	if got := s.CountEntriesInFolder(f.ID); got != n {
+		t.Fatalf("expected %d entries in %q, got %d", n, name, got)
+	}
+}
+
+func TestExportBitwardenRoundTrip(t *testing.T) {
+	src := openTestStore(t, t.TempDir())
+	want := seedVault(t, src)
+
+	path, stats := exportFile(t, src, "bitwarden.json", func(s *store.Store, f *os.File) (Stats, error) {
+		return ExportBitwarden(s, f)
+	})
+	if stats.Exported != 3 || stats.Skipped != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	dir := t.TempDir()
+	openTestStore(t, dir).Close()
+	if err := importer.ImportBitwarden(path, testPassword, config.AppConfig{DataDir: dir}); err != nil {
+		t.Fatalf("ImportBitwarden: %v", err)
+	}
+	dst := openTestStore(t, dir)
+	assertRoundTrip(t, dst, want, keeps{history: true, fieldTypes: true, extraURIs: true, matchModes: true})
+	assertFolder(t, dst, "Work", 1)
+	assertFolder(t, dst, "Finance/Cards", 1)
+}
+
+func TestExportBitwardenFormat(t *testing.T) {
+	src := openTestStore(t, t.TempDir())
+	seedVault(t, src)
+
+	path, _ := exportFile(t, src, "bitwarden.json", func(s *store.Store, f *os.File) (Stats, error) {
+		return ExportBitwarden(s, f)
+	})
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	var export bitwardenExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	// Nested folders are written with their full path, the way Bitwarden
+	// names them.
+	if len(export.Folders) != 3 || export.Folders[1].Name != "Finance/Cards" || export.Folders[2].Name != "Work" {
+		t.Fatalf("expected Finance, Finance/Cards and Work folders, got %+v", export.Folders)
+	}
+	for _, item := range export.Items {
+		switch item.Name {
+		case "GitHub":
+			if item.FolderID == nil || *item.FolderID != export.Folders[2].ID {
+				t.Fatalf("expected login to reference the Work folder")
+			}
+			if item.Notes != "Work account" || len(item.Fields) != 2 || item.Fields[0].Value != "abc: 123" ||
+				item.Fields[1].Type != bitwardenFieldHidden {
+				t.Fatalf("expected custom fields as Bitwarden fields, got %q %+v", item.Notes, item.Fields)
+			}
+		case "Visa":
+			if item.Card.ExpMonth != "12" || item.Card.ExpYear != "30" || item.Card.CardholderName != "Jane Doe" {
+				t.Fatalf("unexpected card: %+v", item.Card)
+			}
+			if item.Notes != "Backup card\nline two" {
+				t.Fatalf("unexpected card notes %q", item.Notes)
+			}
+		case "Wifi":
+			if item.Type != 2 || item.SecureNote == nil {
+				t.Fatalf("expected secure note, got %+v", item)
+			}
+		}
+	}
+}
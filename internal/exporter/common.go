@@ -0,0 +1,67 @@
+// Package exporter writes vault contents in formats that other password
+// managers can import. It is the reverse of package importer: every file it
+// produces re-imports through the matching importer without losing data.
+package exporter
+
+import (
+	"fmt"
+	"strings"
+
+	"passbook/internal/store"
+)
+
+// Stats reports how many entries were written and how many had no
+// representation in the target format.
+type Stats struct {
+	Exported int
+	Skipped  int
+}
+
+// loadEntries returns every entry in s with its full contents, together
+// with the vault's folders.
+func loadEntries(s *store.Store) ([]*store.EntryFull, []store.FolderInfo, error) {
+	folders, err := s.ListFolders()
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing folders: %w", err)
+	}
+
+	metas, err := s.ListAllEntries()
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing entries: %w", err)
+	}
+	entries := make([]*store.EntryFull, 0, len(metas))
+	for _, m := range metas {
+		e, err := s.LoadEntry(m.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("loading %q: %w", m.Title, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, folders, nil
+}
+
+// splitCardholder removes the trailing "Cardholder: <name>" paragraph that
+// the importers add to card notes.
+func splitCardholder(notes string) (string, string) {
+	const marker = "Cardholder: "
+
+	var rest, holder string
+	if strings.HasPrefix(notes, marker) {
+		holder = notes[len(marker):]
+	} else if i := strings.LastIndex(notes, "\n\n"+marker); i >= 0 {
+		rest, holder = notes[:i], notes[i+2+len(marker):]
+	} else {
+		return notes, ""
+	}
+
+	if holder == "" || strings.Contains(holder, "\n") {
+		return notes, ""
+	}
+	return rest, holder
+}
+
+// sensitiveField reports whether a custom field holds a secret that target
+// formats should mask.
+func sensitiveField(f store.CustomField) bool {
+	return f.Type == store.FieldHidden || f.Type == store.FieldTOTP
+}
@@ -0,0 +1,186 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"passbook/internal/kdbx"
+	"passbook/internal/store"
+)
+
+// keepassKDF is the Argon2id cost used for exported databases. Tests lower
+// it to keep them fast.
+var keepassKDF = kdbx.DefaultKDF
+
+// keepassReserved are the entry strings the KeePass exporter fills from
+// PassBook's own fields. Custom fields with these names stay in the notes.
+var keepassReserved = map[string]bool{
+	kdbx.KeyTitle:           true,
+	kdbx.KeyUserName:        true,
+	kdbx.KeyPassword:        true,
+	kdbx.KeyURL:             true,
+	kdbx.KeyNotes:           true,
+	"otp":                   true,
+	"TimeOtp-Secret-Base32": true,
+	"Card Number":           true,
+	"Expiry":                true,
+	"CVV":                   true,
+}
+
+// ExportKeePass writes every entry in s to w as a KDBX 4 database encrypted
+// with password. Folders become groups nested the same way, and "/" in a
+// folder name from before folders could nest also creates nested groups.
+// Password history and attachments are kept.
+func ExportKeePass(s *store.Store, w io.Writer, password string) (Stats, error) {
+	entries, folders, err := loadEntries(s)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	root := &kdbx.Group{Name: "PassBook"}
+	groups := map[int64]*kdbx.Group{0: root}
+	for _, f := range folders {
+		// ListFolders puts parents first.
+		parent, ok := groups[f.ParentID]
+		if !ok {
+			parent = root
+		}
+		groups[f.ID] = keepassGroup(parent, f.Name)
+	}
+
+	var stats Stats
+	for _, e := range entries {
+		entry, err := convertToKeePassEntry(s, e)
+		if err != nil {
+			return stats, err
+		}
+		g, ok := groups[e.FolderID]
+		if !ok {
+			g = root
+		}
+		g.Entries = append(g.Entries, entry)
+		stats.Exported++
+	}
+
+	db := &kdbx.Database{Name: "PassBook", Root: root}
+	if err := kdbx.Write(w, db, password, keepassKDF); err != nil {
+		return stats, fmt.Errorf("writing KeePass database: %w", err)
+	}
+	return stats, nil
+}
+
+// keepassGroup returns the group for a folder path below parent, creating
+// any missing groups along the way.
+func keepassGroup(parent *kdbx.Group, path string) *kdbx.Group {
+	g := parent
+next:
+	for _, name := range strings.Split(path, "/") {
+		for _, sub := range g.Groups {
+			if sub.Name == name {
+				g = sub
+				continue next
+			}
+		}
+		sub := &kdbx.Group{Name: name}
+		g.Groups = append(g.Groups, sub)
+		g = sub
+	}
+	return g
+}
+
+func convertToKeePassEntry(s *store.Store, e *store.EntryFull) (*kdbx.Entry, error) {
+	notes, fields := e.CustomText, e.Fields
+	if !keepassFieldsFit(fields) {
+		notes, fields = store.FieldsToNotes(notes, fields), nil
+	}
+
+	entry := &kdbx.Entry{Modified: time.Now()}
+	add := func(key, value string, protected bool) {
+		if value != "" || key == kdbx.KeyTitle {
+			entry.Strings = append(entry.Strings, kdbx.String{Key: key, Value: value, Protected: protected})
+		}
+	}
+	add(kdbx.KeyTitle, e.Title, false)
+	add(kdbx.KeyUserName, e.Username, false)
+	add(kdbx.KeyPassword, e.Password, true)
+	add(kdbx.KeyURL, e.Link(), false)
+	add(kdbx.KeyNotes, notes, false)
+	if e.TotpSecret != "" {
+		add("otp", fmt.Sprintf("otpauth://totp/%s?secret=%s", url.PathEscape(e.Title), url.QueryEscape(e.TotpSecret)), true)
+	}
+	if e.Type == "Card" {
+		add("Card Number", e.CardNumber, true)
+		add("Expiry", e.Expiry, false)
+		add("CVV", e.CVV, true)
+	}
+	for _, f := range fields {
+		add(f.Name, f.Value, sensitiveField(f))
+	}
+	// KeePass has one URL; KeePassXC keeps the others as KP2A_URL strings.
+	// Match rules are not kept.
+	for i, u := range e.URIs {
+		switch {
+		case i == 1:
+			add("KP2A_URL", u.URI, false)
+		case i > 1:
+			add(fmt.Sprintf("KP2A_URL_%d", i-1), u.URI, false)
+		}
+	}
+
+	// KeePass keeps whole-entry snapshots rather than a password list. Each
+	// snapshot is dated when its password was set, so the importer reads
+	// the change dates back from the following snapshot.
+	for i, h := range e.History {
+		snapshot := &kdbx.Entry{
+			Strings: []kdbx.String{
+				{Key: kdbx.KeyTitle, Value: e.Title},
+				{Key: kdbx.KeyPassword, Value: h.Password, Protected: true},
+			},
+			Modified: parseHistoryDate(h.Date),
+		}
+		if i > 0 {
+			snapshot.Modified = parseHistoryDate(e.History[i-1].Date)
+		}
+		entry.History = append(entry.History, snapshot)
+	}
+	if n := len(e.History); n > 0 {
+		entry.Modified = parseHistoryDate(e.History[n-1].Date)
+	}
+
+	if len(e.FileData) > 0 {
+		entry.Binaries = append(entry.Binaries, kdbx.Binary{Name: e.FileName, Data: e.FileData})
+	}
+	for _, a := range e.Attachments {
+		data, err := s.ReadAttachment(a.ID)
+		if err != nil {
+			return nil, fmt.Errorf("reading attachment %q of %q: %w", a.FileName, e.Title, err)
+		}
+		entry.Binaries = append(entry.Binaries, kdbx.Binary{Name: a.FileName, Data: data})
+	}
+	return entry, nil
+}
+
+// keepassFieldsFit reports whether every custom field can become its own
+// entry string. KeePass string keys must be non-empty and unique, and the
+// KP2A_URL keys are taken by the entry's extra URIs.
+func keepassFieldsFit(fields []store.CustomField) bool {
+	seen := make(map[string]bool)
+	for _, f := range fields {
+		if f.Name == "" || keepassReserved[f.Name] || strings.HasPrefix(f.Name, "KP2A_URL") || seen[f.Name] {
+			return false
+		}
+		seen[f.Name] = true
+	}
+	return true
+}
+
+func parseHistoryDate(date string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", date, time.Local)
+	if err != nil {
+		return time.Now()
+	}
+	return t
+}
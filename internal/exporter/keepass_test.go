@@ -0,0 +1,111 @@
+package exporter
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"passbook/internal/config"
+	"passbook/internal/importer"
+	"passbook/internal/kdbx"
+	"passbook/internal/store"
+)
+
+func init() {
+	keepassKDF = kdbx.KDFParams{Memory: 64 << 10, Iterations: 2, Parallelism: 2}
+}
+
+func TestExportKeePassRoundTrip(t *testing.T) {
+	src := openTestStore(t, t.TempDir())
+	want := seedVault(t, src)
+	want["Keys"] = &store.EntryFull{Type: "File", Title: "Keys"}
+
+	path, stats := exportFile(t, src, "vault.kdbx", func(s *store.Store, f *os.File) (Stats, error) {
+		return ExportKeePass(s, f, "kdbxpass")
+	})
+	if stats.Exported != 4 || stats.Skipped != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	dir := t.TempDir()
+	openTestStore(t, dir).Close()
+	if err := importer.ImportKeePass(path, "kdbxpass", testPassword, config.AppConfig{DataDir: dir}); err != nil {
+		t.Fatalf("ImportKeePass: %v", err)
+	}
+	dst := openTestStore(t, dir)
+	assertRoundTrip(t, dst, want, keeps{history: true, fieldTypes: true, extraURIs: true})
+
+	assertFolder(t, dst, "Work", 1)
+
+	metas, _ := dst.ListAllEntries()
+	for _, m := range metas {
+		if m.Title != "Keys" {
+			continue
+		}
+		keys, err := dst.LoadEntry(m.ID)
+		if err != nil {
+			t.Fatalf("LoadEntry: %v", err)
+		}
+		if len(keys.Attachments) != 1 || keys.Attachments[0].FileName != "id_rsa" {
+			t.Fatalf("expected file data as an attachment, got %+v", keys.Attachments)
+		}
+	}
+}
+
+func TestExportKeePassFormat(t *testing.T) {
+	src := openTestStore(t, t.TempDir())
+	if _, err := src.CreateFolderPath("Work/Servers"); err != nil {
+		t.Fatalf("CreateFolderPath: %v", err)
+	}
+	seedVault(t, src)
+
+	path, _ := exportFile(t, src, "vault.kdbx", func(s *store.Store, f *os.File) (Stats, error) {
+		return ExportKeePass(s, f, "kdbxpass")
+	})
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	db, err := kdbx.Read(bytes.NewReader(data), "kdbxpass")
+	if err != nil {
+		t.Fatalf("kdbx.Read: %v", err)
+	}
+
+	if len(db.Root.Groups) != 2 || db.Root.Groups[0].Name != "Finance" || db.Root.Groups[1].Name != "Work" {
+		t.Fatalf("expected Finance and Work groups, got %+v", db.Root.Groups)
+	}
+	if cards := db.Root.Groups[0].Groups; len(cards) != 1 || cards[0].Name != "Cards" || len(cards[0].Entries) != 1 {
+		t.Fatalf("expected the card in Finance/Cards, got %+v", cards)
+	}
+	work := db.Root.Groups[1]
+	if len(work.Groups) != 1 || work.Groups[0].Name != "Servers" {
+		t.Fatalf("expected Work/Servers to nest, got %+v", work.Groups)
+	}
+	github := work.Entries[0]
+	if github.Get("otp") != "otpauth://totp/GitHub?secret=JBSWY3DPEHPK3PXP" {
+		t.Fatalf("unexpected otp %q", github.Get("otp"))
+	}
+	if github.Get("PIN") != "0000" || github.Get(kdbx.KeyNotes) != "Work account" {
+		t.Fatalf("expected custom fields as entry strings, got %+v", github.Strings)
+	}
+	for _, s := range github.Strings {
+		if s.Key == "PIN" && !s.Protected {
+			t.Fatalf("expected the hidden field to be protected")
+		}
+	}
+	if len(github.History) != 1 || github.History[0].Get(kdbx.KeyPassword) != "old" {
+		t.Fatalf("unexpected history: %+v", github.History)
+	}
+}
+
+func TestKeePassFieldsFit(t *testing.T) {
+	if keepassFieldsFit([]store.CustomField{{Name: "Password", Value: "x"}}) {
+		t.Fatalf("reserved key should not fit")
+	}
+	if keepassFieldsFit([]store.CustomField{{Name: "A", Value: "1"}, {Name: "A", Value: "2"}}) {
+		t.Fatalf("duplicate keys should not fit")
+	}
+	if !keepassFieldsFit([]store.CustomField{{Name: "A", Value: "1"}, {Name: "B", Value: "2"}}) {
+		t.Fatalf("distinct keys should fit")
+	}
+}
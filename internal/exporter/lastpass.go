@@ -0,0 +1,148 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"passbook/internal/store"
+)
+
+var lastPassHeader = []string{"url", "username", "password", "totp", "extra", "name", "grouping", "fav"}
+
+// lastPassSecureNoteURL marks a row as a secure note rather than a login.
+const lastPassSecureNoteURL = "http://sn"
+
+// lastPassURLField names the custom fields that hold a login's URIs after
+// the first, which the url column has no room for. The LastPass importer
+// turns them back into URIs.
+const lastPassURLField = "URL"
+
+// lastPassCardKeys are the keys LastPass itself writes for credit card
+// notes. Custom fields with one of these names cannot be stored as extra
+// "Key:Value" lines without being mistaken for card data.
+var lastPassCardKeys = map[string]bool{
+	"NoteType":        true,
+	"Language":        true,
+	"Name on Card":    true,
+	"Number":          true,
+	"Security Code":   true,
+	"Expiration Date": true,
+	"Notes":           true,
+}
+
+// ExportLastPass writes the logins, cards and notes in s to w in LastPass's
+// CSV format. Folders become the "grouping" column and cards are written as
+// LastPass credit card notes. The format has no place for password history
+// or attachments, and File entries are skipped. URIs after the first are
+// kept in the notes, without their match rules.
+func ExportLastPass(s *store.Store, w io.Writer) (Stats, error) {
+	entries, folders, err := loadEntries(s)
+	if err != nil {
+		return Stats{}, err
+	}
+	groupings := make(map[int64]string)
+	for _, f := range folders {
+		groupings[f.ID] = strings.ReplaceAll(f.Path, "/", "\\")
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(lastPassHeader); err != nil {
+		return Stats{}, fmt.Errorf("writing CSV: %w", err)
+	}
+
+	var stats Stats
+	for _, e := range entries {
+		row, ok := convertToLastPassRow(e)
+		if !ok {
+			stats.Skipped++
+			continue
+		}
+		row[6] = groupings[e.FolderID]
+		if err := cw.Write(row); err != nil {
+			return stats, fmt.Errorf("writing CSV: %w", err)
+		}
+		stats.Exported++
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return stats, fmt.Errorf("writing CSV: %w", err)
+	}
+	return stats, nil
+}
+
+// convertToLastPassRow returns a row in lastPassHeader order, leaving the
+// grouping column for the caller.
+func convertToLastPassRow(e *store.EntryFull) ([]string, bool) {
+	switch e.Type {
+	case "Login":
+		return []string{e.Link(), e.Username, e.Password, e.TotpSecret, store.FieldsToNotes(e.CustomText, lastPassLoginFields(e)), e.Title, "", "0"}, true
+	case "Note":
+		return []string{lastPassSecureNoteURL, "", "", "", store.FieldsToNotes(e.CustomText, e.Fields), e.Title, "", "0"}, true
+	case "Card":
+		return []string{lastPassSecureNoteURL, "", "", "", formatLastPassCard(e), e.Title, "", "0"}, true
+	default:
+		return nil, false
+	}
+}
+
+// lastPassLoginFields returns the custom fields of a login followed by one
+// lastPassURLField field for each URI after the first.
+func lastPassLoginFields(e *store.EntryFull) []store.CustomField {
+	if len(e.URIs) < 2 {
+		return e.Fields
+	}
+	fields := append([]store.CustomField(nil), e.Fields...)
+	for _, u := range e.URIs[1:] {
+		fields = append(fields, store.CustomField{Name: lastPassURLField, Value: u.URI, Type: store.FieldURL})
+	}
+	return fields
+}
+
+// formatLastPassCard builds the "extra" text of a LastPass credit card note.
+// The cardholder that the importers fold into notes and the custom fields
+// are written back as their own lines when they can be read back
+// unambiguously; otherwise everything is kept in the notes.
+func formatLastPassCard(e *store.EntryFull) string {
+	notes, holder := splitCardholder(e.CustomText)
+	fields := e.Fields
+	for _, f := range fields {
+		if !fitsLastPassCard(f.Name, f.Value) {
+			notes, holder, fields = store.FieldsToNotes(e.CustomText, e.Fields), "", nil
+			break
+		}
+	}
+
+	expiry := ","
+	if t, err := time.Parse("01/06", e.Expiry); err == nil {
+		expiry = t.Format("January,2006")
+	} else if e.Expiry != "" {
+		expiry = e.Expiry
+	}
+
+	lines := []string{
+		"NoteType:Credit Card",
+		"Language:en-US",
+		"Name on Card:" + holder,
+		"Number:" + e.CardNumber,
+		"Security Code:" + e.CVV,
+		"Expiration Date:" + expiry,
+	}
+	for _, f := range fields {
+		lines = append(lines, f.Name+":"+f.Value)
+	}
+	lines = append(lines, "Notes:"+notes)
+	return strings.Join(lines, "\n")
+}
+
+// fitsLastPassCard reports whether a custom field can be stored as its own
+// "Key:Value" line in a card note and parsed back unchanged.
+func fitsLastPassCard(name, value string) bool {
+	if name == "" || strings.Contains(name, ":") || lastPassCardKeys[name] {
+		return false
+	}
+	return value != "" && value != ","
+}
@@ -0,0 +1,85 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"os"
+	"strings"
+	"testing"
+
+	"passbook/internal/config"
+	"passbook/internal/importer"
+	"passbook/internal/store"
+)
+
+func TestExportLastPassRoundTrip(t *testing.T) {
+	src := openTestStore(t, t.TempDir())
+	want := seedVault(t, src)
+
+	path, stats := exportFile(t, src, "lastpass.csv", func(s *store.Store, f *os.File) (Stats, error) {
+		return ExportLastPass(s, f)
+	})
+	if stats.Exported != 3 || stats.Skipped != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	dir := t.TempDir()
+	openTestStore(t, dir).Close()
+	if err := importer.ImportLastPass(path, testPassword, config.AppConfig{DataDir: dir}); err != nil {
+		t.Fatalf("ImportLastPass: %v", err)
+	}
+	dst := openTestStore(t, dir)
+	assertRoundTrip(t, dst, want, keeps{extraURIs: true})
+	assertFolder(t, dst, "Work", 1)
+	assertFolder(t, dst, "Finance/Cards", 1)
+}
+
+func TestExportLastPassFormat(t *testing.T) {
+	src := openTestStore(t, t.TempDir())
+	seedVault(t, src)
+
+	path, _ := exportFile(t, src, "lastpass.csv", func(s *store.Store, f *os.File) (Stats, error) {
+		return ExportLastPass(s, f)
+	})
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	if strings.Join(records[0], ",") != "url,username,password,totp,extra,name,grouping,fav" {
+		t.Fatalf("unexpected header %v", records[0])
+	}
+	for _, row := range records[1:] {
+		switch row[5] {
+		case "GitHub":
+			if row[6] != "Work" {
+				t.Fatalf("expected grouping Work, got %q", row[6])
+			}
+			if row[4] != "Work account\n\nCustom Fields:\nRecovery: abc: 123\nPIN: 0000\nURL: https://github.com/login" {
+				t.Fatalf("expected custom fields at the end of the notes, got %q", row[4])
+			}
+		case "Visa":
+			if row[0] != "http://sn" || !strings.Contains(row[4], "Expiration Date:December,2030\n") ||
+				!strings.Contains(row[4], "Name on Card:Jane Doe\n") || !strings.Contains(row[4], "Bank:Example\n") {
+				t.Fatalf("unexpected card note %q", row[4])
+			}
+		}
+	}
+}
+
+func TestFormatLastPassCardKeepsAmbiguousNotes(t *testing.T) {
+	e := &store.EntryFull{
+		Type:       "Card",
+		CustomText: "text\n\nCardholder: Jane",
+		Fields:     []store.CustomField{{Name: "Number", Value: "42"}},
+	}
+	extra := formatLastPassCard(e)
+	if !strings.Contains(extra, "Name on Card:\n") ||
+		!strings.HasSuffix(extra, "Notes:text\n\nCardholder: Jane\n\nCustom Fields:\nNumber: 42") {
+		t.Fatalf("expected notes to be kept whole, got %q", extra)
+	}
+}
@@ -4,19 +4,27 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 
 	"passbook/internal/config"
 	"passbook/internal/store"
 )
 
 type bitwardenExport struct {
-	Items []bitwardenItem `json:"items"`
+	Folders []bitwardenFolder `json:"folders"`
+	Items   []bitwardenItem   `json:"items"`
+}
+
+type bitwardenFolder struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
 }
 
 type bitwardenItem struct {
 	Type            int                        `json:"type"`
 	Name            string                     `json:"name"`
 	Notes           string                     `json:"notes"`
+	FolderID        string                     `json:"folderId"`
 	Login           *bitwardenLogin            `json:"login"`
 	Card            *bitwardenCard             `json:"card"`
 	Fields          []bitwardenField           `json:"fields"`
@@ -36,7 +44,8 @@ type bitwardenLogin struct {
 }
 
 type bitwardenURI struct {
-	URI string `json:"uri"`
+	URI   string `json:"uri"`
+	Match *int   `json:"match"`
 }
 
 type bitwardenCard struct {
@@ -53,27 +62,35 @@ type bitwardenField struct {
 	Type  int    `json:"type"`
 }
 
+func init() {
+	Register(Source{Name: "bitwarden", Ext: ".json", Parse: parseBitwarden})
+}
+
 func ImportBitwarden(jsonPath, masterPassword string, cfg config.AppConfig) error {
+	return importFrom("bitwarden", jsonPath, "", masterPassword, cfg)
+}
+
+func parseBitwarden(jsonPath, _ string) (*Batch, error) {
 	data, err := os.ReadFile(jsonPath)
 	if err != nil {
-		return fmt.Errorf("reading file: %w", err)
+		return nil, fmt.Errorf("reading file: %w", err)
 	}
 
 	var export bitwardenExport
 	if err := json.Unmarshal(data, &export); err != nil {
-		return fmt.Errorf("parsing JSON: %w", err)
+		return nil, fmt.Errorf("parsing JSON: %w", err)
 	}
 
-	var entries []*store.EntryFull
-	var names []string
+	folders := make(map[string]string)
+	for _, f := range export.Folders {
+		folders[f.ID] = f.Name
+	}
 
+	b := &Batch{}
 	for _, item := range export.Items {
-		entry := convertBitwardenItem(item)
-		entries = append(entries, entry)
-		names = append(names, item.Name)
+		b.add(convertBitwardenItem(item), item.Name, folders[item.FolderID])
 	}
-
-	return saveEntries(entries, names, masterPassword, cfg)
+	return b, nil
 }
 
 func convertBitwardenItem(item bitwardenItem) *store.EntryFull {
@@ -90,8 +107,10 @@ func convertBitwardenItem(item bitwardenItem) *store.EntryFull {
 			entry.Username = item.Login.Username
 			entry.Password = item.Login.Password
 			entry.TotpSecret = item.Login.Totp
-			if len(item.Login.URIs) > 0 {
-				entry.Link = item.Login.URIs[0].URI
+			for _, u := range item.Login.URIs {
+				if uri := strings.TrimSpace(u.URI); uri != "" {
+					entry.URIs = append(entry.URIs, store.EntryURI{URI: uri, Match: bitwardenMatchMode(u.Match)})
+				}
 			}
 		}
 		for _, h := range item.PasswordHistory {
@@ -100,7 +119,7 @@ func convertBitwardenItem(item bitwardenItem) *store.EntryFull {
 				Date:     h.LastUsedDate,
 			})
 		}
-		entry.CustomText = appendNotes(entry.CustomText, formatCustomFields(fields))
+		entry.Fields = fields
 		return entry
 
 	case 2: // Secure Note
@@ -108,8 +127,8 @@ func convertBitwardenItem(item bitwardenItem) *store.EntryFull {
 			Type:       "Note",
 			Title:      item.Name,
 			CustomText: item.Notes,
+			Fields:     fields,
 		}
-		entry.CustomText = appendNotes(entry.CustomText, formatCustomFields(fields))
 		return entry
 
 	case 3: // Card
@@ -132,7 +151,7 @@ func convertBitwardenItem(item bitwardenItem) *store.EntryFull {
 				entry.CustomText = appendNotes(entry.CustomText, "Cardholder: "+item.Card.CardholderName)
 			}
 		}
-		entry.CustomText = appendNotes(entry.CustomText, formatCustomFields(fields))
+		entry.Fields = fields
 		return entry
 
 	default:
@@ -140,10 +159,45 @@ func convertBitwardenItem(item bitwardenItem) *store.EntryFull {
 	}
 }
 
-func convertBitwardenFields(fields []bitwardenField) [][2]string {
-	var result [][2]string
+// bitwardenMatchModes maps Bitwarden's URI match numbers to match modes. A
+// null match means the account default, which is domain matching.
+var bitwardenMatchModes = map[int]store.MatchMode{
+	0: store.MatchDomain,
+	1: store.MatchHost,
+	2: store.MatchStartsWith,
+	3: store.MatchExact,
+	4: store.MatchRegex,
+	5: store.MatchNever,
+}
+
+func bitwardenMatchMode(match *int) store.MatchMode {
+	if match == nil {
+		return store.MatchDefault
+	}
+	return bitwardenMatchModes[*match]
+}
+
+// Bitwarden field types. Boolean fields keep their "true"/"false" value as
+// text; linked fields only point at another field of the item and have no
+// value of their own.
+const (
+	bitwardenFieldText    = 0
+	bitwardenFieldHidden  = 1
+	bitwardenFieldBoolean = 2
+	bitwardenFieldLinked  = 3
+)
+
+func convertBitwardenFields(fields []bitwardenField) []store.CustomField {
+	var result []store.CustomField
 	for _, f := range fields {
-		result = append(result, [2]string{f.Name, f.Value})
+		if f.Type == bitwardenFieldLinked || (f.Name == "" && f.Value == "") {
+			continue
+		}
+		field := store.CustomField{Name: f.Name, Value: f.Value, Type: store.FieldText}
+		if f.Type == bitwardenFieldHidden {
+			field.Type = store.FieldHidden
+		}
+		result = append(result, field)
 	}
 	return result
 }
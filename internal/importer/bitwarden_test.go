@@ -70,6 +70,7 @@ func writeBitwardenJSON(t *testing.T, items []bitwardenItem) string {
 
 func TestImportBitwardenLogin(t *testing.T) {
 	password := "testpass"
+	exactMatch := 3
 	dir, cfg := setupTestVault(t, password)
 
 	jsonPath := writeBitwardenJSON(t, []bitwardenItem{
@@ -80,7 +81,10 @@ func TestImportBitwardenLogin(t *testing.T) {
 				Username: "user@example.com",
 				Password: "secret123",
 				Totp:     "JBSWY3DPEHPK3PXP",
-				URIs:     []bitwardenURI{{URI: "https://github.com"}},
+				URIs: []bitwardenURI{
+					{URI: "https://github.com"},
+					{URI: "https://github.com/login", Match: &exactMatch},
+				},
 			},
 			Notes: "My GitHub account",
 		},
@@ -107,8 +111,11 @@ func TestImportBitwardenLogin(t *testing.T) {
 	if entry.TotpSecret != "JBSWY3DPEHPK3PXP" {
 		t.Fatalf("expected TOTP, got %s", entry.TotpSecret)
 	}
-	if entry.Link != "https://github.com" {
-		t.Fatalf("expected link, got %s", entry.Link)
+	if entry.Link() != "https://github.com" {
+		t.Fatalf("expected link, got %s", entry.Link())
+	}
+	if len(entry.URIs) != 2 || entry.URIs[0].Match != store.MatchDefault || entry.URIs[1].Match != store.MatchExact {
+		t.Fatalf("expected both URIs with their match rules, got %+v", entry.URIs)
 	}
 	if entry.CustomText != "My GitHub account" {
 		t.Fatalf("expected notes, got %s", entry.CustomText)
@@ -265,6 +272,8 @@ func TestImportBitwardenCustomFields(t *testing.T) {
 			Login: &bitwardenLogin{Username: "u"},
 			Fields: []bitwardenField{
 				{Name: "API Key", Value: "abc123", Type: 0},
+				{Name: "PIN", Value: "0000", Type: 1},
+				{Name: "Linked", Type: 3},
 			},
 		},
 	})
@@ -275,8 +284,11 @@ func TestImportBitwardenCustomFields(t *testing.T) {
 
 	s := openTestStore(t, dir, password)
 	entry := loadEntryFromStore(t, s, "WithFields")
-	if entry.CustomText == "" || !contains(entry.CustomText, "API Key: abc123") {
-		t.Fatalf("expected custom fields in notes, got %q", entry.CustomText)
+	if got, want := fieldLines(entry.Fields), "API Key: abc123\nPIN: 0000 [hidden]"; got != want {
+		t.Fatalf("unexpected fields:\n%s\nwant\n%s", got, want)
+	}
+	if entry.CustomText != "" {
+		t.Fatalf("expected fields to stay out of the notes, got %q", entry.CustomText)
 	}
 }
 
@@ -299,15 +311,42 @@ func TestSanitizeTitle(t *testing.T) {
 	}
 }
 
-func contains(s, sub string) bool {
-	return len(s) >= len(sub) && (s == sub || len(s) > 0 && containsStr(s, sub))
-}
+func TestImportBitwardenFolders(t *testing.T) {
+	password := "testpass"
+	dir, cfg := setupTestVault(t, password)
 
-func containsStr(s, sub string) bool {
-	for i := 0; i <= len(s)-len(sub); i++ {
-		if s[i:i+len(sub)] == sub {
-			return true
-		}
+	data := `{"folders": [{"id": "f1", "name": "Work"}],
+  "items": [
+    {"type": 1, "name": "GitHub", "folderId": "f1", "login": {"username": "a"}},
+    {"type": 1, "name": "GitHub", "folderId": "f1", "login": {"username": "b"}},
+    {"type": 2, "name": "Loose", "folderId": null}
+  ]}`
+	path := filepath.Join(t.TempDir(), "export.json")
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	s := openTestStore(t, dir, password)
+	if _, err := s.CreateFolderPath("Old/Work"); err != nil {
+		t.Fatalf("CreateFolder: %v", err)
+	}
+	src, _ := Lookup("bitwarden")
+	if err := Import(src, path, "", password, Options{Into: "Old"}, cfg); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	work, err := s.GetFolderByPath("Old/Work")
+	if err != nil || work == nil {
+		t.Fatalf("expected existing folder to be reused: %v", err)
+	}
+	if n := s.CountEntriesInFolder(work.ID); n != 2 {
+		t.Fatalf("expected 2 entries in Old/Work, got %d", n)
+	}
+	if !s.EntryExistsInFolder(work.ID, "GitHub_1") {
+		t.Fatalf("expected duplicate title to be renamed within the folder")
+	}
+	old, err := s.GetFolderByPath("Old")
+	if err != nil || old == nil || !s.EntryExistsInFolder(old.ID, "Loose") {
+		t.Fatalf("expected unfiled item in the --into folder")
 	}
-	return false
 }
@@ -0,0 +1,54 @@
+package importer
+
+import (
+	"fmt"
+
+	"passbook/internal/store"
+)
+
+// Chrome and Edge share the same password CSV:
+// name,url,username,password,note
+func init() {
+	Register(Source{Name: "chrome", Ext: ".csv", Parse: parseChromeCSV})
+	Register(Source{Name: "edge", Ext: ".csv", Parse: parseChromeCSV})
+}
+
+func parseChromeCSV(csvPath, _ string) (*Batch, error) {
+	records, err := readCSV(csvPath)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Batch{}
+	if len(records) < 2 {
+		return b, nil
+	}
+
+	colIndex := buildColumnIndex(records[0])
+	if _, ok := colIndex["password"]; !ok {
+		return nil, fmt.Errorf("parsing CSV: no password column; is this a browser password export?")
+	}
+
+	for _, row := range records[1:] {
+		b.add(convertChromeRow(row, colIndex), colVal(row, colIndex, "name"), "")
+	}
+	return b, nil
+}
+
+func convertChromeRow(row []string, colIndex map[string]int) *store.EntryFull {
+	entry := &store.EntryFull{
+		Type:       "Login",
+		Title:      colVal(row, colIndex, "name"),
+		Username:   colVal(row, colIndex, "username"),
+		Password:   colVal(row, colIndex, "password"),
+		CustomText: colVal(row, colIndex, "note"),
+	}
+	entry.AddURI(colVal(row, colIndex, "url"))
+	if entry.Username == "" && entry.Password == "" && entry.Link() == "" {
+		return nil
+	}
+	if entry.Title == "" {
+		entry.Title = titleFromURL(entry.Link())
+	}
+	return entry
+}
@@ -0,0 +1,35 @@
+package importer
+
+import "testing"
+
+func TestImportChromeCSV(t *testing.T) {
+	password := "testpass"
+	dir, cfg := setupTestVault(t, password)
+
+	path := writeTestFile(t, "Chrome Passwords.csv", `name,url,username,password,note
+github.com,https://github.com/login,octocat,s3cret,work account
+,https://www.example.com/,bob,pw,
+,,,,
+`)
+	src, _ := Lookup("chrome")
+	if err := Import(src, path, "", password, Options{}, cfg); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	s := openTestStore(t, dir, password)
+	gh := loadEntryFromStore(t, s, "github.com")
+	if gh.Type != "Login" || gh.Username != "octocat" || gh.Password != "s3cret" ||
+		gh.Link() != "https://github.com/login" || gh.CustomText != "work account" {
+		t.Fatalf("unexpected entry: %+v", gh)
+	}
+	if e := loadEntryFromStore(t, s, "example.com"); e.Username != "bob" {
+		t.Fatalf("expected title from URL host, got %+v", e)
+	}
+}
+
+func TestImportChromeRejectsOtherCSV(t *testing.T) {
+	path := writeTestFile(t, "other.csv", "a,b\n1,2\n")
+	if _, err := parseChromeCSV(path, ""); err == nil {
+		t.Fatalf("expected an error for a CSV without a password column")
+	}
+}
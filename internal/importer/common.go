@@ -1,65 +1,29 @@
 package importer
 
 import (
-	"fmt"
-	"path/filepath"
+	"net/url"
 	"strings"
 
-	"passbook/internal/config"
 	"passbook/internal/store"
 )
 
-func saveEntries(entries []*store.EntryFull, names []string, masterPassword string, cfg config.AppConfig) error {
-	dataDir := config.ExpandPath(cfg.DataDir)
-	dbPath := filepath.Join(dataDir, "passbook.db")
-
-	s, err := store.Open(dbPath, masterPassword)
-	if err != nil {
-		return fmt.Errorf("opening store: %w", err)
-	}
-	defer s.Close()
-
-	var imported, skipped int
-	for i, entry := range entries {
-		if entry == nil {
-			skipped++
-			continue
-		}
-
-		origName := names[i]
-
-		title := sanitizeTitle(entry.Title)
-		if title == "" {
-			title = "Untitled"
-		}
-		entry.Title = title
-
-		finalTitle := title
-		if s.EntryExistsInFolder(0, finalTitle) {
-			counter := 1
-			for {
-				finalTitle = fmt.Sprintf("%s_%d", title, counter)
-				if !s.EntryExistsInFolder(0, finalTitle) {
-					break
-				}
-				counter++
+// folderPath cleans a "/"-separated folder path from an export and places
+// it below into, giving the vault folder path such as "Imported/Work".
+func folderPath(into, path string) string {
+	var parts []string
+	for _, p := range strings.Split(into+"/"+path, "/") {
+		p = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(`<>:"\|?*`, r) {
+				return -1
 			}
-			entry.Title = finalTitle
-		}
-
-		if _, err := s.SaveEntry(0, entry); err != nil {
-			fmt.Printf("  ⚠ skipping %q: write error: %v\n", origName, err)
-			skipped++
-			continue
+			return r
+		}, p)
+		p = strings.TrimLeft(strings.TrimSpace(p), "._")
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
 		}
-
-		imported++
 	}
-
-	total := len(entries)
-	fmt.Printf("Import complete: %d imported, %d skipped (total %d items)\n",
-		imported, skipped, total)
-	return nil
+	return strings.Join(parts, "/")
 }
 
 func sanitizeTitle(title string) string {
@@ -75,20 +39,53 @@ func sanitizeTitle(title string) string {
 	return title
 }
 
-func formatCustomFields(fields [][2]string) string {
-	if len(fields) == 0 {
-		return ""
-	}
-	var parts []string
-	for _, f := range fields {
-		if f[0] != "" || f[1] != "" {
-			parts = append(parts, fmt.Sprintf("%s: %s", f[0], f[1]))
+// textFields turns name/value pairs from an export into text custom
+// fields, dropping pairs that are empty.
+func textFields(pairs [][2]string) []store.CustomField {
+	var fields []store.CustomField
+	for _, p := range pairs {
+		if p[0] != "" || p[1] != "" {
+			fields = append(fields, store.CustomField{Name: p[0], Value: p[1], Type: store.FieldText})
 		}
 	}
-	if len(parts) == 0 {
-		return ""
+	return fields
+}
+
+// otpauthSecret returns the secret of an otpauth:// URI. Anything else is
+// assumed to be a bare secret and returned as is.
+func otpauthSecret(v string) string {
+	if u, err := url.Parse(v); err == nil && u.Scheme == "otpauth" {
+		return u.Query().Get("secret")
+	}
+	return v
+}
+
+// titleFromURL derives an entry title from a login URL, for exports that
+// have no name column.
+func titleFromURL(link string) string {
+	if u, err := url.Parse(link); err == nil && u.Host != "" {
+		return strings.TrimPrefix(u.Hostname(), "www.")
+	}
+	return link
+}
+
+// formatExpiry builds an MM/YY card expiry from a month ("5", "05") and a
+// year ("2030", "30"). A missing part leaves just the other.
+func formatExpiry(month, year string) string {
+	month, year = strings.TrimSpace(month), strings.TrimSpace(year)
+	if len(month) == 1 {
+		month = "0" + month
+	}
+	if len(year) == 4 {
+		year = year[2:]
+	}
+	switch {
+	case month != "" && year != "":
+		return month + "/" + year
+	case month != "":
+		return month
 	}
-	return "Custom Fields:\n" + strings.Join(parts, "\n")
+	return year
 }
 
 func appendNotes(existing, extra string) string {
@@ -0,0 +1,279 @@
+package importer
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"passbook/internal/store"
+)
+
+// Dashlane exports either a ZIP of CSV files (credentials.csv,
+// securenotes.csv, payments.csv, ...) or, in older versions, one JSON file
+// keyed by item type. Both are accepted, as is a single CSV taken out of
+// the ZIP.
+func init() {
+	Register(Source{Name: "dashlane", Ext: ".zip", Parse: parseDashlane})
+}
+
+type dashlaneExport struct {
+	Credentials []dashlaneCredential `json:"AUTHENTIFIANT"`
+	Notes       []dashlaneNote       `json:"SECUREDNOTE"`
+	Cards       []dashlaneCard       `json:"PAYMENTMEANS_CREDITCARD"`
+}
+
+type dashlaneCredential struct {
+	Title          string `json:"title"`
+	Domain         string `json:"domain"`
+	Email          string `json:"email"`
+	Login          string `json:"login"`
+	SecondaryLogin string `json:"secondaryLogin"`
+	Password       string `json:"password"`
+	Note           string `json:"note"`
+	OTPSecret      string `json:"otpSecret"`
+}
+
+type dashlaneNote struct {
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	Category string `json:"category"`
+}
+
+type dashlaneCard struct {
+	Name         string `json:"name"`
+	Owner        string `json:"owner"`
+	CardNumber   string `json:"cardNumber"`
+	SecurityCode string `json:"securityCode"`
+	ExpireMonth  string `json:"expireMonth"`
+	ExpireYear   string `json:"expireYear"`
+	Bank         string `json:"bank"`
+}
+
+func parseDashlane(path, _ string) (*Batch, error) {
+	b := &Batch{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return b, parseDashlaneJSON(path, b)
+	case ".csv":
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("opening file: %w", err)
+		}
+		defer f.Close()
+		ok, err := parseDashlaneCSV(f, b)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("parsing CSV: not a Dashlane credentials, secure notes or payments file")
+		}
+		return b, nil
+	default:
+		return b, parseDashlaneZip(path, b)
+	}
+}
+
+func parseDashlaneZip(path string, b *Batch) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("opening archive: %w", err)
+	}
+	defer zr.Close()
+
+	for _, zf := range zr.File {
+		if !strings.EqualFold(filepath.Ext(zf.Name), ".csv") {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", zf.Name, err)
+		}
+		// Files other than credentials, notes and payments (IDs,
+		// personal info) have no PassBook equivalent and are ignored.
+		_, err = parseDashlaneCSV(rc, b)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", zf.Name, err)
+		}
+	}
+	return nil
+}
+
+// parseDashlaneCSV adds the rows of one Dashlane CSV to b, recognising the
+// file by its header. It reports false for files it does not know.
+func parseDashlaneCSV(r io.Reader, b *Batch) (bool, error) {
+	reader := csv.NewReader(r)
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return false, fmt.Errorf("parsing CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return false, nil
+	}
+
+	colIndex := buildColumnIndex(records[0])
+	var convert func(row []string) *store.EntryFull
+	switch {
+	case hasColumn(colIndex, "cc_number"):
+		convert = func(row []string) *store.EntryFull { return convertDashlanePayment(row, colIndex) }
+	case hasColumn(colIndex, "password"):
+		convert = func(row []string) *store.EntryFull { return convertDashlaneCredential(row, colIndex) }
+	case hasColumn(colIndex, "title") && hasColumn(colIndex, "note"):
+		convert = func(row []string) *store.EntryFull {
+			return &store.EntryFull{
+				Type:       "Note",
+				Title:      colVal(row, colIndex, "title"),
+				CustomText: colVal(row, colIndex, "note"),
+			}
+		}
+	default:
+		return false, nil
+	}
+
+	for _, row := range records[1:] {
+		entry := convert(row)
+		name := ""
+		if entry != nil {
+			name = entry.Title
+		}
+		b.add(entry, name, strings.TrimSpace(colVal(row, colIndex, "category")))
+	}
+	return true, nil
+}
+
+func hasColumn(colIndex map[string]int, col string) bool {
+	_, ok := colIndex[col]
+	return ok
+}
+
+func convertDashlaneCredential(row []string, colIndex map[string]int) *store.EntryFull {
+	entry := &store.EntryFull{
+		Type:       "Login",
+		Title:      colVal(row, colIndex, "title"),
+		Username:   colVal(row, colIndex, "username"),
+		Password:   colVal(row, colIndex, "password"),
+		CustomText: colVal(row, colIndex, "note"),
+	}
+	entry.AddURI(colVal(row, colIndex, "url"))
+	if otp := colVal(row, colIndex, "otpurl"); otp != "" {
+		entry.TotpSecret = otpauthSecret(otp)
+	} else {
+		entry.TotpSecret = colVal(row, colIndex, "otpsecret")
+	}
+	if entry.Title == "" {
+		entry.Title = titleFromURL(entry.Link())
+	}
+
+	var fields [][2]string
+	for i, col := range []string{"username2", "username3"} {
+		if v := colVal(row, colIndex, col); v != "" {
+			fields = append(fields, [2]string{fmt.Sprintf("Username %d", i+2), v})
+		}
+	}
+	entry.Fields = textFields(fields)
+	return entry
+}
+
+// convertDashlanePayment turns a payments.csv row into a Card, or a Note for
+// bank accounts.
+func convertDashlanePayment(row []string, colIndex map[string]int) *store.EntryFull {
+	title := colVal(row, colIndex, "account_name")
+	holder := colVal(row, colIndex, "account_holder")
+
+	if colVal(row, colIndex, "type") == "bank" {
+		var fields [][2]string
+		for _, f := range [][2]string{
+			{"Account Holder", holder},
+			{"Account Number", colVal(row, colIndex, "account_number")},
+			{"Routing Number", colVal(row, colIndex, "routing_number")},
+			{"Bank", colVal(row, colIndex, "issuing_bank")},
+			{"Country", colVal(row, colIndex, "country")},
+		} {
+			if f[1] != "" {
+				fields = append(fields, f)
+			}
+		}
+		return &store.EntryFull{Type: "Note", Title: title, Fields: textFields(fields)}
+	}
+
+	entry := &store.EntryFull{
+		Type:       "Card",
+		Title:      title,
+		CardNumber: colVal(row, colIndex, "cc_number"),
+		CVV:        colVal(row, colIndex, "code"),
+		Expiry:     formatExpiry(colVal(row, colIndex, "expiration_month"), colVal(row, colIndex, "expiration_year")),
+	}
+	if holder != "" {
+		entry.CustomText = "Cardholder: " + holder
+	}
+	if bank := colVal(row, colIndex, "issuing_bank"); bank != "" {
+		entry.Fields = textFields([][2]string{{"Bank", bank}})
+	}
+	return entry
+}
+
+func parseDashlaneJSON(path string, b *Batch) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading file: %w", err)
+	}
+	var export dashlaneExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return fmt.Errorf("parsing JSON: %w", err)
+	}
+
+	for _, c := range export.Credentials {
+		entry := &store.EntryFull{
+			Type:       "Login",
+			Title:      c.Title,
+			Username:   c.Login,
+			Password:   c.Password,
+			TotpSecret: otpauthSecret(c.OTPSecret),
+			CustomText: c.Note,
+		}
+		entry.AddURI(c.Domain)
+		var fields [][2]string
+		if entry.Username == "" {
+			entry.Username = c.Email
+		} else if c.Email != "" && c.Email != c.Login {
+			fields = append(fields, [2]string{"Email", c.Email})
+		}
+		if c.SecondaryLogin != "" {
+			fields = append(fields, [2]string{"Secondary Login", c.SecondaryLogin})
+		}
+		if entry.Title == "" {
+			entry.Title = titleFromURL(c.Domain)
+		}
+		entry.Fields = textFields(fields)
+		b.add(entry, c.Title, "")
+	}
+
+	for _, n := range export.Notes {
+		b.add(&store.EntryFull{Type: "Note", Title: n.Title, CustomText: n.Content}, n.Title, n.Category)
+	}
+
+	for _, c := range export.Cards {
+		entry := &store.EntryFull{
+			Type:       "Card",
+			Title:      c.Name,
+			CardNumber: c.CardNumber,
+			CVV:        c.SecurityCode,
+			Expiry:     formatExpiry(c.ExpireMonth, c.ExpireYear),
+		}
+		if c.Owner != "" {
+			entry.CustomText = "Cardholder: " + c.Owner
+		}
+		if c.Bank != "" {
+			entry.Fields = textFields([][2]string{{"Bank", c.Bank}})
+		}
+		b.add(entry, c.Name, "")
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package importer
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeZip(t *testing.T, name string, files map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip: %v", err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	return path
+}
+
+func TestParseDashlaneZip(t *testing.T) {
+	path := writeZip(t, "dashlane.zip", map[string]string{
+		"credentials.csv": "username,username2,username3,title,password,note,url,category,otpUrl\n" +
+			"alice,alt,,GitHub,pw,note,https://github.com,Work,otpauth://totp/GitHub?secret=JBSWY3DPEHPK3PXP\n",
+		"securenotes.csv": "title,note\nWifi,SSID: home\n",
+		"payments.csv": "type,account_name,account_holder,cc_number,code,expiration_month,expiration_year,routing_number,account_number,country,issuing_bank\n" +
+			"payment_card,Visa,Jane Doe,4111111111111111,123,5,2030,,,US,\n" +
+			"bank,Checking,Jane Doe,,,,,021000021,12345,US,Example Bank\n",
+		"ids.csv": "type,number,name\npassport,X1,Jane\n",
+	})
+
+	b, err := parseDashlane(path, "")
+	if err != nil {
+		t.Fatalf("parseDashlane: %v", err)
+	}
+	if len(b.Entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(b.Entries))
+	}
+	byTitle := make(map[string]int)
+	for i, e := range b.Entries {
+		byTitle[e.Title] = i
+	}
+
+	gh := b.Entries[byTitle["GitHub"]]
+	if gh.Type != "Login" || gh.Username != "alice" || gh.TotpSecret != "JBSWY3DPEHPK3PXP" ||
+		gh.CustomText != "note" || fieldLines(gh.Fields) != "Username 2: alt" {
+		t.Fatalf("unexpected login: %+v", gh)
+	}
+	if b.Folders[byTitle["GitHub"]] != "Work" {
+		t.Fatalf("expected category as folder, got %q", b.Folders[byTitle["GitHub"]])
+	}
+	visa := b.Entries[byTitle["Visa"]]
+	if visa.Type != "Card" || visa.Expiry != "05/30" || visa.CustomText != "Cardholder: Jane Doe" {
+		t.Fatalf("unexpected card: %+v", visa)
+	}
+	if bank := b.Entries[byTitle["Checking"]]; bank.Type != "Note" {
+		t.Fatalf("expected bank account as note, got %+v", bank)
+	}
+	if wifi := b.Entries[byTitle["Wifi"]]; wifi.Type != "Note" || wifi.CustomText != "SSID: home" {
+		t.Fatalf("unexpected note: %+v", wifi)
+	}
+}
+
+func TestParseDashlaneJSON(t *testing.T) {
+	path := writeTestFile(t, "dashlane.json", `{
+  "AUTHENTIFIANT": [{"title": "Example", "domain": "example.com", "email": "a@example.com", "login": "alice", "password": "pw", "note": ""}],
+  "SECUREDNOTE": [{"title": "Note", "content": "text", "category": "Personal"}],
+  "PAYMENTMEANS_CREDITCARD": [{"name": "Visa", "owner": "Jane", "cardNumber": "4111", "securityCode": "1", "expireMonth": "12", "expireYear": "2031"}]
+}`)
+	b, err := parseDashlane(path, "")
+	if err != nil {
+		t.Fatalf("parseDashlane: %v", err)
+	}
+	if len(b.Entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(b.Entries))
+	}
+	if e := b.Entries[0]; e.Username != "alice" || fieldLines(e.Fields) != "Email: a@example.com" {
+		t.Fatalf("unexpected login: %+v", e)
+	}
+	if b.Folders[1] != "Personal" {
+		t.Fatalf("expected note category as folder, got %q", b.Folders[1])
+	}
+	if e := b.Entries[2]; e.Type != "Card" || e.Expiry != "12/31" {
+		t.Fatalf("unexpected card: %+v", e)
+	}
+}
@@ -0,0 +1,55 @@
+package importer
+
+import (
+	"fmt"
+
+	"passbook/internal/store"
+)
+
+// Firefox exports logins as
+// url,username,password,httpRealm,formActionOrigin,guid,timeCreated,timeLastUsed,timePasswordChanged
+// with no name column, so titles come from the site's host name.
+func init() {
+	Register(Source{Name: "firefox", Ext: ".csv", Parse: parseFirefoxCSV})
+}
+
+func parseFirefoxCSV(csvPath, _ string) (*Batch, error) {
+	records, err := readCSV(csvPath)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Batch{}
+	if len(records) < 2 {
+		return b, nil
+	}
+
+	colIndex := buildColumnIndex(records[0])
+	if _, ok := colIndex["password"]; !ok {
+		return nil, fmt.Errorf("parsing CSV: no password column; is this a Firefox login export?")
+	}
+
+	for _, row := range records[1:] {
+		entry := convertFirefoxRow(row, colIndex)
+		b.add(entry, colVal(row, colIndex, "url"), "")
+	}
+	return b, nil
+}
+
+func convertFirefoxRow(row []string, colIndex map[string]int) *store.EntryFull {
+	link := colVal(row, colIndex, "url")
+	entry := &store.EntryFull{
+		Type:     "Login",
+		Title:    titleFromURL(link),
+		Username: colVal(row, colIndex, "username"),
+		Password: colVal(row, colIndex, "password"),
+	}
+	entry.AddURI(link)
+	if entry.Username == "" && entry.Password == "" && link == "" {
+		return nil
+	}
+	if realm := colVal(row, colIndex, "httprealm"); realm != "" {
+		entry.Fields = textFields([][2]string{{"HTTP Realm", realm}})
+	}
+	return entry
+}
@@ -0,0 +1,24 @@
+package importer
+
+import "testing"
+
+func TestParseFirefoxCSV(t *testing.T) {
+	path := writeTestFile(t, "logins.csv", `"url","username","password","httpRealm","formActionOrigin","guid","timeCreated","timeLastUsed","timePasswordChanged"
+"https://accounts.example.com","alice","pw1",,"https://accounts.example.com","{1}","1","1","1"
+"https://intranet.local","bob","pw2","Staff","","{2}","1","1","1"
+`)
+	b, err := parseFirefoxCSV(path, "")
+	if err != nil {
+		t.Fatalf("parseFirefoxCSV: %v", err)
+	}
+	if len(b.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(b.Entries))
+	}
+	e := b.Entries[0]
+	if e.Title != "accounts.example.com" || e.Username != "alice" || e.Password != "pw1" || e.Link() != "https://accounts.example.com" {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+	if got := fieldLines(b.Entries[1].Fields); got != "HTTP Realm: Staff" {
+		t.Fatalf("expected realm as custom field, got %q", got)
+	}
+}
@@ -0,0 +1,172 @@
+package importer
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"passbook/internal/config"
+	"passbook/internal/kdbx"
+	"passbook/internal/store"
+)
+
+// Entry strings PassBook maps onto its own fields. Everything else is kept
+// as a custom field in the notes.
+const (
+	keepassOTP        = "otp"
+	keepassOTPSecret  = "TimeOtp-Secret-Base32"
+	keepassCardNumber = "Card Number"
+	keepassExpiry     = "Expiry"
+	keepassCVV        = "CVV"
+)
+
+// keepassURLKey reports whether key holds one of KeePassXC's additional
+// URLs, named KP2A_URL, KP2A_URL_1, KP2A_URL_2 and so on.
+func keepassURLKey(key string) bool {
+	return key == "KP2A_URL" || strings.HasPrefix(key, "KP2A_URL_")
+}
+
+func init() {
+	Register(Source{
+		Name:           "keepass",
+		Ext:            ".kdbx",
+		PasswordPrompt: "KeePass Password: ",
+		Parse:          parseKeePass,
+	})
+}
+
+// ImportKeePass imports a KDBX 4 database. Each group becomes a folder at
+// the same path below the root group ("Work/Servers"). Entries in the
+// recycle bin are skipped.
+func ImportKeePass(kdbxPath, kdbxPassword, masterPassword string, cfg config.AppConfig) error {
+	return importFrom("keepass", kdbxPath, kdbxPassword, masterPassword, cfg)
+}
+
+func parseKeePass(kdbxPath, kdbxPassword string) (*Batch, error) {
+	f, err := os.Open(kdbxPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening file: %w", err)
+	}
+	defer f.Close()
+
+	db, err := kdbx.Read(f, kdbxPassword)
+	if err != nil {
+		return nil, fmt.Errorf("reading KeePass database: %w", err)
+	}
+
+	b := &Batch{}
+	collectKeePassGroup(db.Root, "", b)
+	return b, nil
+}
+
+func collectKeePassGroup(g *kdbx.Group, path string, b *Batch) {
+	if g.IsRecycleBin {
+		return
+	}
+	for _, e := range g.Entries {
+		var attachments []Attachment
+		for _, bin := range e.Binaries {
+			attachments = append(attachments, Attachment{Name: bin.Name, Data: bin.Data})
+		}
+		b.add(convertKeePassEntry(e), e.Get(kdbx.KeyTitle), path, attachments...)
+	}
+	for _, sub := range g.Groups {
+		name := strings.TrimSpace(sub.Name)
+		if path != "" {
+			name = path + "/" + name
+		}
+		collectKeePassGroup(sub, name, b)
+	}
+}
+
+func convertKeePassEntry(e *kdbx.Entry) *store.EntryFull {
+	entry := &store.EntryFull{
+		Title:      e.Get(kdbx.KeyTitle),
+		Username:   e.Get(kdbx.KeyUserName),
+		Password:   e.Get(kdbx.KeyPassword),
+		TotpSecret: keepassTOTPSecret(e),
+		CardNumber: e.Get(keepassCardNumber),
+		Expiry:     e.Get(keepassExpiry),
+		CVV:        e.Get(keepassCVV),
+		CustomText: e.Get(kdbx.KeyNotes),
+	}
+
+	entry.AddURI(e.Get(kdbx.KeyURL))
+
+	// Protected strings are the ones KeePass masks, so they become hidden
+	// fields.
+	var fields []store.CustomField
+	for _, s := range e.Strings {
+		switch s.Key {
+		case kdbx.KeyTitle, kdbx.KeyUserName, kdbx.KeyPassword, kdbx.KeyURL, kdbx.KeyNotes,
+			keepassOTP, keepassOTPSecret, keepassCardNumber, keepassExpiry, keepassCVV:
+			continue
+		}
+		if keepassURLKey(s.Key) {
+			entry.AddURI(s.Value)
+			continue
+		}
+		if s.Value != "" {
+			f := store.CustomField{Name: s.Key, Value: s.Value, Type: store.FieldText}
+			if s.Protected {
+				f.Type = store.FieldHidden
+			}
+			fields = append(fields, f)
+		}
+	}
+
+	switch {
+	case entry.CardNumber != "":
+		entry.Type = "Card"
+	case entry.Username != "" || entry.Password != "" || entry.Link() != "" || entry.TotpSecret != "":
+		entry.Type = "Login"
+		entry.History = keepassPasswordHistory(e)
+	case len(e.Binaries) > 0:
+		entry.Type = "File"
+	default:
+		entry.Type = "Note"
+	}
+	if entry.Type != "Card" {
+		entry.CardNumber, entry.Expiry, entry.CVV = "", "", ""
+		for _, key := range []string{keepassCardNumber, keepassExpiry, keepassCVV} {
+			if v := e.Get(key); v != "" {
+				fields = append(fields, store.CustomField{Name: key, Value: v, Type: store.FieldText})
+			}
+		}
+	}
+
+	entry.Fields = fields
+	return entry
+}
+
+// keepassTOTPSecret returns the base32 secret from KeePassXC's "otp"
+// otpauth:// URI or KeePass 2's TimeOtp-Secret-Base32 string.
+func keepassTOTPSecret(e *kdbx.Entry) string {
+	if v := e.Get(keepassOTP); v != "" {
+		return otpauthSecret(v)
+	}
+	return e.Get(keepassOTPSecret)
+}
+
+// keepassPasswordHistory turns KeePass history snapshots (oldest first)
+// into PassBook history items. A password is recorded when the following
+// snapshot, or the current entry, replaced it; the date is when that
+// happened.
+func keepassPasswordHistory(e *kdbx.Entry) []store.PasswordHistory {
+	var history []store.PasswordHistory
+	for i, h := range e.History {
+		password := h.Get(kdbx.KeyPassword)
+		next, changed := e.Modified, e.Get(kdbx.KeyPassword)
+		if i+1 < len(e.History) {
+			next, changed = e.History[i+1].Modified, e.History[i+1].Get(kdbx.KeyPassword)
+		}
+		if password == "" || password == changed {
+			continue
+		}
+		history = append(history, store.PasswordHistory{
+			Password: password,
+			Date:     next.Local().Format("2006-01-02 15:04"),
+		})
+	}
+	return history
+}
@@ -0,0 +1,156 @@
+package importer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"passbook/internal/kdbx"
+)
+
+func writeKeePassFile(t *testing.T, db *kdbx.Database, password string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	kdf := kdbx.KDFParams{Memory: 64 << 10, Iterations: 2, Parallelism: 2}
+	if err := kdbx.Write(&buf, db, password, kdf); err != nil {
+		t.Fatalf("kdbx.Write: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "vault.kdbx")
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return path
+}
+
+func keepassStrings(kv ...string) []kdbx.String {
+	var out []kdbx.String
+	for i := 0; i+1 < len(kv); i += 2 {
+		out = append(out, kdbx.String{Key: kv[i], Value: kv[i+1]})
+	}
+	return out
+}
+
+func TestImportKeePass(t *testing.T) {
+	password := "testpass"
+	dir, cfg := setupTestVault(t, password)
+
+	changed := time.Date(2024, 3, 1, 9, 30, 0, 0, time.Local)
+	db := &kdbx.Database{Root: &kdbx.Group{
+		Name: "Root",
+		Entries: []*kdbx.Entry{{
+			Strings: keepassStrings(kdbx.KeyTitle, "Wifi", kdbx.KeyNotes, "SSID: home"),
+		}},
+		Groups: []*kdbx.Group{
+			{Name: "Work", Groups: []*kdbx.Group{{
+				Name: "Servers",
+				Entries: []*kdbx.Entry{{
+					Strings: append(keepassStrings(
+						kdbx.KeyTitle, "db01",
+						kdbx.KeyUserName, "root",
+						kdbx.KeyPassword, "new",
+						kdbx.KeyURL, "ssh://db01",
+						"otp", "otpauth://totp/db01?secret=JBSWY3DPEHPK3PXP&period=30",
+						"Port", "5432",
+					), kdbx.String{Key: "PIN", Value: "0000", Protected: true}),
+					Binaries: []kdbx.Binary{{Name: "id_rsa", Data: []byte("key")}},
+					Modified: changed,
+					History: []*kdbx.Entry{{
+						Strings:  keepassStrings(kdbx.KeyTitle, "db01", kdbx.KeyPassword, "old"),
+						Modified: changed.Add(-time.Hour),
+					}},
+				}},
+			}}},
+			{Name: "Recycle Bin", IsRecycleBin: true, Entries: []*kdbx.Entry{{
+				Strings: keepassStrings(kdbx.KeyTitle, "Deleted", kdbx.KeyPassword, "x"),
+			}}},
+		},
+	}}
+	path := writeKeePassFile(t, db, "kdbxpass")
+
+	if err := ImportKeePass(path, "kdbxpass", password, cfg); err != nil {
+		t.Fatalf("ImportKeePass: %v", err)
+	}
+
+	s := openTestStore(t, dir, password)
+	entries, err := s.ListAllEntries()
+	if err != nil {
+		t.Fatalf("ListAllEntries: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries (recycle bin skipped), got %d", len(entries))
+	}
+
+	folder, err := s.GetFolderByPath("Work/Servers")
+	if err != nil || folder == nil {
+		t.Fatalf("expected folder %q, got %v %v", "Work/Servers", folder, err)
+	}
+	login := loadEntryFromStore(t, s, "db01")
+	if login.FolderID != folder.ID {
+		t.Fatalf("expected db01 in the Work/Servers folder")
+	}
+	if login.Type != "Login" || login.Username != "root" || login.Password != "new" || login.Link() != "ssh://db01" {
+		t.Fatalf("unexpected login: %+v", login)
+	}
+	if login.TotpSecret != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("expected TOTP secret from otpauth URI, got %q", login.TotpSecret)
+	}
+	if login.CustomText != "" || fieldLines(login.Fields) != "Port: 5432\nPIN: 0000 [hidden]" {
+		t.Fatalf("unexpected notes %q and fields %+v", login.CustomText, login.Fields)
+	}
+	if len(login.History) != 1 || login.History[0].Password != "old" ||
+		login.History[0].Date != changed.Format("2006-01-02 15:04") {
+		t.Fatalf("unexpected history: %+v", login.History)
+	}
+	if len(login.Attachments) != 1 || login.Attachments[0].FileName != "id_rsa" {
+		t.Fatalf("unexpected attachments: %+v", login.Attachments)
+	}
+	data, err := s.ReadAttachment(login.Attachments[0].ID)
+	if err != nil || string(data) != "key" {
+		t.Fatalf("unexpected attachment data %q: %v", data, err)
+	}
+
+	note := loadEntryFromStore(t, s, "Wifi")
+	if note.Type != "Note" || note.FolderID != 0 || note.CustomText != "SSID: home" {
+		t.Fatalf("unexpected note: %+v", note)
+	}
+}
+
+func TestImportKeePassCard(t *testing.T) {
+	password := "testpass"
+	dir, cfg := setupTestVault(t, password)
+
+	db := &kdbx.Database{Root: &kdbx.Group{
+		Name: "Root",
+		Entries: []*kdbx.Entry{{
+			Strings: keepassStrings(
+				kdbx.KeyTitle, "Visa",
+				"Card Number", "4111111111111111",
+				"Expiry", "12/30",
+				"CVV", "123",
+			),
+		}},
+	}}
+	path := writeKeePassFile(t, db, "kdbxpass")
+
+	if err := ImportKeePass(path, "kdbxpass", password, cfg); err != nil {
+		t.Fatalf("ImportKeePass: %v", err)
+	}
+
+	s := openTestStore(t, dir, password)
+	card := loadEntryFromStore(t, s, "Visa")
+	if card.Type != "Card" || card.CardNumber != "4111111111111111" || card.Expiry != "12/30" || card.CVV != "123" {
+		t.Fatalf("unexpected card: %+v", card)
+	}
+}
+
+func TestImportKeePassWrongPassword(t *testing.T) {
+	password := "testpass"
+	_, cfg := setupTestVault(t, password)
+
+	path := writeKeePassFile(t, &kdbx.Database{Root: &kdbx.Group{Name: "Root"}}, "kdbxpass")
+	if err := ImportKeePass(path, "wrong", password, cfg); err == nil {
+		t.Fatalf("expected an error for the wrong KeePass password")
+	}
+}
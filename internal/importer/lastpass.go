@@ -5,48 +5,58 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"passbook/internal/config"
 	"passbook/internal/store"
 )
 
-func ImportLastPass(csvPath, masterPassword string, cfg config.AppConfig) error {
-	f, err := os.Open(csvPath)
-	if err != nil {
-		return fmt.Errorf("opening file: %w", err)
-	}
-	defer func(f *os.File) {
-		err := f.Close()
-		if err != nil {
-			return
-		}
-	}(f)
+func init() {
+	Register(Source{Name: "lastpass", Ext: ".csv", Parse: parseLastPass})
+}
 
-	reader := csv.NewReader(f)
-	reader.LazyQuotes = true
+func ImportLastPass(csvPath, masterPassword string, cfg config.AppConfig) error {
+	return importFrom("lastpass", csvPath, "", masterPassword, cfg)
+}
 
-	records, err := reader.ReadAll()
+func parseLastPass(csvPath, _ string) (*Batch, error) {
+	records, err := readCSV(csvPath)
 	if err != nil {
-		return fmt.Errorf("parsing CSV: %w", err)
+		return nil, err
 	}
 
+	b := &Batch{}
 	if len(records) < 2 {
-		return nil
+		return b, nil
 	}
 
 	header := records[0]
 	colIndex := buildColumnIndex(header)
 
-	var entries []*store.EntryFull
-	var names []string
-
 	for _, row := range records[1:] {
-		entry := convertLastPassRow(row, colIndex)
-		entries = append(entries, entry)
-		names = append(names, colVal(row, colIndex, "name"))
+		b.add(convertLastPassRow(row, colIndex), colVal(row, colIndex, "name"), lastPassFolder(row, colIndex))
+	}
+	return b, nil
+}
+
+// readCSV reads every record of a CSV export. Quotes are parsed leniently
+// since password managers do not all escape them.
+func readCSV(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening file: %w", err)
 	}
+	defer f.Close()
 
-	return saveEntries(entries, names, masterPassword, cfg)
+	reader := csv.NewReader(f)
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parsing CSV: %w", err)
+	}
+	return records, nil
 }
 
 func buildColumnIndex(header []string) map[string]int {
@@ -78,11 +88,14 @@ func convertLastPassRow(row []string, colIndex map[string]int) *store.EntryFull
 	}
 
 	if isLastPassSecureNote(url, grouping) {
-		return &store.EntryFull{
-			Type:       "Note",
-			Title:      name,
-			CustomText: extra,
+		if strings.HasPrefix(extra, lastPassCardNoteType) {
+			card := convertLastPassCard(extra)
+			card.Title = name
+			return card
 		}
+		entry := &store.EntryFull{Type: "Note", Title: name}
+		entry.CustomText, entry.Fields = store.FieldsFromNotes(extra)
+		return entry
 	}
 
 	entry := &store.EntryFull{
@@ -90,9 +103,20 @@ func convertLastPassRow(row []string, colIndex map[string]int) *store.EntryFull
 		Title:      name,
 		Username:   username,
 		Password:   password,
-		Link:       url,
 		TotpSecret: totpVal,
-		CustomText: extra,
+	}
+	entry.AddURI(url)
+	// LastPass has no custom fields on logins; PassBook's own LastPass
+	// export keeps them as a block at the end of the notes, together with
+	// the URIs after the first as "URL" fields.
+	var fields []store.CustomField
+	entry.CustomText, fields = store.FieldsFromNotes(extra)
+	for _, f := range fields {
+		if f.Name == lastPassURLField {
+			entry.AddURI(f.Value)
+		} else {
+			entry.Fields = append(entry.Fields, f)
+		}
 	}
 
 	if name == "" && url != "" {
@@ -102,6 +126,77 @@ func convertLastPassRow(row []string, colIndex map[string]int) *store.EntryFull
 	return entry
 }
 
+// lastPassURLField names the fields PassBook's LastPass export keeps extra
+// URIs in.
+const lastPassURLField = "URL"
+
+// lastPassCardNoteType starts the "extra" column of secure notes that
+// LastPass stores as payment cards.
+const lastPassCardNoteType = "NoteType:Credit Card"
+
+// convertLastPassCard parses a LastPass credit card note: "Key:Value" lines
+// followed by a free-form "Notes:" section that runs to the end.
+func convertLastPassCard(extra string) *store.EntryFull {
+	entry := &store.EntryFull{Type: "Card"}
+	var holder string
+	var fields [][2]string
+
+	lines := strings.Split(extra, "\n")
+	for i, line := range lines {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		if key == "Notes" {
+			entry.CustomText = strings.Join(append([]string{value}, lines[i+1:]...), "\n")
+			break
+		}
+		if value == "" || value == "," {
+			continue
+		}
+		switch key {
+		case "NoteType", "Language":
+		case "Name on Card":
+			holder = value
+		case "Number":
+			entry.CardNumber = value
+		case "Security Code":
+			entry.CVV = value
+		case "Expiration Date":
+			entry.Expiry = parseLastPassExpiry(value)
+		default:
+			fields = append(fields, [2]string{key, value})
+		}
+	}
+
+	if holder != "" {
+		entry.CustomText = appendNotes(entry.CustomText, "Cardholder: "+holder)
+	}
+	entry.Fields = textFields(fields)
+	return entry
+}
+
+// parseLastPassExpiry converts LastPass's "January,2030" to MM/YY. Values in
+// any other format are kept as they are.
+func parseLastPassExpiry(value string) string {
+	t, err := time.Parse("January,2006", value)
+	if err != nil {
+		return value
+	}
+	return t.Format("01/06")
+}
+
+// lastPassFolder returns the grouping column as a folder path. LastPass
+// separates subfolders with a backslash. Its "Secure Notes" grouping marks
+// the entry type rather than a folder.
+func lastPassFolder(row []string, colIndex map[string]int) string {
+	grouping := colVal(row, colIndex, "grouping")
+	if strings.EqualFold(grouping, "Secure Notes") {
+		return ""
+	}
+	return strings.ReplaceAll(grouping, "\\", "/")
+}
+
 func isLastPassSecureNote(url, grouping string) bool {
 	if url == "http://sn" {
 		return true
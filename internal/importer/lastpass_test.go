@@ -42,8 +42,8 @@ https://github.com,user@example.com,secret123,JBSWY3DPEHPK3PXP,My GitHub account
 	if entry.TotpSecret != "JBSWY3DPEHPK3PXP" {
 		t.Fatalf("expected TOTP, got %s", entry.TotpSecret)
 	}
-	if entry.Link != "https://github.com" {
-		t.Fatalf("expected link, got %s", entry.Link)
+	if entry.Link() != "https://github.com" {
+		t.Fatalf("expected link, got %s", entry.Link())
 	}
 	if entry.CustomText != "My GitHub account" {
 		t.Fatalf("expected notes, got %s", entry.CustomText)
@@ -158,3 +158,70 @@ https://example.com,user,pass,,,,, 0
 		t.Fatalf("expected 1 entry, got %d", len(entries))
 	}
 }
+
+func TestImportLastPassCreditCard(t *testing.T) {
+	password := "testpass"
+	dir, cfg := setupTestVault(t, password)
+
+	csv := `url,username,password,totp,extra,name,grouping,fav
+http://sn,,,,"NoteType:Credit Card
+Language:en-US
+Name on Card:Jane Doe
+Type:Visa
+Number:4111111111111111
+Security Code:123
+Start Date:,
+Expiration Date:December,2030
+Notes:Backup card
+second line",Visa,,0
+`
+	csvPath := writeLastPassCSV(t, csv)
+
+	if err := ImportLastPass(csvPath, password, cfg); err != nil {
+		t.Fatalf("ImportLastPass: %v", err)
+	}
+
+	s := openTestStore(t, dir, password)
+	entry := loadEntryFromStore(t, s, "Visa")
+	if entry.Type != "Card" {
+		t.Fatalf("expected Card type, got %s", entry.Type)
+	}
+	if entry.CardNumber != "4111111111111111" || entry.CVV != "123" {
+		t.Fatalf("unexpected card fields: %+v", entry)
+	}
+	if entry.Expiry != "12/30" {
+		t.Fatalf("expected expiry 12/30, got %s", entry.Expiry)
+	}
+	want := "Backup card\nsecond line\n\nCardholder: Jane Doe"
+	if entry.CustomText != want {
+		t.Fatalf("unexpected notes:\n%q\nwant\n%q", entry.CustomText, want)
+	}
+	if got := fieldLines(entry.Fields); got != "Type: Visa" {
+		t.Fatalf("unexpected fields %q", got)
+	}
+}
+
+func TestImportLastPassGrouping(t *testing.T) {
+	password := "testpass"
+	dir, cfg := setupTestVault(t, password)
+
+	csv := `url,username,password,totp,extra,name,grouping,fav
+https://a.com,u1,p1,,,Site A,Work\Servers,0
+http://sn,,,,text,Note,Secure Notes,0
+`
+	if err := ImportLastPass(writeLastPassCSV(t, csv), password, cfg); err != nil {
+		t.Fatalf("ImportLastPass: %v", err)
+	}
+
+	s := openTestStore(t, dir, password)
+	folder, err := s.GetFolderByPath("Work/Servers")
+	if err != nil || folder == nil {
+		t.Fatalf("expected folder from grouping: %v", err)
+	}
+	if e := loadEntryFromStore(t, s, "Site A"); e.FolderID != folder.ID {
+		t.Fatalf("expected Site A in Work/Servers")
+	}
+	if e := loadEntryFromStore(t, s, "Note"); e.FolderID != 0 {
+		t.Fatalf("expected the Secure Notes grouping to stay at the top level")
+	}
+}
@@ -18,6 +18,9 @@ type onePasswordAccount struct {
 }
 
 type onePasswordVault struct {
+	Attrs struct {
+		Name string `json:"name"`
+	} `json:"attrs"`
 	Items []onePasswordItem `json:"items"`
 }
 
@@ -47,31 +50,34 @@ type onePasswordSection struct {
 	Fields []onePasswordField `json:"fields"`
 }
 
+func init() {
+	Register(Source{Name: "1password", Ext: ".1pux", Parse: parse1Password})
+}
+
 func Import1Password(jsonPath, masterPassword string, cfg config.AppConfig) error {
+	return importFrom("1password", jsonPath, "", masterPassword, cfg)
+}
+
+func parse1Password(jsonPath, _ string) (*Batch, error) {
 	data, err := os.ReadFile(jsonPath)
 	if err != nil {
-		return fmt.Errorf("reading file: %w", err)
+		return nil, fmt.Errorf("reading file: %w", err)
 	}
 
 	var export onePasswordExport
 	if err := json.Unmarshal(data, &export); err != nil {
-		return fmt.Errorf("parsing JSON: %w", err)
+		return nil, fmt.Errorf("parsing JSON: %w", err)
 	}
 
-	var entries []*store.EntryFull
-	var names []string
-
+	b := &Batch{}
 	for _, acct := range export.Accounts {
 		for _, vault := range acct.Vaults {
 			for _, item := range vault.Items {
-				entry := convert1PasswordItem(item)
-				entries = append(entries, entry)
-				names = append(names, item.Title)
+				b.add(convert1PasswordItem(item), item.Title, vault.Attrs.Name)
 			}
 		}
 	}
-
-	return saveEntries(entries, names, masterPassword, cfg)
+	return b, nil
 }
 
 func convert1PasswordItem(item onePasswordItem) *store.EntryFull {
@@ -100,12 +106,11 @@ func convert1PasswordItem(item onePasswordItem) *store.EntryFull {
 			}
 		}
 
-		if len(item.URLs) > 0 {
-			entry.Link = item.URLs[0].URL
+		for _, u := range item.URLs {
+			entry.AddURI(u.URL)
 		}
 
-		extra := collect1PasswordExtraFields(item)
-		entry.CustomText = appendNotes(entry.CustomText, formatCustomFields(extra))
+		entry.Fields = collect1PasswordExtraFields(item)
 		return entry
 
 	case "002": // Credit Card
@@ -154,8 +159,7 @@ func convert1PasswordItem(item onePasswordItem) *store.EntryFull {
 			entry.CustomText = appendNotes(entry.CustomText, "Cardholder: "+cardholder)
 		}
 
-		extra := collect1PasswordExtraFields(item)
-		entry.CustomText = appendNotes(entry.CustomText, formatCustomFields(extra))
+		entry.Fields = collect1PasswordExtraFields(item)
 		return entry
 
 	case "003": // Secure Note
@@ -165,8 +169,7 @@ func convert1PasswordItem(item onePasswordItem) *store.EntryFull {
 			CustomText: item.Notes,
 		}
 
-		extra := collect1PasswordExtraFields(item)
-		entry.CustomText = appendNotes(entry.CustomText, formatCustomFields(extra))
+		entry.Fields = collect1PasswordExtraFields(item)
 		return entry
 
 	case "006": // Document
@@ -176,8 +179,7 @@ func convert1PasswordItem(item onePasswordItem) *store.EntryFull {
 			CustomText: item.Notes,
 		}
 
-		extra := collect1PasswordExtraFields(item)
-		entry.CustomText = appendNotes(entry.CustomText, formatCustomFields(extra))
+		entry.Fields = collect1PasswordExtraFields(item)
 		return entry
 
 	default:
@@ -189,19 +191,31 @@ func convert1PasswordItem(item onePasswordItem) *store.EntryFull {
 			Title:      item.Title,
 			CustomText: item.Notes,
 		}
-		extra := collect1PasswordExtraFields(item)
-		entry.CustomText = appendNotes(entry.CustomText, formatCustomFields(extra))
+		entry.Fields = collect1PasswordExtraFields(item)
 		return entry
 	}
 }
 
-func collect1PasswordExtraFields(item onePasswordItem) [][2]string {
+// onePasswordFieldTypes maps 1Password field types to PassBook's. Types not
+// listed are kept as text.
+var onePasswordFieldTypes = map[string]store.FieldType{
+	"concealed": store.FieldHidden,
+	"C":         store.FieldHidden,
+	"URL":       store.FieldURL,
+	"url":       store.FieldURL,
+	"email":     store.FieldEmail,
+	"phone":     store.FieldPhone,
+	"date":      store.FieldDate,
+	"monthYear": store.FieldDate,
+}
+
+func collect1PasswordExtraFields(item onePasswordItem) []store.CustomField {
 	skip := map[string]bool{
 		"username": true, "password": true,
 		"ccnum": true, "cvv": true, "expiry": true, "cardholder": true,
 	}
 
-	var fields [][2]string
+	var fields []store.CustomField
 	for _, sec := range item.Sections {
 		for _, f := range sec.Fields {
 			if skip[f.Name] || skip[f.Designation] {
@@ -217,7 +231,11 @@ func collect1PasswordExtraFields(item onePasswordItem) [][2]string {
 			if label == "" {
 				label = f.ID
 			}
-			fields = append(fields, [2]string{label, f.Value})
+			fieldType, ok := onePasswordFieldTypes[f.Type]
+			if !ok {
+				fieldType = store.FieldText
+			}
+			fields = append(fields, store.CustomField{Name: label, Value: f.Value, Type: fieldType})
 		}
 	}
 	return fields
@@ -68,8 +68,8 @@ func TestImport1PasswordLogin(t *testing.T) {
 	if entry.TotpSecret != "JBSWY3DPEHPK3PXP" {
 		t.Fatalf("expected TOTP, got %s", entry.TotpSecret)
 	}
-	if entry.Link != "https://github.com" {
-		t.Fatalf("expected link, got %s", entry.Link)
+	if entry.Link() != "https://github.com" {
+		t.Fatalf("expected link, got %s", entry.Link())
 	}
 	if entry.CustomText != "My GitHub account" {
 		t.Fatalf("expected notes, got %s", entry.CustomText)
@@ -157,6 +157,8 @@ func TestImport1PasswordExtraFields(t *testing.T) {
 			Sections: []onePasswordSection{
 				{Fields: []onePasswordField{
 					{Name: "API Key", Value: "abc123"},
+					{Name: "Recovery", Value: "xyz", Type: "concealed"},
+					{Name: "Support", Value: "https://help.example.com", Type: "URL"},
 				}},
 			},
 		},
@@ -168,8 +170,9 @@ func TestImport1PasswordExtraFields(t *testing.T) {
 
 	s := openTestStore(t, dir, password)
 	entry := loadEntryFromStore(t, s, "WithExtra")
-	if !strings.Contains(entry.CustomText, "API Key: abc123") {
-		t.Fatalf("expected extra field in notes, got %q", entry.CustomText)
+	want := "API Key: abc123\nRecovery: xyz [hidden]\nSupport: https://help.example.com [url]"
+	if got := fieldLines(entry.Fields); got != want {
+		t.Fatalf("unexpected fields:\n%s\nwant\n%s", got, want)
 	}
 }
 
@@ -195,3 +198,17 @@ func TestImport1PasswordUnknownCategoryAsNote(t *testing.T) {
 		t.Fatalf("expected Note type for unknown category, got %s", entry.Type)
 	}
 }
+
+func TestParse1PasswordVaultFolders(t *testing.T) {
+	path := writeTestFile(t, "export.1pux", `{"accounts": [{"vaults": [
+  {"attrs": {"name": "Private"}, "items": [{"title": "A", "categoryUuid": "003"}]},
+  {"attrs": {"name": "Shared"}, "items": [{"title": "B", "categoryUuid": "003"}]}
+]}]}`)
+	b, err := parse1Password(path, "")
+	if err != nil {
+		t.Fatalf("parse1Password: %v", err)
+	}
+	if len(b.Folders) != 2 || b.Folders[0] != "Private" || b.Folders[1] != "Shared" {
+		t.Fatalf("expected vault names as folders, got %v", b.Folders)
+	}
+}
@@ -0,0 +1,112 @@
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"passbook/internal/store"
+)
+
+// pass(1) keeps one gpg-encrypted file per entry under ~/.password-store.
+// The first line is the password; the lines after it are free-form, with
+// "key: value" lines and an otpauth:// URI by convention.
+func init() {
+	Register(Source{Name: "pass", Parse: parsePasswordStore})
+}
+
+// gpgCommand decrypts a file to stdout. It relies on the user's gpg-agent
+// for the passphrase. Tests replace it.
+var gpgCommand = func(path string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("gpg", "--quiet", "--yes", "--decrypt", path)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// passUsernameKeys and passURLKeys are the keys commonly used for the
+// username and the site in pass entries (browserpass, passff).
+var (
+	passUsernameKeys = map[string]bool{"login": true, "username": true, "user": true, "email": true}
+	passURLKeys      = map[string]bool{"url": true, "link": true, "website": true, "site": true}
+)
+
+func parsePasswordStore(dir, _ string) (*Batch, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && strings.HasPrefix(d.Name(), ".") && path != dir {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && strings.HasSuffix(d.Name(), ".gpg") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading password store: %w", err)
+	}
+
+	b := &Batch{}
+	for _, path := range files {
+		rel, _ := filepath.Rel(dir, path)
+		name := strings.TrimSuffix(filepath.Base(rel), ".gpg")
+		folder := filepath.ToSlash(filepath.Dir(rel))
+		if folder == "." {
+			folder = ""
+		}
+
+		plain, err := gpgCommand(path)
+		if err != nil {
+			fmt.Printf("  ⚠ skipping %q: gpg: %v\n", filepath.ToSlash(rel), err)
+			b.add(nil, name, folder)
+			continue
+		}
+		b.add(convertPassEntry(name, string(plain)), name, folder)
+	}
+	return b, nil
+}
+
+func convertPassEntry(name, content string) *store.EntryFull {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	password, rest, _ := strings.Cut(content, "\n")
+	entry := &store.EntryFull{Type: "Login", Title: name, Password: password}
+
+	var notes []string
+	var fields [][2]string
+	for _, line := range strings.Split(strings.TrimRight(rest, "\n"), "\n") {
+		if strings.HasPrefix(line, "otpauth://") && entry.TotpSecret == "" {
+			entry.TotpSecret = otpauthSecret(line)
+			continue
+		}
+		key, value, ok := strings.Cut(line, ": ")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		lower := strings.ToLower(key)
+		switch {
+		case !ok || key == "":
+			notes = append(notes, line)
+		case passUsernameKeys[lower] && entry.Username == "":
+			entry.Username = value
+		case passURLKeys[lower]:
+			entry.AddURI(value)
+		default:
+			fields = append(fields, [2]string{key, value})
+		}
+	}
+
+	entry.CustomText = strings.TrimSpace(strings.Join(notes, "\n"))
+	entry.Fields = textFields(fields)
+	return entry
+}
@@ -0,0 +1,75 @@
+package importer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeGPG treats files as plaintext, or fails for files containing "FAIL".
+func fakeGPG(t *testing.T) {
+	t.Helper()
+	orig := gpgCommand
+	gpgCommand = func(path string) ([]byte, error) {
+		data, err := os.ReadFile(path)
+		if err == nil && strings.Contains(string(data), "FAIL") {
+			return nil, errors.New("decryption failed")
+		}
+		return data, err
+	}
+	t.Cleanup(func() { gpgCommand = orig })
+}
+
+func writePassEntry(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name+".gpg")
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func TestImportPasswordStore(t *testing.T) {
+	fakeGPG(t)
+	password := "testpass"
+	dir, cfg := setupTestVault(t, password)
+
+	storeDir := t.TempDir()
+	writePassEntry(t, storeDir, "Email/github.com", "s3cret\nlogin: octocat\nurl: https://github.com\notpauth://totp/gh?secret=JBSWY3DPEHPK3PXP\nRecovery: abc\nfree text line\n")
+	writePassEntry(t, storeDir, "wifi", "hunter2\n")
+	writePassEntry(t, storeDir, "broken", "FAIL")
+	writePassEntry(t, storeDir, ".git/objects/x", "ignored")
+
+	src, _ := Lookup("pass")
+	if err := Import(src, storeDir, "", password, Options{}, cfg); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	s := openTestStore(t, dir, password)
+	entries, err := s.ListAllEntries()
+	if err != nil {
+		t.Fatalf("ListAllEntries: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	gh := loadEntryFromStore(t, s, "github.com")
+	folder, err := s.GetFolderByPath("Email")
+	if err != nil || folder == nil || gh.FolderID != folder.ID {
+		t.Fatalf("expected github.com in folder Email")
+	}
+	if gh.Password != "s3cret" || gh.Username != "octocat" || gh.Link() != "https://github.com" || gh.TotpSecret != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("unexpected entry: %+v", gh)
+	}
+	if gh.CustomText != "free text line" || fieldLines(gh.Fields) != "Recovery: abc" {
+		t.Fatalf("unexpected notes %q and fields %+v", gh.CustomText, gh.Fields)
+	}
+	if wifi := loadEntryFromStore(t, s, "wifi"); wifi.Password != "hunter2" || wifi.FolderID != 0 {
+		t.Fatalf("unexpected entry: %+v", wifi)
+	}
+}
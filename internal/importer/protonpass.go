@@ -0,0 +1,239 @@
+package importer
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"passbook/internal/store"
+)
+
+// Proton Pass exports a ZIP holding "Proton Pass/data.json". Vaults become
+// folders.
+func init() {
+	Register(Source{Name: "protonpass", Ext: ".zip", Parse: parseProtonPass})
+}
+
+type protonExport struct {
+	Encrypted bool                   `json:"encrypted"`
+	Vaults    map[string]protonVault `json:"vaults"`
+}
+
+type protonVault struct {
+	Name  string       `json:"name"`
+	Items []protonItem `json:"items"`
+}
+
+type protonItem struct {
+	Data       protonItemData `json:"data"`
+	State      int            `json:"state"`
+	AliasEmail string         `json:"aliasEmail"`
+}
+
+type protonItemData struct {
+	Metadata struct {
+		Name string `json:"name"`
+		Note string `json:"note"`
+	} `json:"metadata"`
+	ExtraFields []protonExtraField `json:"extraFields"`
+	Type        string             `json:"type"`
+	Content     json.RawMessage    `json:"content"`
+}
+
+type protonExtraField struct {
+	FieldName string `json:"fieldName"`
+	Type      string `json:"type"`
+	Data      struct {
+		Content string `json:"content"`
+		TotpURI string `json:"totpUri"`
+	} `json:"data"`
+}
+
+type protonLogin struct {
+	ItemEmail    string   `json:"itemEmail"`
+	ItemUsername string   `json:"itemUsername"`
+	Username     string   `json:"username"`
+	Password     string   `json:"password"`
+	URLs         []string `json:"urls"`
+	TotpURI      string   `json:"totpUri"`
+}
+
+type protonCard struct {
+	CardholderName     string `json:"cardholderName"`
+	Number             string `json:"number"`
+	VerificationNumber string `json:"verificationNumber"`
+	ExpirationDate     string `json:"expirationDate"`
+	PIN                string `json:"pin"`
+}
+
+// protonTrashed is the item state of entries in the trash.
+const protonTrashed = 2
+
+func parseProtonPass(exportPath, _ string) (*Batch, error) {
+	data, err := readProtonData(exportPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var export protonExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("parsing JSON: %w", err)
+	}
+	if export.Encrypted {
+		return nil, fmt.Errorf("encrypted Proton Pass exports are not supported; export without a PGP passphrase")
+	}
+
+	vaults := make([]protonVault, 0, len(export.Vaults))
+	for _, v := range export.Vaults {
+		vaults = append(vaults, v)
+	}
+	sort.Slice(vaults, func(i, j int) bool { return vaults[i].Name < vaults[j].Name })
+
+	b := &Batch{}
+	for _, v := range vaults {
+		for _, item := range v.Items {
+			entry := convertProtonItem(item)
+			if item.State == protonTrashed {
+				entry = nil
+			}
+			b.add(entry, item.Data.Metadata.Name, strings.TrimSpace(v.Name))
+		}
+	}
+	return b, nil
+}
+
+// readProtonData returns data.json from a Proton Pass ZIP, or the file
+// itself when it was already extracted.
+func readProtonData(exportPath string) ([]byte, error) {
+	if strings.EqualFold(filepath.Ext(exportPath), ".json") {
+		data, err := os.ReadFile(exportPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading file: %w", err)
+		}
+		return data, nil
+	}
+
+	zr, err := zip.OpenReader(exportPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening archive: %w", err)
+	}
+	defer zr.Close()
+	for _, zf := range zr.File {
+		if path.Base(zf.Name) != "data.json" {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", zf.Name, err)
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	return nil, fmt.Errorf("opening archive: no data.json found")
+}
+
+func convertProtonItem(item protonItem) *store.EntryFull {
+	d := item.Data
+	entry := &store.EntryFull{Title: d.Metadata.Name, CustomText: d.Metadata.Note}
+	var fields [][2]string
+
+	switch d.Type {
+	case "login":
+		var login protonLogin
+		if err := json.Unmarshal(d.Content, &login); err != nil {
+			return nil
+		}
+		entry.Type = "Login"
+		entry.Username = login.ItemUsername
+		if entry.Username == "" {
+			entry.Username = login.Username
+		}
+		if entry.Username == "" {
+			entry.Username = login.ItemEmail
+		} else if login.ItemEmail != "" {
+			fields = append(fields, [2]string{"Email", login.ItemEmail})
+		}
+		entry.Password = login.Password
+		entry.TotpSecret = otpauthSecret(login.TotpURI)
+		for _, u := range login.URLs {
+			entry.AddURI(u)
+		}
+
+	case "creditCard":
+		var card protonCard
+		if err := json.Unmarshal(d.Content, &card); err != nil {
+			return nil
+		}
+		entry.Type = "Card"
+		entry.CardNumber = card.Number
+		entry.CVV = card.VerificationNumber
+		if year, month, ok := strings.Cut(card.ExpirationDate, "-"); ok {
+			entry.Expiry = formatExpiry(month, year)
+		} else {
+			entry.Expiry = card.ExpirationDate
+		}
+		if card.CardholderName != "" {
+			entry.CustomText = appendNotes(entry.CustomText, "Cardholder: "+card.CardholderName)
+		}
+		if card.PIN != "" {
+			fields = append(fields, [2]string{"PIN", card.PIN})
+		}
+
+	case "alias":
+		entry.Type = "Note"
+		if item.AliasEmail != "" {
+			fields = append(fields, [2]string{"Alias", item.AliasEmail})
+		}
+
+	default:
+		// Notes, identities and any newer item types. Identity details are
+		// flat string fields and are kept as custom fields.
+		entry.Type = "Note"
+		fields = append(fields, protonStringFields(d.Content)...)
+	}
+
+	for _, f := range d.ExtraFields {
+		value := f.Data.Content
+		if f.Type == "totp" {
+			value = f.Data.TotpURI
+			if entry.Type == "Login" && entry.TotpSecret == "" {
+				entry.TotpSecret = otpauthSecret(value)
+				continue
+			}
+		}
+		if value != "" {
+			fields = append(fields, [2]string{f.FieldName, value})
+		}
+	}
+
+	entry.Fields = textFields(fields)
+	return entry
+}
+
+// protonStringFields returns the non-empty top-level string values of an
+// item's content, sorted by key.
+func protonStringFields(content json.RawMessage) [][2]string {
+	var m map[string]any
+	if json.Unmarshal(content, &m) != nil {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k, v := range m {
+		if s, ok := v.(string); ok && s != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	fields := make([][2]string, 0, len(keys))
+	for _, k := range keys {
+		fields = append(fields, [2]string{k, m[k].(string)})
+	}
+	return fields
+}
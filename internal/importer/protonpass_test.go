@@ -0,0 +1,87 @@
+package importer
+
+import "testing"
+
+const protonExportJSON = `{
+  "version": "1.21.0",
+  "encrypted": false,
+  "vaults": {
+    "share1": {
+      "name": "Personal",
+      "items": [
+        {
+          "state": 1,
+          "data": {
+            "metadata": {"name": "GitHub", "note": "main account"},
+            "extraFields": [{"fieldName": "PIN", "type": "hidden", "data": {"content": "0000"}}],
+            "type": "login",
+            "content": {
+              "itemEmail": "a@example.com",
+              "itemUsername": "octocat",
+              "password": "pw",
+              "urls": ["https://github.com", "https://gist.github.com"],
+              "totpUri": "otpauth://totp/GitHub?secret=JBSWY3DPEHPK3PXP"
+            }
+          }
+        },
+        {
+          "state": 1,
+          "data": {
+            "metadata": {"name": "Visa", "note": ""},
+            "type": "creditCard",
+            "content": {"cardholderName": "Jane Doe", "number": "4111111111111111", "verificationNumber": "123", "expirationDate": "2030-05", "pin": ""}
+          }
+        },
+        {
+          "state": 2,
+          "data": {"metadata": {"name": "Old"}, "type": "note", "content": {}}
+        },
+        {
+          "state": 1,
+          "aliasEmail": "x.y@passmail.net",
+          "data": {"metadata": {"name": "Shop alias", "note": ""}, "type": "alias", "content": {}}
+        }
+      ]
+    }
+  }
+}`
+
+func TestParseProtonPass(t *testing.T) {
+	path := writeZip(t, "Proton Pass_export.zip", map[string]string{"Proton Pass/data.json": protonExportJSON})
+	b, err := parseProtonPass(path, "")
+	if err != nil {
+		t.Fatalf("parseProtonPass: %v", err)
+	}
+	if len(b.Entries) != 4 || b.Entries[2] != nil {
+		t.Fatalf("expected 4 items with the trashed one skipped, got %+v", b.Entries)
+	}
+	if b.Folders[0] != "Personal" {
+		t.Fatalf("expected vault name as folder, got %q", b.Folders[0])
+	}
+
+	gh := b.Entries[0]
+	if gh.Type != "Login" || gh.Username != "octocat" || gh.Password != "pw" || gh.Link() != "https://github.com" ||
+		gh.TotpSecret != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("unexpected login: %+v", gh)
+	}
+	if len(gh.URIs) != 2 || gh.URIs[1].URI != "https://gist.github.com" {
+		t.Fatalf("expected every URL to be kept, got %+v", gh.URIs)
+	}
+	want := "Email: a@example.com\nPIN: 0000"
+	if gh.CustomText != "main account" || fieldLines(gh.Fields) != want {
+		t.Fatalf("unexpected notes %q and fields:\n%s\nwant\n%s", gh.CustomText, fieldLines(gh.Fields), want)
+	}
+	if visa := b.Entries[1]; visa.Type != "Card" || visa.Expiry != "05/30" || visa.CustomText != "Cardholder: Jane Doe" {
+		t.Fatalf("unexpected card: %+v", visa)
+	}
+	if alias := b.Entries[3]; alias.Type != "Note" || fieldLines(alias.Fields) != "Alias: x.y@passmail.net" {
+		t.Fatalf("unexpected alias: %+v", alias)
+	}
+}
+
+func TestParseProtonPassEncrypted(t *testing.T) {
+	path := writeTestFile(t, "data.json", `{"encrypted": true, "vaults": {}}`)
+	if _, err := parseProtonPass(path, ""); err == nil {
+		t.Fatalf("expected an error for an encrypted export")
+	}
+}
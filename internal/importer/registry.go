@@ -0,0 +1,117 @@
+package importer
+
+import (
+	"fmt"
+	"sort"
+
+	"passbook/internal/config"
+	"passbook/internal/store"
+)
+
+// Source is an external password manager PassBook can import from. Each
+// importer registers its Source from an init function.
+type Source struct {
+	// Name selects the source on the command line (--import <name>).
+	Name string
+	// Ext is the extension of the export file, shown in usage messages.
+	// It is empty for sources that read a directory.
+	Ext string
+	// PasswordPrompt is set for sources whose exports are encrypted; the
+	// CLI asks for that password and passes it to Parse.
+	PasswordPrompt string
+	// Parse reads the export at path.
+	Parse func(path, password string) (*Batch, error)
+}
+
+// Batch is the result of parsing an export. Names, Folders and Attachments
+// run parallel to Entries. A nil entry is an item that could not be
+// converted and is counted as skipped.
+type Batch struct {
+	Entries []*store.EntryFull
+	// Names are the item names as they appear in the export, for messages.
+	Names []string
+	// Folders are the entries' folders in the export, as "/"-separated
+	// paths; "" is the top level.
+	Folders     []string
+	Attachments [][]Attachment
+}
+
+// Attachment is a file attached to an imported entry.
+type Attachment struct {
+	Name string
+	Data []byte
+}
+
+func (b *Batch) add(entry *store.EntryFull, name, folder string, attachments ...Attachment) {
+	b.Entries = append(b.Entries, entry)
+	b.Names = append(b.Names, name)
+	b.Folders = append(b.Folders, folder)
+	b.Attachments = append(b.Attachments, attachments)
+}
+
+// Options control how an import is saved.
+type Options struct {
+	// Into is a folder that the whole import is placed under. The
+	// export's own folders are kept below it.
+	Into string
+	// DryRun prints what would be created, updated and skipped without
+	// writing anything.
+	DryRun bool
+	// OnConflict handles entries that are already in the vault. The zero
+	// value is ConflictSkip.
+	OnConflict Conflict
+}
+
+var sources = make(map[string]Source)
+
+// Register makes a source available to Lookup. It panics if the name is
+// already taken.
+func Register(s Source) {
+	if _, dup := sources[s.Name]; dup {
+		panic("importer: source registered twice: " + s.Name)
+	}
+	sources[s.Name] = s
+}
+
+// Lookup returns the source registered under name.
+func Lookup(name string) (Source, bool) {
+	s, ok := sources[name]
+	return s, ok
+}
+
+// Names returns the names of all registered sources, sorted.
+func Names() []string {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Import parses path with src and saves the entries into the vault.
+// password is only used by sources with a PasswordPrompt.
+func Import(src Source, path, password, masterPassword string, opts Options, cfg config.AppConfig) error {
+	b, err := src.Parse(path, password)
+	if err != nil {
+		return err
+	}
+	return saveEntries(b, masterPassword, opts, cfg)
+}
+
+// ImportInto is Import for a vault the caller has already unlocked.
+func ImportInto(src Source, path, password string, s *store.Store, opts Options) error {
+	b, err := src.Parse(path, password)
+	if err != nil {
+		return err
+	}
+	return saveInto(b, s, opts)
+}
+
+func importFrom(name, path, password, masterPassword string, cfg config.AppConfig) error {
+	src, ok := Lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown import source %q", name)
+	}
+	return Import(src, path, password, masterPassword, Options{}, cfg)
+}
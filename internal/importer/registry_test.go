@@ -0,0 +1,93 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"passbook/internal/store"
+)
+
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return path
+}
+
+func TestRegistry(t *testing.T) {
+	for _, name := range []string{"bitwarden", "1password", "lastpass", "keepass", "chrome", "edge", "firefox", "dashlane", "protonpass", "pass"} {
+		src, ok := Lookup(name)
+		if !ok || src.Name != name || src.Parse == nil {
+			t.Fatalf("source %q not registered", name)
+		}
+	}
+	if src, _ := Lookup("keepass"); src.PasswordPrompt == "" {
+		t.Fatalf("keepass should ask for the database password")
+	}
+	names := Names()
+	for i := 1; i < len(names); i++ {
+		if names[i-1] >= names[i] {
+			t.Fatalf("Names not sorted: %v", names)
+		}
+	}
+	if _, ok := Lookup("nope"); ok {
+		t.Fatalf("unexpected source")
+	}
+}
+
+func TestRegisterDuplicatePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic on duplicate registration")
+		}
+	}()
+	Register(Source{Name: "bitwarden"})
+}
+
+func TestFormatExpiry(t *testing.T) {
+	tests := []struct{ month, year, want string }{
+		{"5", "2030", "05/30"},
+		{"12", "30", "12/30"},
+		{"", "2030", "30"},
+		{"07", "", "07"},
+	}
+	for _, tt := range tests {
+		if got := formatExpiry(tt.month, tt.year); got != tt.want {
+			t.Fatalf("formatExpiry(%q, %q) = %q, want %q", tt.month, tt.year, got, tt.want)
+		}
+	}
+}
+
+func TestFolderPath(t *testing.T) {
+	tests := []struct{ into, path, want string }{
+		{"", "", ""},
+		{"", "Work", "Work"},
+		{"", "Work/Servers", "Work/Servers"},
+		{"Imported", "", "Imported"},
+		{"Imported", "Work/Servers", "Imported/Work/Servers"},
+		{"", " a:b* / .hidden /", "ab/hidden"},
+	}
+	for _, tt := range tests {
+		if got := folderPath(tt.into, tt.path); got != tt.want {
+			t.Fatalf("folderPath(%q, %q) = %q, want %q", tt.into, tt.path, got, tt.want)
+		}
+	}
+}
+
+// fieldLines renders custom fields as "name: value" lines, with the type
+// in brackets when it is not text, so tests can compare them as a string.
+func fieldLines(fields []store.CustomField) string {
+	var lines []string
+	for _, f := range fields {
+		line := f.Name + ": " + f.Value
+		if f.Type != store.FieldText {
+			line += " [" + string(f.Type) + "]"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
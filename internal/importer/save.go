@@ -0,0 +1,399 @@
+package importer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"passbook/internal/config"
+	"passbook/internal/store"
+)
+
+// Conflict says what an import does with an entry that is already in the
+// vault: one in the same folder with the same title, username and link.
+type Conflict string
+
+const (
+	// ConflictSkip leaves the vault entry alone. It is the default.
+	ConflictSkip Conflict = "skip"
+	// ConflictRename saves the imported entry as a copy named Title_N.
+	ConflictRename Conflict = "rename"
+	// ConflictOverwrite replaces the vault entry with the imported one.
+	ConflictOverwrite Conflict = "overwrite"
+	// ConflictMerge fills the vault entry with the imported values and
+	// keeps its old password in the history.
+	ConflictMerge Conflict = "merge"
+)
+
+// ParseConflict validates a --on-conflict value.
+func ParseConflict(s string) (Conflict, error) {
+	switch c := Conflict(s); c {
+	case ConflictSkip, ConflictRename, ConflictOverwrite, ConflictMerge:
+		return c, nil
+	}
+	return "", fmt.Errorf("unknown conflict strategy %q (use skip, rename, overwrite or merge)", s)
+}
+
+// vaultFolder is what an import knows about one folder of the vault. It is
+// updated as entries are written so that duplicates within the export are
+// found too, and so that a dry run sees its own planned changes.
+type vaultFolder struct {
+	id      int64
+	exists  bool
+	titles  map[string]bool
+	entries []vaultEntry
+}
+
+type vaultEntry struct {
+	id                    int64
+	title, username, link string
+}
+
+func (f *vaultFolder) find(title, username, link string) *vaultEntry {
+	for i := range f.entries {
+		e := &f.entries[i]
+		if e.title == title && e.username == username && e.link == link {
+			return e
+		}
+	}
+	return nil
+}
+
+// uniqueTitle returns title, or title with a numeric suffix when an entry
+// with that title already exists in the folder.
+func (f *vaultFolder) uniqueTitle(title string) string {
+	finalTitle := title
+	for counter := 1; f.titles[finalTitle]; counter++ {
+		finalTitle = fmt.Sprintf("%s_%d", title, counter)
+	}
+	return finalTitle
+}
+
+type importRun struct {
+	s            *store.Store
+	opts         Options
+	folders      map[string]*vaultFolder
+	attachmentID int64
+
+	created, updated, skipped int
+	// duplicates counts the skipped entries that were already in the vault.
+	duplicates int
+}
+
+func saveEntries(b *Batch, masterPassword string, opts Options, cfg config.AppConfig) error {
+	dataDir := config.ExpandPath(cfg.DataDir)
+	dbPath := filepath.Join(dataDir, "passbook.db")
+
+	creds, err := cfg.Credentials(masterPassword, "")
+	if err != nil {
+		return err
+	}
+	s, err := store.OpenWith(dbPath, creds)
+	if err != nil {
+		return fmt.Errorf("opening store: %w", err)
+	}
+	defer s.Close()
+	return saveInto(b, s, opts)
+}
+
+// saveInto saves the entries of b into the open vault s.
+func saveInto(b *Batch, s *store.Store, opts Options) error {
+	if opts.OnConflict == "" {
+		opts.OnConflict = ConflictSkip
+	}
+	run := &importRun{
+		opts:         opts,
+		folders:      make(map[string]*vaultFolder),
+		attachmentID: time.Now().UnixNano(),
+	}
+
+	// The whole import is one transaction: if any entry fails to save,
+	// nothing is imported.
+	err := s.WithTx(func(tx *store.Store) error {
+		run.s = tx
+		for i, entry := range b.Entries {
+			if entry == nil {
+				run.skipped++
+				continue
+			}
+			if err := run.save(entry, b.Names[i], folderPath(opts.Into, b.Folders[i]), b.Attachments[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("import failed, nothing was imported: %w", err)
+	}
+
+	total := len(b.Entries)
+	if opts.DryRun {
+		fmt.Printf("Dry run: %d would be imported, %d updated, %d skipped (total %d items). Nothing was written.\n",
+			run.created, run.updated, run.skipped, total)
+		run.reportDuplicates()
+		return nil
+	}
+	fmt.Printf("Import complete: %d imported, %d updated, %d skipped (total %d items)\n",
+		run.created, run.updated, run.skipped, total)
+	run.reportDuplicates()
+	return nil
+}
+
+// reportDuplicates points out entries skipped because they were already in
+// the vault. Imports used to save them as Title_N copies, so a re-import
+// that adds nothing should say why.
+func (r *importRun) reportDuplicates() {
+	if r.duplicates == 0 {
+		return
+	}
+	fmt.Printf("%d already in the vault and left unchanged; use --on-conflict rename, overwrite or merge to import them anyway.\n",
+		r.duplicates)
+}
+
+func (r *importRun) save(entry *store.EntryFull, origName, folder string, attachments []Attachment) error {
+	f, err := r.folder(folder)
+	if err != nil {
+		return err
+	}
+
+	title := sanitizeTitle(entry.Title)
+	if title == "" {
+		title = "Untitled"
+	}
+
+	if dup := f.find(title, entry.Username, entry.Link()); dup != nil && r.opts.OnConflict != ConflictRename {
+		if r.opts.OnConflict == ConflictSkip {
+			r.report("skip", folder, title, "already in the vault")
+			r.skipped++
+			r.duplicates++
+			return nil
+		}
+		r.report(string(r.opts.OnConflict), folder, title, "")
+		if !r.opts.DryRun {
+			if err := r.update(dup.id, entry, attachments); err != nil {
+				return fmt.Errorf("updating %q: %w", origName, err)
+			}
+		}
+		r.updated++
+		return nil
+	}
+
+	entry.Title = f.uniqueTitle(title)
+	note := ""
+	if entry.Title != title {
+		note = "renamed from " + title
+	}
+	r.report("create", folder, entry.Title, note)
+
+	var id int64
+	if !r.opts.DryRun {
+		if !f.exists {
+			if f.id, err = r.s.CreateFolderPath(folder); err != nil {
+				return fmt.Errorf("creating folder %q: %w", folder, err)
+			}
+			f.exists = true
+		}
+		id, err = r.s.SaveEntry(f.id, entry)
+		if err != nil {
+			return fmt.Errorf("saving %q: %w", origName, err)
+		}
+		if err := r.writeAttachments(id, attachments); err != nil {
+			return fmt.Errorf("saving %q: %w", origName, err)
+		}
+	}
+
+	f.titles[entry.Title] = true
+	f.entries = append(f.entries, vaultEntry{id: id, title: entry.Title, username: entry.Username, link: entry.Link()})
+	r.created++
+	return nil
+}
+
+// update applies the overwrite or merge strategy to vault entry id.
+func (r *importRun) update(id int64, imported *store.EntryFull, attachments []Attachment) error {
+	existing, err := r.s.LoadEntry(id)
+	if err != nil {
+		return err
+	}
+
+	var updated *store.EntryFull
+	if r.opts.OnConflict == ConflictOverwrite {
+		updated = imported
+		updated.Title = existing.Title
+		for _, a := range existing.Attachments {
+			if err := r.s.DeleteAttachment(a.ID); err != nil {
+				return err
+			}
+		}
+	} else {
+		updated = mergeEntry(existing, imported)
+		attachments = newAttachments(existing.Attachments, attachments)
+	}
+
+	if err := r.s.UpdateEntryFull(id, existing.FolderID, updated); err != nil {
+		return err
+	}
+	return r.writeAttachments(id, attachments)
+}
+
+func (r *importRun) writeAttachments(entryID int64, attachments []Attachment) error {
+	for _, a := range attachments {
+		r.attachmentID++
+		if err := r.s.WriteAttachment(fmt.Sprintf("%d", r.attachmentID), entryID, a.Name, int64(len(a.Data)), a.Data); err != nil {
+			return fmt.Errorf("attachment %q: %w", a.Name, err)
+		}
+	}
+	return nil
+}
+
+// folder returns what is known about the vault folder at path name,
+// reading its entries the first time. A folder that does not exist yet is
+// only created, with any missing parents, once an entry is written to it.
+func (r *importRun) folder(name string) (*vaultFolder, error) {
+	if f, ok := r.folders[name]; ok {
+		return f, nil
+	}
+
+	f := &vaultFolder{exists: name == "", titles: make(map[string]bool)}
+	if name != "" {
+		info, err := r.s.GetFolderByPath(name)
+		if err != nil {
+			return nil, fmt.Errorf("looking up folder %q: %w", name, err)
+		}
+		if info != nil {
+			f.id, f.exists = info.ID, true
+		}
+	}
+	if f.exists {
+		metas, err := r.s.ListEntries(f.id)
+		if err != nil {
+			return nil, fmt.Errorf("listing folder %q: %w", name, err)
+		}
+		for _, m := range metas {
+			e, err := r.s.LoadEntry(m.ID)
+			if err != nil {
+				return nil, fmt.Errorf("loading %q: %w", m.Title, err)
+			}
+			f.titles[e.Title] = true
+			f.entries = append(f.entries, vaultEntry{id: e.ID, title: e.Title, username: e.Username, link: e.Link()})
+		}
+	}
+	r.folders[name] = f
+	return f, nil
+}
+
+// report prints what a dry run would do with one entry.
+func (r *importRun) report(action, folder, title, note string) {
+	if !r.opts.DryRun {
+		return
+	}
+	path := title
+	if folder != "" {
+		path = folder + "/" + title
+	}
+	if note != "" {
+		path += " (" + note + ")"
+	}
+	fmt.Printf("  %-9s %s\n", action, path)
+}
+
+// mergeEntry folds an imported entry into the vault entry it duplicates.
+// Imported values win where they are set, including custom fields of the
+// same name and the match rule of a URI already present, the vault entry's
+// password moves to the history when it changes, and imported history,
+// URIs, fields and notes are added to what is already there.
+func mergeEntry(existing, imported *store.EntryFull) *store.EntryFull {
+	merged := *existing
+	for _, f := range []struct {
+		dst *string
+		v   string
+	}{
+		{&merged.Username, imported.Username},
+		{&merged.Password, imported.Password},
+		{&merged.TotpSecret, imported.TotpSecret},
+		{&merged.CardNumber, imported.CardNumber},
+		{&merged.Expiry, imported.Expiry},
+		{&merged.CVV, imported.CVV},
+	} {
+		if f.v != "" {
+			*f.dst = f.v
+		}
+	}
+
+	merged.History = append([]store.PasswordHistory(nil), existing.History...)
+	for _, h := range imported.History {
+		if !containsHistory(merged.History, h) {
+			merged.History = append(merged.History, h)
+		}
+	}
+	merged.TrackPasswordChange(existing.Password)
+
+	merged.URIs = append([]store.EntryURI(nil), existing.URIs...)
+	for _, u := range imported.URIs {
+		if i := uriIndex(merged.URIs, u.URI); i >= 0 {
+			merged.URIs[i] = u
+		} else {
+			merged.URIs = append(merged.URIs, u)
+		}
+	}
+
+	merged.Fields = append([]store.CustomField(nil), existing.Fields...)
+	for _, f := range imported.Fields {
+		if i := fieldIndex(merged.Fields, f.Name); i >= 0 {
+			merged.Fields[i] = f
+		} else {
+			merged.Fields = append(merged.Fields, f)
+		}
+	}
+
+	if imported.CustomText != "" && !strings.Contains(merged.CustomText, imported.CustomText) {
+		merged.CustomText = appendNotes(merged.CustomText, imported.CustomText)
+	}
+	return &merged
+}
+
+func containsHistory(history []store.PasswordHistory, h store.PasswordHistory) bool {
+	for _, existing := range history {
+		if existing == h {
+			return true
+		}
+	}
+	return false
+}
+
+func uriIndex(uris []store.EntryURI, uri string) int {
+	for i, u := range uris {
+		if u.URI == uri {
+			return i
+		}
+	}
+	return -1
+}
+
+func fieldIndex(fields []store.CustomField, name string) int {
+	for i, f := range fields {
+		if f.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// newAttachments drops imported attachments the vault entry already has a
+// file of the same name and size for.
+func newAttachments(existing []store.AttachmentMeta, imported []Attachment) []Attachment {
+	var out []Attachment
+	for _, a := range imported {
+		dup := false
+		for _, e := range existing {
+			if e.FileName == a.Name && e.Size == int64(len(a.Data)) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			out = append(out, a)
+		}
+	}
+	return out
+}
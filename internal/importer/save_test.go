@@ -0,0 +1,206 @@
+package importer
+
+import (
+	"reflect"
+	"testing"
+
+	"passbook/internal/store"
+)
+
+const conflictCSV = `url,username,password,totp,extra,name,grouping,fav
+https://a.com,alice,new-pass,,imported note,Site A,Work,0
+`
+
+// seedConflictVault stores the entry conflictCSV duplicates, with an older
+// password.
+func seedConflictVault(t *testing.T, s *store.Store) int64 {
+	t.Helper()
+	folderID, err := s.CreateFolder(0, "Work")
+	if err != nil {
+		t.Fatalf("CreateFolder: %v", err)
+	}
+	id, err := s.SaveEntry(folderID, &store.EntryFull{
+		Type:       "Login",
+		Title:      "Site A",
+		Username:   "alice",
+		Password:   "old-pass",
+		URIs:       []store.EntryURI{{URI: "https://a.com"}},
+		CustomText: "my note",
+		History:    []store.PasswordHistory{{Password: "older", Date: "2020-01-01 00:00"}},
+	})
+	if err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	return id
+}
+
+func importConflictCSV(t *testing.T, opts Options) (*store.Store, int64) {
+	t.Helper()
+	password := "testpass"
+	dir, cfg := setupTestVault(t, password)
+	s := openTestStore(t, dir, password)
+	id := seedConflictVault(t, s)
+
+	src, _ := Lookup("lastpass")
+	if err := Import(src, writeLastPassCSV(t, conflictCSV), "", password, opts, cfg); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	return s, id
+}
+
+func countEntries(t *testing.T, s *store.Store) int {
+	t.Helper()
+	entries, err := s.ListAllEntries()
+	if err != nil {
+		t.Fatalf("ListAllEntries: %v", err)
+	}
+	return len(entries)
+}
+
+func TestImportConflictSkipIsDefault(t *testing.T) {
+	s, id := importConflictCSV(t, Options{})
+	if n := countEntries(t, s); n != 1 {
+		t.Fatalf("expected the duplicate to be skipped, got %d entries", n)
+	}
+	e, _ := s.LoadEntry(id)
+	if e.Password != "old-pass" {
+		t.Fatalf("expected vault entry to be untouched, got %+v", e)
+	}
+}
+
+func TestImportConflictSkipLeavesEntryUnchanged(t *testing.T) {
+	password := "testpass"
+	dir, cfg := setupTestVault(t, password)
+	s := openTestStore(t, dir, password)
+	id := seedConflictVault(t, s)
+	before, err := s.LoadEntry(id)
+	if err != nil {
+		t.Fatalf("LoadEntry: %v", err)
+	}
+
+	src, _ := Lookup("lastpass")
+	for range 2 {
+		if err := Import(src, writeLastPassCSV(t, conflictCSV), "", password, Options{OnConflict: ConflictSkip}, cfg); err != nil {
+			t.Fatalf("Import: %v", err)
+		}
+	}
+
+	after, err := s.LoadEntry(id)
+	if err != nil {
+		t.Fatalf("LoadEntry: %v", err)
+	}
+	if !reflect.DeepEqual(before, after) {
+		t.Fatalf("expected the vault entry to be exactly as it was:\nbefore %+v\nafter  %+v", before, after)
+	}
+	if n := countEntries(t, s); n != 1 {
+		t.Fatalf("expected no copies, got %d entries", n)
+	}
+}
+
+func TestImportConflictRename(t *testing.T) {
+	s, _ := importConflictCSV(t, Options{OnConflict: ConflictRename})
+	if n := countEntries(t, s); n != 2 {
+		t.Fatalf("expected a renamed copy, got %d entries", n)
+	}
+	loadEntryFromStore(t, s, "Site A_1")
+}
+
+func TestImportConflictOverwrite(t *testing.T) {
+	s, id := importConflictCSV(t, Options{OnConflict: ConflictOverwrite})
+	if n := countEntries(t, s); n != 1 {
+		t.Fatalf("expected 1 entry, got %d", n)
+	}
+	e, _ := s.LoadEntry(id)
+	if e.Password != "new-pass" || e.CustomText != "imported note" || len(e.History) != 0 {
+		t.Fatalf("expected the imported entry to replace the vault entry, got %+v", e)
+	}
+}
+
+func TestImportConflictMerge(t *testing.T) {
+	s, id := importConflictCSV(t, Options{OnConflict: ConflictMerge})
+	if n := countEntries(t, s); n != 1 {
+		t.Fatalf("expected 1 entry, got %d", n)
+	}
+	e, _ := s.LoadEntry(id)
+	if e.Password != "new-pass" || e.CustomText != "my note\n\nimported note" {
+		t.Fatalf("unexpected merged entry: %+v", e)
+	}
+	if len(e.History) != 2 || e.History[0].Password != "older" || e.History[1].Password != "old-pass" {
+		t.Fatalf("expected the old password to move to the history, got %+v", e.History)
+	}
+}
+
+func TestImportDryRun(t *testing.T) {
+	password := "testpass"
+	dir, cfg := setupTestVault(t, password)
+	s := openTestStore(t, dir, password)
+	id := seedConflictVault(t, s)
+
+	csv := conflictCSV + "https://b.com,bob,pw,,,Site B,Personal,0\n"
+	src, _ := Lookup("lastpass")
+	opts := Options{DryRun: true, OnConflict: ConflictOverwrite}
+	if err := Import(src, writeLastPassCSV(t, csv), "", password, opts, cfg); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	if n := countEntries(t, s); n != 1 {
+		t.Fatalf("dry run wrote entries: %d", n)
+	}
+	if f, _ := s.GetFolderByPath("Personal"); f != nil {
+		t.Fatalf("dry run created a folder")
+	}
+	if e, _ := s.LoadEntry(id); e.Password != "old-pass" {
+		t.Fatalf("dry run changed an entry")
+	}
+}
+
+func TestImportDuplicatesWithinExport(t *testing.T) {
+	password := "testpass"
+	dir, cfg := setupTestVault(t, password)
+
+	csv := `url,username,password,totp,extra,name,grouping,fav
+https://a.com,alice,p1,,,Site A,,0
+https://a.com,alice,p1,,,Site A,,0
+https://a.com,bob,p2,,,Site A,,0
+`
+	if err := ImportLastPass(writeLastPassCSV(t, csv), password, cfg); err != nil {
+		t.Fatalf("ImportLastPass: %v", err)
+	}
+	s := openTestStore(t, dir, password)
+	if n := countEntries(t, s); n != 2 {
+		t.Fatalf("expected the repeated row to be skipped and bob renamed, got %d entries", n)
+	}
+	if e := loadEntryFromStore(t, s, "Site A_1"); e.Username != "bob" {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+}
+
+func TestImportRollsBackOnFailure(t *testing.T) {
+	password := "testpass"
+	dir, cfg := setupTestVault(t, password)
+
+	b := &Batch{}
+	b.add(&store.EntryFull{Type: "Login", Title: "First"}, "First", "Imported")
+	// Attachment data is NOT NULL in the schema, so this entry fails to save.
+	b.add(&store.EntryFull{Type: "Login", Title: "Second"}, "Second", "Imported", Attachment{Name: "broken"})
+	if err := saveEntries(b, password, Options{}, cfg); err == nil {
+		t.Fatalf("expected the import to fail")
+	}
+
+	s := openTestStore(t, dir, password)
+	if n := countEntries(t, s); n != 0 {
+		t.Fatalf("expected nothing to be imported, got %d entries", n)
+	}
+	if f, _ := s.GetFolderByPath("Imported"); f != nil {
+		t.Fatalf("expected the folder to be rolled back")
+	}
+}
+
+func TestParseConflict(t *testing.T) {
+	if c, err := ParseConflict("merge"); err != nil || c != ConflictMerge {
+		t.Fatalf("ParseConflict(merge) = %q, %v", c, err)
+	}
+	if _, err := ParseConflict("replace"); err == nil {
+		t.Fatalf("expected an error for an unknown strategy")
+	}
+}
@@ -0,0 +1,41 @@
+package kdbx
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Test vectors from RFC 9106, section 5.
+func TestArgon2RFCVectors(t *testing.T) {
+	password := bytes.Repeat([]byte{0x01}, 32)
+	salt := bytes.Repeat([]byte{0x02}, 16)
+	secret := bytes.Repeat([]byte{0x03}, 8)
+	data := bytes.Repeat([]byte{0x04}, 12)
+
+	tests := []struct {
+		name string
+		mode int
+		want string
+	}{
+		{"argon2d", argon2d, "512b391b6f1162975371d30919734294f868e3be3984f3c1a13a4db9fabe4acb"},
+		{"argon2id", argon2id, "0d640df58d78766c08c037a34a8b53c9d01ef0452d75b65eb52520e96b01e659"},
+	}
+	for _, tt := range tests {
+		got := hex.EncodeToString(argon2Key(tt.mode, password, salt, secret, data, 3, 32, 4, 32))
+		if got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestArgon2idMatchesXCrypto(t *testing.T) {
+	password, salt := []byte("password"), []byte("somesaltsomesalt")
+	got := argon2Key(argon2id, password, salt, nil, nil, 2, 256, 2, 32)
+	want := argon2.IDKey(password, salt, 2, 256, 2, 32)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("argon2id mismatch:\n got %x\nwant %x", got, want)
+	}
+}
@@ -0,0 +1,266 @@
+package kdbx
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/salsa20/salsa"
+)
+
+// blockSize is the payload size of each HMAC block written.
+const blockSize = 1 << 20
+
+// Limits on the Argon2 settings of a database being read. The header is
+// not authenticated until the key is derived, so a crafted file could
+// otherwise make Argon2 allocate terabytes or run for days.
+const (
+	maxArgon2Memory     = 2 << 30 // bytes
+	maxArgon2Iterations = 1 << 24
+)
+
+// compositeKey combines the credentials into the KDF input. Only a
+// password is supported; key files and challenge-response are not.
+func compositeKey(password string) []byte {
+	h := sha256.Sum256([]byte(password))
+	c := sha256.Sum256(h[:])
+	return c[:]
+}
+
+// transformKey runs the KDF described by params over the composite key.
+func transformKey(params variantDict, composite []byte) ([]byte, error) {
+	uuid := params.getBytes("$UUID")
+	switch {
+	case bytes.Equal(uuid, kdfArgon2d), bytes.Equal(uuid, kdfArgon2id):
+		salt := params.getBytes("S")
+		parallelism, err := params.getUint("P")
+		if err != nil {
+			return nil, err
+		}
+		memory, err := params.getUint("M")
+		if err != nil {
+			return nil, err
+		}
+		iterations, err := params.getUint("I")
+		if err != nil {
+			return nil, err
+		}
+		if parallelism < 1 || parallelism > math.MaxUint8 || iterations < 1 {
+			return nil, fmt.Errorf("%w: Argon2 parameters out of range", ErrCorrupt)
+		}
+		if memory > maxArgon2Memory || iterations > maxArgon2Iterations {
+			return nil, fmt.Errorf("%w: Argon2 with %d MiB and %d iterations (at most %d MiB and %d)",
+				ErrKDFTooExpensive, memory>>20, iterations, maxArgon2Memory>>20, maxArgon2Iterations)
+		}
+		secret, data := params.getBytes("K"), params.getBytes("A")
+
+		mode := argon2d
+		if bytes.Equal(uuid, kdfArgon2id) {
+			mode = argon2id
+		}
+		if mode == argon2id && secret == nil && data == nil {
+			return argon2.IDKey(composite, salt, uint32(iterations), uint32(memory/1024), uint8(parallelism), 32), nil
+		}
+		return argon2Key(mode, composite, salt, secret, data, uint32(iterations), uint32(memory/1024), uint8(parallelism), 32), nil
+
+	case bytes.Equal(uuid, kdfAES):
+		rounds, err := params.getUint("R")
+		if err != nil {
+			return nil, err
+		}
+		seed := params.getBytes("S")
+		block, err := aes.NewCipher(seed)
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad AES-KDF seed", ErrCorrupt)
+		}
+		key := append([]byte(nil), composite...)
+		for i := uint64(0); i < rounds; i++ {
+			block.Encrypt(key[:16], key[:16])
+			block.Encrypt(key[16:], key[16:])
+		}
+		sum := sha256.Sum256(key)
+		return sum[:], nil
+	}
+	return nil, fmt.Errorf("%w: unknown key derivation function", ErrCorrupt)
+}
+
+// hmacBaseKey is the key from which the per-block HMAC keys are derived.
+func hmacBaseKey(masterSeed, transformed []byte) []byte {
+	h := sha512.New()
+	h.Write(masterSeed)
+	h.Write(transformed)
+	h.Write([]byte{1})
+	return h.Sum(nil)
+}
+
+func blockHMACKey(base []byte, index uint64) []byte {
+	var idx [8]byte
+	binary.LittleEndian.PutUint64(idx[:], index)
+	h := sha512.New()
+	h.Write(idx[:])
+	h.Write(base)
+	return h.Sum(nil)
+}
+
+func headerHMAC(base, header []byte) []byte {
+	mac := hmac.New(sha256.New, blockHMACKey(base, math.MaxUint64))
+	mac.Write(header)
+	return mac.Sum(nil)
+}
+
+func blockHMAC(base []byte, index uint64, data []byte) []byte {
+	var prefix [12]byte
+	binary.LittleEndian.PutUint64(prefix[:8], index)
+	binary.LittleEndian.PutUint32(prefix[8:], uint32(len(data)))
+	mac := hmac.New(sha256.New, blockHMACKey(base, index))
+	mac.Write(prefix[:])
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// readBlocks verifies and concatenates the HMAC block stream.
+func readBlocks(data, base []byte) ([]byte, error) {
+	var out bytes.Buffer
+	for index := uint64(0); ; index++ {
+		if len(data) < 36 {
+			return nil, ErrCorrupt
+		}
+		mac := data[:32]
+		size := int(binary.LittleEndian.Uint32(data[32:]))
+		data = data[36:]
+		if size < 0 || size > len(data) {
+			return nil, ErrCorrupt
+		}
+		block := data[:size]
+		data = data[size:]
+		if !hmac.Equal(mac, blockHMAC(base, index, block)) {
+			return nil, ErrCorrupt
+		}
+		if size == 0 {
+			return out.Bytes(), nil
+		}
+		out.Write(block)
+	}
+}
+
+func writeBlocks(buf *bytes.Buffer, data, base []byte) {
+	var size [4]byte
+	for index := uint64(0); ; index++ {
+		n := min(len(data), blockSize)
+		block := data[:n]
+		data = data[n:]
+		binary.LittleEndian.PutUint32(size[:], uint32(n))
+		buf.Write(blockHMAC(base, index, block))
+		buf.Write(size[:])
+		buf.Write(block)
+		if n == 0 {
+			return
+		}
+	}
+}
+
+func decryptPayload(cipherID, key, iv, data []byte) ([]byte, error) {
+	switch {
+	case bytes.Equal(cipherID, cipherAES256):
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(iv) != aes.BlockSize || len(data) == 0 || len(data)%aes.BlockSize != 0 {
+			return nil, ErrCorrupt
+		}
+		out := make([]byte, len(data))
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+		pad := int(out[len(out)-1])
+		if pad < 1 || pad > aes.BlockSize {
+			return nil, ErrCorrupt
+		}
+		return out[:len(out)-pad], nil
+
+	case bytes.Equal(cipherID, cipherChaCha20):
+		c, err := chacha20.NewUnauthenticatedCipher(key, iv)
+		if err != nil {
+			return nil, ErrCorrupt
+		}
+		out := make([]byte, len(data))
+		c.XORKeyStream(out, data)
+		return out, nil
+	}
+	return nil, fmt.Errorf("%w: unsupported cipher", ErrCorrupt)
+}
+
+func encryptPayload(cipherID, key, iv, data []byte) ([]byte, error) {
+	if bytes.Equal(cipherID, cipherChaCha20) {
+		c, err := chacha20.NewUnauthenticatedCipher(key, iv)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]byte, len(data))
+		c.XORKeyStream(out, data)
+		return out, nil
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	pad := aes.BlockSize - len(data)%aes.BlockSize
+	out := make([]byte, len(data)+pad)
+	copy(out, data)
+	for i := len(data); i < len(out); i++ {
+		out[i] = byte(pad)
+	}
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, out)
+	return out, nil
+}
+
+// ── Inner random stream ─────────────────────────────────────────────
+
+// keyStream encrypts protected values. Values share one stream, consumed
+// in document order.
+type keyStream interface {
+	XORKeyStream(dst, src []byte)
+}
+
+func newInnerStream(id uint32, key []byte) (keyStream, error) {
+	switch id {
+	case innerStreamChaCha:
+		h := sha512.Sum512(key)
+		return chacha20.NewUnauthenticatedCipher(h[:32], h[32:44])
+	case innerStreamSalsa:
+		s := &salsaStream{key: sha256.Sum256(key)}
+		copy(s.counter[:8], []byte{0xE8, 0x30, 0x09, 0x4B, 0x97, 0x20, 0x5D, 0x2A})
+		return s, nil
+	}
+	return nil, fmt.Errorf("%w: unsupported inner stream %d", ErrCorrupt, id)
+}
+
+// salsaStream is a stateful Salsa20 keystream, used by older KDBX 4 files.
+type salsaStream struct {
+	key     [32]byte
+	counter [16]byte
+	block   uint64
+	buf     []byte
+}
+
+func (s *salsaStream) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if len(s.buf) == 0 {
+			var ks [64]byte
+			binary.LittleEndian.PutUint64(s.counter[8:], s.block)
+			salsa.XORKeyStream(ks[:], ks[:], &s.counter, &s.key)
+			s.block++
+			s.buf = ks[:]
+		}
+		dst[i] = src[i] ^ s.buf[0]
+		s.buf = s.buf[1:]
+	}
+}
@@ -0,0 +1,233 @@
+package kdbx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	signature1 = 0x9AA2D903
+	signature2 = 0xB54BFB67
+
+	versionMajor4 = 4
+)
+
+// Outer header field IDs.
+const (
+	hdrEnd              = 0
+	hdrCipherID         = 2
+	hdrCompressionFlags = 3
+	hdrMasterSeed       = 4
+	hdrEncryptionIV     = 7
+	hdrKdfParameters    = 11
+)
+
+// Inner header field IDs.
+const (
+	innerEnd          = 0
+	innerStreamID     = 1
+	innerStreamKey    = 2
+	innerBinary       = 3
+	innerStreamSalsa  = 2
+	innerStreamChaCha = 3
+)
+
+var (
+	cipherAES256   = []byte{0x31, 0xc1, 0xf2, 0xe6, 0xbf, 0x71, 0x43, 0x50, 0xbe, 0x58, 0x05, 0x21, 0x6a, 0xfc, 0x5a, 0xff}
+	cipherChaCha20 = []byte{0xd6, 0x03, 0x8a, 0x2b, 0x8b, 0x6f, 0x4c, 0xb5, 0xa5, 0x24, 0x33, 0x9a, 0x31, 0xdb, 0xb5, 0x9a}
+
+	kdfAES      = []byte{0xc9, 0xd9, 0xf3, 0x9a, 0x62, 0x8a, 0x44, 0x60, 0xbf, 0x74, 0x0d, 0x08, 0xc1, 0x8a, 0x4f, 0xea}
+	kdfArgon2d  = []byte{0xef, 0x63, 0x6d, 0xdf, 0x8c, 0x29, 0x44, 0x4b, 0x91, 0xf7, 0xa9, 0xa4, 0x03, 0xe3, 0x0a, 0x0c}
+	kdfArgon2id = []byte{0x9e, 0x29, 0x8b, 0x19, 0x56, 0xdb, 0x47, 0x73, 0xb2, 0x3d, 0xfc, 0x3e, 0xc6, 0xf0, 0xa1, 0xe6}
+)
+
+type header struct {
+	cipherID   []byte
+	compressed bool
+	masterSeed []byte
+	iv         []byte
+	kdf        variantDict
+}
+
+// readHeader parses the outer header at the start of data and returns it
+// together with its length in bytes.
+func readHeader(data []byte) (*header, int, error) {
+	if len(data) < 12 ||
+		binary.LittleEndian.Uint32(data[0:]) != signature1 ||
+		binary.LittleEndian.Uint32(data[4:]) != signature2 {
+		return nil, 0, ErrNotKDBX
+	}
+	if major := binary.LittleEndian.Uint16(data[10:]); major != versionMajor4 {
+		return nil, 0, ErrUnsupportedVersion
+	}
+
+	h := &header{}
+	pos := 12
+	for {
+		if pos+5 > len(data) {
+			return nil, 0, ErrCorrupt
+		}
+		id := data[pos]
+		size := int(binary.LittleEndian.Uint32(data[pos+1:]))
+		pos += 5
+		if size < 0 || pos+size > len(data) {
+			return nil, 0, ErrCorrupt
+		}
+		value := data[pos : pos+size]
+		pos += size
+
+		switch id {
+		case hdrEnd:
+			if h.cipherID == nil || h.masterSeed == nil || h.iv == nil || h.kdf == nil {
+				return nil, 0, ErrCorrupt
+			}
+			return h, pos, nil
+		case hdrCipherID:
+			h.cipherID = value
+		case hdrCompressionFlags:
+			if len(value) != 4 {
+				return nil, 0, ErrCorrupt
+			}
+			h.compressed = binary.LittleEndian.Uint32(value) == 1
+		case hdrMasterSeed:
+			h.masterSeed = value
+		case hdrEncryptionIV:
+			h.iv = value
+		case hdrKdfParameters:
+			kdf, err := readVariantDict(value)
+			if err != nil {
+				return nil, 0, err
+			}
+			h.kdf = kdf
+		}
+	}
+}
+
+func (h *header) bytes() []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint32(signature1))
+	binary.Write(&buf, binary.LittleEndian, uint32(signature2))
+	binary.Write(&buf, binary.LittleEndian, uint16(0))
+	binary.Write(&buf, binary.LittleEndian, uint16(versionMajor4))
+
+	compression := make([]byte, 4)
+	if h.compressed {
+		binary.LittleEndian.PutUint32(compression, 1)
+	}
+	writeField(&buf, hdrCipherID, h.cipherID)
+	writeField(&buf, hdrCompressionFlags, compression)
+	writeField(&buf, hdrMasterSeed, h.masterSeed)
+	writeField(&buf, hdrEncryptionIV, h.iv)
+	writeField(&buf, hdrKdfParameters, h.kdf.bytes())
+	writeField(&buf, hdrEnd, []byte("\r\n\r\n"))
+	return buf.Bytes()
+}
+
+func writeField(buf *bytes.Buffer, id byte, value []byte) {
+	buf.WriteByte(id)
+	binary.Write(buf, binary.LittleEndian, uint32(len(value)))
+	buf.Write(value)
+}
+
+// ── Variant dictionary ──────────────────────────────────────────────
+
+const (
+	variantVersion = 0x0100
+
+	variantUint32 = 0x04
+	variantUint64 = 0x05
+	variantBytes  = 0x42
+)
+
+type variantItem struct {
+	kind  byte
+	value []byte
+}
+
+// variantDict is the typed key/value map KDBX 4 uses for KDF parameters.
+// Values are kept in their little-endian wire form.
+type variantDict map[string]variantItem
+
+func readVariantDict(data []byte) (variantDict, error) {
+	if len(data) < 2 || binary.LittleEndian.Uint16(data)>>8 != variantVersion>>8 {
+		return nil, ErrCorrupt
+	}
+	d := make(variantDict)
+	pos := 2
+	for pos < len(data) {
+		kind := data[pos]
+		pos++
+		if kind == 0 {
+			return d, nil
+		}
+		if pos+4 > len(data) {
+			return nil, ErrCorrupt
+		}
+		keyLen := int(binary.LittleEndian.Uint32(data[pos:]))
+		pos += 4
+		if keyLen < 0 || pos+keyLen+4 > len(data) {
+			return nil, ErrCorrupt
+		}
+		key := string(data[pos : pos+keyLen])
+		pos += keyLen
+		valueLen := int(binary.LittleEndian.Uint32(data[pos:]))
+		pos += 4
+		if valueLen < 0 || pos+valueLen > len(data) {
+			return nil, ErrCorrupt
+		}
+		d[key] = variantItem{kind: kind, value: data[pos : pos+valueLen]}
+		pos += valueLen
+	}
+	return nil, ErrCorrupt
+}
+
+func (d variantDict) bytes() []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint16(variantVersion))
+	for key, item := range d {
+		buf.WriteByte(item.kind)
+		binary.Write(&buf, binary.LittleEndian, uint32(len(key)))
+		buf.WriteString(key)
+		binary.Write(&buf, binary.LittleEndian, uint32(len(item.value)))
+		buf.Write(item.value)
+	}
+	buf.WriteByte(0)
+	return buf.Bytes()
+}
+
+func (d variantDict) getBytes(key string) []byte {
+	if item, ok := d[key]; ok && item.kind == variantBytes {
+		return item.value
+	}
+	return nil
+}
+
+func (d variantDict) getUint(key string) (uint64, error) {
+	item, ok := d[key]
+	switch {
+	case !ok:
+		return 0, fmt.Errorf("%w: missing KDF parameter %q", ErrCorrupt, key)
+	case item.kind == variantUint32 && len(item.value) == 4:
+		return uint64(binary.LittleEndian.Uint32(item.value)), nil
+	case item.kind == variantUint64 && len(item.value) == 8:
+		return binary.LittleEndian.Uint64(item.value), nil
+	}
+	return 0, fmt.Errorf("%w: bad KDF parameter %q", ErrCorrupt, key)
+}
+
+func (d variantDict) setUint32(key string, v uint32) {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+	d[key] = variantItem{kind: variantUint32, value: b}
+}
+
+func (d variantDict) setUint64(key string, v uint64) {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, v)
+	d[key] = variantItem{kind: variantUint64, value: b}
+}
+
+func (d variantDict) setBytes(key string, v []byte) {
+	d[key] = variantItem{kind: variantBytes, value: v}
+}
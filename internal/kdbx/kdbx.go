@@ -0,0 +1,89 @@
+// Package kdbx reads and writes KeePass KDBX 4 database files.
+//
+// Only the parts of the format PassBook needs are modelled: the group tree,
+// entry strings, entry history and binary attachments. Reading supports the
+// AES-256 and ChaCha20 ciphers with the Argon2d, Argon2id and AES-KDF key
+// derivation functions. Files are written with Argon2id and AES-256.
+package kdbx
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotKDBX            = errors.New("not a KeePass database")
+	ErrUnsupportedVersion = errors.New("unsupported KeePass database version (only KDBX 4 is supported)")
+	ErrInvalidCredentials = errors.New("wrong KeePass password or corrupt database")
+	ErrCorrupt            = errors.New("corrupt KeePass database")
+	ErrKDFTooExpensive    = errors.New("KeePass key derivation settings are too expensive to open")
+)
+
+// Standard entry string keys.
+const (
+	KeyTitle    = "Title"
+	KeyUserName = "UserName"
+	KeyPassword = "Password"
+	KeyURL      = "URL"
+	KeyNotes    = "Notes"
+)
+
+// Database is the decrypted content of a KDBX file.
+type Database struct {
+	Name string
+	Root *Group
+}
+
+// Group is a node of the KeePass group tree.
+type Group struct {
+	Name         string
+	Groups       []*Group
+	Entries      []*Entry
+	IsRecycleBin bool
+}
+
+// Entry is a KeePass entry. Strings keep the order they had in the file.
+type Entry struct {
+	Strings  []String
+	Binaries []Binary
+	Modified time.Time
+	History  []*Entry
+}
+
+// String is a key/value pair of an entry. Protected values are encrypted
+// with the inner random stream in the file.
+type String struct {
+	Key       string
+	Value     string
+	Protected bool
+}
+
+// Binary is a file attached to an entry.
+type Binary struct {
+	Name string
+	Data []byte
+}
+
+// Get returns the value of the string with the given key.
+func (e *Entry) Get(key string) string {
+	for _, s := range e.Strings {
+		if s.Key == key {
+			return s.Value
+		}
+	}
+	return ""
+}
+
+// KDFParams configures the Argon2id key derivation used when writing.
+type KDFParams struct {
+	Memory      uint64 // bytes
+	Iterations  uint64
+	Parallelism uint32
+}
+
+// DefaultKDF matches KeePassXC's defaults for new databases.
+var DefaultKDF = KDFParams{
+	Memory:      64 << 20,
+	Iterations:  10,
+	Parallelism: 2,
+}
@@ -0,0 +1,234 @@
+package kdbx
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testKDF keeps key derivation fast in tests.
+var testKDF = KDFParams{Memory: 64 << 10, Iterations: 2, Parallelism: 2}
+
+func sampleDatabase() *Database {
+	modified := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	return &Database{
+		Name: "Team",
+		Root: &Group{
+			Name: "Root",
+			Entries: []*Entry{{
+				Strings: []String{
+					{Key: KeyTitle, Value: "Root entry"},
+					{Key: KeyPassword, Value: "", Protected: true},
+				},
+			}},
+			Groups: []*Group{{
+				Name: "Work",
+				Groups: []*Group{{
+					Name: "Servers",
+					Entries: []*Entry{{
+						Strings: []String{
+							{Key: KeyTitle, Value: "db01"},
+							{Key: KeyUserName, Value: "root"},
+							{Key: KeyPassword, Value: "n3w <&> pass", Protected: true},
+							{Key: KeyURL, Value: "ssh://db01"},
+							{Key: KeyNotes, Value: "line one\nline two"},
+							{Key: "otp", Value: "otpauth://totp/db01?secret=JBSWY3DPEHPK3PXP", Protected: true},
+						},
+						Binaries: []Binary{{Name: "id_rsa", Data: []byte{0, 1, 2, 3}}},
+						Modified: modified,
+						History: []*Entry{{
+							Strings: []String{
+								{Key: KeyTitle, Value: "db01"},
+								{Key: KeyPassword, Value: "old pass", Protected: true},
+							},
+							Modified: modified.Add(-24 * time.Hour),
+						}},
+					}},
+				}},
+			}},
+		},
+	}
+}
+
+func assertSample(t *testing.T, db *Database) {
+	t.Helper()
+	if db.Name != "Team" || db.Root.Name != "Root" || len(db.Root.Entries) != 1 {
+		t.Fatalf("unexpected root: %+v", db.Root)
+	}
+	servers := db.Root.Groups[0].Groups[0]
+	if servers.Name != "Servers" || len(servers.Entries) != 1 {
+		t.Fatalf("unexpected group tree: %+v", servers)
+	}
+	e := servers.Entries[0]
+	if e.Get(KeyPassword) != "n3w <&> pass" || e.Get(KeyNotes) != "line one\nline two" {
+		t.Fatalf("unexpected entry strings: %+v", e.Strings)
+	}
+	if e.Get("otp") != "otpauth://totp/db01?secret=JBSWY3DPEHPK3PXP" {
+		t.Fatalf("protected custom string not decrypted: %q", e.Get("otp"))
+	}
+	if len(e.Binaries) != 1 || e.Binaries[0].Name != "id_rsa" || !bytes.Equal(e.Binaries[0].Data, []byte{0, 1, 2, 3}) {
+		t.Fatalf("unexpected binaries: %+v", e.Binaries)
+	}
+	if !e.Modified.Equal(time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected modification time %v", e.Modified)
+	}
+	if len(e.History) != 1 || e.History[0].Get(KeyPassword) != "old pass" {
+		t.Fatalf("unexpected history: %+v", e.History)
+	}
+}
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, sampleDatabase(), "secret", testKDF); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("db01")) {
+		t.Fatalf("output contains plaintext")
+	}
+	db, err := Read(&buf, "secret")
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	assertSample(t, db)
+}
+
+// TestReadVariants covers the cipher and KDF combinations KeePass and
+// KeePassXC produce besides the one Write uses.
+func TestReadVariants(t *testing.T) {
+	tests := []struct {
+		name       string
+		cipherID   []byte
+		ivSize     int
+		compressed bool
+		kdf        func(variantDict)
+	}{
+		{"chacha20-argon2d", cipherChaCha20, 12, true, func(d variantDict) {
+			d.setBytes("$UUID", kdfArgon2d)
+			d.setBytes("S", randomBytes(32))
+			d.setUint32("P", 2)
+			d.setUint64("M", 64<<10)
+			d.setUint64("I", 2)
+			d.setUint32("V", argon2Version)
+		}},
+		{"aes-aeskdf-uncompressed", cipherAES256, 16, false, func(d variantDict) {
+			d.setBytes("$UUID", kdfAES)
+			d.setBytes("S", randomBytes(32))
+			d.setUint64("R", 1000)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &header{
+				cipherID:   tt.cipherID,
+				compressed: tt.compressed,
+				masterSeed: randomBytes(32),
+				iv:         randomBytes(tt.ivSize),
+				kdf:        make(variantDict),
+			}
+			tt.kdf(h.kdf)
+
+			var buf bytes.Buffer
+			if err := encode(&buf, sampleDatabase(), "secret", h); err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			db, err := Read(&buf, "secret")
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+			assertSample(t, db)
+		})
+	}
+}
+
+func TestSalsa20StreamIsContinuous(t *testing.T) {
+	key := randomBytes(32)
+	a, _ := newInnerStream(innerStreamSalsa, key)
+	b, _ := newInnerStream(innerStreamSalsa, key)
+
+	msg := bytes.Repeat([]byte("protected value "), 10)
+	enc := append([]byte(nil), msg...)
+	a.XORKeyStream(enc[:5], enc[:5])
+	a.XORKeyStream(enc[5:], enc[5:])
+	b.XORKeyStream(enc, enc)
+	if !bytes.Equal(enc, msg) {
+		t.Fatalf("salsa20 stream is not consistent across calls")
+	}
+}
+
+func TestReadWrongPassword(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, sampleDatabase(), "secret", testKDF); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if _, err := Read(&buf, "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestReadTampered(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, sampleDatabase(), "secret", testKDF); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	data := buf.Bytes()
+	data[len(data)-40] ^= 0xFF
+	if _, err := Read(bytes.NewReader(data), "secret"); !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("expected ErrCorrupt, got %v", err)
+	}
+}
+
+func TestReadRejectsExpensiveKDF(t *testing.T) {
+	tests := []struct {
+		name       string
+		memory     uint64
+		iterations uint64
+	}{
+		{"memory", 4 << 40, 2},
+		{"iterations", 64 << 10, 1 << 32},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &header{
+				cipherID:   cipherChaCha20,
+				masterSeed: randomBytes(32),
+				iv:         randomBytes(12),
+				kdf:        make(variantDict),
+			}
+			h.kdf.setBytes("$UUID", kdfArgon2id)
+			h.kdf.setBytes("S", randomBytes(32))
+			h.kdf.setUint32("P", 2)
+			h.kdf.setUint64("M", tt.memory)
+			h.kdf.setUint64("I", tt.iterations)
+			h.kdf.setUint32("V", argon2Version)
+
+			// Encoding would run the KDF, so the file is just the header,
+			// its hash and a dummy HMAC: Read must stop at the KDF.
+			data := h.bytes()
+			sum := sha256.Sum256(data)
+			data = append(append(data, sum[:]...), make([]byte, 32)...)
+			if _, err := Read(bytes.NewReader(data), "secret"); !errors.Is(err, ErrKDFTooExpensive) {
+				t.Fatalf("expected ErrKDFTooExpensive, got %v", err)
+			}
+		})
+	}
+}
+
+func TestReadRejectsOtherFiles(t *testing.T) {
+	if _, err := Read(bytes.NewReader([]byte("not a database")), "x"); !errors.Is(err, ErrNotKDBX) {
+		t.Fatalf("expected ErrNotKDBX, got %v", err)
+	}
+
+	kdbx3 := []byte{0x03, 0xd9, 0xa2, 0x9a, 0x67, 0xfb, 0x4b, 0xb5, 0x01, 0x00, 0x03, 0x00}
+	if _, err := Read(bytes.NewReader(kdbx3), "x"); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("expected ErrUnsupportedVersion, got %v", err)
+	}
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
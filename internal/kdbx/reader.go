@@ -0,0 +1,152 @@
+package kdbx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Read decrypts a KDBX 4 database protected by password.
+func Read(r io.Reader, password string) (*Database, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	h, n, err := readHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < n+64 {
+		return nil, ErrCorrupt
+	}
+	rawHeader := data[:n]
+	if sum := sha256.Sum256(rawHeader); !bytes.Equal(sum[:], data[n:n+32]) {
+		return nil, ErrCorrupt
+	}
+
+	transformed, err := transformKey(h.kdf, compositeKey(password))
+	if err != nil {
+		return nil, err
+	}
+	base := hmacBaseKey(h.masterSeed, transformed)
+	if !hmac.Equal(headerHMAC(base, rawHeader), data[n+32:n+64]) {
+		return nil, ErrInvalidCredentials
+	}
+
+	ciphertext, err := readBlocks(data[n+64:], base)
+	if err != nil {
+		return nil, err
+	}
+	key := sha256.Sum256(append(append([]byte(nil), h.masterSeed...), transformed...))
+	payload, err := decryptPayload(h.cipherID, key[:], h.iv, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	if h.compressed {
+		zr, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+		if payload, err = io.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+	}
+
+	stream, binaries, doc, err := readInnerHeader(payload)
+	if err != nil {
+		return nil, err
+	}
+	if doc, err = transformProtected(doc, unprotect(stream)); err != nil {
+		return nil, err
+	}
+	var file xmlFile
+	if err := xml.Unmarshal(doc, &file); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+
+	db := &Database{Name: file.Meta.DatabaseName}
+	recycleBin := ""
+	if file.Meta.RecycleBinEnabled != "False" {
+		recycleBin = file.Meta.RecycleBinUUID
+	}
+	db.Root = convertGroup(file.Root.Group, binaries, recycleBin)
+	return db, nil
+}
+
+func readInnerHeader(data []byte) (keyStream, [][]byte, []byte, error) {
+	var streamID uint32
+	var streamKey []byte
+	var binaries [][]byte
+	pos := 0
+	for {
+		if pos+5 > len(data) {
+			return nil, nil, nil, ErrCorrupt
+		}
+		id := data[pos]
+		size := int(binary.LittleEndian.Uint32(data[pos+1:]))
+		pos += 5
+		if size < 0 || pos+size > len(data) {
+			return nil, nil, nil, ErrCorrupt
+		}
+		value := data[pos : pos+size]
+		pos += size
+
+		switch id {
+		case innerEnd:
+			stream, err := newInnerStream(streamID, streamKey)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			return stream, binaries, data[pos:], nil
+		case innerStreamID:
+			if len(value) != 4 {
+				return nil, nil, nil, ErrCorrupt
+			}
+			streamID = binary.LittleEndian.Uint32(value)
+		case innerStreamKey:
+			streamKey = value
+		case innerBinary:
+			if len(value) < 1 {
+				return nil, nil, nil, ErrCorrupt
+			}
+			binaries = append(binaries, value[1:]) // first byte holds flags
+		}
+	}
+}
+
+func convertGroup(g xmlGroup, binaries [][]byte, recycleBin string) *Group {
+	group := &Group{Name: g.Name, IsRecycleBin: recycleBin != "" && g.UUID == recycleBin}
+	for _, e := range g.Entries {
+		group.Entries = append(group.Entries, convertEntry(e, binaries))
+	}
+	for _, sub := range g.Groups {
+		group.Groups = append(group.Groups, convertGroup(sub, binaries, recycleBin))
+	}
+	return group
+}
+
+func convertEntry(e xmlEntry, binaries [][]byte) *Entry {
+	entry := &Entry{Modified: parseTime(e.Times.LastModificationTime)}
+	for _, s := range e.Strings {
+		entry.Strings = append(entry.Strings, String{
+			Key:       s.Key,
+			Value:     s.Value.Text,
+			Protected: s.Value.Protected == "True",
+		})
+	}
+	for _, b := range e.Binaries {
+		if b.Value.Ref < 0 || b.Value.Ref >= len(binaries) {
+			continue
+		}
+		entry.Binaries = append(entry.Binaries, Binary{Name: b.Key, Data: binaries[b.Value.Ref]})
+	}
+	for _, h := range e.History {
+		entry.History = append(entry.History, convertEntry(h, binaries))
+	}
+	return entry
+}
@@ -0,0 +1,153 @@
+package kdbx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// Write encrypts db with password and writes it to w as a KDBX 4.0 file
+// using AES-256, Argon2id with the given parameters, and gzip compression.
+func Write(w io.Writer, db *Database, password string, kdf KDFParams) error {
+	h := &header{
+		cipherID:   cipherAES256,
+		compressed: true,
+		masterSeed: make([]byte, 32),
+		iv:         make([]byte, 16),
+		kdf:        make(variantDict),
+	}
+	salt := make([]byte, 32)
+	for _, b := range [][]byte{h.masterSeed, h.iv, salt} {
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+	}
+	h.kdf.setBytes("$UUID", kdfArgon2id)
+	h.kdf.setBytes("S", salt)
+	h.kdf.setUint32("P", kdf.Parallelism)
+	h.kdf.setUint64("M", kdf.Memory)
+	h.kdf.setUint64("I", kdf.Iterations)
+	h.kdf.setUint32("V", argon2Version)
+	return encode(w, db, password, h)
+}
+
+// encode writes db using the cipher, KDF and compression set in h.
+func encode(w io.Writer, db *Database, password string, h *header) error {
+	streamKey := make([]byte, 64)
+	if _, err := rand.Read(streamKey); err != nil {
+		return err
+	}
+	stream, err := newInnerStream(innerStreamChaCha, streamKey)
+	if err != nil {
+		return err
+	}
+	var binaries [][]byte
+	file := xmlFile{Meta: xmlMeta{Generator: "PassBook", DatabaseName: db.Name}}
+	file.Root.Group = buildGroup(db.Root, &binaries, &file.Meta)
+	doc, err := xml.MarshalIndent(file, "", "\t")
+	if err != nil {
+		return err
+	}
+	doc = append([]byte(xml.Header), doc...)
+	if doc, err = transformProtected(doc, protect(stream)); err != nil {
+		return err
+	}
+
+	var inner bytes.Buffer
+	streamID := make([]byte, 4)
+	binary.LittleEndian.PutUint32(streamID, innerStreamChaCha)
+	writeField(&inner, innerStreamID, streamID)
+	writeField(&inner, innerStreamKey, streamKey)
+	for _, b := range binaries {
+		writeField(&inner, innerBinary, append([]byte{0}, b...))
+	}
+	writeField(&inner, innerEnd, nil)
+	inner.Write(doc)
+
+	payload := inner.Bytes()
+	if h.compressed {
+		var compressed bytes.Buffer
+		zw := gzip.NewWriter(&compressed)
+		if _, err := zw.Write(payload); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		payload = compressed.Bytes()
+	}
+
+	transformed, err := transformKey(h.kdf, compositeKey(password))
+	if err != nil {
+		return err
+	}
+	key := sha256.Sum256(append(append([]byte(nil), h.masterSeed...), transformed...))
+	ciphertext, err := encryptPayload(h.cipherID, key[:], h.iv, payload)
+	if err != nil {
+		return err
+	}
+
+	base := hmacBaseKey(h.masterSeed, transformed)
+	rawHeader := h.bytes()
+	sum := sha256.Sum256(rawHeader)
+
+	var out bytes.Buffer
+	out.Write(rawHeader)
+	out.Write(sum[:])
+	out.Write(headerHMAC(base, rawHeader))
+	writeBlocks(&out, ciphertext, base)
+	_, err = w.Write(out.Bytes())
+	return err
+}
+
+func buildGroup(g *Group, binaries *[][]byte, meta *xmlMeta) xmlGroup {
+	group := xmlGroup{UUID: newUUID(), Name: g.Name, Times: newTimes(time.Now())}
+	if g.IsRecycleBin {
+		meta.RecycleBinEnabled = "True"
+		meta.RecycleBinUUID = group.UUID
+	}
+	for _, e := range g.Entries {
+		group.Entries = append(group.Entries, buildEntry(e, binaries))
+	}
+	for _, sub := range g.Groups {
+		group.Groups = append(group.Groups, buildGroup(sub, binaries, meta))
+	}
+	return group
+}
+
+func buildEntry(e *Entry, binaries *[][]byte) xmlEntry {
+	modified := e.Modified
+	if modified.IsZero() {
+		modified = time.Now()
+	}
+	entry := xmlEntry{UUID: newUUID(), Times: newTimes(modified)}
+	for _, s := range e.Strings {
+		v := xmlValue{Text: s.Value}
+		if s.Protected {
+			v.Protected = "True"
+		}
+		entry.Strings = append(entry.Strings, xmlString{Key: s.Key, Value: v})
+	}
+	for _, b := range e.Binaries {
+		entry.Binaries = append(entry.Binaries, xmlBinary{Key: b.Name, Value: xmlBinaryValue{Ref: len(*binaries)}})
+		*binaries = append(*binaries, b.Data)
+	}
+	for _, h := range e.History {
+		hist := buildEntry(h, binaries)
+		hist.UUID = entry.UUID
+		entry.History = append(entry.History, hist)
+	}
+	return entry
+}
+
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return base64.StdEncoding.EncodeToString(b[:])
+}
@@ -0,0 +1,180 @@
+package kdbx
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+type xmlFile struct {
+	XMLName xml.Name `xml:"KeePassFile"`
+	Meta    xmlMeta  `xml:"Meta"`
+	Root    xmlRoot  `xml:"Root"`
+}
+
+type xmlMeta struct {
+	Generator         string `xml:"Generator"`
+	DatabaseName      string `xml:"DatabaseName"`
+	RecycleBinEnabled string `xml:"RecycleBinEnabled,omitempty"`
+	RecycleBinUUID    string `xml:"RecycleBinUUID,omitempty"`
+}
+
+type xmlRoot struct {
+	Group xmlGroup `xml:"Group"`
+}
+
+type xmlGroup struct {
+	UUID    string     `xml:"UUID"`
+	Name    string     `xml:"Name"`
+	Times   xmlTimes   `xml:"Times"`
+	Entries []xmlEntry `xml:"Entry"`
+	Groups  []xmlGroup `xml:"Group"`
+}
+
+type xmlEntry struct {
+	UUID     string      `xml:"UUID"`
+	Times    xmlTimes    `xml:"Times"`
+	Strings  []xmlString `xml:"String"`
+	Binaries []xmlBinary `xml:"Binary"`
+	History  []xmlEntry  `xml:"History>Entry"`
+}
+
+type xmlTimes struct {
+	CreationTime         string `xml:"CreationTime,omitempty"`
+	LastModificationTime string `xml:"LastModificationTime,omitempty"`
+	LastAccessTime       string `xml:"LastAccessTime,omitempty"`
+	ExpiryTime           string `xml:"ExpiryTime,omitempty"`
+	Expires              string `xml:"Expires,omitempty"`
+	UsageCount           string `xml:"UsageCount,omitempty"`
+	LocationChanged      string `xml:"LocationChanged,omitempty"`
+}
+
+type xmlString struct {
+	Key   string   `xml:"Key"`
+	Value xmlValue `xml:"Value"`
+}
+
+type xmlValue struct {
+	Protected string `xml:"Protected,attr,omitempty"`
+	Text      string `xml:",chardata"`
+}
+
+type xmlBinary struct {
+	Key   string         `xml:"Key"`
+	Value xmlBinaryValue `xml:"Value"`
+}
+
+type xmlBinaryValue struct {
+	Ref int `xml:"Ref,attr"`
+}
+
+// transformProtected rewrites the text of every <Value Protected="True">
+// element in doc order, which is the order the inner stream is consumed in.
+func transformProtected(doc []byte, fn func(string) (string, error)) ([]byte, error) {
+	dec := xml.NewDecoder(bytes.NewReader(doc))
+	var out bytes.Buffer
+	enc := xml.NewEncoder(&out)
+
+	var protected bool
+	var text bytes.Buffer
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "Value" {
+				for _, a := range t.Attr {
+					if a.Name.Local == "Protected" && a.Value == "True" {
+						protected = true
+						text.Reset()
+					}
+				}
+			}
+		case xml.CharData:
+			if protected {
+				text.Write(t)
+				continue
+			}
+		case xml.EndElement:
+			if protected {
+				protected = false
+				value, err := fn(text.String())
+				if err != nil {
+					return nil, err
+				}
+				if err := enc.EncodeToken(xml.CharData(value)); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if err := enc.EncodeToken(xml.CopyToken(tok)); err != nil {
+			return nil, err
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func unprotect(stream keyStream) func(string) (string, error) {
+	return func(s string) (string, error) {
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return "", fmt.Errorf("%w: bad protected value", ErrCorrupt)
+		}
+		stream.XORKeyStream(b, b)
+		return string(b), nil
+	}
+}
+
+func protect(stream keyStream) func(string) (string, error) {
+	return func(s string) (string, error) {
+		b := []byte(s)
+		stream.XORKeyStream(b, b)
+		return base64.StdEncoding.EncodeToString(b), nil
+	}
+}
+
+// KDBX 4 stores times as base64-encoded seconds since 0001-01-01 UTC.
+const unixToKDBXSeconds = 62135596800
+
+func parseTime(s string) time.Time {
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil && len(b) == 8 {
+		secs := int64(binary.LittleEndian.Uint64(b))
+		return time.Unix(secs-unixToKDBXSeconds, 0).UTC()
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+func formatTime(t time.Time) string {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(t.Unix()+unixToKDBXSeconds))
+	return base64.StdEncoding.EncodeToString(b[:])
+}
+
+func newTimes(t time.Time) xmlTimes {
+	ts := formatTime(t)
+	return xmlTimes{
+		CreationTime:         ts,
+		LastModificationTime: ts,
+		LastAccessTime:       ts,
+		ExpiryTime:           ts,
+		Expires:              "False",
+		UsageCount:           "0",
+		LocationChanged:      ts,
+	}
+}
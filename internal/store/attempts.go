@@ -0,0 +1,149 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Kinds of unlock attempt counted by Attempts.
+const (
+	AttemptPassword = "password"
+	AttemptPIN      = "pin"
+)
+
+const (
+	// freeAttempts failures are allowed before each further attempt has to
+	// wait, 1s after the next failure and twice as long after each one
+	// after that, up to maxBackoff.
+	freeAttempts = 3
+	maxBackoff   = 15 * time.Minute
+
+	// LockoutPeriod is the wait after every failure once the configured
+	// maximum has been reached.
+	LockoutPeriod = time.Hour
+)
+
+// Attempts counts the failed unlocks of one kind for a vault. The count is
+// kept in a file next to the database, so restarting PassBook does not
+// reset it; only a successful unlock does.
+type Attempts struct {
+	path        string
+	kind        string
+	Failures    int
+	LastFailure time.Time
+}
+
+type attemptRecord struct {
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"last_failure"`
+}
+
+func attemptsPath(dbPath string) string {
+	return dbPath + ".attempts"
+}
+
+// LoadAttempts reads the failed attempts of kind for the vault at dbPath.
+func LoadAttempts(dbPath, kind string) *Attempts {
+	a := &Attempts{path: attemptsPath(dbPath), kind: kind}
+	r := a.records()[kind]
+	a.Failures, a.LastFailure = r.Failures, r.LastFailure
+	return a
+}
+
+func (a *Attempts) records() map[string]attemptRecord {
+	records := make(map[string]attemptRecord)
+	if data, err := os.ReadFile(a.path); err == nil {
+		_ = json.Unmarshal(data, &records)
+	}
+	return records
+}
+
+func (a *Attempts) save() error {
+	records := a.records()
+	if a.Failures == 0 {
+		delete(records, a.kind)
+	} else {
+		records[a.kind] = attemptRecord{Failures: a.Failures, LastFailure: a.LastFailure}
+	}
+	if len(records) == 0 {
+		if err := os.Remove(a.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(a.path, data, 0600)
+}
+
+// Wait returns how long the next attempt has to wait at now. limit is the
+// configured maximum number of failures, 0 for none; once it is reached
+// every attempt waits LockoutPeriod.
+func (a *Attempts) Wait(limit int, now time.Time) time.Duration {
+	var delay time.Duration
+	if n := a.Failures - freeAttempts - 1; n >= 0 {
+		delay = maxBackoff
+		if n < 20 && time.Second<<n < maxBackoff {
+			delay = time.Second << n
+		}
+	}
+	if a.Exceeded(limit) {
+		delay = LockoutPeriod
+	}
+	if wait := a.LastFailure.Add(delay).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// Exceeded reports whether limit failures, if limit is not 0, have been
+// reached.
+func (a *Attempts) Exceeded(limit int) bool {
+	return limit > 0 && a.Failures >= limit
+}
+
+// Remaining returns the failures left before limit is reached, or -1 when
+// there is no limit.
+func (a *Attempts) Remaining(limit int) int {
+	if limit <= 0 {
+		return -1
+	}
+	if n := limit - a.Failures; n > 0 {
+		return n
+	}
+	return 0
+}
+
+// Fail records a failed attempt at now.
+func (a *Attempts) Fail(now time.Time) error {
+	a.Failures++
+	a.LastFailure = now
+	return a.save()
+}
+
+// Reset clears the count after a successful unlock.
+func (a *Attempts) Reset() error {
+	if a.Failures == 0 {
+		return nil
+	}
+	a.Failures, a.LastFailure = 0, time.Time{}
+	return a.save()
+}
+
+// RemoveVault deletes the vault at dbPath and every file holding a copy of
+// it or its key material: its WAL files, cipher headers, a re-encrypted
+// copy left by an interrupted UpgradeKDF, the backups kept from schema
+// upgrades and the attempt counters. The store must be closed.
+func RemoveVault(dbPath string) error {
+	backups, _ := filepath.Glob(dbPath + ".v*.bak")
+	var paths []string
+	for _, db := range append([]string{dbPath, upgradePath(dbPath)}, backups...) {
+		paths = append(paths, db, db+"-wal", db+"-shm", db+"-journal",
+			headerPath(db), headerPath(db)+".tmp", previousHeaderPath(db), previousHeaderPath(db)+".tmp")
+	}
+	return removeFiles(append(paths, attemptsPath(dbPath))...)
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAttemptsBackoff(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "passbook.db")
+	now := time.Now()
+
+	a := LoadAttempts(dbPath, AttemptPIN)
+	for i := 0; i < freeAttempts; i++ {
+		if err := a.Fail(now); err != nil {
+			t.Fatalf("Fail: %v", err)
+		}
+	}
+	if w := a.Wait(0, now); w != 0 {
+		t.Fatalf("expected no wait after %d failures, got %v", freeAttempts, w)
+	}
+	a.Fail(now)
+	a.Fail(now)
+	if w := a.Wait(0, now); w != 2*time.Second {
+		t.Fatalf("expected a 2s wait, got %v", w)
+	}
+	if w := a.Wait(0, now.Add(time.Second)); w != time.Second {
+		t.Fatalf("expected the wait to shrink over time, got %v", w)
+	}
+
+	// The count survives a restart and is kept per kind.
+	if got := LoadAttempts(dbPath, AttemptPIN); got.Failures != 5 || !got.LastFailure.Equal(now) {
+		t.Fatalf("expected 5 failures to be kept, got %+v", got)
+	}
+	if got := LoadAttempts(dbPath, AttemptPassword); got.Failures != 0 {
+		t.Fatalf("expected no password failures, got %d", got.Failures)
+	}
+
+	if !a.Exceeded(5) || a.Remaining(5) != 0 || a.Remaining(0) != -1 {
+		t.Fatalf("expected the maximum of 5 to be reached")
+	}
+	if w := a.Wait(5, now); w != LockoutPeriod {
+		t.Fatalf("expected the lockout period, got %v", w)
+	}
+
+	if err := a.Reset(); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if _, err := os.Stat(attemptsPath(dbPath)); !os.IsNotExist(err) {
+		t.Fatalf("expected the counter file to be removed, got %v", err)
+	}
+}
+
+func TestRemoveVault(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "passbook.db")
+	s, err := Open(dbPath, "testpass")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	s.Close()
+	for _, p := range []string{
+		backupPath(dbPath, 3), headerPath(backupPath(dbPath, 3)),
+		upgradePath(dbPath), upgradePath(dbPath) + "-wal", headerPath(upgradePath(dbPath)),
+		previousHeaderPath(dbPath), headerPath(dbPath) + ".tmp",
+	} {
+		os.WriteFile(p, []byte("copy"), 0600)
+	}
+	LoadAttempts(dbPath, AttemptPassword).Fail(time.Now())
+
+	if err := RemoveVault(dbPath); err != nil {
+		t.Fatalf("RemoveVault: %v", err)
+	}
+	left, _ := filepath.Glob(dbPath + "*")
+	if len(left) != 0 {
+		t.Fatalf("expected every vault file to be removed, left %v", left)
+	}
+}
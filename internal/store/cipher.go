@@ -0,0 +1,619 @@
+package store
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	sqlite3 "github.com/mutecomm/go-sqlcipher/v4"
+
+	"passbook/internal/crypto"
+)
+
+// SQLCipher 4's own defaults, which vaults without a cipher header use.
+const (
+	sqlcipherPageSize = 4096
+	sqlcipherKDFIter  = 256000
+)
+
+// CipherParams say how a vault's key is derived and how it is encrypted.
+// The master password is stretched with Argon2id under KDF and a salt of
+// the vault's own; SQLCipher then runs KDFIter rounds of PBKDF2 over the
+// result and encrypts pages of PageSize bytes.
+type CipherParams struct {
+	KDF      crypto.KDFParams
+	PageSize int
+	KDFIter  int
+}
+
+// DefaultCipherParams is what new vaults get unless configured otherwise.
+func DefaultCipherParams() CipherParams {
+	return CipherParams{KDF: crypto.DefaultKDFParams, PageSize: sqlcipherPageSize, KDFIter: sqlcipherKDFIter}
+}
+
+// Validate reports parameters SQLCipher or Argon2id cannot use.
+func (p CipherParams) Validate() error {
+	if err := p.KDF.Validate(); err != nil {
+		return err
+	}
+	if p.PageSize < 512 || p.PageSize > 65536 || p.PageSize&(p.PageSize-1) != 0 {
+		return fmt.Errorf("cipher page size must be a power of two from 512 to 65536")
+	}
+	if p.KDFIter < 1 {
+		return fmt.Errorf("kdf_iter must be at least 1")
+	}
+	return nil
+}
+
+// Credentials open a vault: the master password, plus the keyfile hash
+// (see crypto.ReadKeyfile) and the PIN when its cipher header asks for
+// them. Those the vault does not need are ignored.
+type Credentials struct {
+	Password string
+	Keyfile  []byte
+	PIN      string
+}
+
+// keyFactors are the keyfile hash and PIN a vault was opened with, kept
+// in locked memory while it is open so that it can be re-keyed.
+type keyFactors struct {
+	keyfile *crypto.Secret
+	pin     *crypto.Secret
+}
+
+func newKeyFactors(c Credentials) keyFactors {
+	return keyFactors{keyfile: crypto.NewSecret(c.Keyfile), pin: crypto.SecretString(c.PIN)}
+}
+
+func (f keyFactors) release() {
+	f.keyfile.Release()
+	f.pin.Release()
+}
+
+var (
+	ErrKeyfileRequired = errors.New("this vault needs its keyfile")
+	ErrPINRequired     = errors.New("this vault needs the PIN")
+
+	// ErrWrongKey is returned when the derived key does not decrypt the
+	// vault: a wrong password, keyfile or PIN. SQLCipher cannot tell this
+	// from a file that is not a vault at all.
+	ErrWrongKey = errors.New("wrong password or key")
+
+	// ErrNoCipherHeader is returned for changes that need a vault created
+	// or upgraded with a cipher header.
+	ErrNoCipherHeader = errors.New("the vault has no cipher header; run passbook vault upgrade-kdf first")
+)
+
+// cipherHeader is kept in a file next to the database. It holds the salt
+// and every parameter needed to derive the key, so a vault keeps opening
+// when the defaults or the configuration change. Vaults from before the
+// header existed have none and pass the master password to SQLCipher as
+// is.
+type cipherHeader struct {
+	Version  int    `json:"version"`
+	KDF      string `json:"kdf"`
+	Salt     []byte `json:"salt"`
+	Memory   uint32 `json:"memory_kib"`
+	Time     uint32 `json:"time"`
+	Threads  uint8  `json:"threads"`
+	PageSize int    `json:"cipher_page_size"`
+	KDFIter  int    `json:"kdf_iter"`
+
+	// Keyfile and PIN say whether the keyfile hash and the PIN are part of
+	// the key besides the master password.
+	Keyfile bool `json:"keyfile,omitempty"`
+	PIN     bool `json:"pin,omitempty"`
+
+	// Recovery is the SQLCipher passphrase sealed with the recovery key,
+	// see SetupRecovery.
+	Recovery []byte `json:"recovery,omitempty"`
+}
+
+const (
+	cipherHeaderVersion = 1
+	kdfArgon2id         = "argon2id"
+)
+
+func headerPath(dbPath string) string {
+	return dbPath + ".kdf"
+}
+
+// previousHeaderPath holds the header a vault had before its key was
+// switched, until the switch is complete. An empty file stands for a vault
+// that had no header.
+func previousHeaderPath(dbPath string) string {
+	return headerPath(dbPath) + ".old"
+}
+
+// upgradePath is the re-encrypted copy UpgradeKDF writes before it
+// replaces the vault.
+func upgradePath(dbPath string) string {
+	return dbPath + ".upgrade"
+}
+
+// renameFile moves the re-encrypted copy over the vault. Tests replace it.
+var renameFile = os.Rename
+
+func newCipherHeader(p CipherParams) (*cipherHeader, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	salt, err := crypto.NewSalt()
+	if err != nil {
+		return nil, err
+	}
+	return &cipherHeader{
+		Version:  cipherHeaderVersion,
+		KDF:      kdfArgon2id,
+		Salt:     salt,
+		Memory:   p.KDF.Memory,
+		Time:     p.KDF.Time,
+		Threads:  p.KDF.Threads,
+		PageSize: p.PageSize,
+		KDFIter:  p.KDFIter,
+	}, nil
+}
+
+// readCipherHeader returns the header of the vault at dbPath, or nil when
+// it has none.
+func readCipherHeader(dbPath string) (*cipherHeader, error) {
+	data, err := os.ReadFile(headerPath(dbPath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading cipher header: %w", err)
+	}
+	return parseCipherHeader(data)
+}
+
+func parseCipherHeader(data []byte) (*cipherHeader, error) {
+	var h cipherHeader
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("parsing cipher header: %w", err)
+	}
+	if h.Version > cipherHeaderVersion || h.KDF != kdfArgon2id {
+		return nil, fmt.Errorf("%w (cipher header version %d, kdf %q)", ErrSchemaTooNew, h.Version, h.KDF)
+	}
+	if err := h.params().Validate(); err != nil {
+		return nil, fmt.Errorf("cipher header: %w", err)
+	}
+	return &h, nil
+}
+
+func writeCipherHeader(dbPath string, h *cipherHeader) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(headerPath(dbPath), data)
+}
+
+// writeFileAtomic replaces path with data, so that a crash leaves either
+// the old or the new contents.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// beginSwitch makes h the header of the vault at dbPath, keeping old, its
+// current header or nil, as the previous header. Until finishSwitch, a
+// vault that h does not open is opened with the previous header instead,
+// so that a crash between replacing the header and re-encrypting the
+// database leaves a vault that still opens.
+func beginSwitch(dbPath string, old, h *cipherHeader) error {
+	var data []byte
+	if old != nil {
+		var err error
+		if data, err = json.MarshalIndent(old, "", "  "); err != nil {
+			return err
+		}
+	}
+	if err := writeFileAtomic(previousHeaderPath(dbPath), data); err != nil {
+		return fmt.Errorf("keeping cipher header: %w", err)
+	}
+	if err := writeCipherHeader(dbPath, h); err != nil {
+		_ = removeFiles(previousHeaderPath(dbPath))
+		return fmt.Errorf("writing cipher header: %w", err)
+	}
+	return nil
+}
+
+// finishSwitch drops the previous header once the database is encrypted
+// under the new one.
+func finishSwitch(dbPath string) {
+	_ = removeFiles(previousHeaderPath(dbPath))
+}
+
+// restorePreviousHeader puts back the header beginSwitch kept.
+func restorePreviousHeader(dbPath string) error {
+	data, err := os.ReadFile(previousHeaderPath(dbPath))
+	if err != nil {
+		return fmt.Errorf("restoring cipher header: %w", err)
+	}
+	if len(data) == 0 {
+		err = removeFiles(headerPath(dbPath))
+	} else {
+		err = os.Rename(previousHeaderPath(dbPath), headerPath(dbPath))
+	}
+	if err != nil {
+		return fmt.Errorf("restoring cipher header: %w", err)
+	}
+	return removeFiles(previousHeaderPath(dbPath))
+}
+
+// openPrevious opens the vault at dbPath with the header it had before a
+// key switch that did not complete, and makes that its header again. It
+// returns nil if there is no previous header or it does not open the
+// vault either.
+func openPrevious(dbPath string, c Credentials) *Store {
+	data, err := os.ReadFile(previousHeaderPath(dbPath))
+	if err != nil {
+		return nil
+	}
+	var h *cipherHeader
+	if len(data) > 0 {
+		if h, err = parseCipherHeader(data); err != nil {
+			return nil
+		}
+	}
+	s, err := open(dbPath, c, h)
+	if err != nil {
+		return nil
+	}
+	if err := restorePreviousHeader(dbPath); err != nil {
+		s.Close()
+		return nil
+	}
+	return s
+}
+
+func (h *cipherHeader) params() CipherParams {
+	return CipherParams{
+		KDF:      crypto.KDFParams{Memory: h.Memory, Time: h.Time, Threads: h.Threads},
+		PageSize: h.PageSize,
+		KDFIter:  h.KDFIter,
+	}
+}
+
+// factors keeps the parts of c besides the password that h needs.
+func (h *cipherHeader) factors(c Credentials) Credentials {
+	var f Credentials
+	if h.Keyfile {
+		f.Keyfile = c.Keyfile
+	}
+	if h.PIN {
+		f.PIN = c.PIN
+	}
+	return f
+}
+
+// key derives the passphrase SQLCipher is given for c.
+func (h *cipherHeader) key(c Credentials) (string, error) {
+	if h.Keyfile && c.Keyfile == nil {
+		return "", ErrKeyfileRequired
+	}
+	if h.PIN && c.PIN == "" {
+		return "", ErrPINRequired
+	}
+	f := h.factors(c)
+	secret := crypto.CompositeKey(c.Password, f.Keyfile, f.PIN)
+	defer crypto.WipeBytes(secret)
+	k := crypto.DeriveKey(secret, h.Salt, h.params().KDF)
+	defer crypto.WipeBytes(k)
+	return hex.EncodeToString(k), nil
+}
+
+// connectMu serialises connecting to a vault. SQLCipher takes kdf_iter
+// for a database only from its process-wide default: the driver reads
+// the schema before a PRAGMA kdf_iter on the new connection could run.
+var connectMu sync.Mutex
+
+// connect opens db's connection with SQLCipher's default kdf_iter set to
+// kdfIter and checks that the key decrypts it, returning ErrWrongKey if
+// it does not.
+func connect(db *sql.DB, kdfIter int) error {
+	connectMu.Lock()
+	defer connectMu.Unlock()
+
+	mem, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return err
+	}
+	_, err = mem.Exec(fmt.Sprintf("PRAGMA cipher_default_kdf_iter = %d", kdfIter))
+	mem.Close()
+	if err != nil {
+		return fmt.Errorf("setting kdf_iter: %w", err)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT count(*) FROM sqlite_master").Scan(&count); err != nil {
+		var sqlErr sqlite3.Error
+		if errors.As(err, &sqlErr) && sqlErr.Code == sqlite3.ErrNotADB {
+			return ErrWrongKey
+		}
+		return fmt.Errorf("reading database: %w", err)
+	}
+	return nil
+}
+
+// Create makes a new vault at dbPath, encrypted with p and a new salt.
+func Create(dbPath, key string, p CipherParams) (*Store, error) {
+	return CreateWith(dbPath, Credentials{Password: key}, p)
+}
+
+// CreateWith is Create for a vault whose key includes c's keyfile as well,
+// if it has one. A PIN only becomes part of the key once it is set up.
+func CreateWith(dbPath string, c Credentials, p CipherParams) (*Store, error) {
+	if DBExists(dbPath) {
+		return nil, fmt.Errorf("a vault already exists at %s", dbPath)
+	}
+	h, err := newCipherHeader(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0700); err != nil {
+		return nil, fmt.Errorf("creating db directory: %w", err)
+	}
+	h.Keyfile = c.Keyfile != nil
+	c.PIN = ""
+	if err := writeCipherHeader(dbPath, h); err != nil {
+		return nil, fmt.Errorf("writing cipher header: %w", err)
+	}
+	s, err := open(dbPath, c, h)
+	if err != nil {
+		os.Remove(headerPath(dbPath))
+		return nil, err
+	}
+	return s, nil
+}
+
+// UpgradeKDF re-encrypts the vault with p and a new salt. password must be
+// the master password s was opened with. The vault is copied to a new
+// file, which replaces it once it opens; s is closed either way.
+func (s *Store) UpgradeKDF(password string, p CipherParams) error {
+	h, err := newCipherHeader(p)
+	if err != nil {
+		s.Close()
+		return err
+	}
+	// The keyfile and PIN stay part of the key.
+	c := s.credentials(password)
+	if s.header != nil {
+		h.Keyfile, h.PIN = s.header.Keyfile, s.header.PIN
+	}
+	key, err := h.key(c)
+	if err == nil {
+		err = s.sealRecovery(h, key)
+	}
+	if err != nil {
+		s.Close()
+		return err
+	}
+	tmp := upgradePath(s.path)
+	removeFiles(tmp, tmp+"-wal", tmp+"-shm", headerPath(tmp))
+
+	err = s.exportTo(tmp, h, key)
+	s.Close()
+	if err == nil {
+		err = writeCipherHeader(tmp, h)
+	}
+	if err == nil {
+		var check *Store
+		if check, err = open(tmp, c, h); err == nil {
+			check.Close()
+		}
+	}
+	removeFiles(headerPath(tmp))
+	if err == nil {
+		err = beginSwitch(s.path, s.header, h)
+	}
+	if err != nil {
+		removeFiles(tmp, tmp+"-wal", tmp+"-shm")
+		return fmt.Errorf("re-encrypting vault: %w", err)
+	}
+
+	// The export read through the WAL, so whatever close left of it is
+	// already in the copy.
+	removeFiles(s.path+"-wal", s.path+"-shm")
+	if err := renameFile(tmp, s.path); err != nil {
+		removeFiles(tmp, tmp+"-wal", tmp+"-shm")
+		if restoreErr := restorePreviousHeader(s.path); restoreErr != nil {
+			return fmt.Errorf("replacing vault: %w; %v", err, restoreErr)
+		}
+		return fmt.Errorf("replacing vault: %w", err)
+	}
+	finishSwitch(s.path)
+	return nil
+}
+
+// exportTo copies the vault into a new database at path, encrypted with
+// key under h.
+func (s *Store) exportTo(path string, h *cipherHeader, key string) error {
+	var version int
+	if err := s.q.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec("ATTACH DATABASE ? AS upgraded KEY ?", path, key); err != nil {
+		return err
+	}
+	err := execAll(s.db,
+		fmt.Sprintf("PRAGMA upgraded.cipher_page_size = %d", h.PageSize),
+		fmt.Sprintf("PRAGMA upgraded.kdf_iter = %d", h.KDFIter),
+		"SELECT sqlcipher_export('upgraded')",
+		fmt.Sprintf("PRAGMA upgraded.user_version = %d", version),
+	)
+	if _, detachErr := s.db.Exec("DETACH DATABASE upgraded"); err == nil {
+		err = detachErr
+	}
+	return err
+}
+
+func execAll(db querier, queries ...string) error {
+	for _, q := range queries {
+		if _, err := db.Exec(q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CipherParams returns the parameters the vault is encrypted with and
+// whether it has a cipher header; without one, only SQLCipher's own key
+// derivation protects it.
+func (s *Store) CipherParams() (CipherParams, bool) {
+	if s.header == nil {
+		return CipherParams{PageSize: sqlcipherPageSize, KDFIter: sqlcipherKDFIter}, false
+	}
+	return s.header.params(), true
+}
+
+// credentials are the credentials s was opened with, with password in
+// place of the master password. They are copies, still valid once s is
+// closed.
+func (s *Store) credentials(password string) Credentials {
+	return Credentials{Password: password, Keyfile: bytes.Clone(s.factors.keyfile.Bytes()), PIN: s.factors.pin.String()}
+}
+
+// VerifyPassword checks that password is the master password by opening
+// the vault a second time with it and the other credentials s was opened
+// with.
+func (s *Store) VerifyPassword(password string) error {
+	v, err := open(s.path, s.credentials(password), s.header)
+	if err != nil {
+		return err
+	}
+	return v.Close()
+}
+
+// SetKeyfile re-keys the vault so that opening it needs keyfile, a hash
+// from crypto.ReadKeyfile, besides the master password, or no keyfile when
+// keyfile is nil. password must be the master password.
+func (s *Store) SetKeyfile(password string, keyfile []byte) error {
+	return s.setFactors(password, keyfile, s.factors.pin.String())
+}
+
+// KeyWithPIN makes pin part of the vault key, so that the vault cannot be
+// decrypted without it, and drops the PIN check kept inside the vault.
+func (s *Store) KeyWithPIN(password, pin string) error {
+	if err := s.setFactors(password, s.credentials(password).Keyfile, pin); err != nil {
+		return err
+	}
+	return s.WritePinConfig(&PinConfig{Mode: "pin"})
+}
+
+// UnkeyPIN takes the PIN out of the vault key and keeps it as a check
+// inside the vault instead, for when another second factor has to be able
+// to stand in for it. password must be the master password. It does
+// nothing if the key does not include the PIN.
+func (s *Store) UnkeyPIN(password string) error {
+	if s.header == nil || !s.header.PIN {
+		return nil
+	}
+	pin := s.factors.pin.String()
+	if err := s.setFactors(password, s.credentials(password).Keyfile, ""); err != nil {
+		return err
+	}
+	cfg, err := s.ReadPinConfig()
+	if err != nil {
+		return err
+	}
+	if cfg == nil {
+		cfg = &PinConfig{Mode: "pin"}
+	}
+	if cfg.PinKey, err = crypto.GeneratePinKey(); err != nil {
+		return err
+	}
+	cfg.PinTag = crypto.ComputePinTag(cfg.PinKey, pin)
+	return s.WritePinConfig(cfg)
+}
+
+// CheckPIN reports whether pin is the vault's PIN: the one it was opened
+// with when the PIN is part of the key, or else the one the check kept in
+// the vault was made for.
+func (s *Store) CheckPIN(pin string) bool {
+	if s.header != nil && s.header.PIN {
+		return s.factors.pin.Equal([]byte(pin))
+	}
+	cfg, err := s.ReadPinConfig()
+	return err == nil && cfg != nil && cfg.PinTag != "" && crypto.VerifyPinTag(cfg.PinKey, pin, cfg.PinTag)
+}
+
+// setFactors re-keys the vault so that opening it needs keyfile, unless it
+// is nil, and pin, unless it is empty, besides the master password.
+func (s *Store) setFactors(password string, keyfile []byte, pin string) error {
+	if s.header == nil {
+		return ErrNoCipherHeader
+	}
+	if err := s.VerifyPassword(password); err != nil {
+		return err
+	}
+	h := *s.header
+	h.Keyfile, h.PIN = keyfile != nil, pin != ""
+	c := Credentials{Password: password, Keyfile: keyfile, PIN: pin}
+	key, err := h.key(c)
+	if err != nil {
+		return err
+	}
+	if err := s.switchKey(&h, key); err != nil {
+		return err
+	}
+	old := s.factors
+	s.factors = newKeyFactors(h.factors(c))
+	old.release()
+	return nil
+}
+
+// switchKey re-keys the vault to key, which h describes, and makes h the
+// vault's header. A recovery key is carried over to the new key. The old
+// header is kept until the re-key is done, see beginSwitch.
+func (s *Store) switchKey(h *cipherHeader, key string) error {
+	if err := s.sealRecovery(h, key); err != nil {
+		return err
+	}
+	if err := beginSwitch(s.path, s.header, h); err != nil {
+		return err
+	}
+	if err := s.rekey(key); err != nil {
+		if restoreErr := restorePreviousHeader(s.path); restoreErr != nil {
+			return fmt.Errorf("%w; %v", err, restoreErr)
+		}
+		return err
+	}
+	s.header = h
+	finishSwitch(s.path)
+	return nil
+}
+
+func removeFiles(paths ...string) error {
+	var first error
+	for _, p := range paths {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,314 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"passbook/internal/crypto"
+)
+
+// fastCipherParams keep the tests quick; they are far too weak for a vault.
+var fastCipherParams = CipherParams{
+	KDF:      crypto.KDFParams{Memory: 64, Time: 1, Threads: 1},
+	PageSize: 8192,
+	KDFIter:  1000,
+}
+
+func TestCreateWritesCipherHeader(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "passbook.db")
+	s, err := Create(dbPath, "testpass", fastCipherParams)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := s.SaveEntry(0, &EntryFull{Type: "Login", Title: "Site"}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	s.Close()
+
+	h, err := readCipherHeader(dbPath)
+	if err != nil || h == nil {
+		t.Fatalf("readCipherHeader: %v, %v", h, err)
+	}
+	if len(h.Salt) != crypto.SaltSize || h.params() != fastCipherParams {
+		t.Fatalf("unexpected header %+v", h)
+	}
+
+	if _, err := Open(dbPath, "wrong"); !errors.Is(err, ErrWrongKey) {
+		t.Fatalf("expected ErrWrongKey for the wrong password, got %v", err)
+	}
+	if _, err := open(dbPath, Credentials{Password: "testpass"}, nil); err == nil {
+		t.Fatalf("expected the password alone not to open the vault")
+	}
+	s, err = Open(dbPath, "testpass")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+	if !s.HasEntries() {
+		t.Fatalf("expected the entry to be kept")
+	}
+	if _, err := Create(dbPath, "testpass", fastCipherParams); err == nil {
+		t.Fatalf("expected Create to refuse an existing vault")
+	}
+}
+
+func TestOpenErrorsOtherThanWrongKey(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "passbook.db")
+	s, err := Create(dbPath, "testpass", fastCipherParams)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	s.Close()
+
+	if err := os.WriteFile(headerPath(dbPath), []byte("{not json"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := Open(dbPath, "testpass"); err == nil || errors.Is(err, ErrWrongKey) {
+		t.Fatalf("expected a damaged header not to count as a wrong key, got %v", err)
+	}
+}
+
+func TestOpenRejectsExpensiveHeader(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "passbook.db")
+	s, err := Create(dbPath, "testpass", fastCipherParams)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	s.Close()
+
+	h, err := readCipherHeader(dbPath)
+	if err != nil {
+		t.Fatalf("readCipherHeader: %v", err)
+	}
+	h.Memory = 1<<32 - 1
+	if err := writeCipherHeader(dbPath, h); err != nil {
+		t.Fatalf("writeCipherHeader: %v", err)
+	}
+	if _, err := Open(dbPath, "testpass"); err == nil || errors.Is(err, ErrWrongKey) {
+		t.Fatalf("expected the header to be rejected before deriving a key, got %v", err)
+	}
+}
+
+func TestCreateRejectsBadParams(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "passbook.db")
+	bad := fastCipherParams
+	bad.PageSize = 3000
+	if _, err := Create(dbPath, "testpass", bad); err == nil {
+		t.Fatalf("expected a page size that is not a power of two to be rejected")
+	}
+	if DBExists(dbPath) {
+		t.Fatalf("expected no vault to be created")
+	}
+}
+
+func TestUpgradeKDFFromLegacyVault(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "passbook.db")
+	s, err := open(dbPath, Credentials{Password: "testpass"}, nil)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := s.SaveEntry(0, &EntryFull{Type: "Login", Title: "Site", Password: "s3cret"}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	if _, upgraded := s.CipherParams(); upgraded {
+		t.Fatalf("expected a vault without a cipher header")
+	}
+
+	if err := s.UpgradeKDF("testpass", fastCipherParams); err != nil {
+		t.Fatalf("UpgradeKDF: %v", err)
+	}
+	for _, p := range []string{dbPath + ".upgrade", headerPath(dbPath + ".upgrade")} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be gone", p)
+		}
+	}
+
+	s, err = Open(dbPath, "testpass")
+	if err != nil {
+		t.Fatalf("Open after upgrade: %v", err)
+	}
+	defer s.Close()
+	if p, upgraded := s.CipherParams(); !upgraded || p != fastCipherParams {
+		t.Fatalf("expected %+v, got %+v", fastCipherParams, p)
+	}
+	if v := userVersion(t, s); v != SchemaVersion() {
+		t.Fatalf("expected schema version %d, got %d", SchemaVersion(), v)
+	}
+	entries, err := s.ListEntries(0)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected the entry to survive, got %v, %v", entries, err)
+	}
+	e, err := s.LoadEntry(entries[0].ID)
+	if err != nil || e.Password != "s3cret" {
+		t.Fatalf("expected the password to survive, got %v", err)
+	}
+}
+
+func TestUpgradeKDFRestoresVaultWhenReplaceFails(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "passbook.db")
+	s, err := Create(dbPath, "testpass", fastCipherParams)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := s.SaveEntry(0, &EntryFull{Type: "Login", Title: "Site"}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+
+	renameFile = func(string, string) error { return errors.New("disk full") }
+	t.Cleanup(func() { renameFile = os.Rename })
+	stronger := fastCipherParams
+	stronger.KDFIter = 2000
+	if err := s.UpgradeKDF("testpass", stronger); err == nil {
+		t.Fatalf("expected the upgrade to fail")
+	}
+	tmp := dbPath + ".upgrade"
+	for _, p := range []string{tmp, tmp + "-wal", tmp + "-shm", headerPath(tmp), previousHeaderPath(dbPath)} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be gone", p)
+		}
+	}
+
+	s, err = Open(dbPath, "testpass")
+	if err != nil {
+		t.Fatalf("expected the vault to open as before: %v", err)
+	}
+	defer s.Close()
+	if p, _ := s.CipherParams(); p != fastCipherParams {
+		t.Fatalf("expected the old parameters, got %+v", p)
+	}
+	if entries, _ := s.ListAllEntries(); len(entries) != 1 {
+		t.Fatalf("expected the entry to be kept, got %d entries", len(entries))
+	}
+}
+
+func TestInterruptedKeySwitchFallsBack(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		dbPath := filepath.Join(t.TempDir(), "passbook.db")
+		var s *Store
+		var err error
+		if legacy {
+			s, err = open(dbPath, Credentials{Password: "testpass"}, nil)
+		} else {
+			s, err = Create(dbPath, "testpass", fastCipherParams)
+		}
+		if err != nil {
+			t.Fatalf("creating vault: %v", err)
+		}
+		s.Close()
+		h, err := readCipherHeader(dbPath)
+		if err != nil {
+			t.Fatalf("readCipherHeader: %v", err)
+		}
+
+		// A crash after the new header is written and before the
+		// database is re-encrypted under it.
+		next, err := newCipherHeader(fastCipherParams)
+		if err != nil {
+			t.Fatalf("newCipherHeader: %v", err)
+		}
+		if err := beginSwitch(dbPath, h, next); err != nil {
+			t.Fatalf("beginSwitch: %v", err)
+		}
+
+		s, err = Open(dbPath, "testpass")
+		if err != nil {
+			t.Fatalf("legacy %v: expected the previous header to open the vault: %v", legacy, err)
+		}
+		s.Close()
+		got, err := readCipherHeader(dbPath)
+		if err != nil || (h == nil) != (got == nil) || (h != nil && !bytes.Equal(got.Salt, h.Salt)) {
+			t.Fatalf("legacy %v: expected the previous header back, got %+v, %v", legacy, got, err)
+		}
+		if _, err := os.Stat(previousHeaderPath(dbPath)); !os.IsNotExist(err) {
+			t.Fatalf("legacy %v: expected the previous header to be gone", legacy)
+		}
+	}
+}
+
+func TestRekeyKeepsCipherHeader(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "passbook.db")
+	s, err := Create(dbPath, "testpass", fastCipherParams)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := s.Rekey("newpass"); err != nil {
+		t.Fatalf("Rekey: %v", err)
+	}
+	s.Close()
+
+	if err := VerifyKey(dbPath, "testpass"); err == nil {
+		t.Fatalf("expected the old password to fail")
+	}
+	if err := VerifyKey(dbPath, "newpass"); err != nil {
+		t.Fatalf("VerifyKey: %v", err)
+	}
+}
+
+func TestKeyfileAndPINInKey(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "passbook.db")
+	s, err := Create(dbPath, "testpass", fastCipherParams)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	keyfile := []byte("0123456789abcdef0123456789abcdef")
+	if err := s.SetKeyfile("wrong", keyfile); err == nil {
+		t.Fatalf("expected the wrong master password to be refused")
+	}
+	if err := s.SetKeyfile("testpass", keyfile); err != nil {
+		t.Fatalf("SetKeyfile: %v", err)
+	}
+	if err := s.KeyWithPIN("testpass", "123456"); err != nil {
+		t.Fatalf("KeyWithPIN: %v", err)
+	}
+	if cfg, err := s.ReadPinConfig(); err != nil || cfg.Mode != "pin" || cfg.PinTag != "" {
+		t.Fatalf("expected a PIN config without a tag, got %+v, %v", cfg, err)
+	}
+	if err := s.Rekey("newpass"); err != nil {
+		t.Fatalf("Rekey: %v", err)
+	}
+	s.Close()
+
+	if _, err := Open(dbPath, "newpass"); !errors.Is(err, ErrKeyfileRequired) {
+		t.Fatalf("expected ErrKeyfileRequired, got %v", err)
+	}
+	if _, err := OpenWith(dbPath, Credentials{Password: "newpass", Keyfile: keyfile}); !errors.Is(err, ErrPINRequired) {
+		t.Fatalf("expected ErrPINRequired, got %v", err)
+	}
+	if _, err := OpenWith(dbPath, Credentials{Password: "newpass", Keyfile: keyfile, PIN: "654321"}); !errors.Is(err, ErrWrongKey) {
+		t.Fatalf("expected ErrWrongKey for the wrong PIN, got %v", err)
+	}
+	other := []byte("fedcba9876543210fedcba9876543210")
+	if _, err := OpenWith(dbPath, Credentials{Password: "newpass", Keyfile: other, PIN: "123456"}); !errors.Is(err, ErrWrongKey) {
+		t.Fatalf("expected ErrWrongKey for the wrong keyfile, got %v", err)
+	}
+
+	s, err = OpenWith(dbPath, Credentials{Password: "newpass", Keyfile: keyfile, PIN: "123456"})
+	if err != nil {
+		t.Fatalf("OpenWith: %v", err)
+	}
+	if err := s.SetKeyfile("newpass", nil); err != nil {
+		t.Fatalf("SetKeyfile(nil): %v", err)
+	}
+	if err := s.UpgradeKDF("newpass", fastCipherParams); err != nil {
+		t.Fatalf("UpgradeKDF: %v", err)
+	}
+	s, err = OpenWith(dbPath, Credentials{Password: "newpass", PIN: "123456"})
+	if err != nil {
+		t.Fatalf("expected the PIN to stay part of the key after an upgrade: %v", err)
+	}
+	s.Close()
+}
+
+func TestKeyWithPINNeedsCipherHeader(t *testing.T) {
+	s, err := open(filepath.Join(t.TempDir(), "passbook.db"), Credentials{Password: "testpass"}, nil)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s.Close()
+	if err := s.KeyWithPIN("testpass", "123456"); !errors.Is(err, ErrNoCipherHeader) {
+		t.Fatalf("expected ErrNoCipherHeader, got %v", err)
+	}
+}
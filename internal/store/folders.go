@@ -0,0 +1,252 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ErrFolderCycle is returned by MoveFolder when the new parent is the
+// folder itself or one of its subfolders.
+var ErrFolderCycle = errors.New("a folder cannot be moved into itself")
+
+// FolderInfo is a folder. ParentID is 0 for a top-level folder, and Path is
+// the names from the top down joined with "/", like "Work/AWS".
+type FolderInfo struct {
+	ID       int64
+	ParentID int64
+	Name     string
+	Path     string
+}
+
+// SplitFolderPath splits a "/"-separated folder path into its names,
+// trimming each and dropping empty ones: " Work//AWS/ " gives Work and AWS.
+func SplitFolderPath(path string) []string {
+	var names []string
+	for _, n := range strings.Split(path, "/") {
+		if n = strings.TrimSpace(n); n != "" {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// subtreeCTE selects the folder given as its argument and all folders below
+// it as "subtree(id)".
+const subtreeCTE = `WITH RECURSIVE subtree(id) AS (
+	SELECT ?
+	UNION ALL
+	SELECT f.id FROM folders f JOIN subtree ON f.parent_id = subtree.id
+)`
+
+// ListFolders returns every folder, ordered by path so that each folder
+// comes right after its parent.
+func (s *Store) ListFolders() ([]FolderInfo, error) {
+	rows, err := s.q.Query("SELECT id, parent_id, name FROM folders")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byID := make(map[int64]*FolderInfo)
+	var folders []FolderInfo
+	for rows.Next() {
+		var f FolderInfo
+		if err := rows.Scan(&f.ID, &f.ParentID, &f.Name); err != nil {
+			return nil, err
+		}
+		folders = append(folders, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range folders {
+		byID[folders[i].ID] = &folders[i]
+	}
+	var pathOf func(f *FolderInfo, depth int) string
+	pathOf = func(f *FolderInfo, depth int) string {
+		if f.Path == "" {
+			f.Path = f.Name
+			if parent, ok := byID[f.ParentID]; ok && depth < len(folders) {
+				f.Path = pathOf(parent, depth+1) + "/" + f.Name
+			}
+		}
+		return f.Path
+	}
+	for i := range folders {
+		pathOf(&folders[i], 0)
+	}
+	sort.Slice(folders, func(i, j int) bool {
+		return pathLess(folders[i].Path, folders[j].Path)
+	})
+	return folders, nil
+}
+
+// pathLess orders paths name by name, so "Work/AWS" sorts before
+// "Work Projects" even though ' ' < '/'.
+func pathLess(a, b string) bool {
+	an, bn := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(an) && i < len(bn); i++ {
+		if an[i] != bn[i] {
+			return an[i] < bn[i]
+		}
+	}
+	return len(an) < len(bn)
+}
+
+// ListSubfolders returns the folders directly below parentID, by name.
+func (s *Store) ListSubfolders(parentID int64) ([]FolderInfo, error) {
+	all, err := s.ListFolders()
+	if err != nil {
+		return nil, err
+	}
+	var folders []FolderInfo
+	for _, f := range all {
+		if f.ParentID == parentID {
+			folders = append(folders, f)
+		}
+	}
+	return folders, nil
+}
+
+// CreateFolder creates a folder called name below parentID, or at the top
+// level when parentID is 0. Names are unique among siblings.
+func (s *Store) CreateFolder(parentID int64, name string) (int64, error) {
+	res, err := s.q.Exec("INSERT INTO folders (parent_id, name) VALUES (?, ?)", parentID, name)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// CreateFolderPath returns the folder at path, creating it and any missing
+// parents first. An empty path is the root, 0.
+func (s *Store) CreateFolderPath(path string) (int64, error) {
+	var id int64
+	for _, name := range SplitFolderPath(path) {
+		f, err := s.GetFolderByName(id, name)
+		if err != nil {
+			return 0, err
+		}
+		if f != nil {
+			id = f.ID
+			continue
+		}
+		if id, err = s.CreateFolder(id, name); err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+func (s *Store) RenameFolder(id int64, name string) error {
+	_, err := s.q.Exec("UPDATE folders SET name = ? WHERE id = ?", name, id)
+	return err
+}
+
+// MoveFolder moves folder id, with everything in it, below parentID.
+func (s *Store) MoveFolder(id, parentID int64) error {
+	if parentID != 0 {
+		var inside int
+		err := s.q.QueryRow(subtreeCTE+" SELECT count(*) FROM subtree WHERE id = ?", id, parentID).Scan(&inside)
+		if err != nil {
+			return err
+		}
+		if inside > 0 {
+			return ErrFolderCycle
+		}
+	}
+	_, err := s.q.Exec("UPDATE folders SET parent_id = ? WHERE id = ?", parentID, id)
+	return err
+}
+
+// DeleteFolder deletes folder id together with its subfolders and every
+// entry in them.
+func (s *Store) DeleteFolder(id int64) error {
+	return s.WithTx(func(tx *Store) error {
+		if _, err := tx.q.Exec(subtreeCTE+" DELETE FROM entries WHERE folder_id IN subtree", id); err != nil {
+			return err
+		}
+		_, err := tx.q.Exec(subtreeCTE+" DELETE FROM folders WHERE id IN subtree", id)
+		return err
+	})
+}
+
+// CountFolderContents returns how many subfolders and entries folder id
+// holds, at any depth.
+func (s *Store) CountFolderContents(id int64) (folders, entries int, err error) {
+	err = s.q.QueryRow(
+		subtreeCTE+` SELECT
+			(SELECT count(*) FROM subtree) - 1,
+			(SELECT count(*) FROM entries WHERE folder_id IN subtree)`, id).
+		Scan(&folders, &entries)
+	return folders, entries, err
+}
+
+// GetFolderByName returns the folder called name directly below parentID,
+// or nil if there is none.
+func (s *Store) GetFolderByName(parentID int64, name string) (*FolderInfo, error) {
+	var f FolderInfo
+	err := s.q.QueryRow("SELECT id, parent_id, name FROM folders WHERE parent_id = ? AND name = ?", parentID, name).
+		Scan(&f.ID, &f.ParentID, &f.Name)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, s.fillPath(&f)
+}
+
+// GetFolderByPath returns the folder at a "/"-separated path such as
+// "Work/AWS", or nil if there is none.
+func (s *Store) GetFolderByPath(path string) (*FolderInfo, error) {
+	names := SplitFolderPath(path)
+	if len(names) == 0 {
+		return nil, nil
+	}
+	var f *FolderInfo
+	var parentID int64
+	for _, name := range names {
+		var err error
+		if f, err = s.GetFolderByName(parentID, name); err != nil || f == nil {
+			return nil, err
+		}
+		parentID = f.ID
+	}
+	return f, nil
+}
+
+func (s *Store) GetFolder(id int64) (*FolderInfo, error) {
+	var f FolderInfo
+	err := s.q.QueryRow("SELECT id, parent_id, name FROM folders WHERE id = ?", id).
+		Scan(&f.ID, &f.ParentID, &f.Name)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, s.fillPath(&f)
+}
+
+// fillPath sets f.Path from the names of f's parents.
+func (s *Store) fillPath(f *FolderInfo) error {
+	f.Path = f.Name
+	parentID := f.ParentID
+	for seen := map[int64]bool{f.ID: true}; parentID != 0 && !seen[parentID]; {
+		seen[parentID] = true
+		var name string
+		err := s.q.QueryRow("SELECT parent_id, name FROM folders WHERE id = ?", parentID).Scan(&parentID, &name)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		f.Path = name + "/" + f.Path
+	}
+	return nil
+}
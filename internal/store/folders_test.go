@@ -0,0 +1,94 @@
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFolderPaths(t *testing.T) {
+	s := openTestStore(t)
+
+	prod, err := s.CreateFolderPath("Work/AWS/Prod")
+	if err != nil {
+		t.Fatalf("CreateFolderPath: %v", err)
+	}
+	if again, _ := s.CreateFolderPath(" Work / AWS/Prod/"); again != prod {
+		t.Fatalf("expected the existing path to be reused, got %d want %d", again, prod)
+	}
+	if _, err := s.CreateFolder(0, "Work Projects"); err != nil {
+		t.Fatalf("CreateFolder: %v", err)
+	}
+
+	f, err := s.GetFolder(prod)
+	if err != nil || f == nil || f.Path != "Work/AWS/Prod" || f.Name != "Prod" {
+		t.Fatalf("unexpected folder %+v (%v)", f, err)
+	}
+	if f, _ := s.GetFolderByPath("Work/Prod"); f != nil {
+		t.Fatalf("expected no folder at Work/Prod, got %+v", f)
+	}
+
+	folders, err := s.ListFolders()
+	if err != nil {
+		t.Fatalf("ListFolders: %v", err)
+	}
+	var paths []string
+	for _, f := range folders {
+		paths = append(paths, f.Path)
+	}
+	want := []string{"Work", "Work/AWS", "Work/AWS/Prod", "Work Projects"}
+	if len(paths) != len(want) {
+		t.Fatalf("unexpected paths %q", paths)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Fatalf("unexpected paths %q, want %q", paths, want)
+		}
+	}
+}
+
+func TestMoveFolder(t *testing.T) {
+	s := openTestStore(t)
+
+	aws, _ := s.CreateFolderPath("Work/AWS")
+	work, _ := s.GetFolderByPath("Work")
+	home, _ := s.CreateFolder(0, "Home")
+
+	if err := s.MoveFolder(work.ID, aws); !errors.Is(err, ErrFolderCycle) {
+		t.Fatalf("expected ErrFolderCycle, got %v", err)
+	}
+	if err := s.MoveFolder(aws, home); err != nil {
+		t.Fatalf("MoveFolder: %v", err)
+	}
+	if f, _ := s.GetFolder(aws); f.Path != "Home/AWS" {
+		t.Fatalf("expected the folder under Home, got %q", f.Path)
+	}
+}
+
+func TestDeleteFolderIsRecursive(t *testing.T) {
+	s := openTestStore(t)
+
+	aws, _ := s.CreateFolderPath("Work/AWS")
+	work, _ := s.GetFolderByPath("Work")
+	for _, e := range []struct {
+		folder int64
+		title  string
+	}{{work.ID, "Jira"}, {aws, "Console"}, {0, "Bank"}} {
+		if _, err := s.SaveEntry(e.folder, &EntryFull{Type: "Login", Title: e.title}); err != nil {
+			t.Fatalf("SaveEntry: %v", err)
+		}
+	}
+
+	folders, entries, err := s.CountFolderContents(work.ID)
+	if err != nil || folders != 1 || entries != 2 {
+		t.Fatalf("expected 1 subfolder and 2 entries, got %d, %d (%v)", folders, entries, err)
+	}
+	if err := s.DeleteFolder(work.ID); err != nil {
+		t.Fatalf("DeleteFolder: %v", err)
+	}
+	if all, _ := s.ListFolders(); len(all) != 0 {
+		t.Fatalf("expected no folders left, got %+v", all)
+	}
+	if all, _ := s.ListAllEntries(); len(all) != 1 || all[0].Title != "Bank" {
+		t.Fatalf("expected only the root entry left, got %+v", all)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !sqlite_fts5 && !fts5
+
+package store
+
+// The search index is an FTS5 table, and go-sqlcipher only compiles FTS5
+// into SQLite with the sqlite_fts5 build tag. Build with -tags sqlite_fts5.
+var _ = passbookNeedsBuildTagSqliteFTS5
@@ -0,0 +1,371 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrSchemaTooNew is returned by Open for a vault written by a newer
+// PassBook, whose schema this binary does not know.
+var ErrSchemaTooNew = errors.New("vault was created by a newer version of PassBook")
+
+// migration is one step of the schema's history. migrations[i] upgrades a
+// vault from version i to version i+1; the version is kept in
+// PRAGMA user_version. Steps are only ever appended, never edited.
+type migration struct {
+	name string
+	up   func(tx *Store) error
+}
+
+var migrations = []migration{
+	{"initial schema", migrateInitialSchema},
+	{"custom fields", migrateEntryFields},
+	{"entry uris", migrateEntryURIs},
+	{"tags and saved searches", migrateTags},
+	{"nested folders", migrateNestedFolders},
+	{"search index", migrateSearchIndex},
+	{"entry last used", migrateLastUsed},
+	{"recovery", migrateRecovery},
+	{"fts5 search index", migrateSearchIndexFTS5},
+}
+
+// SchemaVersion is the schema version this binary writes.
+func SchemaVersion() int {
+	return len(migrations)
+}
+
+// migrate brings the vault up to SchemaVersion. Each step runs in its own
+// transaction together with the version bump, so an interrupted upgrade
+// resumes from the last completed step. An existing vault is copied to a
+// backup next to the database before the first step runs.
+func (s *Store) migrate() error {
+	var version int
+	if err := s.q.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+	latest := SchemaVersion()
+	if version > latest {
+		return fmt.Errorf("%w (schema version %d, this build supports up to %d)", ErrSchemaTooNew, version, latest)
+	}
+	if version == latest {
+		return nil
+	}
+
+	// Vaults from before versioning have tables but user_version 0; they
+	// are upgraded like any other. Only a brand-new database skips the
+	// backup.
+	var tables int
+	if err := s.q.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table'").Scan(&tables); err != nil {
+		return err
+	}
+	if tables > 0 {
+		if err := s.backupForUpgrade(version); err != nil {
+			return fmt.Errorf("backing up before upgrade: %w", err)
+		}
+	}
+
+	for v := version; v < latest; v++ {
+		m := migrations[v]
+		err := s.WithTx(func(tx *Store) error {
+			if err := m.up(tx); err != nil {
+				return err
+			}
+			_, err := tx.q.Exec(fmt.Sprintf("PRAGMA user_version = %d", v+1))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("schema version %d (%s): %w", v+1, m.name, err)
+		}
+	}
+	return nil
+}
+
+// backupPath is where the copy of a vault at schema version is kept while
+// it is upgraded.
+func backupPath(dbPath string, version int) string {
+	return fmt.Sprintf("%s.v%d.bak", dbPath, version)
+}
+
+// backupForUpgrade checkpoints the WAL into the database file and copies
+// it. The copy is encrypted with the same key as the vault and gets a copy
+// of its cipher header.
+func (s *Store) backupForUpgrade(version int) error {
+	if _, err := s.q.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return err
+	}
+	src, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(backupPath(s.path, version), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	if s.header != nil {
+		return writeCipherHeader(backupPath(s.path, version), s.header)
+	}
+	return nil
+}
+
+func migrateInitialSchema(tx *Store) error {
+	_, err := tx.q.Exec(`
+	CREATE TABLE IF NOT EXISTS folders (
+		id   INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE
+	);
+
+	CREATE TABLE IF NOT EXISTS entries (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		folder_id   INTEGER NOT NULL DEFAULT 0,
+		entry_type  TEXT NOT NULL DEFAULT '',
+		title       TEXT NOT NULL,
+		username    TEXT NOT NULL DEFAULT '',
+		password    TEXT NOT NULL DEFAULT '',
+		link        TEXT NOT NULL DEFAULT '',
+		totp_secret TEXT NOT NULL DEFAULT '',
+		card_number TEXT NOT NULL DEFAULT '',
+		expiry      TEXT NOT NULL DEFAULT '',
+		cvv         TEXT NOT NULL DEFAULT '',
+		custom_text TEXT NOT NULL DEFAULT '',
+		file_name   TEXT NOT NULL DEFAULT '',
+		file_data   BLOB
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_entries_folder_title
+		ON entries(folder_id, title);
+
+	CREATE TABLE IF NOT EXISTS password_history (
+		id       INTEGER PRIMARY KEY AUTOINCREMENT,
+		entry_id INTEGER NOT NULL REFERENCES entries(id) ON DELETE CASCADE,
+		password TEXT NOT NULL,
+		date     TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_password_history_entry
+		ON password_history(entry_id);
+
+	CREATE TABLE IF NOT EXISTS attachments (
+		id        TEXT PRIMARY KEY,
+		entry_id  INTEGER NOT NULL REFERENCES entries(id) ON DELETE CASCADE,
+		file_name TEXT NOT NULL DEFAULT '',
+		size      INTEGER NOT NULL DEFAULT 0,
+		data      BLOB NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_attachments_entry
+		ON attachments(entry_id);
+
+	CREATE TABLE IF NOT EXISTS pin_config (
+		id          INTEGER PRIMARY KEY CHECK (id = 1),
+		mode        TEXT NOT NULL DEFAULT '',
+		pin_key     BLOB,
+		pin_tag     TEXT NOT NULL DEFAULT '',
+		totp_secret TEXT NOT NULL DEFAULT ''
+	);
+	`)
+	return err
+}
+
+// migrateEntryFields adds entry_fields and moves the "Custom Fields:" block
+// that importers used to append to notes into it.
+func migrateEntryFields(tx *Store) error {
+	_, err := tx.q.Exec(`
+	CREATE TABLE entry_fields (
+		id       INTEGER PRIMARY KEY AUTOINCREMENT,
+		entry_id INTEGER NOT NULL REFERENCES entries(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL DEFAULT 0,
+		name     TEXT NOT NULL DEFAULT '',
+		value    TEXT NOT NULL DEFAULT '',
+		type     TEXT NOT NULL DEFAULT 'text'
+	);
+
+	CREATE INDEX idx_entry_fields_entry
+		ON entry_fields(entry_id, position);
+	`)
+	if err != nil {
+		return err
+	}
+
+	type legacy struct {
+		id    int64
+		notes string
+	}
+	rows, err := tx.q.Query("SELECT id, custom_text FROM entries WHERE custom_text LIKE '%Custom Fields:%'")
+	if err != nil {
+		return err
+	}
+	var found []legacy
+	for rows.Next() {
+		var l legacy
+		if err := rows.Scan(&l.id, &l.notes); err != nil {
+			rows.Close()
+			return err
+		}
+		found = append(found, l)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, l := range found {
+		notes, fields := FieldsFromNotes(l.notes)
+		if fields == nil {
+			continue
+		}
+		if _, err := tx.q.Exec("UPDATE entries SET custom_text = ? WHERE id = ?", notes, l.id); err != nil {
+			return err
+		}
+		if err := tx.replaceFields(l.id, fields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateEntryURIs adds entry_uris and moves each entry's link into it. The
+// link column is left empty and no longer read.
+func migrateEntryURIs(tx *Store) error {
+	_, err := tx.q.Exec(`
+	CREATE TABLE entry_uris (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		entry_id   INTEGER NOT NULL REFERENCES entries(id) ON DELETE CASCADE,
+		position   INTEGER NOT NULL DEFAULT 0,
+		uri        TEXT NOT NULL,
+		match_mode TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE INDEX idx_entry_uris_entry
+		ON entry_uris(entry_id, position);
+
+	INSERT INTO entry_uris (entry_id, position, uri)
+		SELECT id, 0, link FROM entries WHERE link != '';
+
+	UPDATE entries SET link = '' WHERE link != '';
+	`)
+	return err
+}
+
+func migrateTags(tx *Store) error {
+	_, err := tx.q.Exec(`
+	CREATE TABLE tags (
+		id   INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE COLLATE NOCASE
+	);
+
+	CREATE TABLE entry_tags (
+		entry_id INTEGER NOT NULL REFERENCES entries(id) ON DELETE CASCADE,
+		tag_id   INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+		PRIMARY KEY (entry_id, tag_id)
+	);
+
+	CREATE INDEX idx_entry_tags_tag
+		ON entry_tags(tag_id);
+
+	CREATE TABLE saved_searches (
+		id    INTEGER PRIMARY KEY AUTOINCREMENT,
+		name  TEXT NOT NULL UNIQUE,
+		query TEXT NOT NULL
+	);
+	`)
+	return err
+}
+
+// migrateNestedFolders adds parent_id to folders. Names are now unique
+// among siblings rather than across the vault, and SQLite cannot drop the
+// old UNIQUE constraint, so the table is rebuilt.
+func migrateNestedFolders(tx *Store) error {
+	_, err := tx.q.Exec(`
+	CREATE TABLE folders_new (
+		id        INTEGER PRIMARY KEY AUTOINCREMENT,
+		parent_id INTEGER NOT NULL DEFAULT 0,
+		name      TEXT NOT NULL
+	);
+
+	INSERT INTO folders_new (id, parent_id, name)
+		SELECT id, 0, name FROM folders;
+
+	DROP TABLE folders;
+	ALTER TABLE folders_new RENAME TO folders;
+
+	CREATE UNIQUE INDEX idx_folders_parent_name
+		ON folders(parent_id, name);
+	`)
+	return err
+}
+
+// migrateSearchIndex adds the entry_search full-text index as an FTS4 table
+// and fills it. migrateSearchIndexFTS5 later rebuilds it as FTS5.
+func migrateSearchIndex(tx *Store) error {
+	_, err := tx.q.Exec(`
+	CREATE VIRTUAL TABLE entry_search USING fts4(
+		title, username, url, notes, fields, attachments,
+		tokenize=unicode61
+	);
+
+	CREATE TRIGGER entries_search_delete AFTER DELETE ON entries BEGIN
+		DELETE FROM entry_search WHERE docid = old.id;
+	END;
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.q.Exec(indexInsert + indexSelect)
+	return err
+}
+
+// migrateLastUsed records when each entry was last opened or copied from,
+// as Unix seconds; 0 means never.
+func migrateLastUsed(tx *Store) error {
+	_, err := tx.q.Exec("ALTER TABLE entries ADD COLUMN last_used INTEGER NOT NULL DEFAULT 0")
+	return err
+}
+
+// migrateRecovery adds the key that seals the vault key for a recovery
+// key, and the hashes of unused authenticator backup codes.
+func migrateRecovery(tx *Store) error {
+	_, err := tx.q.Exec(`
+	CREATE TABLE recovery (
+		id       INTEGER PRIMARY KEY CHECK (id = 1),
+		seal_key BLOB NOT NULL
+	);
+
+	CREATE TABLE backup_codes (
+		code_hash TEXT PRIMARY KEY
+	);`)
+	return err
+}
+
+// migrateSearchIndexFTS5 rebuilds entry_search as an FTS5 table keyed by
+// entry ID, so search can rank with bm25. Saving an entry reindexes it; a
+// trigger drops the rows of deleted entries, however they are deleted.
+func migrateSearchIndexFTS5(tx *Store) error {
+	_, err := tx.q.Exec(`
+	DROP TRIGGER entries_search_delete;
+	DROP TABLE entry_search;
+
+	CREATE VIRTUAL TABLE entry_search USING fts5(
+		title, username, url, notes, fields, attachments,
+		tokenize = 'unicode61'
+	);
+
+	CREATE TRIGGER entries_search_delete AFTER DELETE ON entries BEGIN
+		DELETE FROM entry_search WHERE rowid = old.id;
+	END;
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.q.Exec(indexInsert + indexSelect)
+	return err
+}
@@ -0,0 +1,264 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func userVersion(t *testing.T, s *Store) int {
+	t.Helper()
+	var v int
+	if err := s.q.QueryRow("PRAGMA user_version").Scan(&v); err != nil {
+		t.Fatalf("user_version: %v", err)
+	}
+	return v
+}
+
+func TestOpenNewVaultIsCurrent(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "passbook.db")
+	s, err := Open(dbPath, "testpass")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	if v := userVersion(t, s); v != SchemaVersion() {
+		t.Fatalf("expected schema version %d, got %d", SchemaVersion(), v)
+	}
+	if _, err := os.Stat(backupPath(dbPath, 0)); !os.IsNotExist(err) {
+		t.Fatalf("expected no backup for a new vault")
+	}
+}
+
+func TestMigrateUpgradesWithBackup(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "passbook.db")
+	s, err := Open(dbPath, "testpass")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := s.SaveEntry(0, &EntryFull{Type: "Login", Title: "Site"}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	s.Close()
+
+	old := SchemaVersion()
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+	migrations = append(append([]migration(nil), saved...), migration{"add color", func(tx *Store) error {
+		_, err := tx.q.Exec("ALTER TABLE entries ADD COLUMN color TEXT NOT NULL DEFAULT 'red'")
+		return err
+	}})
+
+	s, err = Open(dbPath, "testpass")
+	if err != nil {
+		t.Fatalf("Open after upgrade: %v", err)
+	}
+	defer s.Close()
+
+	if v := userVersion(t, s); v != old+1 {
+		t.Fatalf("expected schema version %d, got %d", old+1, v)
+	}
+	var color string
+	if err := s.q.QueryRow("SELECT color FROM entries WHERE title = 'Site'").Scan(&color); err != nil || color != "red" {
+		t.Fatalf("expected migrated column, got %q %v", color, err)
+	}
+
+	// The backup is the vault as it was, readable with the same key.
+	b, err := Open(backupPath(dbPath, old), "testpass")
+	if err != nil {
+		t.Fatalf("opening backup: %v", err)
+	}
+	defer b.Close()
+	if !b.HasEntries() {
+		t.Fatalf("expected the backup to hold the entry")
+	}
+}
+
+func TestMigrateFailureKeepsVersion(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "passbook.db")
+	s, err := Open(dbPath, "testpass")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	s.Close()
+
+	old := SchemaVersion()
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+	migrations = append(append([]migration(nil), saved...), migration{"broken", func(tx *Store) error {
+		if _, err := tx.q.Exec("CREATE TABLE half_done (id INTEGER)"); err != nil {
+			return err
+		}
+		return errors.New("boom")
+	}})
+
+	if _, err := Open(dbPath, "testpass"); err == nil {
+		t.Fatalf("expected the failed migration to fail Open")
+	}
+
+	migrations = saved
+	s, err = Open(dbPath, "testpass")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+	if v := userVersion(t, s); v != old {
+		t.Fatalf("expected schema version %d, got %d", old, v)
+	}
+	var n int
+	s.q.QueryRow("SELECT count(*) FROM sqlite_master WHERE name = 'half_done'").Scan(&n)
+	if n != 0 {
+		t.Fatalf("expected the failed step to be rolled back")
+	}
+}
+
+func TestOpenRefusesNewerSchema(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "passbook.db")
+	s, err := Open(dbPath, "testpass")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := s.q.Exec("PRAGMA user_version = 999"); err != nil {
+		t.Fatalf("set user_version: %v", err)
+	}
+	s.Close()
+
+	if _, err := Open(dbPath, "testpass"); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("expected ErrSchemaTooNew, got %v", err)
+	}
+}
+
+func TestMigrateMovesLegacyCustomFields(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "passbook.db")
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+
+	migrations = saved[:1]
+	s, err := Open(dbPath, "testpass")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	for _, notes := range []string{"my notes\n\nCustom Fields:\nPIN: 1234\nRegion: eu", "not: a block"} {
+		if _, err := s.q.Exec("INSERT INTO entries (entry_type, title, custom_text) VALUES ('Login', ?, ?)", notes, notes); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	s.Close()
+
+	migrations = saved
+	s, err = Open(dbPath, "testpass")
+	if err != nil {
+		t.Fatalf("Open after upgrade: %v", err)
+	}
+	defer s.Close()
+
+	e, err := s.LoadEntry(1)
+	if err != nil {
+		t.Fatalf("LoadEntry: %v", err)
+	}
+	if e.CustomText != "my notes" {
+		t.Fatalf("expected the block to leave the notes, got %q", e.CustomText)
+	}
+	want := []CustomField{{"PIN", "1234", FieldText}, {"Region", "eu", FieldText}}
+	if len(e.Fields) != 2 || e.Fields[0] != want[0] || e.Fields[1] != want[1] {
+		t.Fatalf("unexpected fields: %+v", e.Fields)
+	}
+
+	other, _ := s.LoadEntry(2)
+	if other.CustomText != "not: a block" || len(other.Fields) != 0 {
+		t.Fatalf("expected plain notes to be left alone, got %+v", other)
+	}
+}
+
+func TestMigrateMovesLinksToURIs(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "passbook.db")
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+
+	migrations = saved[:2]
+	s, err := Open(dbPath, "testpass")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := s.q.Exec("INSERT INTO entries (entry_type, title, link) VALUES ('Login', 'GitHub', 'https://github.com')"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	s.Close()
+
+	migrations = saved
+	s, err = Open(dbPath, "testpass")
+	if err != nil {
+		t.Fatalf("Open after upgrade: %v", err)
+	}
+	defer s.Close()
+
+	e, err := s.LoadEntry(1)
+	if err != nil {
+		t.Fatalf("LoadEntry: %v", err)
+	}
+	if len(e.URIs) != 1 || e.URIs[0] != (EntryURI{URI: "https://github.com"}) {
+		t.Fatalf("unexpected URIs: %+v", e.URIs)
+	}
+}
+
+func TestMigrateNestsFolders(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "passbook.db")
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+
+	migrations = saved[:4]
+	s, err := Open(dbPath, "testpass")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := s.q.Exec("INSERT INTO folders (name) VALUES ('Work')"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	s.Close()
+
+	migrations = saved
+	s, err = Open(dbPath, "testpass")
+	if err != nil {
+		t.Fatalf("Open after upgrade: %v", err)
+	}
+	defer s.Close()
+
+	work, err := s.GetFolderByPath("Work")
+	if err != nil || work == nil || work.ParentID != 0 {
+		t.Fatalf("expected Work to stay a top-level folder: %+v %v", work, err)
+	}
+	// Names only need to be unique among siblings now.
+	if _, err := s.CreateFolder(work.ID, "Work"); err != nil {
+		t.Fatalf("CreateFolder: %v", err)
+	}
+}
+
+func TestMigrateIndexesExistingEntries(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "passbook.db")
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+
+	migrations = saved[:5]
+	s, err := Open(dbPath, "testpass")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := s.q.Exec("INSERT INTO entries (entry_type, title, username) VALUES ('Login', 'GitHub', 'octocat')"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	s.Close()
+
+	migrations = saved
+	s, err = Open(dbPath, "testpass")
+	if err != nil {
+		t.Fatalf("Open after upgrade: %v", err)
+	}
+	defer s.Close()
+
+	results, err := s.Search(ParseSearch("user:octo"))
+	if err != nil || len(results) != 1 || results[0].Title != "GitHub" {
+		t.Fatalf("expected the existing entry to be searchable, got %+v (%v)", results, err)
+	}
+}
@@ -0,0 +1,47 @@
+package store
+
+import "time"
+
+// EntrySummary is what the quick finder shows of an entry: enough to match
+// and rank it without loading its secrets.
+type EntrySummary struct {
+	EntryMeta
+	Username string
+	URL      string
+	LastUsed time.Time
+}
+
+// ListEntrySummaries returns every entry with its username, first URL and
+// last use, most recently used first. Entries never used follow by title.
+func (s *Store) ListEntrySummaries() ([]EntrySummary, error) {
+	rows, err := s.q.Query(`
+		SELECT e.id, e.folder_id, e.title, e.entry_type, e.username,
+			coalesce((SELECT uri FROM entry_uris WHERE entry_id = e.id ORDER BY position LIMIT 1), ''),
+			e.last_used
+		FROM entries e
+		ORDER BY e.last_used DESC, e.title COLLATE NOCASE`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []EntrySummary
+	for rows.Next() {
+		var e EntrySummary
+		var used int64
+		if err := rows.Scan(&e.ID, &e.FolderID, &e.Title, &e.EntryType, &e.Username, &e.URL, &used); err != nil {
+			return nil, err
+		}
+		if used > 0 {
+			e.LastUsed = time.Unix(used, 0)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// MarkEntryUsed records that entry id was just opened or copied from.
+func (s *Store) MarkEntryUsed(id int64) error {
+	_, err := s.q.Exec("UPDATE entries SET last_used = ? WHERE id = ?", time.Now().Unix(), id)
+	return err
+}
@@ -0,0 +1,44 @@
+package store
+
+import "testing"
+
+func TestListEntrySummaries(t *testing.T) {
+	s := openTestStore(t)
+
+	github, err := s.SaveEntry(0, &EntryFull{
+		Type: "Login", Title: "GitHub", Username: "octocat",
+		URIs: []EntryURI{{URI: "https://github.com"}, {URI: "https://gist.github.com"}},
+	})
+	if err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	if _, err := s.SaveEntry(0, &EntryFull{Type: "Note", Title: "alpha"}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	if _, err := s.SaveEntry(0, &EntryFull{Type: "Note", Title: "Beta"}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+
+	got, err := s.ListEntrySummaries()
+	if err != nil {
+		t.Fatalf("ListEntrySummaries: %v", err)
+	}
+	if len(got) != 3 || got[0].Title != "alpha" || got[1].Title != "Beta" || !got[0].LastUsed.IsZero() {
+		t.Fatalf("expected unused entries by title, got %+v", got)
+	}
+
+	if err := s.MarkEntryUsed(github); err != nil {
+		t.Fatalf("MarkEntryUsed: %v", err)
+	}
+	got, err = s.ListEntrySummaries()
+	if err != nil {
+		t.Fatalf("ListEntrySummaries: %v", err)
+	}
+	first := got[0]
+	if first.Title != "GitHub" || first.LastUsed.IsZero() {
+		t.Fatalf("expected the used entry first, got %+v", got)
+	}
+	if first.Username != "octocat" || first.URL != "https://github.com" {
+		t.Fatalf("unexpected summary %+v", first)
+	}
+}
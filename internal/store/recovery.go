@@ -0,0 +1,160 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"passbook/internal/crypto"
+)
+
+var (
+	ErrNoRecoveryKey    = errors.New("no recovery key is set up for this vault")
+	ErrWrongRecoveryKey = errors.New("wrong recovery key")
+)
+
+// SetupRecovery makes a new recovery key for the vault and returns it;
+// any earlier recovery key stops working. password must be the master
+// password. The cipher header keeps the vault's passphrase sealed with
+// the recovery key, and the vault keeps the sealing key so the seal can
+// follow later changes of the password, keyfile or PIN.
+func (s *Store) SetupRecovery(password string) (string, error) {
+	if s.header == nil {
+		return "", ErrNoCipherHeader
+	}
+	if err := s.VerifyPassword(password); err != nil {
+		return "", err
+	}
+	key, err := s.header.key(s.credentials(password))
+	if err != nil {
+		return "", err
+	}
+	recoveryKey, err := crypto.GenerateRecoveryKey()
+	if err != nil {
+		return "", err
+	}
+	sealKey, err := crypto.RecoveryKeyHash(recoveryKey)
+	if err != nil {
+		return "", err
+	}
+	defer crypto.WipeBytes(sealKey)
+
+	h := *s.header
+	if h.Recovery, err = crypto.Seal(sealKey, []byte(key)); err != nil {
+		return "", err
+	}
+	if err := writeCipherHeader(s.path, &h); err != nil {
+		return "", fmt.Errorf("writing cipher header: %w", err)
+	}
+	_, err = s.q.Exec(
+		`INSERT INTO recovery (id, seal_key) VALUES (1, ?)
+		 ON CONFLICT(id) DO UPDATE SET seal_key = excluded.seal_key`, sealKey)
+	if err != nil {
+		_ = writeCipherHeader(s.path, s.header)
+		return "", err
+	}
+	s.header = &h
+	return recoveryKey, nil
+}
+
+// HasRecoveryKey reports whether a recovery key can open the vault.
+func (s *Store) HasRecoveryKey() bool {
+	return s.header != nil && s.header.Recovery != nil
+}
+
+// sealRecovery seals key, the vault's new passphrase, into h for the
+// vault's recovery key, if it has one.
+func (s *Store) sealRecovery(h *cipherHeader, key string) error {
+	var sealKey []byte
+	err := s.q.QueryRow("SELECT seal_key FROM recovery WHERE id = 1").Scan(&sealKey)
+	if err == sql.ErrNoRows {
+		h.Recovery = nil
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading recovery key: %w", err)
+	}
+	defer crypto.WipeBytes(sealKey)
+	h.Recovery, err = crypto.Seal(sealKey, []byte(key))
+	return err
+}
+
+// Recover opens the vault at dbPath with its recovery key and resets it:
+// newPassword becomes the master password, the keyfile and PIN are no
+// longer needed, and the PIN or authenticator app and its backup codes
+// are removed, to be set up again at the next login. The recovery key
+// keeps working.
+func Recover(dbPath, recoveryKey, newPassword string) error {
+	h, err := readCipherHeader(dbPath)
+	if err != nil {
+		return err
+	}
+	if h == nil || h.Recovery == nil {
+		return ErrNoRecoveryKey
+	}
+	sealKey, err := crypto.RecoveryKeyHash(recoveryKey)
+	if err != nil {
+		return ErrWrongRecoveryKey
+	}
+	defer crypto.WipeBytes(sealKey)
+	key, err := crypto.Unseal(sealKey, h.Recovery)
+	if err != nil {
+		return ErrWrongRecoveryKey
+	}
+	defer crypto.WipeBytes(key)
+
+	s, err := openKey(dbPath, string(key), h, Credentials{})
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	err = s.WithTx(func(tx *Store) error {
+		return execAll(tx.q, "DELETE FROM pin_config", "DELETE FROM backup_codes")
+	})
+	if err != nil {
+		return fmt.Errorf("resetting two-factor authentication: %w", err)
+	}
+	reset := *h
+	reset.Keyfile, reset.PIN = false, false
+	newKey, err := reset.key(Credentials{Password: newPassword})
+	if err != nil {
+		return err
+	}
+	return s.switchKey(&reset, newKey)
+}
+
+// ── Backup codes ────────────────────────────────────────────────────
+
+// SetBackupCodes replaces the authenticator backup codes with codes.
+func (s *Store) SetBackupCodes(codes []string) error {
+	return s.WithTx(func(tx *Store) error {
+		if _, err := tx.q.Exec("DELETE FROM backup_codes"); err != nil {
+			return err
+		}
+		for _, c := range codes {
+			if _, err := tx.q.Exec("INSERT INTO backup_codes (code_hash) VALUES (?)", crypto.BackupCodeHash(c)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UseBackupCode reports whether code is an unused backup code, and uses
+// it up if so.
+func (s *Store) UseBackupCode(code string) (bool, error) {
+	res, err := s.q.Exec("DELETE FROM backup_codes WHERE code_hash = ?", crypto.BackupCodeHash(code))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// BackupCodesLeft returns how many backup codes are still unused.
+func (s *Store) BackupCodesLeft() (int, error) {
+	var n int
+	err := s.q.QueryRow("SELECT count(*) FROM backup_codes").Scan(&n)
+	return n, err
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecoverResetsPasswordAndSecondFactor(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "passbook.db")
+	s, err := Create(dbPath, "testpass", fastCipherParams)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := s.SaveEntry(0, &EntryFull{Type: "Login", Title: "Site", Password: "s3cret"}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	if err := Recover(dbPath, "AAAA-AAAA-AAAA-AAAA-AAAA-AAAA-AAAA-AAAA", "newpass"); !errors.Is(err, ErrNoRecoveryKey) {
+		t.Fatalf("expected ErrNoRecoveryKey, got %v", err)
+	}
+	if _, err := s.SetupRecovery("wrong"); err == nil {
+		t.Fatalf("expected the wrong master password to be refused")
+	}
+	recoveryKey, err := s.SetupRecovery("testpass")
+	if err != nil {
+		t.Fatalf("SetupRecovery: %v", err)
+	}
+
+	// The recovery key follows later changes of the key.
+	keyfile := []byte("0123456789abcdef0123456789abcdef")
+	if err := s.SetKeyfile("testpass", keyfile); err != nil {
+		t.Fatalf("SetKeyfile: %v", err)
+	}
+	if err := s.KeyWithPIN("testpass", "123456"); err != nil {
+		t.Fatalf("KeyWithPIN: %v", err)
+	}
+	if err := s.Rekey("otherpass"); err != nil {
+		t.Fatalf("Rekey: %v", err)
+	}
+	if err := s.SetBackupCodes([]string{"12345-67890"}); err != nil {
+		t.Fatalf("SetBackupCodes: %v", err)
+	}
+	if err := s.UpgradeKDF("otherpass", fastCipherParams); err != nil {
+		t.Fatalf("UpgradeKDF: %v", err)
+	}
+
+	if err := Recover(dbPath, "AAAA-AAAA-AAAA-AAAA-AAAA-AAAA-AAAA-AAAA", "newpass"); !errors.Is(err, ErrWrongRecoveryKey) {
+		t.Fatalf("expected ErrWrongRecoveryKey, got %v", err)
+	}
+	if err := Recover(dbPath, recoveryKey, "newpass"); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+
+	s, err = Open(dbPath, "newpass")
+	if err != nil {
+		t.Fatalf("expected the new password alone to open the vault: %v", err)
+	}
+	defer s.Close()
+	if s.PinConfigExists() {
+		t.Fatalf("expected the second factor to be reset")
+	}
+	if n, err := s.BackupCodesLeft(); err != nil || n != 0 {
+		t.Fatalf("expected the backup codes to be removed, got %d, %v", n, err)
+	}
+	entries, err := s.ListEntries(0)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected the entry to survive, got %v, %v", entries, err)
+	}
+	if !s.HasRecoveryKey() {
+		t.Fatalf("expected the recovery key to keep working")
+	}
+}
+
+func TestRecoveryNeedsCipherHeader(t *testing.T) {
+	s, err := open(filepath.Join(t.TempDir(), "passbook.db"), Credentials{Password: "testpass"}, nil)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.SetupRecovery("testpass"); !errors.Is(err, ErrNoCipherHeader) {
+		t.Fatalf("expected ErrNoCipherHeader, got %v", err)
+	}
+}
+
+func TestBackupCodesAreSingleUse(t *testing.T) {
+	s := openTestStore(t)
+	if err := s.SetBackupCodes([]string{"12345-67890", "11111-22222"}); err != nil {
+		t.Fatalf("SetBackupCodes: %v", err)
+	}
+	if ok, err := s.UseBackupCode("1234567890"); err != nil || !ok {
+		t.Fatalf("expected the code to be accepted: %v, %v", ok, err)
+	}
+	if ok, _ := s.UseBackupCode("12345-67890"); ok {
+		t.Fatalf("expected a used code to be refused")
+	}
+	if ok, _ := s.UseBackupCode("99999-99999"); ok {
+		t.Fatalf("expected an unknown code to be refused")
+	}
+	if n, _ := s.BackupCodesLeft(); n != 1 {
+		t.Fatalf("expected one code left, got %d", n)
+	}
+}
@@ -0,0 +1,330 @@
+package store
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// searchColumns are the columns of the entry_search full-text index, in
+// order, with the weight bm25 gives a hit in each when ranking.
+var searchColumns = []struct {
+	name   string
+	weight float64
+}{
+	{"title", 10},
+	{"username", 5},
+	{"url", 4},
+	{"notes", 1},
+	{"fields", 2},
+	{"attachments", 2},
+}
+
+// searchFields maps the "field:" prefixes of the query syntax to index
+// columns. "type:" and "tag:" filter on the entry itself instead.
+var searchFields = map[string]string{
+	"title":      "title",
+	"user":       "username",
+	"username":   "username",
+	"url":        "url",
+	"link":       "url",
+	"notes":      "notes",
+	"note":       "notes",
+	"field":      "fields",
+	"fields":     "fields",
+	"file":       "attachments",
+	"attachment": "attachments",
+}
+
+// indexSelect selects an entry's row for entry_search. Passwords, card
+// details and the values of hidden and TOTP fields are not indexed.
+const indexSelect = `
+	SELECT e.id, e.title, e.username,
+		(SELECT group_concat(uri, ' ') FROM entry_uris WHERE entry_id = e.id),
+		e.custom_text,
+		(SELECT group_concat(CASE WHEN type IN ('hidden', 'totp') THEN name ELSE name || ' ' || value END, ' ')
+		 FROM entry_fields WHERE entry_id = e.id),
+		trim(e.file_name || ' ' || coalesce((SELECT group_concat(file_name, ' ') FROM attachments WHERE entry_id = e.id), ''))
+	FROM entries e`
+
+const indexInsert = `INSERT INTO entry_search (rowid, title, username, url, notes, fields, attachments)`
+
+// indexEntry brings entry id's row in the search index up to date. Rows of
+// deleted entries are removed by a trigger.
+func (s *Store) indexEntry(id int64) error {
+	if _, err := s.q.Exec("DELETE FROM entry_search WHERE rowid = ?", id); err != nil {
+		return err
+	}
+	_, err := s.q.Exec(indexInsert+indexSelect+" WHERE e.id = ?", id)
+	return err
+}
+
+// SearchTerm is one word or quoted phrase of a search. Field is the index
+// column it is limited to, or "" for any.
+type SearchTerm struct {
+	Field  string
+	Text   string
+	Phrase bool
+}
+
+// SearchQuery is a parsed search such as `git user:alice "dev box"
+// type:login tag:prod`.
+type SearchQuery struct {
+	Terms []SearchTerm
+	Types []string
+	Tags  []string
+}
+
+// ParseSearch parses the search syntax. Bare words match the start of a
+// word in any indexed field and "quoted phrases" match whole words in
+// order. A "title:", "user:", "url:", "notes:", "field:" or "file:" prefix
+// limits a word or phrase to that field; "type:card" and "tag:prod" keep
+// only entries of that type or with that tag. Unknown prefixes are
+// searched as plain text.
+func ParseSearch(s string) SearchQuery {
+	var q SearchQuery
+	r := []rune(s)
+	for i := 0; i < len(r); {
+		if unicode.IsSpace(r[i]) {
+			i++
+			continue
+		}
+
+		// An optional "prefix:" before the word or phrase.
+		var field string
+		j := i
+		for j < len(r) && !unicode.IsSpace(r[j]) && r[j] != ':' && r[j] != '"' {
+			j++
+		}
+		prefix := strings.ToLower(string(r[i:j]))
+		if j < len(r) && r[j] == ':' && (searchFields[prefix] != "" || prefix == "type" || prefix == "tag") {
+			field = prefix
+			i = j + 1
+		}
+
+		var text string
+		phrase := i < len(r) && r[i] == '"'
+		if phrase {
+			end := i + 1
+			for end < len(r) && r[end] != '"' {
+				end++
+			}
+			text = string(r[i+1 : end])
+			i = end + 1
+		} else {
+			end := i
+			for end < len(r) && !unicode.IsSpace(r[end]) {
+				end++
+			}
+			text = string(r[i:end])
+			i = end
+		}
+
+		switch field {
+		case "type":
+			if t := strings.ToLower(strings.TrimSpace(text)); t != "" {
+				q.Types = append(q.Types, t)
+			}
+		case "tag":
+			if t := NormalizeTag(text); t != "" {
+				q.Tags = append(q.Tags, strings.ToLower(t))
+			}
+		default:
+			if len(searchTokens(text)) > 0 {
+				q.Terms = append(q.Terms, SearchTerm{Field: searchFields[field], Text: text, Phrase: phrase})
+			}
+		}
+	}
+	return q
+}
+
+// Empty reports whether q matches every entry.
+func (q SearchQuery) Empty() bool {
+	return len(q.Terms) == 0 && len(q.Types) == 0 && len(q.Tags) == 0
+}
+
+// Words returns the lower-cased words of q's terms, for highlighting.
+func (q SearchQuery) Words() []string {
+	var words []string
+	for _, t := range q.Terms {
+		words = append(words, searchTokens(t.Text)...)
+	}
+	return words
+}
+
+// searchTokens splits text into lower-cased words the way the index's
+// unicode61 tokenizer does.
+func searchTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// matchExpr builds the full-text MATCH expression for q's terms. Terms are
+// reduced to their words, so no user input reaches the query syntax.
+func (q SearchQuery) matchExpr() string {
+	var parts []string
+	for _, t := range q.Terms {
+		words := searchTokens(t.Text)
+		col := ""
+		if t.Field != "" {
+			col = t.Field + ":"
+		}
+		switch {
+		case t.Phrase:
+			parts = append(parts, col+`"`+strings.Join(words, " ")+`"`)
+		case len(words) == 1:
+			parts = append(parts, col+words[0]+"*")
+		default:
+			parts = append(parts, col+`"`+strings.Join(words, " ")+`"*`)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// SearchResult is an entry found by Search. Fields names the index columns
+// that matched, best first by weight.
+type SearchResult struct {
+	EntryMeta
+	Score  float64
+	Fields []string
+}
+
+// Search returns the entries matching q, best match first by bm25. Entries
+// that only pass type and tag filters have no score and are ordered by
+// title.
+func (s *Store) Search(q SearchQuery) ([]SearchResult, error) {
+	var where []string
+	var args []any
+	match := q.matchExpr()
+	query := "SELECT e.id, e.folder_id, e.title, e.entry_type, 0, '', '', '', '', '', '' FROM entries e"
+	if match != "" {
+		// bm25 is lower for better matches, so it is negated into a score.
+		query = `SELECT e.id, e.folder_id, e.title, e.entry_type, -` + bm25Call() + `,
+				` + searchColumnList() + `
+			FROM entry_search JOIN entries e ON e.id = entry_search.rowid`
+		where = append(where, "entry_search MATCH ?")
+		args = append(args, match)
+	}
+	if len(q.Types) > 0 {
+		where = append(where, "lower(e.entry_type) IN (?"+strings.Repeat(", ?", len(q.Types)-1)+")")
+		for _, t := range q.Types {
+			args = append(args, t)
+		}
+	}
+	for _, tag := range q.Tags {
+		where = append(where, `e.id IN (SELECT et.entry_id FROM entry_tags et
+			JOIN tags t ON t.id = et.tag_id WHERE t.name = ?)`)
+		args = append(args, tag)
+	}
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	rows, err := s.q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		cols := make([]string, len(searchColumns))
+		dest := []any{&r.ID, &r.FolderID, &r.Title, &r.EntryType, &r.Score}
+		for i := range cols {
+			dest = append(dest, &cols[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		if match != "" {
+			r.Fields = q.matchedColumns(cols)
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return strings.ToLower(results[i].Title) < strings.ToLower(results[j].Title)
+	})
+	return results, nil
+}
+
+// bm25Call is the bm25 ranking call for entry_search with the weights of
+// searchColumns.
+func bm25Call() string {
+	var weights []string
+	for _, c := range searchColumns {
+		weights = append(weights, strconv.FormatFloat(c.weight, 'f', -1, 64))
+	}
+	return "bm25(entry_search, " + strings.Join(weights, ", ") + ")"
+}
+
+// searchColumnList selects the text of every index column, "" for none.
+func searchColumnList() string {
+	var cols []string
+	for _, c := range searchColumns {
+		cols = append(cols, "coalesce(entry_search."+c.name+", '')")
+	}
+	return strings.Join(cols, ", ")
+}
+
+// matchedColumns returns the index columns whose text, given in
+// searchColumns order, holds one of q's terms, heaviest first. FTS5 has no
+// matchinfo, so the words are matched here the way MATCH does: in order,
+// with the last word of an unquoted term as a prefix.
+func (q SearchQuery) matchedColumns(cols []string) []string {
+	var fields []string
+	for c, col := range searchColumns {
+		tokens := searchTokens(cols[c])
+		for _, t := range q.Terms {
+			if (t.Field == "" || t.Field == col.name) && containsWords(tokens, searchTokens(t.Text), !t.Phrase) {
+				fields = append(fields, col.name)
+				break
+			}
+		}
+	}
+	// searchColumns is not in weight order, so sort the hits.
+	sort.SliceStable(fields, func(i, j int) bool {
+		return columnWeight(fields[i]) > columnWeight(fields[j])
+	})
+	return fields
+}
+
+// containsWords reports whether words appear in tokens in a row. With
+// prefix set, the last word only has to start a token.
+func containsWords(tokens, words []string, prefix bool) bool {
+	if len(words) == 0 {
+		return false
+	}
+	for i := 0; i+len(words) <= len(tokens); i++ {
+		ok := true
+		for j, w := range words {
+			tok := tokens[i+j]
+			if tok != w && !(prefix && j == len(words)-1 && strings.HasPrefix(tok, w)) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func columnWeight(name string) float64 {
+	for _, c := range searchColumns {
+		if c.name == name {
+			return c.weight
+		}
+	}
+	return 0
+}
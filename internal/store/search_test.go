@@ -0,0 +1,152 @@
+package store
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSearch(t *testing.T) {
+	q := ParseSearch(`git USER:alice url:"github.com" "dev box" type:Card tag:#Prod https://x.io`)
+	want := []SearchTerm{
+		{Text: "git"},
+		{Field: "username", Text: "alice"},
+		{Field: "url", Text: "github.com", Phrase: true},
+		{Text: "dev box", Phrase: true},
+		{Text: "https://x.io"},
+	}
+	if !reflect.DeepEqual(q.Terms, want) {
+		t.Fatalf("unexpected terms %+v", q.Terms)
+	}
+	if !reflect.DeepEqual(q.Types, []string{"card"}) || !reflect.DeepEqual(q.Tags, []string{"prod"}) {
+		t.Fatalf("unexpected filters %v %v", q.Types, q.Tags)
+	}
+	if got := q.matchExpr(); got != `git* username:alice* url:"github com" "dev box" "https x io"*` {
+		t.Fatalf("unexpected match expression %s", got)
+	}
+	if !ParseSearch(`  "" -- `).Empty() {
+		t.Fatalf("expected a query without words to be empty")
+	}
+}
+
+func searchTitles(t *testing.T, s *Store, query string) []string {
+	t.Helper()
+	results, err := s.Search(ParseSearch(query))
+	if err != nil {
+		t.Fatalf("Search(%q): %v", query, err)
+	}
+	titles := []string{}
+	for _, r := range results {
+		titles = append(titles, r.Title)
+	}
+	return titles
+}
+
+func TestSearch(t *testing.T) {
+	s := openTestStore(t)
+
+	entries := []*EntryFull{
+		{Type: "Login", Title: "GitHub", Username: "alice", URIs: []EntryURI{{URI: "https://github.com"}}, Tags: []string{"dev"}},
+		{Type: "Login", Title: "Mail", Username: "bob", CustomText: "forwarded to github notifications"},
+		{Type: "Card", Title: "Visa", Fields: []CustomField{{Name: "Bank", Value: "Example Bank"}, {Name: "PIN", Value: "secretpin", Type: FieldHidden}}},
+		{Type: "Note", Title: "Server", CustomText: "the dev box runs on port 22"},
+	}
+	for _, e := range entries {
+		if _, err := s.SaveEntry(0, e); err != nil {
+			t.Fatalf("SaveEntry: %v", err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"git", []string{"GitHub", "Mail"}}, // a title hit outranks a notes hit
+		{"user:alice", []string{"GitHub"}},
+		{"url:github", []string{"GitHub"}},
+		{`"dev box"`, []string{"Server"}},
+		{`"box dev"`, []string{}},
+		{"type:card", []string{"Visa"}},
+		{"bank type:card", []string{"Visa"}},
+		{"secretpin", []string{}}, // hidden values are not indexed
+		{"tag:dev git", []string{"GitHub"}},
+	}
+	for _, tt := range tests {
+		if got := searchTitles(t, s, tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Search(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	results, _ := s.Search(ParseSearch("git"))
+	if !reflect.DeepEqual(results[0].Fields, []string{"title", "url"}) {
+		t.Fatalf("unexpected matched fields %v", results[0].Fields)
+	}
+}
+
+func TestSearchIndexFollowsChanges(t *testing.T) {
+	s := openTestStore(t)
+
+	id, err := s.SaveEntry(0, &EntryFull{Type: "File", Title: "Keys"})
+	if err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	if err := s.WriteAttachment("a1", id, "deploy_key.pem", 1, []byte{1}); err != nil {
+		t.Fatalf("WriteAttachment: %v", err)
+	}
+	if got := searchTitles(t, s, "file:deploy"); len(got) != 1 {
+		t.Fatalf("expected the attachment name to be indexed, got %q", got)
+	}
+	if err := s.DeleteAttachment("a1"); err != nil {
+		t.Fatalf("DeleteAttachment: %v", err)
+	}
+	if got := searchTitles(t, s, "deploy"); len(got) != 0 {
+		t.Fatalf("expected the attachment to leave the index, got %q", got)
+	}
+
+	if err := s.UpdateEntryFull(id, 0, &EntryFull{Type: "File", Title: "Certificates"}); err != nil {
+		t.Fatalf("UpdateEntryFull: %v", err)
+	}
+	if got := searchTitles(t, s, "keys"); len(got) != 0 {
+		t.Fatalf("expected the old title to leave the index, got %q", got)
+	}
+	if err := s.DeleteEntry(id); err != nil {
+		t.Fatalf("DeleteEntry: %v", err)
+	}
+	var rows int
+	s.q.QueryRow("SELECT count(*) FROM entry_search").Scan(&rows)
+	if rows != 0 {
+		t.Fatalf("expected the deleted entry to leave the index, %d rows left", rows)
+	}
+}
+
+func TestSearchIndexMovesToFTS5(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "passbook.db")
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+	migrations = saved[:len(saved)-1]
+
+	s, err := Open(dbPath, "testpass")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := s.SaveEntry(0, &EntryFull{Type: "Login", Title: "GitHub", Username: "alice"}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	s.Close()
+
+	migrations = saved
+	s, err = Open(dbPath, "testpass")
+	if err != nil {
+		t.Fatalf("Open after upgrade: %v", err)
+	}
+	defer s.Close()
+	var sql string
+	s.q.QueryRow("SELECT sql FROM sqlite_master WHERE name = 'entry_search'").Scan(&sql)
+	if !strings.Contains(sql, "fts5") {
+		t.Fatalf("expected an FTS5 index, got %q", sql)
+	}
+	if got := searchTitles(t, s, "user:ali"); !reflect.DeepEqual(got, []string{"GitHub"}) {
+		t.Fatalf("expected the entry to be reindexed, got %q", got)
+	}
+}
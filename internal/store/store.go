@@ -2,11 +2,13 @@ package store
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	_ "github.com/mutecomm/go-sqlcipher/v4"
 )
@@ -14,11 +16,23 @@ import (
 type Store struct {
 	db   *sql.DB
 	path string
+
+	// header is nil for vaults that predate cipher headers. factors holds
+	// the keyfile hash and PIN the vault was opened with, if its key
+	// needs them.
+	header  *cipherHeader
+	factors keyFactors
+
+	// q runs the queries: the database itself, or tx inside WithTx.
+	q  querier
+	tx *sql.Tx
 }
 
-type FolderInfo struct {
-	ID   int64
-	Name string
+// querier is what *sql.DB and *sql.Tx have in common.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
 }
 
 type EntryMeta struct {
@@ -35,15 +49,17 @@ type EntryFull struct {
 	Title      string
 	Username   string
 	Password   string
-	Link       string
+	URIs       []EntryURI
 	TotpSecret string
 	CardNumber string
 	Expiry     string
 	CVV        string
 	CustomText string
+	Tags       []string
 	FileName   string
 	FileData   []byte
 	History    []PasswordHistory
+	Fields     []CustomField
 	Attachments []AttachmentMeta
 }
 
@@ -52,12 +68,99 @@ type PasswordHistory struct {
 	Date     string
 }
 
+// TrackPasswordChange records prior in the password history when the
+// entry's password differs from it.
+func (e *EntryFull) TrackPasswordChange(prior string) {
+	if prior == "" || prior == e.Password {
+		return
+	}
+	e.History = append(e.History, PasswordHistory{
+		Password: prior,
+		Date:     time.Now().Format("2006-01-02 15:04"),
+	})
+}
+
+// FieldType says how a custom field's value is shown and copied.
+type FieldType string
+
+const (
+	FieldText   FieldType = "text"
+	FieldHidden FieldType = "hidden"
+	FieldURL    FieldType = "url"
+	FieldEmail  FieldType = "email"
+	FieldPhone  FieldType = "phone"
+	FieldDate   FieldType = "date"
+	FieldTOTP   FieldType = "totp"
+)
+
+// FieldTypes lists the field types in the order the editor offers them.
+var FieldTypes = []FieldType{FieldText, FieldHidden, FieldURL, FieldEmail, FieldPhone, FieldDate, FieldTOTP}
+
+// CustomField is a named value on an entry beyond its built-in fields, such
+// as a PIN or a security question. An entry's fields keep their order.
+type CustomField struct {
+	Name  string
+	Value string
+	Type  FieldType
+}
+
+// fieldsMarker starts the block of custom fields that formats without
+// fields of their own keep at the end of the notes.
+const fieldsMarker = "Custom Fields:\n"
+
+// FieldsToNotes appends fields to notes as a "Custom Fields:" block of
+// "name: value" lines, for formats that have nowhere else to keep them.
+// Field types are not kept.
+func FieldsToNotes(notes string, fields []CustomField) string {
+	var lines []string
+	for _, f := range fields {
+		if f.Name != "" || f.Value != "" {
+			lines = append(lines, f.Name+": "+f.Value)
+		}
+	}
+	if len(lines) == 0 {
+		return notes
+	}
+	block := fieldsMarker + strings.Join(lines, "\n")
+	if strings.TrimSpace(notes) == "" {
+		return block
+	}
+	return notes + "\n\n" + block
+}
+
+// FieldsFromNotes undoes FieldsToNotes: it removes a trailing
+// "Custom Fields:" block from notes and returns it as text fields. Notes
+// are returned unchanged, with no fields, when the block cannot be parsed.
+func FieldsFromNotes(notes string) (string, []CustomField) {
+	var rest, block string
+	if strings.HasPrefix(notes, fieldsMarker) {
+		block = notes[len(fieldsMarker):]
+	} else if i := strings.LastIndex(notes, "\n\n"+fieldsMarker); i >= 0 {
+		rest, block = notes[:i], notes[i+2+len(fieldsMarker):]
+	} else {
+		return notes, nil
+	}
+
+	var fields []CustomField
+	for _, line := range strings.Split(block, "\n") {
+		name, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			return notes, nil
+		}
+		fields = append(fields, CustomField{Name: name, Value: value, Type: FieldText})
+	}
+	return rest, fields
+}
+
 type AttachmentMeta struct {
 	ID       string
 	FileName string
 	Size     int64
 }
 
+// PinConfig is the vault's second factor. A vault can have a PIN, an
+// authenticator app (TOTP) or both; Mode is the one asked for first,
+// "pin" or "totp", or "off" when two-factor authentication is turned off.
 type PinConfig struct {
 	Mode       string
 	PinKey     []byte
@@ -65,12 +168,78 @@ type PinConfig struct {
 	TotpSecret string
 }
 
+// KeyedPIN reports whether the PIN is part of the vault key, see
+// KeyWithPIN, rather than a check kept in the vault. Only a PIN that is
+// the sole second factor can be.
+func (c *PinConfig) KeyedPIN() bool {
+	return c.Mode == "pin" && c.PinTag == "" && c.TotpSecret == ""
+}
+
+func (c *PinConfig) HasPIN() bool {
+	return c.PinTag != "" || c.KeyedPIN()
+}
+
+func (c *PinConfig) HasTOTP() bool {
+	return c.TotpSecret != ""
+}
+
+// Open opens the vault at dbPath with the master password key, creating
+// it with DefaultCipherParams if there is none yet.
 func Open(dbPath string, key string) (*Store, error) {
+	return OpenWith(dbPath, Credentials{Password: key})
+}
+
+// OpenWith is Open for vaults that may need a keyfile or PIN as well. It
+// returns ErrKeyfileRequired or ErrPINRequired, before trying to decrypt
+// anything, when c lacks one the vault needs.
+func OpenWith(dbPath string, c Credentials) (*Store, error) {
+	h, err := readCipherHeader(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if h == nil && !DBExists(dbPath) {
+		return CreateWith(dbPath, c, DefaultCipherParams())
+	}
+	s, err := open(dbPath, c, h)
+	switch {
+	case err == nil:
+		// The header opens the vault, so a key switch that left the
+		// previous header behind did complete.
+		finishSwitch(dbPath)
+	case errors.Is(err, ErrWrongKey), errors.Is(err, ErrKeyfileRequired), errors.Is(err, ErrPINRequired):
+		if prev := openPrevious(dbPath, c); prev != nil {
+			return prev, nil
+		}
+	}
+	return s, err
+}
+
+// open opens the database at dbPath with a key derived from c under h, or
+// with the password itself and SQLCipher's defaults when h is nil.
+func open(dbPath string, c Credentials, h *cipherHeader) (*Store, error) {
 	if err := os.MkdirAll(filepath.Dir(dbPath), 0700); err != nil {
 		return nil, fmt.Errorf("creating db directory: %w", err)
 	}
 
-	dsn := fmt.Sprintf("file:%s?_pragma_key=%s", dbPath, url.QueryEscape(key))
+	key := c.Password
+	var factors Credentials
+	if h != nil {
+		var err error
+		if key, err = h.key(c); err != nil {
+			return nil, err
+		}
+		factors = h.factors(c)
+	}
+	return openKey(dbPath, key, h, factors)
+}
+
+// openKey opens the database at dbPath with the SQLCipher passphrase key.
+func openKey(dbPath, key string, h *cipherHeader, factors Credentials) (*Store, error) {
+	pageSize, kdfIter := sqlcipherPageSize, sqlcipherKDFIter
+	if h != nil {
+		pageSize, kdfIter = h.PageSize, h.KDFIter
+	}
+	dsn := fmt.Sprintf("file:%s?_pragma_key=%s&_pragma_cipher_page_size=%d", dbPath, url.QueryEscape(key), pageSize)
 	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("opening database: %w", err)
@@ -78,10 +247,9 @@ func Open(dbPath string, key string) (*Store, error) {
 
 	db.SetMaxOpenConns(1)
 
-	var count int
-	if err := db.QueryRow("SELECT count(*) FROM sqlite_master").Scan(&count); err != nil {
+	if err := connect(db, kdfIter); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("wrong database key or corrupt database: %w", err)
+		return nil, err
 	}
 
 	if _, err := db.Exec("PRAGMA journal_mode = WAL"); err != nil {
@@ -93,9 +261,9 @@ func Open(dbPath string, key string) (*Store, error) {
 		return nil, fmt.Errorf("enabling foreign keys: %w", err)
 	}
 
-	s := &Store{db: db, path: dbPath}
+	s := &Store{db: db, path: dbPath, header: h, factors: newKeyFactors(factors), q: db}
 	if err := s.migrate(); err != nil {
-		db.Close()
+		s.Close()
 		return nil, fmt.Errorf("migrating schema: %w", err)
 	}
 
@@ -120,150 +288,79 @@ func VerifyKey(dbPath string, key string) error {
 	return nil
 }
 
+// Rekey changes the master password. A vault with a cipher header keeps
+// its salt, parameters, keyfile, PIN and recovery key.
 func (s *Store) Rekey(newKey string) error {
-	escaped := strings.ReplaceAll(newKey, "'", "''")
+	if s.header == nil {
+		return s.rekey(newKey)
+	}
+	h := *s.header
+	key, err := h.key(s.credentials(newKey))
+	if err != nil {
+		return err
+	}
+	return s.switchKey(&h, key)
+}
+
+func (s *Store) rekey(key string) error {
+	escaped := strings.ReplaceAll(key, "'", "''")
 	_, err := s.db.Exec(fmt.Sprintf("PRAGMA rekey = '%s'", escaped))
 	return err
 }
 
 func (s *Store) Close() error {
+	if s.tx == nil {
+		s.factors.release()
+	}
 	if s.db != nil {
 		return s.db.Close()
 	}
 	return nil
 }
 
-func (s *Store) migrate() error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS folders (
-		id   INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL UNIQUE
-	);
-
-	CREATE TABLE IF NOT EXISTS entries (
-		id          INTEGER PRIMARY KEY AUTOINCREMENT,
-		folder_id   INTEGER NOT NULL DEFAULT 0,
-		entry_type  TEXT NOT NULL DEFAULT '',
-		title       TEXT NOT NULL,
-		username    TEXT NOT NULL DEFAULT '',
-		password    TEXT NOT NULL DEFAULT '',
-		link        TEXT NOT NULL DEFAULT '',
-		totp_secret TEXT NOT NULL DEFAULT '',
-		card_number TEXT NOT NULL DEFAULT '',
-		expiry      TEXT NOT NULL DEFAULT '',
-		cvv         TEXT NOT NULL DEFAULT '',
-		custom_text TEXT NOT NULL DEFAULT '',
-		file_name   TEXT NOT NULL DEFAULT '',
-		file_data   BLOB
-	);
-
-	CREATE UNIQUE INDEX IF NOT EXISTS idx_entries_folder_title
-		ON entries(folder_id, title);
-
-	CREATE TABLE IF NOT EXISTS password_history (
-		id       INTEGER PRIMARY KEY AUTOINCREMENT,
-		entry_id INTEGER NOT NULL REFERENCES entries(id) ON DELETE CASCADE,
-		password TEXT NOT NULL,
-		date     TEXT NOT NULL DEFAULT ''
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_password_history_entry
-		ON password_history(entry_id);
-
-	CREATE TABLE IF NOT EXISTS attachments (
-		id        TEXT PRIMARY KEY,
-		entry_id  INTEGER NOT NULL REFERENCES entries(id) ON DELETE CASCADE,
-		file_name TEXT NOT NULL DEFAULT '',
-		size      INTEGER NOT NULL DEFAULT 0,
-		data      BLOB NOT NULL
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_attachments_entry
-		ON attachments(entry_id);
-
-	CREATE TABLE IF NOT EXISTS pin_config (
-		id          INTEGER PRIMARY KEY CHECK (id = 1),
-		mode        TEXT NOT NULL DEFAULT '',
-		pin_key     BLOB,
-		pin_tag     TEXT NOT NULL DEFAULT '',
-		totp_secret TEXT NOT NULL DEFAULT ''
-	);
-	`
-
-	_, err := s.db.Exec(schema)
-	return err
-}
-
-// ── Folders ─────────────────────────────────────────────────────────
-
-func (s *Store) ListFolders() ([]FolderInfo, error) {
-	rows, err := s.db.Query("SELECT id, name FROM folders ORDER BY name")
-	if err != nil {
-		return nil, err
+// WithTx runs fn in a transaction. fn gets a Store bound to the
+// transaction and must use it for every read and write; the database only
+// has one connection, so using s inside fn would block. The transaction is
+// rolled back if fn returns an error and committed otherwise. Calling
+// WithTx on a Store that is already in a transaction joins it.
+func (s *Store) WithTx(fn func(tx *Store) error) error {
+	if s.tx != nil {
+		return fn(s)
 	}
-	defer rows.Close()
-
-	var folders []FolderInfo
-	for rows.Next() {
-		var f FolderInfo
-		if err := rows.Scan(&f.ID, &f.Name); err != nil {
-			return nil, err
-		}
-		folders = append(folders, f)
-	}
-	return folders, rows.Err()
-}
-
-func (s *Store) CreateFolder(name string) (int64, error) {
-	res, err := s.db.Exec("INSERT INTO folders (name) VALUES (?)", name)
+	tx, err := s.db.Begin()
 	if err != nil {
-		return 0, err
+		return fmt.Errorf("starting transaction: %w", err)
 	}
-	return res.LastInsertId()
-}
-
-func (s *Store) RenameFolder(id int64, name string) error {
-	_, err := s.db.Exec("UPDATE folders SET name = ? WHERE id = ?", name, id)
-	return err
-}
-
-func (s *Store) DeleteFolder(id int64) error {
-	_, err := s.db.Exec("DELETE FROM folders WHERE id = ?", id)
-	return err
-}
-
-func (s *Store) GetFolderByName(name string) (*FolderInfo, error) {
-	var f FolderInfo
-	err := s.db.QueryRow("SELECT id, name FROM folders WHERE name = ?", name).Scan(&f.ID, &f.Name)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-	if err != nil {
-		return nil, err
+	ts := &Store{db: s.db, path: s.path, header: s.header, factors: s.factors, q: tx, tx: tx}
+	if err := fn(ts); err != nil {
+		tx.Rollback()
+		return err
 	}
-	return &f, nil
+	return tx.Commit()
 }
 
-func (s *Store) GetFolder(id int64) (*FolderInfo, error) {
-	var f FolderInfo
-	err := s.db.QueryRow("SELECT id, name FROM folders WHERE id = ?", id).Scan(&f.ID, &f.Name)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
+// ── Entries ─────────────────────────────────────────────────────────
+
+// SaveEntry inserts e and its password history in one transaction.
+func (s *Store) SaveEntry(folderID int64, e *EntryFull) (int64, error) {
+	var id int64
+	err := s.WithTx(func(tx *Store) error {
+		var err error
+		id, err = tx.insertEntry(folderID, e)
+		return err
+	})
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	return &f, nil
+	return id, nil
 }
 
-// ── Entries ─────────────────────────────────────────────────────────
-
-func (s *Store) SaveEntry(folderID int64, e *EntryFull) (int64, error) {
-	res, err := s.db.Exec(
-		`INSERT INTO entries (folder_id, entry_type, title, username, password, link,
+func (s *Store) insertEntry(folderID int64, e *EntryFull) (int64, error) {
+	res, err := s.q.Exec(
+		`INSERT INTO entries (folder_id, entry_type, title, username, password,
 		 totp_secret, card_number, expiry, cvv, custom_text, file_name, file_data)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		folderID, e.Type, e.Title, e.Username, e.Password, e.Link,
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		folderID, e.Type, e.Title, e.Username, e.Password,
 		e.TotpSecret, e.CardNumber, e.Expiry, e.CVV, e.CustomText,
 		e.FileName, e.FileData)
 	if err != nil {
@@ -274,31 +371,63 @@ func (s *Store) SaveEntry(folderID int64, e *EntryFull) (int64, error) {
 		return 0, err
 	}
 	if err := s.replaceHistory(id, e.History); err != nil {
-		return id, err
+		return 0, err
+	}
+	if err := s.replaceURIs(id, e.URIs); err != nil {
+		return 0, err
+	}
+	if err := s.replaceTags(id, e.Tags); err != nil {
+		return 0, err
+	}
+	if err := s.replaceFields(id, e.Fields); err != nil {
+		return 0, err
+	}
+	if err := s.indexEntry(id); err != nil {
+		return 0, err
 	}
 	return id, nil
 }
 
+// UpdateEntryFull replaces entry id and its password history in one
+// transaction.
 func (s *Store) UpdateEntryFull(id, folderID int64, e *EntryFull) error {
-	_, err := s.db.Exec(
+	return s.WithTx(func(tx *Store) error {
+		return tx.updateEntry(id, folderID, e)
+	})
+}
+
+func (s *Store) updateEntry(id, folderID int64, e *EntryFull) error {
+	_, err := s.q.Exec(
 		`UPDATE entries SET folder_id=?, entry_type=?, title=?, username=?, password=?,
-		 link=?, totp_secret=?, card_number=?, expiry=?, cvv=?, custom_text=?,
+		 totp_secret=?, card_number=?, expiry=?, cvv=?, custom_text=?,
 		 file_name=?, file_data=? WHERE id=?`,
 		folderID, e.Type, e.Title, e.Username, e.Password,
-		e.Link, e.TotpSecret, e.CardNumber, e.Expiry, e.CVV, e.CustomText,
+		e.TotpSecret, e.CardNumber, e.Expiry, e.CVV, e.CustomText,
 		e.FileName, e.FileData, id)
 	if err != nil {
 		return err
 	}
-	return s.replaceHistory(id, e.History)
+	if err := s.replaceHistory(id, e.History); err != nil {
+		return err
+	}
+	if err := s.replaceURIs(id, e.URIs); err != nil {
+		return err
+	}
+	if err := s.replaceTags(id, e.Tags); err != nil {
+		return err
+	}
+	if err := s.replaceFields(id, e.Fields); err != nil {
+		return err
+	}
+	return s.indexEntry(id)
 }
 
 func (s *Store) replaceHistory(entryID int64, history []PasswordHistory) error {
-	if _, err := s.db.Exec("DELETE FROM password_history WHERE entry_id = ?", entryID); err != nil {
+	if _, err := s.q.Exec("DELETE FROM password_history WHERE entry_id = ?", entryID); err != nil {
 		return err
 	}
 	for _, h := range history {
-		if _, err := s.db.Exec(
+		if _, err := s.q.Exec(
 			"INSERT INTO password_history (entry_id, password, date) VALUES (?, ?, ?)",
 			entryID, h.Password, h.Date); err != nil {
 			return err
@@ -307,20 +436,37 @@ func (s *Store) replaceHistory(entryID int64, history []PasswordHistory) error {
 	return nil
 }
 
+func (s *Store) replaceFields(entryID int64, fields []CustomField) error {
+	if _, err := s.q.Exec("DELETE FROM entry_fields WHERE entry_id = ?", entryID); err != nil {
+		return err
+	}
+	for i, f := range fields {
+		if f.Type == "" {
+			f.Type = FieldText
+		}
+		if _, err := s.q.Exec(
+			"INSERT INTO entry_fields (entry_id, position, name, value, type) VALUES (?, ?, ?, ?, ?)",
+			entryID, i, f.Name, f.Value, string(f.Type)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *Store) LoadEntry(id int64) (*EntryFull, error) {
 	e := &EntryFull{ID: id}
-	err := s.db.QueryRow(
-		`SELECT folder_id, entry_type, title, username, password, link, totp_secret,
+	err := s.q.QueryRow(
+		`SELECT folder_id, entry_type, title, username, password, totp_secret,
 		 card_number, expiry, cvv, custom_text, file_name, file_data
 		 FROM entries WHERE id = ?`, id,
-	).Scan(&e.FolderID, &e.Type, &e.Title, &e.Username, &e.Password, &e.Link,
+	).Scan(&e.FolderID, &e.Type, &e.Title, &e.Username, &e.Password,
 		&e.TotpSecret, &e.CardNumber, &e.Expiry, &e.CVV, &e.CustomText,
 		&e.FileName, &e.FileData)
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := s.db.Query(
+	rows, err := s.q.Query(
 		"SELECT password, date FROM password_history WHERE entry_id = ? ORDER BY id", id)
 	if err == nil {
 		defer rows.Close()
@@ -332,7 +478,35 @@ func (s *Store) LoadEntry(id int64) (*EntryFull, error) {
 		}
 	}
 
-	attRows, err := s.db.Query(
+	uriRows, err := s.q.Query(
+		"SELECT uri, match_mode FROM entry_uris WHERE entry_id = ? ORDER BY position", id)
+	if err == nil {
+		defer uriRows.Close()
+		for uriRows.Next() {
+			var u EntryURI
+			if uriRows.Scan(&u.URI, &u.Match) == nil {
+				e.URIs = append(e.URIs, u)
+			}
+		}
+	}
+
+	fieldRows, err := s.q.Query(
+		"SELECT name, value, type FROM entry_fields WHERE entry_id = ? ORDER BY position", id)
+	if err == nil {
+		defer fieldRows.Close()
+		for fieldRows.Next() {
+			var f CustomField
+			if fieldRows.Scan(&f.Name, &f.Value, &f.Type) == nil {
+				e.Fields = append(e.Fields, f)
+			}
+		}
+	}
+
+	if tags, err := s.loadTags(id); err == nil {
+		e.Tags = tags
+	}
+
+	attRows, err := s.q.Query(
 		"SELECT id, file_name, size FROM attachments WHERE entry_id = ? ORDER BY file_name", id)
 	if err == nil {
 		defer attRows.Close()
@@ -348,7 +522,7 @@ func (s *Store) LoadEntry(id int64) (*EntryFull, error) {
 }
 
 func (s *Store) ListEntries(folderID int64) ([]EntryMeta, error) {
-	rows, err := s.db.Query(
+	rows, err := s.q.Query(
 		"SELECT id, folder_id, title, entry_type FROM entries WHERE folder_id = ? ORDER BY title",
 		folderID)
 	if err != nil {
@@ -368,7 +542,7 @@ func (s *Store) ListEntries(folderID int64) ([]EntryMeta, error) {
 }
 
 func (s *Store) ListAllEntries() ([]EntryMeta, error) {
-	rows, err := s.db.Query(
+	rows, err := s.q.Query(
 		"SELECT id, folder_id, title, entry_type FROM entries ORDER BY folder_id, title")
 	if err != nil {
 		return nil, err
@@ -388,7 +562,7 @@ func (s *Store) ListAllEntries() ([]EntryMeta, error) {
 
 func (s *Store) GetEntryMeta(id int64) (*EntryMeta, error) {
 	var e EntryMeta
-	err := s.db.QueryRow(
+	err := s.q.QueryRow(
 		"SELECT id, folder_id, title, entry_type FROM entries WHERE id = ?", id,
 	).Scan(&e.ID, &e.FolderID, &e.Title, &e.EntryType)
 	if err == sql.ErrNoRows {
@@ -401,13 +575,13 @@ func (s *Store) GetEntryMeta(id int64) (*EntryMeta, error) {
 }
 
 func (s *Store) DeleteEntry(id int64) error {
-	_, err := s.db.Exec("DELETE FROM entries WHERE id = ?", id)
+	_, err := s.q.Exec("DELETE FROM entries WHERE id = ?", id)
 	return err
 }
 
 func (s *Store) EntryExistsInFolder(folderID int64, title string) bool {
 	var count int
-	err := s.db.QueryRow(
+	err := s.q.QueryRow(
 		"SELECT COUNT(*) FROM entries WHERE folder_id = ? AND title = ?",
 		folderID, title).Scan(&count)
 	return err == nil && count > 0
@@ -415,7 +589,7 @@ func (s *Store) EntryExistsInFolder(folderID int64, title string) bool {
 
 func (s *Store) EntryExistsInFolderExcluding(folderID int64, title string, excludeID int64) bool {
 	var count int
-	err := s.db.QueryRow(
+	err := s.q.QueryRow(
 		"SELECT COUNT(*) FROM entries WHERE folder_id = ? AND title = ? AND id != ?",
 		folderID, title, excludeID).Scan(&count)
 	return err == nil && count > 0
@@ -423,13 +597,13 @@ func (s *Store) EntryExistsInFolderExcluding(folderID int64, title string, exclu
 
 func (s *Store) HasEntries() bool {
 	var count int
-	err := s.db.QueryRow("SELECT COUNT(*) FROM entries").Scan(&count)
+	err := s.q.QueryRow("SELECT COUNT(*) FROM entries").Scan(&count)
 	return err == nil && count > 0
 }
 
 func (s *Store) CountEntriesInFolder(folderID int64) int {
 	var count int
-	_ = s.db.QueryRow("SELECT COUNT(*) FROM entries WHERE folder_id = ?", folderID).Scan(&count)
+	_ = s.q.QueryRow("SELECT COUNT(*) FROM entries WHERE folder_id = ?", folderID).Scan(&count)
 	return count
 }
 
@@ -437,7 +611,7 @@ func (s *Store) CountEntriesInFolder(folderID int64) int {
 
 func (s *Store) ReadAttachment(id string) ([]byte, error) {
 	var data []byte
-	err := s.db.QueryRow("SELECT data FROM attachments WHERE id = ?", id).Scan(&data)
+	err := s.q.QueryRow("SELECT data FROM attachments WHERE id = ?", id).Scan(&data)
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("attachment not found: %s", id)
 	}
@@ -445,23 +619,40 @@ func (s *Store) ReadAttachment(id string) ([]byte, error) {
 }
 
 func (s *Store) WriteAttachment(id string, entryID int64, fileName string, size int64, data []byte) error {
-	_, err := s.db.Exec(
-		`INSERT INTO attachments (id, entry_id, file_name, size, data) VALUES (?, ?, ?, ?, ?)
-		 ON CONFLICT(id) DO UPDATE SET data = excluded.data, file_name = excluded.file_name, size = excluded.size`,
-		id, entryID, fileName, size, data)
-	return err
+	return s.WithTx(func(tx *Store) error {
+		_, err := tx.q.Exec(
+			`INSERT INTO attachments (id, entry_id, file_name, size, data) VALUES (?, ?, ?, ?, ?)
+			 ON CONFLICT(id) DO UPDATE SET data = excluded.data, file_name = excluded.file_name, size = excluded.size`,
+			id, entryID, fileName, size, data)
+		if err != nil {
+			return err
+		}
+		return tx.indexEntry(entryID)
+	})
 }
 
 func (s *Store) DeleteAttachment(id string) error {
-	_, err := s.db.Exec("DELETE FROM attachments WHERE id = ?", id)
-	return err
+	return s.WithTx(func(tx *Store) error {
+		var entryID int64
+		err := tx.q.QueryRow("SELECT entry_id FROM attachments WHERE id = ?", id).Scan(&entryID)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.q.Exec("DELETE FROM attachments WHERE id = ?", id); err != nil {
+			return err
+		}
+		return tx.indexEntry(entryID)
+	})
 }
 
 // ── PIN Config ──────────────────────────────────────────────────────
 
 func (s *Store) ReadPinConfig() (*PinConfig, error) {
 	var cfg PinConfig
-	err := s.db.QueryRow(
+	err := s.q.QueryRow(
 		"SELECT mode, pin_key, pin_tag, totp_secret FROM pin_config WHERE id = 1",
 	).Scan(&cfg.Mode, &cfg.PinKey, &cfg.PinTag, &cfg.TotpSecret)
 	if err == sql.ErrNoRows {
@@ -477,7 +668,7 @@ func (s *Store) ReadPinConfig() (*PinConfig, error) {
 }
 
 func (s *Store) WritePinConfig(cfg *PinConfig) error {
-	_, err := s.db.Exec(
+	_, err := s.q.Exec(
 		`INSERT INTO pin_config (id, mode, pin_key, pin_tag, totp_secret) VALUES (1, ?, ?, ?, ?)
 		 ON CONFLICT(id) DO UPDATE SET mode=excluded.mode, pin_key=excluded.pin_key,
 		 pin_tag=excluded.pin_tag, totp_secret=excluded.totp_secret`,
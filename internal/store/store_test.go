@@ -0,0 +1,119 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "passbook.db"), "testpass")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestWithTxCommits(t *testing.T) {
+	s := openTestStore(t)
+
+	err := s.WithTx(func(tx *Store) error {
+		folderID, err := tx.CreateFolder(0, "Work")
+		if err != nil {
+			return err
+		}
+		_, err = tx.SaveEntry(folderID, &EntryFull{Type: "Login", Title: "Site"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+
+	if f, _ := s.GetFolderByPath("Work"); f == nil {
+		t.Fatalf("expected the folder to be committed")
+	}
+	if !s.HasEntries() {
+		t.Fatalf("expected the entry to be committed")
+	}
+}
+
+func TestWithTxRollsBack(t *testing.T) {
+	s := openTestStore(t)
+
+	boom := errors.New("boom")
+	err := s.WithTx(func(tx *Store) error {
+		folderID, err := tx.CreateFolder(0, "Work")
+		if err != nil {
+			return err
+		}
+		// A nested WithTx joins the outer transaction.
+		err = tx.WithTx(func(tx *Store) error {
+			_, err := tx.SaveEntry(folderID, &EntryFull{Type: "Login", Title: "Site"})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected fn's error, got %v", err)
+	}
+
+	if f, _ := s.GetFolderByPath("Work"); f != nil {
+		t.Fatalf("expected the folder to be rolled back")
+	}
+	if s.HasEntries() {
+		t.Fatalf("expected the entry to be rolled back")
+	}
+}
+
+func TestEntryFieldsKeepOrder(t *testing.T) {
+	s := openTestStore(t)
+
+	fields := []CustomField{
+		{Name: "PIN", Value: "1234", Type: FieldHidden},
+		{Name: "Support", Value: "https://help.example.com", Type: FieldURL},
+		{Name: "Note", Value: "plain"},
+	}
+	id, err := s.SaveEntry(0, &EntryFull{Type: "Login", Title: "Site", Fields: fields})
+	if err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	e, err := s.LoadEntry(id)
+	if err != nil {
+		t.Fatalf("LoadEntry: %v", err)
+	}
+	fields[2].Type = FieldText
+	if len(e.Fields) != 3 || e.Fields[0] != fields[0] || e.Fields[1] != fields[1] || e.Fields[2] != fields[2] {
+		t.Fatalf("unexpected fields: %+v", e.Fields)
+	}
+
+	e.Fields = []CustomField{fields[1], fields[0]}
+	if err := s.UpdateEntryFull(id, 0, e); err != nil {
+		t.Fatalf("UpdateEntryFull: %v", err)
+	}
+	e, _ = s.LoadEntry(id)
+	if len(e.Fields) != 2 || e.Fields[0].Name != "Support" || e.Fields[1].Name != "PIN" {
+		t.Fatalf("expected reordered fields, got %+v", e.Fields)
+	}
+}
+
+func TestFieldsNotesRoundTrip(t *testing.T) {
+	fields := []CustomField{{Name: "Recovery", Value: "abc: 123", Type: FieldText}}
+	notes := FieldsToNotes("intro", fields)
+	if notes != "intro\n\nCustom Fields:\nRecovery: abc: 123" {
+		t.Fatalf("unexpected notes %q", notes)
+	}
+	rest, got := FieldsFromNotes(notes)
+	if rest != "intro" || len(got) != 1 || got[0] != fields[0] {
+		t.Fatalf("unexpected split %q %+v", rest, got)
+	}
+
+	unparseable := "intro\n\nCustom Fields:\nnot a field"
+	if rest, got := FieldsFromNotes(unparseable); rest != unparseable || got != nil {
+		t.Fatalf("expected notes to be left alone, got %q %v", rest, got)
+	}
+}
@@ -0,0 +1,179 @@
+package store
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+)
+
+// TagInfo is a tag together with the number of entries that carry it.
+type TagInfo struct {
+	Name  string
+	Count int
+}
+
+// SavedSearch is a named search query shown as a virtual folder.
+type SavedSearch struct {
+	ID    int64
+	Name  string
+	Query string
+}
+
+// NormalizeTag trims tag and drops a leading '#'. Tags are single words,
+// so inner whitespace becomes '-'. It returns "" for a blank tag.
+func NormalizeTag(tag string) string {
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+	return strings.Join(strings.Fields(tag), "-")
+}
+
+// normalizeTags normalizes tags and drops blanks and case-insensitive
+// duplicates, keeping the first spelling of each.
+func normalizeTags(tags []string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, t := range tags {
+		t = NormalizeTag(t)
+		if t == "" || seen[strings.ToLower(t)] {
+			continue
+		}
+		seen[strings.ToLower(t)] = true
+		out = append(out, t)
+	}
+	return out
+}
+
+// replaceTags sets the tags of entryID, creating tags that do not exist yet
+// and dropping tags no entry uses any more.
+func (s *Store) replaceTags(entryID int64, tags []string) error {
+	if _, err := s.q.Exec("DELETE FROM entry_tags WHERE entry_id = ?", entryID); err != nil {
+		return err
+	}
+	for _, t := range normalizeTags(tags) {
+		if _, err := s.q.Exec("INSERT OR IGNORE INTO tags (name) VALUES (?)", t); err != nil {
+			return err
+		}
+		if _, err := s.q.Exec(
+			"INSERT INTO entry_tags (entry_id, tag_id) SELECT ?, id FROM tags WHERE name = ?",
+			entryID, t); err != nil {
+			return err
+		}
+	}
+	_, err := s.q.Exec("DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM entry_tags)")
+	return err
+}
+
+func (s *Store) loadTags(entryID int64) ([]string, error) {
+	rows, err := s.q.Query(
+		`SELECT t.name FROM entry_tags et JOIN tags t ON t.id = et.tag_id
+		 WHERE et.entry_id = ? ORDER BY t.name`, entryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// ListTags returns the tags in use, by name, with their entry counts.
+func (s *Store) ListTags() ([]TagInfo, error) {
+	rows, err := s.q.Query(
+		`SELECT t.name, count(*) FROM tags t JOIN entry_tags et ON et.tag_id = t.id
+		 GROUP BY t.id ORDER BY t.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []TagInfo
+	for rows.Next() {
+		var t TagInfo
+		if err := rows.Scan(&t.Name, &t.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// EntryTags returns the tags of every tagged entry, keyed by entry ID.
+func (s *Store) EntryTags() (map[int64][]string, error) {
+	rows, err := s.q.Query(
+		"SELECT et.entry_id, t.name FROM entry_tags et JOIN tags t ON t.id = et.tag_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make(map[int64][]string)
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		tags[id] = append(tags[id], name)
+	}
+	for _, t := range tags {
+		sort.Strings(t)
+	}
+	return tags, rows.Err()
+}
+
+// ── Saved searches ──────────────────────────────────────────────────
+
+func (s *Store) ListSavedSearches() ([]SavedSearch, error) {
+	rows, err := s.q.Query("SELECT id, name, query FROM saved_searches ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var searches []SavedSearch
+	for rows.Next() {
+		var ss SavedSearch
+		if err := rows.Scan(&ss.ID, &ss.Name, &ss.Query); err != nil {
+			return nil, err
+		}
+		searches = append(searches, ss)
+	}
+	return searches, rows.Err()
+}
+
+// SaveSearch stores query under name, replacing the query of an existing
+// search with that name.
+func (s *Store) SaveSearch(name, query string) (int64, error) {
+	if _, err := s.q.Exec(
+		`INSERT INTO saved_searches (name, query) VALUES (?, ?)
+		 ON CONFLICT(name) DO UPDATE SET query = excluded.query`, name, query); err != nil {
+		return 0, err
+	}
+	var id int64
+	err := s.q.QueryRow("SELECT id FROM saved_searches WHERE name = ?", name).Scan(&id)
+	return id, err
+}
+
+func (s *Store) GetSavedSearch(id int64) (*SavedSearch, error) {
+	var ss SavedSearch
+	err := s.q.QueryRow("SELECT id, name, query FROM saved_searches WHERE id = ?", id).
+		Scan(&ss.ID, &ss.Name, &ss.Query)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ss, nil
+}
+
+func (s *Store) DeleteSavedSearch(id int64) error {
+	_, err := s.q.Exec("DELETE FROM saved_searches WHERE id = ?", id)
+	return err
+}
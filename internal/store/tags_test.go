@@ -0,0 +1,77 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEntryTags(t *testing.T) {
+	s := openTestStore(t)
+
+	id, err := s.SaveEntry(0, &EntryFull{Type: "Login", Title: "AWS", Tags: []string{" #prod", "cloud", "Prod", "on call"}})
+	if err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	if _, err := s.SaveEntry(0, &EntryFull{Type: "Note", Title: "Runbook", Tags: []string{"PROD"}}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+
+	e, err := s.LoadEntry(id)
+	if err != nil {
+		t.Fatalf("LoadEntry: %v", err)
+	}
+	if want := []string{"cloud", "on-call", "prod"}; !reflect.DeepEqual(e.Tags, want) {
+		t.Fatalf("expected tags %v, got %v", want, e.Tags)
+	}
+
+	tags, err := s.ListTags()
+	if err != nil {
+		t.Fatalf("ListTags: %v", err)
+	}
+	want := []TagInfo{{"cloud", 1}, {"on-call", 1}, {"prod", 2}}
+	if !reflect.DeepEqual(tags, want) {
+		t.Fatalf("expected %v, got %v", want, tags)
+	}
+
+	e.Tags = []string{"prod"}
+	if err := s.UpdateEntryFull(id, 0, e); err != nil {
+		t.Fatalf("UpdateEntryFull: %v", err)
+	}
+	byEntry, err := s.EntryTags()
+	if err != nil {
+		t.Fatalf("EntryTags: %v", err)
+	}
+	if !reflect.DeepEqual(byEntry[id], []string{"prod"}) {
+		t.Fatalf("expected only prod to remain, got %v", byEntry[id])
+	}
+	if tags, _ := s.ListTags(); len(tags) != 1 {
+		t.Fatalf("expected unused tags to be dropped, got %v", tags)
+	}
+}
+
+func TestSavedSearches(t *testing.T) {
+	s := openTestStore(t)
+
+	id, err := s.SaveSearch("Prod logins", "tag:prod")
+	if err != nil {
+		t.Fatalf("SaveSearch: %v", err)
+	}
+	if again, err := s.SaveSearch("Prod logins", "tag:prod aws"); err != nil || again != id {
+		t.Fatalf("expected the search to be updated in place, got %d, %v", again, err)
+	}
+
+	searches, err := s.ListSavedSearches()
+	if err != nil {
+		t.Fatalf("ListSavedSearches: %v", err)
+	}
+	if len(searches) != 1 || searches[0] != (SavedSearch{ID: id, Name: "Prod logins", Query: "tag:prod aws"}) {
+		t.Fatalf("unexpected saved searches: %+v", searches)
+	}
+
+	if err := s.DeleteSavedSearch(id); err != nil {
+		t.Fatalf("DeleteSavedSearch: %v", err)
+	}
+	if ss, _ := s.GetSavedSearch(id); ss != nil {
+		t.Fatalf("expected search to be deleted, got %+v", ss)
+	}
+}
@@ -0,0 +1,180 @@
+package store
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// MatchMode says how an entry URI is compared with the URL being filled.
+type MatchMode string
+
+const (
+	// MatchDefault uses the default rule, which is MatchDomain.
+	MatchDefault    MatchMode = ""
+	MatchDomain     MatchMode = "domain"
+	MatchHost       MatchMode = "host"
+	MatchStartsWith MatchMode = "starts-with"
+	MatchExact      MatchMode = "exact"
+	MatchRegex      MatchMode = "regex"
+	MatchNever      MatchMode = "never"
+)
+
+// MatchModes lists the match modes in the order the editor offers them.
+var MatchModes = []MatchMode{MatchDomain, MatchHost, MatchStartsWith, MatchExact, MatchRegex, MatchNever}
+
+// ValidMatchMode reports whether m is MatchDefault or one of MatchModes.
+func ValidMatchMode(m MatchMode) bool {
+	if m == MatchDefault {
+		return true
+	}
+	for _, mode := range MatchModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// EntryURI is one of a login's URLs together with its match rule.
+type EntryURI struct {
+	URI   string
+	Match MatchMode
+}
+
+// Link returns the entry's first URI, the one opened by default.
+func (e *EntryFull) Link() string {
+	if len(e.URIs) == 0 {
+		return ""
+	}
+	return e.URIs[0].URI
+}
+
+// AddURI appends uri with the default match rule. Blank URIs are ignored.
+func (e *EntryFull) AddURI(uri string) {
+	uri = strings.TrimSpace(uri)
+	if uri != "" {
+		e.URIs = append(e.URIs, EntryURI{URI: uri})
+	}
+}
+
+// SetLink replaces the first URI, keeping its match rule, or removes it
+// when uri is blank.
+func (e *EntryFull) SetLink(uri string) {
+	uri = strings.TrimSpace(uri)
+	switch {
+	case uri == "" && len(e.URIs) > 0:
+		e.URIs = e.URIs[1:]
+	case uri == "":
+	case len(e.URIs) == 0:
+		e.AddURI(uri)
+	default:
+		e.URIs[0].URI = uri
+	}
+}
+
+// Matches reports whether target, a URL, is covered by u.
+func (u EntryURI) Matches(target string) bool {
+	switch u.Match {
+	case MatchNever:
+		return false
+	case MatchExact:
+		return u.URI == target
+	case MatchStartsWith:
+		return strings.HasPrefix(target, u.URI)
+	case MatchRegex:
+		re, err := regexp.Compile(u.URI)
+		return err == nil && re.MatchString(target)
+	case MatchHost:
+		want, ok := uriHost(u.URI, true)
+		got, ok2 := uriHost(target, true)
+		return ok && ok2 && want == got
+	default:
+		want, ok := uriHost(u.URI, false)
+		got, ok2 := uriHost(target, false)
+		return ok && ok2 && BaseDomain(want) == BaseDomain(got)
+	}
+}
+
+// uriHost returns the lower-cased host of raw, with the port when withPort
+// is set. A bare "example.com" is read as a host rather than a path.
+func uriHost(raw string, withPort bool) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", false
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return "", false
+	}
+	if withPort {
+		return strings.ToLower(u.Host), true
+	}
+	return strings.ToLower(u.Hostname()), true
+}
+
+// BaseDomain returns the registrable domain of host under the public
+// suffix list: "mail.example.com" gives "example.com", "www.example.co.uk"
+// gives "example.co.uk" and "me.github.io" stays "me.github.io", since
+// every github.io subdomain belongs to someone else. IP addresses,
+// single-label hosts and public suffixes are returned unchanged.
+func BaseDomain(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	base, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return base
+}
+
+func (s *Store) replaceURIs(entryID int64, uris []EntryURI) error {
+	if _, err := s.q.Exec("DELETE FROM entry_uris WHERE entry_id = ?", entryID); err != nil {
+		return err
+	}
+	for i, u := range uris {
+		if _, err := s.q.Exec(
+			"INSERT INTO entry_uris (entry_id, position, uri, match_mode) VALUES (?, ?, ?, ?)",
+			entryID, i, u.URI, string(u.Match)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MatchLogins returns the logins with a URI that matches target, ordered
+// like ListAllEntries. A login is listed once however many URIs match.
+func (s *Store) MatchLogins(target string) ([]EntryMeta, error) {
+	rows, err := s.q.Query(
+		`SELECT e.id, e.folder_id, e.title, e.entry_type, u.uri, u.match_mode
+		 FROM entry_uris u JOIN entries e ON e.id = u.entry_id
+		 WHERE e.entry_type = 'Login'
+		 ORDER BY e.folder_id, e.title, u.position`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []EntryMeta
+	seen := make(map[int64]bool)
+	for rows.Next() {
+		var e EntryMeta
+		var u EntryURI
+		if err := rows.Scan(&e.ID, &e.FolderID, &e.Title, &e.EntryType, &u.URI, &u.Match); err != nil {
+			return nil, err
+		}
+		if !seen[e.ID] && u.Matches(target) {
+			seen[e.ID] = true
+			matches = append(matches, e)
+		}
+	}
+	return matches, rows.Err()
+}
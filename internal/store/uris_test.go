@@ -0,0 +1,94 @@
+package store
+
+import "testing"
+
+func TestEntryURIMatches(t *testing.T) {
+	tests := []struct {
+		uri    EntryURI
+		target string
+		want   bool
+	}{
+		{EntryURI{URI: "https://github.com/login"}, "https://gist.github.com/x", true},
+		{EntryURI{URI: "github.com"}, "https://github.com", true},
+		{EntryURI{URI: "https://github.com", Match: MatchDomain}, "https://gitlab.com", false},
+		{EntryURI{URI: "https://www.example.co.uk"}, "https://shop.example.co.uk", true},
+		{EntryURI{URI: "https://a.co.uk"}, "https://b.co.uk", false},
+		{EntryURI{URI: "https://me.github.io"}, "https://attacker.github.io", false},
+		{EntryURI{URI: "https://me.github.io"}, "https://www.me.github.io/x", true},
+		{EntryURI{URI: "https://app.herokuapp.com"}, "https://evil.herokuapp.com", false},
+		{EntryURI{URI: "https://me.blogspot.com"}, "https://attacker.blogspot.com", false},
+		{EntryURI{URI: "https://www.example.com.au"}, "https://login.example.com.au", true},
+		{EntryURI{URI: "https://github.com", Match: MatchHost}, "https://gist.github.com", false},
+		{EntryURI{URI: "https://localhost:8080", Match: MatchHost}, "http://localhost:8080/x", true},
+		{EntryURI{URI: "https://localhost:8080", Match: MatchHost}, "http://localhost:9090", false},
+		{EntryURI{URI: "https://example.com/app", Match: MatchStartsWith}, "https://example.com/app/login", true},
+		{EntryURI{URI: "https://example.com/app", Match: MatchStartsWith}, "https://example.com/other", false},
+		{EntryURI{URI: "https://example.com/", Match: MatchExact}, "https://example.com/", true},
+		{EntryURI{URI: "https://example.com/", Match: MatchExact}, "https://example.com/a", false},
+		{EntryURI{URI: `^https://[a-z]+\.example\.com/`, Match: MatchRegex}, "https://eu.example.com/", true},
+		{EntryURI{URI: `(`, Match: MatchRegex}, "(", false},
+		{EntryURI{URI: "https://github.com", Match: MatchNever}, "https://github.com", false},
+	}
+	for _, tt := range tests {
+		if got := tt.uri.Matches(tt.target); got != tt.want {
+			t.Errorf("%+v matches %q = %v, want %v", tt.uri, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestBaseDomain(t *testing.T) {
+	for host, want := range map[string]string{
+		"mail.example.com":  "example.com",
+		"www.example.co.uk": "example.co.uk",
+		"a.b.me.github.io":  "me.github.io",
+		"app.herokuapp.com": "app.herokuapp.com",
+		"github.io":         "github.io",
+		"Example.COM.":      "example.com",
+		"localhost":         "localhost",
+		"192.168.1.1":       "192.168.1.1",
+	} {
+		if got := BaseDomain(host); got != want {
+			t.Errorf("BaseDomain(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
+
+func TestMatchLogins(t *testing.T) {
+	s := openTestStore(t)
+
+	entries := []*EntryFull{
+		{Type: "Login", Title: "GitHub", URIs: []EntryURI{
+			{URI: "https://example.org", Match: MatchNever},
+			{URI: "https://github.com"},
+			{URI: "https://github.com/login", Match: MatchExact},
+		}},
+		{Type: "Login", Title: "Gist", URIs: []EntryURI{{URI: "https://gist.github.com", Match: MatchHost}}},
+		{Type: "Note", Title: "Not a login", URIs: []EntryURI{{URI: "https://github.com"}}},
+	}
+	for _, e := range entries {
+		if _, err := s.SaveEntry(0, e); err != nil {
+			t.Fatalf("SaveEntry: %v", err)
+		}
+	}
+
+	matches, err := s.MatchLogins("https://github.com/login")
+	if err != nil {
+		t.Fatalf("MatchLogins: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Title != "GitHub" {
+		t.Fatalf("unexpected matches: %+v", matches)
+	}
+
+	matches, _ = s.MatchLogins("https://gist.github.com/abc")
+	if len(matches) != 2 {
+		t.Fatalf("expected both logins to match, got %+v", matches)
+	}
+
+	e, err := s.LoadEntry(matches[1].ID)
+	if err != nil {
+		t.Fatalf("LoadEntry: %v", err)
+	}
+	if len(e.URIs) != 3 || e.URIs[2].Match != MatchExact || e.Link() != "https://example.org" {
+		t.Fatalf("expected URIs to keep their order and rules, got %+v", e.URIs)
+	}
+}
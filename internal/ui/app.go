@@ -2,7 +2,9 @@ package ui
 
 import (
 	"path/filepath"
+	"time"
 
+	"passbook/internal/clipboard"
 	"passbook/internal/config"
 	"passbook/internal/store"
 
@@ -18,6 +20,8 @@ var (
 	uiDataDir string
 	uiDBPath  string
 	uiStore   *store.Store
+
+	uiClipboard *clipboard.Manager
 )
 
 func NewApp(c config.AppConfig) (*AppHandle, error) {
@@ -25,6 +29,14 @@ func NewApp(c config.AppConfig) (*AppHandle, error) {
 	uiDataDir = config.ExpandPath(uiCfg.DataDir)
 	uiDBPath = filepath.Join(uiDataDir, "passbook.db")
 
+	backend, err := clipboard.Detect(uiCfg.ClipboardBackend, uiTerm)
+	if err != nil {
+		return nil, err
+	}
+	uiClipboard = clipboard.New(backend, time.Duration(uiCfg.ClipboardClearSeconds)*time.Second)
+
+	uiApp.SetAfterDrawFunc(uiTerm.attach)
+
 	setupUI()
 	uiPages.SwitchToPage("login")
 	return &AppHandle{}, nil
@@ -37,10 +49,14 @@ func (a *AppHandle) Run() error {
 	return uiApp.SetRoot(uiPages, true).EnableMouse(true).Run()
 }
 
+// cleanup drops what the UI holds of the vault on exit, takes a copied
+// secret off the clipboard and wipes the secrets kept in locked memory.
 func (a *AppHandle) cleanup() {
-	if uiStore != nil {
-		uiStore.Close()
-	}
+	uiTerm.attach(nil)
+	uiClipboard.Flush()
+	clearVaultState()
+	replaceSecret(&uiUnlockKey, "")
+	closeAndCleanupStore(false)
 }
 
 func (a *AppHandle) QueueUpdateDraw(f func()) {
@@ -49,18 +65,26 @@ func (a *AppHandle) QueueUpdateDraw(f func()) {
 
 func (a *AppHandle) DrawTOTP() { drawTOTP() }
 
+// CheckIdle locks the vault when it has been idle too long.
+func (a *AppHandle) CheckIdle() { checkIdleLock() }
+
 func setupUI() {
 	tview.Styles.ContrastBackgroundColor = colorUnfocusedBg
 	tview.Styles.TitleColor = tcell.ColorLightSkyBlue
 
 	setupLogin()
 	setupPin()
+	setupRecovery()
 	setupMainLayout()
 	setupModals()
 	setupQuickCopy()
+	setupFinder()
 	setupEditor()
 	setupChangePassword()
+	setupSecurity()
 	setupFolderCreate()
 	setupFolderRename()
 	setupFolderDelete()
+	setupSavedSearches()
+	setupLock()
 }
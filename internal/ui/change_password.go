@@ -1,7 +1,6 @@
 package ui
 
 import (
-	"passbook/internal/store"
 	"passbook/internal/utils"
 
 	"github.com/gdamore/tcell/v2"
@@ -79,7 +78,7 @@ func doChangePassword() {
 		return
 	}
 
-	if err := store.VerifyKey(uiDBPath, currentPwd); err != nil {
+	if err := uiStore.VerifyPassword(currentPwd); err != nil {
 		showChangePwdError("Current password is incorrect.")
 		return
 	}
@@ -100,6 +99,10 @@ func doChangePassword() {
 		return
 	}
 
+	if uiUnlockKey != nil {
+		replaceSecret(&uiUnlockKey, newPwd)
+	}
+
 	clearChangePwdForm()
 	uiPages.SwitchToPage("main")
 	uiApp.SetFocus(uiTreeView)
@@ -5,6 +5,8 @@ import (
 	"os"
 	"strings"
 
+	"passbook/internal/store"
+
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 )
@@ -46,6 +48,7 @@ func setupEditor() {
 	uiEditorLayout.SetBorder(true).SetTitle(" Edit Entry ")
 	uiPages.AddPage("editor", newResponsiveModal(uiEditorLayout, 60, 25, 120, 50, 0.8, 0.85), true, false)
 
+	setupFieldEditor()
 	setupFileBrowser()
 	setupPassGen()
 	setupCollisionModals()
@@ -86,7 +89,7 @@ func openEditor(ent *Entry) {
 	uiEditorForm.AddFormItem(titleField)
 
 	folders := listFolders()
-	folderOptions := []string{"— (root)"}
+	folderOptions := []string{rootFolderOption}
 	folderOptions = append(folderOptions, folders...)
 	currentFolderIdx := 0
 	if uiCurrentEntryID != 0 {
@@ -95,7 +98,7 @@ func openEditor(ent *Entry) {
 			folder, _ := uiStore.GetFolder(meta.FolderID)
 			if folder != nil {
 				for i, opt := range folderOptions {
-					if opt == folder.Name {
+					if opt == folder.Path {
 						currentFolderIdx = i
 						break
 					}
@@ -106,7 +109,7 @@ func openEditor(ent *Entry) {
 		folder, _ := uiStore.GetFolder(uiCurrentFolderID)
 		if folder != nil {
 			for i, opt := range folderOptions {
-				if opt == folder.Name {
+				if opt == folder.Path {
 					currentFolderIdx = i
 					break
 				}
@@ -118,8 +121,10 @@ func openEditor(ent *Entry) {
 	folderDrop.SetCurrentOption(currentFolderIdx)
 	uiEditorFolderField = folderDrop
 	uiEditorForm.AddFormItem(folderDrop)
+	addTagFields(ent)
 
 	uiEditorLayout.RemoveItem(uiAttachFlex)
+	uiEditorLayout.RemoveItem(uiFieldsFlex)
 
 	switch EntryType(ent.Type) {
 	case TypeLogin:
@@ -133,6 +138,7 @@ func openEditor(ent *Entry) {
 	}
 
 	uiEditorForm.AddTextArea("Notes", ent.CustomText, 50, 5, 0, nil)
+	addCustomFields(ent)
 	saveButtonIndex := uiEditorForm.GetButtonCount()
 	uiEditorForm.AddButton("Save", func() { saveEntry(EntryType(ent.Type)) })
 	uiEditorSaveButton = uiEditorForm.GetButton(saveButtonIndex)
@@ -211,6 +217,8 @@ func saveEntry(eType EntryType) {
 		Type:        string(eType),
 		Title:       title,
 		CustomText:  uiEditorForm.GetFormItemByLabel("Notes").(*tview.TextArea).GetText(),
+		Fields:      uiPendingFields,
+		Tags:        uiPendingTags,
 		History:     priorHistory,
 		Attachments: uiPendingAttachments,
 	}
@@ -232,8 +240,8 @@ func saveEntry(eType EntryType) {
 	var folderID int64
 	if uiEditorFolderField != nil {
 		_, folderName := uiEditorFolderField.GetCurrentOption()
-		if folderName != "— (root)" {
-			f, _ := uiStore.GetFolderByName(folderName)
+		if folderName != rootFolderOption {
+			f, _ := uiStore.GetFolderByPath(folderName)
 			if f != nil {
 				folderID = f.ID
 			}
@@ -258,13 +266,19 @@ func saveEntry(eType EntryType) {
 }
 
 func commitSaveNew(folderID int64, ent *Entry) {
-	entryID, err := uiStore.SaveEntry(folderID, ent)
+	var entryID int64
+	err := uiStore.WithTx(func(tx *store.Store) error {
+		var err error
+		if entryID, err = tx.SaveEntry(folderID, ent); err != nil {
+			return err
+		}
+		return saveAttachments(tx, entryID)
+	})
 	if err != nil {
+		showSaveError(err)
 		return
 	}
 
-	saveAttachments(entryID)
-
 	refreshTree(uiSearchField.GetText())
 	selectTreeNode(nodeRef{IsFolder: false, ID: entryID})
 	uiPages.SwitchToPage("main")
@@ -272,9 +286,14 @@ func commitSaveNew(folderID int64, ent *Entry) {
 }
 
 func commitSave(entryID int64, folderID int64, ent *Entry) {
-	saveAttachments(entryID)
-
-	if err := uiStore.UpdateEntryFull(entryID, folderID, ent); err != nil {
+	err := uiStore.WithTx(func(tx *store.Store) error {
+		if err := tx.UpdateEntryFull(entryID, folderID, ent); err != nil {
+			return err
+		}
+		return saveAttachments(tx, entryID)
+	})
+	if err != nil {
+		showSaveError(err)
 		return
 	}
 
@@ -284,11 +303,19 @@ func commitSave(entryID int64, folderID int64, ent *Entry) {
 	loadEntry(entryID)
 }
 
-func saveAttachments(entryID int64) {
+// showSaveError reports a failed save. The save runs in one transaction,
+// so the vault is left as it was and the editor keeps the user's changes.
+func showSaveError(err error) {
+	uiErrorModal.SetText("Could not save the entry, nothing was changed:\n" + err.Error())
+	uiPages.SwitchToPage("error")
+}
+
+// saveAttachments writes the files added in the editor to entryID.
+func saveAttachments(s *store.Store, entryID int64) error {
 	for id, localPath := range uiPendingFilePaths {
 		data, err := os.ReadFile(localPath)
 		if err != nil {
-			continue
+			return fmt.Errorf("reading attachment: %w", err)
 		}
 		var fileName string
 		var size int64
@@ -304,8 +331,11 @@ func saveAttachments(entryID int64) {
 			fileName = parts[len(parts)-1]
 			size = int64(len(data))
 		}
-		_ = uiStore.WriteAttachment(id, entryID, fileName, size, data)
+		if err := s.WriteAttachment(id, entryID, fileName, size, data); err != nil {
+			return fmt.Errorf("saving attachment %q: %w", fileName, err)
+		}
 	}
+	return nil
 }
 
 func highlightFocusedEditorItem() {
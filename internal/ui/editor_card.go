@@ -2,9 +2,10 @@ package ui
 
 import (
 	"fmt"
-	"strconv"
 	"strings"
 
+	"passbook/internal/utils"
+
 	"github.com/rivo/tview"
 )
 
@@ -95,59 +96,19 @@ func validateCardFields() error {
 		return fmt.Errorf("card fields unavailable")
 	}
 
-	number := strings.TrimSpace(uiEditorCardNumber.GetText())
-	expiry := strings.TrimSpace(uiEditorExpiry.GetText())
-	cvv := strings.TrimSpace(uiEditorCVV.GetText())
-
-	if number != "" {
-		if len(number) < 13 || len(number) > 19 || !isDigits(number) {
-			return fmt.Errorf("card number must be 13-19 digits")
-		}
-	}
-
-	if expiry != "" {
-		if len(expiry) != 5 || expiry[2] != '/' {
-			return fmt.Errorf("expiry must be MM/YY")
-		}
-		mm, yy := expiry[:2], expiry[3:]
-		if !isDigits(mm) || !isDigits(yy) {
-			return fmt.Errorf("expiry must be MM/YY")
-		}
-		month, _ := strconv.Atoi(mm)
-		if month < 1 || month > 12 {
-			return fmt.Errorf("expiry must be MM/YY")
-		}
-	}
-
-	if cvv != "" {
-		if (len(cvv) != 3 && len(cvv) != 4) || !isDigits(cvv) {
-			return fmt.Errorf("CVV must be 3 or 4 digits")
-		}
-	}
-
-	return nil
-}
-
-func isDigits(s string) bool {
-	if s == "" {
-		return false
-	}
-	for _, r := range s {
-		if r < '0' || r > '9' {
-			return false
-		}
-	}
-	return true
+	number, expiry, cvv := collectCardFields()
+	return utils.ValidateCard(number, expiry, cvv)
 }
 
 // renderCardView renders the card-type view pane content.
 func renderCardView() {
-	num := uiCurrentEnt.CardNumber
-	if !uiShowSensitive && len(num) > 4 {
-		num = "**** **** **** " + num[len(num)-4:]
+	number := uiEntrySecrets.cardNumber
+	if num := number.Bytes(); !uiShowSensitive && len(num) > 4 {
+		uiViewSubtitle.SetText("**** **** **** " + string(num[len(num)-4:]))
+	} else {
+		uiViewSubtitle.SetText(number.String())
 	}
-	uiViewSubtitle.SetText(num)
-	btnCopy := styleButton(tview.NewButton("cp").SetSelectedFunc(func() { copySensitive(uiCurrentEnt.CardNumber, "Card") }))
+	btnCopy := styleButton(tview.NewButton("cp").SetSelectedFunc(func() { copySensitive(number.String(), "Card") }))
 	btnShow := styleButton(tview.NewButton("vw").SetSelectedFunc(func() { uiShowSensitive = !uiShowSensitive; updateViewPane() }))
 	uiViewFlex.AddItem(makeRow("Number:", uiViewSubtitle, btnShow, btnCopy), 1, 0, false)
 
@@ -156,7 +117,7 @@ func renderCardView() {
 
 	cvv := "***"
 	if uiShowSensitive {
-		cvv = uiCurrentEnt.CVV
+		cvv = uiEntrySecrets.cvv.String()
 	}
 	uiViewPassword.SetText(cvv)
 	uiViewFlex.AddItem(makeRow("CVV:", uiViewPassword), 1, 0, false)
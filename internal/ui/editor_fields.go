@@ -0,0 +1,266 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"passbook/internal/platform"
+	"passbook/internal/store"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+var (
+	uiPendingFields []CustomField
+	uiFieldsFlex    *tview.Flex
+	uiFieldsList    *tview.List
+	uiFieldForm     *tview.Form
+	uiShownFields   map[int]bool
+)
+
+// setupFieldEditor builds the custom field list shown under the editor form
+// and the modal used to add or change one field.
+func setupFieldEditor() {
+	uiFieldsList = tview.NewList().ShowSecondaryText(false).SetMainTextColor(tcell.ColorWhite)
+	uiFieldsList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		i := uiFieldsList.GetCurrentItem()
+		switch {
+		case event.Key() == tcell.KeyEsc || event.Key() == tcell.KeyTab:
+			uiApp.SetFocus(uiEditorForm)
+			return nil
+		case event.Key() == tcell.KeyUp && event.Modifiers()&tcell.ModShift != 0:
+			uiFieldsList.SetCurrentItem(moveField(i, -1))
+			return nil
+		case event.Key() == tcell.KeyDown && event.Modifiers()&tcell.ModShift != 0:
+			uiFieldsList.SetCurrentItem(moveField(i, 1))
+			return nil
+		case event.Key() == tcell.KeyDelete || event.Rune() == 'd':
+			removeField(i)
+			return nil
+		case event.Rune() == 'a':
+			openFieldForm(-1)
+			return nil
+		}
+		return event
+	})
+
+	uiFieldsFlex = tview.NewFlex().SetDirection(tview.FlexRow)
+	uiFieldsFlex.AddItem(tview.NewTextView().
+		SetText(" Fields: [::d]a add · Enter edit · d remove · Shift+↑/↓ move · Esc back[::-]").
+		SetDynamicColors(true).SetTextColor(tcell.ColorYellow), 1, 0, false)
+	uiFieldsFlex.AddItem(uiFieldsList, 0, 1, true)
+
+	uiFieldForm = tview.NewForm()
+	uiFieldForm.SetBorder(true)
+	uiFieldForm.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEsc {
+			closeFieldForm(-1)
+			return nil
+		}
+		return event
+	})
+	uiPages.AddPage("field", newResponsiveModal(uiFieldForm, 50, 11, 70, 11, 0.5, 0.3), true, false)
+}
+
+// addCustomFields adds the custom field list to the editor. The form gets a
+// button that moves focus to the list, where fields are edited.
+func addCustomFields(ent *Entry) {
+	uiPendingFields = append([]CustomField(nil), ent.Fields...)
+	uiEditorLayout.AddItem(uiFieldsFlex, 0, 0, false)
+	uiEditorForm.AddButton("Fields", func() { uiApp.SetFocus(uiFieldsList) })
+	refreshFieldList()
+}
+
+// refreshFieldList rebuilds the field list from uiPendingFields. The last
+// item adds a new field.
+func refreshFieldList() {
+	current := uiFieldsList.GetCurrentItem()
+	uiFieldsList.Clear()
+	for i, f := range uiPendingFields {
+		idx := i
+		uiFieldsList.AddItem(fieldListLabel(f), "", 0, func() { openFieldForm(idx) })
+	}
+	uiFieldsList.AddItem("[green]+ Add field[-]", "", 0, func() { openFieldForm(-1) })
+	uiFieldsList.SetCurrentItem(current)
+
+	size := len(uiPendingFields) + 2
+	if size > 8 {
+		size = 8
+	}
+	uiEditorLayout.ResizeItem(uiFieldsFlex, size, 0)
+}
+
+func fieldListLabel(f CustomField) string {
+	value := f.Value
+	if fieldMasked(f) {
+		value = strings.Repeat("*", len(value))
+	}
+	return fmt.Sprintf("%s [::d](%s)[::-] %s", tview.Escape(f.Name), f.Type, tview.Escape(value))
+}
+
+// moveField swaps field i with its neighbour delta places away and returns
+// the field's new index.
+func moveField(i, delta int) int {
+	j := i + delta
+	if i < 0 || i >= len(uiPendingFields) || j < 0 || j >= len(uiPendingFields) {
+		return i
+	}
+	uiPendingFields[i], uiPendingFields[j] = uiPendingFields[j], uiPendingFields[i]
+	refreshFieldList()
+	return j
+}
+
+func removeField(i int) {
+	if i < 0 || i >= len(uiPendingFields) {
+		return
+	}
+	uiPendingFields = append(uiPendingFields[:i], uiPendingFields[i+1:]...)
+	refreshFieldList()
+}
+
+// openFieldForm edits field i, or adds a new field when i is -1.
+func openFieldForm(i int) {
+	f := CustomField{Type: store.FieldText}
+	if i >= 0 && i < len(uiPendingFields) {
+		f = uiPendingFields[i]
+	} else {
+		i = -1
+	}
+
+	uiFieldForm.Clear(true)
+	if i < 0 {
+		uiFieldForm.SetTitle(" Add Field ")
+	} else {
+		uiFieldForm.SetTitle(" Edit Field ")
+	}
+
+	name := tview.NewInputField().SetLabel("Name").SetText(f.Name).SetFieldWidth(30)
+	value := tview.NewInputField().SetLabel("Value").SetText(f.Value).SetFieldWidth(30)
+	typeOptions := make([]string, len(store.FieldTypes))
+	typeIndex := 0
+	for n, t := range store.FieldTypes {
+		typeOptions[n] = string(t)
+		if t == f.Type {
+			typeIndex = n
+		}
+	}
+	typeDrop := tview.NewDropDown().SetLabel("Type").SetFieldWidth(12)
+	typeDrop.SetOptions(typeOptions, func(option string, _ int) {
+		if store.FieldType(option) == store.FieldHidden {
+			value.SetMaskCharacter('*')
+		} else {
+			value.SetMaskCharacter(0)
+		}
+	})
+	typeDrop.SetCurrentOption(typeIndex)
+
+	uiFieldForm.AddFormItem(name)
+	uiFieldForm.AddFormItem(typeDrop)
+	uiFieldForm.AddFormItem(value)
+	uiFieldForm.AddButton("Save", func() {
+		f.Name = strings.TrimSpace(name.GetText())
+		if f.Name == "" {
+			uiApp.SetFocus(name)
+			return
+		}
+		_, option := typeDrop.GetCurrentOption()
+		f.Type = store.FieldType(option)
+		f.Value = value.GetText()
+		if i < 0 {
+			uiPendingFields = append(uiPendingFields, f)
+			closeFieldForm(len(uiPendingFields) - 1)
+		} else {
+			uiPendingFields[i] = f
+			closeFieldForm(i)
+		}
+	})
+	uiFieldForm.AddButton("Cancel", func() { closeFieldForm(i) })
+	styleForm(uiFieldForm)
+	enableButtonNav(uiFieldForm)
+	uiPages.SwitchToPage("field")
+	uiApp.SetFocus(uiFieldForm)
+}
+
+// closeFieldForm returns to the editor's field list with field i selected,
+// or the current item when i is -1.
+func closeFieldForm(i int) {
+	refreshFieldList()
+	if i >= 0 {
+		uiFieldsList.SetCurrentItem(i)
+	}
+	uiPages.SwitchToPage("editor")
+	uiApp.SetFocus(uiFieldsList)
+}
+
+// fieldMasked reports whether field values are hidden until shown.
+func fieldMasked(f CustomField) bool {
+	return f.Type == store.FieldHidden || f.Type == store.FieldTOTP
+}
+
+// renderFieldsView renders the entry's custom fields in the view pane, each
+// with a copy button. Hidden fields are masked until shown, TOTP fields
+// show the current code, and URL fields can be opened.
+func renderFieldsView() {
+	if len(uiCurrentEnt.Fields) == 0 {
+		return
+	}
+	uiViewFlex.AddItem(tview.NewTextView().SetText(""), 1, 0, false)
+	uiViewFlex.AddItem(tview.NewTextView().SetText("[yellow]Fields:[-]").SetDynamicColors(true), 1, 0, false)
+
+	for i, f := range uiCurrentEnt.Fields {
+		idx, field := i, f
+		text := tview.NewTextView().SetDynamicColors(true)
+		var buttons []*tview.Button
+
+		switch field.Type {
+		case store.FieldHidden:
+			value := uiEntrySecrets.fields[idx]
+			shown := strings.Repeat("*", value.Len())
+			if uiShownFields[idx] {
+				shown = tview.Escape(value.String())
+			}
+			text.SetText(shown)
+			buttons = append(buttons,
+				styleButton(tview.NewButton("vw").SetSelectedFunc(func() {
+					if uiShownFields == nil {
+						uiShownFields = make(map[int]bool)
+					}
+					uiShownFields[idx] = !uiShownFields[idx]
+					updateViewPane()
+				})),
+				styleButton(tview.NewButton("cp").SetSelectedFunc(func() { copySensitive(value.String(), field.Name) })))
+
+		case store.FieldTOTP:
+			secret := uiEntrySecrets.fields[idx]
+			if code, err := totpCode(secret); err == nil {
+				text.SetText(code)
+			} else {
+				text.SetText("[red]invalid secret[-]")
+			}
+			buttons = append(buttons, styleButton(tview.NewButton("cp").SetSelectedFunc(func() {
+				if code, err := totpCode(secret); err == nil {
+					copySensitive(code, field.Name)
+				}
+			})))
+
+		case store.FieldURL:
+			text.SetText("[blue::u]" + tview.Escape(field.Value) + "[-:-:-]")
+			buttons = append(buttons,
+				styleButton(tview.NewButton("open").SetSelectedFunc(func() { _ = platform.OpenURL(field.Value) })),
+				copyFieldButton(field))
+
+		default:
+			text.SetText(tview.Escape(field.Value))
+			buttons = append(buttons, copyFieldButton(field))
+		}
+
+		uiViewFlex.AddItem(makeRow(field.Name+":", text, buttons...), 1, 0, false)
+	}
+}
+
+func copyFieldButton(f CustomField) *tview.Button {
+	return styleButton(tview.NewButton("cp").SetSelectedFunc(func() {
+		copyText(f.Value, f.Name)
+	}))
+}
@@ -0,0 +1,58 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"passbook/internal/store"
+)
+
+func TestMoveAndRemoveFields(t *testing.T) {
+	resetEditorTestState()
+	addCustomFields(&Entry{Fields: []CustomField{
+		{Name: "a", Value: "1", Type: store.FieldText},
+		{Name: "b", Value: "2", Type: store.FieldText},
+		{Name: "c", Value: "3", Type: store.FieldText},
+	}})
+
+	if got := moveField(0, 1); got != 1 {
+		t.Fatalf("expected moved field at 1, got %d", got)
+	}
+	if got := moveField(2, 1); got != 2 {
+		t.Fatalf("expected last field to stay put, got %d", got)
+	}
+	removeField(2)
+
+	var names []string
+	for _, f := range uiPendingFields {
+		names = append(names, f.Name)
+	}
+	if strings.Join(names, ",") != "b,a" {
+		t.Fatalf("unexpected field order %v", names)
+	}
+	// One item per field plus "+ Add field".
+	if uiFieldsList.GetItemCount() != 3 {
+		t.Fatalf("expected list to be refreshed, got %d items", uiFieldsList.GetItemCount())
+	}
+}
+
+func TestAddCustomFieldsCopiesEntryFields(t *testing.T) {
+	resetEditorTestState()
+	ent := &Entry{Fields: []CustomField{{Name: "a", Value: "1", Type: store.FieldText}}}
+	addCustomFields(ent)
+	removeField(0)
+	if len(ent.Fields) != 1 {
+		t.Fatalf("editing pending fields must not change the entry")
+	}
+}
+
+func TestFieldListLabelMasksHiddenValues(t *testing.T) {
+	label := fieldListLabel(CustomField{Name: "PIN", Value: "1234", Type: store.FieldHidden})
+	if strings.Contains(label, "1234") {
+		t.Fatalf("expected hidden value to be masked, got %q", label)
+	}
+	label = fieldListLabel(CustomField{Name: "Email", Value: "me@example.com", Type: store.FieldEmail})
+	if !strings.Contains(label, "me@example.com") {
+		t.Fatalf("expected value to be shown, got %q", label)
+	}
+}
@@ -1,14 +1,13 @@
 package ui
 
 import (
+	"fmt"
 	"strings"
-	"time"
 
-	"github.com/atotto/clipboard"
-	"github.com/pquerna/otp/totp"
 	"github.com/rivo/tview"
 
 	"passbook/internal/platform"
+	"passbook/internal/store"
 )
 
 var uiEditorLoginStrength *strengthMeter
@@ -32,7 +31,7 @@ func addLoginFields(ent *Entry) {
 		uiPages.SwitchToPage("passgen")
 	})
 
-	uiEditorForm.AddInputField("Link", ent.Link, 40, nil, nil)
+	uiEditorForm.AddTextArea("URLs", formatURIs(ent.URIs), 40, 3, 0, nil)
 	uiEditorForm.AddInputField("TOTP Secret", ent.TotpSecret, 40, nil, nil)
 }
 
@@ -44,15 +43,10 @@ func collectLoginFields(ent *Entry, priorPassword string) {
 		ent.Password = uiEditorPasswordField.GetText()
 	}
 
-	ent.Link = uiEditorForm.GetFormItemByLabel("Link").(*tview.InputField).GetText()
+	ent.URIs = parseURIs(uiEditorForm.GetFormItemByLabel("URLs").(*tview.TextArea).GetText())
 	ent.TotpSecret = uiEditorForm.GetFormItemByLabel("TOTP Secret").(*tview.InputField).GetText()
 
-	if priorPassword != "" && priorPassword != ent.Password {
-		ent.History = append(ent.History, PasswordHistory{
-			Password: priorPassword,
-			Date:     time.Now().Format("2006-01-02 15:04"),
-		})
-	}
+	ent.TrackPasswordChange(priorPassword)
 }
 
 // renderLoginView renders the login-type view pane content.
@@ -60,21 +54,18 @@ func renderLoginView() {
 	if uiCurrentEnt.Username != "" {
 		uiViewSubtitle.SetText(uiCurrentEnt.Username)
 		btnCopy := styleButton(tview.NewButton("cp").SetSelectedFunc(func() {
-			if err := clipboard.WriteAll(uiCurrentEnt.Username); err != nil {
-				return
-			}
-			notifyCopied("Username")
+			copyText(uiCurrentEnt.Username, "Username")
 		}))
 		uiViewFlex.AddItem(makeRow("Username:", uiViewSubtitle, btnCopy), 1, 0, false)
 	}
 
-	if uiCurrentEnt.Password != "" {
-		pass := strings.Repeat("*", len(uiCurrentEnt.Password))
+	if password := uiEntrySecrets.password; password != nil {
+		pass := strings.Repeat("*", password.Len())
 		if uiShowSensitive {
-			pass = uiCurrentEnt.Password
+			pass = password.String()
 		}
 		uiViewPassword.SetText(pass)
-		btnPass := styleButton(tview.NewButton("cp").SetSelectedFunc(func() { copySensitive(uiCurrentEnt.Password, "Password") }))
+		btnPass := styleButton(tview.NewButton("cp").SetSelectedFunc(func() { copySensitive(password.String(), "Password") }))
 		btnShow := styleButton(tview.NewButton("vw").SetSelectedFunc(func() { uiShowSensitive = !uiShowSensitive; updateViewPane() }))
 		btnHist := styleButton(tview.NewButton("his").SetSelectedFunc(func() { showHistory() }))
 		uiViewFlex.AddItem(makeRow("Password:", uiViewPassword, btnShow, btnPass, btnHist), 1, 0, false)
@@ -82,24 +73,30 @@ func renderLoginView() {
 		uiShowSensitive = false
 	}
 
-	if strings.TrimSpace(uiCurrentEnt.Link) != "" {
+	if link := uiCurrentEnt.Link(); link != "" {
 		linkText := tview.NewTextView().SetDynamicColors(true)
-		linkText.SetText("[blue::u]" + uiCurrentEnt.Link + "[-:-:-]")
-		btnOpen := styleButton(tview.NewButton("open").SetSelectedFunc(func() { _ = platform.OpenURL(uiCurrentEnt.Link) }))
-		btnCopy := styleButton(tview.NewButton("cp").SetSelectedFunc(func() {
-			if err := clipboard.WriteAll(uiCurrentEnt.Link); err != nil {
+		shown := "[blue::u]" + tview.Escape(link) + "[-:-:-]"
+		if more := len(uiCurrentEnt.URIs) - 1; more > 0 {
+			shown += fmt.Sprintf(" [::d](+%d more)[::-]", more)
+		}
+		linkText.SetText(shown)
+		btnOpen := styleButton(tview.NewButton("open").SetSelectedFunc(func() {
+			if len(uiCurrentEnt.URIs) > 1 {
+				showURLPicker()
 				return
 			}
-			notifyCopied("Link")
+			_ = platform.OpenURL(link)
+		}))
+		btnCopy := styleButton(tview.NewButton("cp").SetSelectedFunc(func() {
+			copyText(link, "Link")
 		}))
 		uiViewFlex.AddItem(makeRow("Link:", linkText, btnOpen, btnCopy), 1, 0, false)
 	}
 
-	cleanSecret := strings.ReplaceAll(uiCurrentEnt.TotpSecret, " ", "")
-	if cleanSecret != "" {
+	if secret := uiEntrySecrets.totp; secret != nil {
 		uiViewFlex.AddItem(tview.NewTextView().SetText(""), 1, 0, false)
 		btnTotp := styleButton(tview.NewButton("cp").SetSelectedFunc(func() {
-			code, err := totp.GenerateCode(cleanSecret, time.Now())
+			code, err := totpCode(secret)
 			if err == nil {
 				copySensitive(code, "TOTP")
 			}
@@ -112,3 +109,39 @@ func renderLoginView() {
 		uiViewTOTPBar.SetText("")
 	}
 }
+
+// formatURIs renders a login's URIs for the editor, one per line. A match
+// mode other than the default follows the URI in brackets, as in
+// "https://example.com/app [starts-with]".
+func formatURIs(uris []store.EntryURI) string {
+	lines := make([]string, 0, len(uris))
+	for _, u := range uris {
+		if u.Match == store.MatchDefault {
+			lines = append(lines, u.URI)
+		} else {
+			lines = append(lines, u.URI+" ["+string(u.Match)+"]")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseURIs reads the editor's URL lines back. A bracketed suffix that is
+// not a known match mode is kept as part of the URI.
+func parseURIs(text string) []store.EntryURI {
+	var uris []store.EntryURI
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		u := store.EntryURI{URI: line}
+		if i := strings.LastIndex(line, " ["); i > 0 && strings.HasSuffix(line, "]") {
+			mode := store.MatchMode(line[i+2 : len(line)-1])
+			if mode != store.MatchDefault && store.ValidMatchMode(mode) {
+				u = store.EntryURI{URI: strings.TrimSpace(line[:i]), Match: mode}
+			}
+		}
+		uris = append(uris, u)
+	}
+	return uris
+}
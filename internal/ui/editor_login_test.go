@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/rivo/tview"
+
+	"passbook/internal/store"
 )
 
 func TestCollectLoginFields(t *testing.T) {
@@ -13,16 +15,36 @@ func TestCollectLoginFields(t *testing.T) {
 
 	uiEditorForm.GetFormItemByLabel("Username").(*tview.InputField).SetText("user")
 	uiEditorPasswordField.SetText("newpass")
-	uiEditorForm.GetFormItemByLabel("Link").(*tview.InputField).SetText("http://example.com")
+	uiEditorForm.GetFormItemByLabel("URLs").(*tview.TextArea).SetText("http://example.com\nhttp://example.com/login [exact]", false)
 	uiEditorForm.GetFormItemByLabel("TOTP Secret").(*tview.InputField).SetText("ABC123")
 
 	collectLoginFields(ent, "oldpass")
-	if ent.Username != "user" || ent.Password != "newpass" || ent.Link != "http://example.com" || ent.TotpSecret != "ABC123" {
+	if ent.Username != "user" || ent.Password != "newpass" || ent.Link() != "http://example.com" || ent.TotpSecret != "ABC123" {
 		t.Fatalf("unexpected values in entry after collect")
 	}
 	if len(ent.History) != 1 || ent.History[0].Password != "oldpass" {
 		t.Fatalf("expected password history to be appended")
 	}
+	if len(ent.URIs) != 2 || ent.URIs[1] != (store.EntryURI{URI: "http://example.com/login", Match: store.MatchExact}) {
+		t.Fatalf("unexpected URIs: %+v", ent.URIs)
+	}
+}
+
+func TestURIsRoundTripThroughEditorText(t *testing.T) {
+	uris := []store.EntryURI{
+		{URI: "https://example.com"},
+		{URI: `^https://.*\.example\.com/`, Match: store.MatchRegex},
+		{URI: "https://example.com/a [b]"},
+	}
+	got := parseURIs(formatURIs(uris))
+	if len(got) != len(uris) {
+		t.Fatalf("expected %d URIs, got %+v", len(uris), got)
+	}
+	for i := range uris {
+		if got[i] != uris[i] {
+			t.Fatalf("URI %d: got %+v, want %+v", i, got[i], uris[i])
+		}
+	}
 }
 
 func TestCollectLoginFieldsNoHistoryWhenSame(t *testing.T) {
@@ -32,7 +54,7 @@ func TestCollectLoginFieldsNoHistoryWhenSame(t *testing.T) {
 
 	uiEditorForm.GetFormItemByLabel("Username").(*tview.InputField).SetText("user")
 	uiEditorPasswordField.SetText("same")
-	uiEditorForm.GetFormItemByLabel("Link").(*tview.InputField).SetText("http://example.com")
+	uiEditorForm.GetFormItemByLabel("URLs").(*tview.TextArea).SetText("http://example.com", false)
 	uiEditorForm.GetFormItemByLabel("TOTP Secret").(*tview.InputField).SetText("ABC123")
 
 	collectLoginFields(ent, "same")
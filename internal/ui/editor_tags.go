@@ -0,0 +1,103 @@
+package ui
+
+import (
+	"strings"
+
+	"passbook/internal/store"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+var (
+	uiPendingTags   []string
+	uiEditorTags    *tview.TextView
+	uiEditorTagItem *tview.InputField
+)
+
+// addTagFields adds the tag chips and the "Add tag" input to the editor.
+// Enter adds the typed tag; Backspace in the empty input removes the last.
+func addTagFields(ent *Entry) {
+	uiPendingTags = append([]string(nil), ent.Tags...)
+
+	uiEditorTags = tview.NewTextView().SetDynamicColors(true).SetWrap(false)
+	uiEditorTags.SetLabel("Tags")
+	uiEditorTags.SetSize(1, 0)
+	uiEditorForm.AddFormItem(uiEditorTags)
+
+	uiEditorTagItem = tview.NewInputField().SetLabel("Add tag").SetFieldWidth(20).
+		SetPlaceholder("Enter adds, Backspace removes")
+	uiEditorTagItem.SetAcceptanceFunc(func(text string, ch rune) bool { return ch != ' ' })
+	uiEditorTagItem.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEnter:
+			if uiEditorTagItem.GetText() != "" {
+				addPendingTag(uiEditorTagItem.GetText())
+				uiEditorTagItem.SetText("")
+				return nil
+			}
+		case tcell.KeyBackspace, tcell.KeyBackspace2:
+			if uiEditorTagItem.GetText() == "" {
+				removeLastPendingTag()
+				return nil
+			}
+		}
+		return event
+	})
+	uiEditorForm.AddFormItem(uiEditorTagItem)
+	refreshTagChips()
+}
+
+// addPendingTag adds tag to the entry being edited unless it is blank or
+// already there, ignoring case.
+func addPendingTag(tag string) {
+	tag = store.NormalizeTag(tag)
+	if tag == "" {
+		return
+	}
+	for _, t := range uiPendingTags {
+		if strings.EqualFold(t, tag) {
+			return
+		}
+	}
+	uiPendingTags = append(uiPendingTags, tag)
+	refreshTagChips()
+}
+
+func removeLastPendingTag() {
+	if len(uiPendingTags) == 0 {
+		return
+	}
+	uiPendingTags = uiPendingTags[:len(uiPendingTags)-1]
+	refreshTagChips()
+}
+
+func refreshTagChips() {
+	if uiEditorTags == nil {
+		return
+	}
+	if len(uiPendingTags) == 0 {
+		uiEditorTags.SetText("[::d]none[::-]")
+		return
+	}
+	uiEditorTags.SetText(tagChips(uiPendingTags))
+}
+
+// tagChips renders tags as coloured chips for a dynamic-colour TextView.
+func tagChips(tags []string) string {
+	chips := make([]string, len(tags))
+	for i, t := range tags {
+		chips[i] = "[black:skyblue] " + tview.Escape(t) + " [-:-]"
+	}
+	return strings.Join(chips, " ")
+}
+
+// renderTagsView adds the entry's tags to the view pane.
+func renderTagsView() {
+	if len(uiCurrentEnt.Tags) == 0 {
+		return
+	}
+	tags := tview.NewTextView().SetDynamicColors(true).SetWrap(false).
+		SetText(tagChips(uiCurrentEnt.Tags))
+	uiViewFlex.AddItem(makeRow("Tags:", tags), 1, 0, false)
+}
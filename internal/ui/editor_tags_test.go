@@ -0,0 +1,41 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPendingTags(t *testing.T) {
+	resetEditorTestState()
+	ent := &Entry{Tags: []string{"prod"}}
+	addTagFields(ent)
+
+	addPendingTag(" #Dev ")
+	addPendingTag("PROD")
+	addPendingTag("")
+	if strings.Join(uiPendingTags, ",") != "prod,Dev" {
+		t.Fatalf("unexpected tags %v", uiPendingTags)
+	}
+	if got := uiEditorTags.GetText(true); got != " prod   Dev " {
+		t.Fatalf("unexpected chips %q", got)
+	}
+
+	removeLastPendingTag()
+	if len(uiPendingTags) != 1 || len(ent.Tags) != 1 {
+		t.Fatalf("expected one pending tag and the entry unchanged, got %v / %v", uiPendingTags, ent.Tags)
+	}
+}
+
+func TestUpdateViewPaneShowsTags(t *testing.T) {
+	resetEditorTestState()
+	initViewTestState()
+	uiCurrentEnt = &Entry{Type: string(TypeNote), Title: "Note"}
+	updateViewPane()
+	untagged := uiViewFlex.GetItemCount()
+
+	uiCurrentEnt.Tags = []string{"ops"}
+	updateViewPane()
+	if got := uiViewFlex.GetItemCount(); got != untagged+1 {
+		t.Fatalf("expected a tags row, got %d items (want %d)", got, untagged+1)
+	}
+}
@@ -9,6 +9,8 @@ func resetEditorTestState() {
 	uiEditorLayout = tview.NewFlex()
 	uiAttachFlex = tview.NewFlex()
 	uiAttachList = tview.NewList()
+	uiFieldsFlex = tview.NewFlex()
+	uiFieldsList = tview.NewList()
 
 	uiEditorTitleField = nil
 	uiEditorPasswordField = nil
@@ -18,5 +20,9 @@ func resetEditorTestState() {
 	uiEditorSaveButton = nil
 
 	uiPendingAttachments = nil
+	uiPendingFields = nil
+	uiPendingTags = nil
+	uiEditorTags = nil
+	uiEditorTagItem = nil
 	uiPendingFilePaths = map[string]string{}
 }
@@ -0,0 +1,327 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"passbook/internal/store"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+var (
+	uiFinderInput   *tview.InputField
+	uiFinderList    *tview.List
+	uiFinderPreview *tview.TextView
+
+	// uiFinderItems holds every entry while the finder is open and
+	// uiFinderShown the ones listed, in list order.
+	uiFinderItems []finderItem
+	uiFinderShown []finderItem
+)
+
+// finderItem is an entry as the finder matches and lists it. Name is the
+// folder path and title, with the title starting at rune TitleAt.
+type finderItem struct {
+	store.EntrySummary
+	Name      string
+	TitleAt   int
+	Score     int
+	Highlight []int
+}
+
+func setupFinder() {
+	uiFinderInput = styleInput(tview.NewInputField().SetLabel("> ")).
+		SetPlaceholder("folder, title, username or URL")
+	uiFinderInput.SetChangedFunc(func(text string) { filterFinder(text) })
+	uiFinderInput.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEsc:
+			dismissFinder()
+			return nil
+		case tcell.KeyEnter:
+			openFinderItem(uiFinderList.GetCurrentItem(), false)
+			return nil
+		case tcell.KeyCtrlY:
+			openFinderItem(uiFinderList.GetCurrentItem(), true)
+			return nil
+		case tcell.KeyDown, tcell.KeyCtrlN, tcell.KeyTab:
+			moveFinderCursor(1)
+			return nil
+		case tcell.KeyUp, tcell.KeyCtrlP, tcell.KeyBacktab:
+			moveFinderCursor(-1)
+			return nil
+		case tcell.KeyPgDn:
+			moveFinderCursor(10)
+			return nil
+		case tcell.KeyPgUp:
+			moveFinderCursor(-10)
+			return nil
+		}
+		return event
+	})
+
+	uiFinderList = tview.NewList().ShowSecondaryText(false)
+	uiFinderList.SetHighlightFullLine(true)
+	uiFinderList.SetMainTextColor(tcell.ColorWhite)
+	uiFinderList.SetSelectedTextColor(tcell.ColorBlack)
+	uiFinderList.SetSelectedBackgroundColor(tcell.ColorSkyblue)
+	uiFinderList.SetChangedFunc(func(index int, _, _ string, _ rune) { previewFinderItem(index) })
+	uiFinderList.SetSelectedFunc(func(index int, _, _ string, _ rune) { openFinderItem(index, false) })
+
+	uiFinderPreview = tview.NewTextView().SetDynamicColors(true).SetWrap(true)
+	uiFinderPreview.SetBorder(true).SetTitle(" Preview ")
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(uiFinderInput, 1, 0, true).
+		AddItem(tview.NewFlex().
+			AddItem(uiFinderList, 0, 3, false).
+			AddItem(uiFinderPreview, 0, 2, false), 0, 1, false)
+	layout.SetBorder(true).SetTitle(" Find Item ")
+
+	uiPages.AddPage("finder", newResponsiveModal(layout, 60, 16, 140, 40, 0.8, 0.75), true, false)
+}
+
+// showFinder opens the fuzzy finder over every entry, most recently used
+// first.
+func showFinder() {
+	entries, err := uiStore.ListEntrySummaries()
+	if err != nil {
+		return
+	}
+	folders, _ := uiStore.ListFolders()
+	paths := make(map[int64]string, len(folders))
+	for _, f := range folders {
+		paths[f.ID] = f.Path
+	}
+
+	uiFinderItems = make([]finderItem, len(entries))
+	for i, e := range entries {
+		uiFinderItems[i] = newFinderItem(e, paths[e.FolderID])
+	}
+	uiFinderInput.SetText("")
+	filterFinder("")
+	uiPages.SwitchToPage("finder")
+	uiApp.SetFocus(uiFinderInput)
+}
+
+func newFinderItem(e store.EntrySummary, folder string) finderItem {
+	it := finderItem{EntrySummary: e, Name: e.Title}
+	if folder != "" {
+		it.Name = folder + "/" + e.Title
+		it.TitleAt = len([]rune(folder)) + 1
+	}
+	return it
+}
+
+func dismissFinder() {
+	uiFinderItems, uiFinderShown = nil, nil
+	uiPages.SwitchToPage("main")
+	uiApp.SetFocus(uiTreeView)
+}
+
+func filterFinder(query string) {
+	uiFinderShown = rankFinder(uiFinderItems, query, time.Now())
+	uiFinderList.Clear()
+	for _, it := range uiFinderShown {
+		uiFinderList.AddItem(finderLabel(it), "", 0, nil)
+	}
+	uiFinderList.SetCurrentItem(0)
+	previewFinderItem(0)
+}
+
+func moveFinderCursor(delta int) {
+	if n := uiFinderList.GetItemCount(); n > 0 {
+		uiFinderList.SetCurrentItem(min(max(uiFinderList.GetCurrentItem()+delta, 0), n-1))
+	}
+}
+
+// rankFinder keeps the items matching every word of query and orders them
+// by score. A word matches the folder path and title, the username or the
+// URL; a hit in the path and title counts double. Recently used entries
+// get a bonus, and ties keep the order of items, most recently used first.
+func rankFinder(items []finderItem, query string, now time.Time) []finderItem {
+	words := strings.Fields(query)
+	var out []finderItem
+	for _, it := range items {
+		it.Score, it.Highlight = 0, nil
+		matched := true
+		for _, w := range words {
+			score, pos, ok := fuzzyMatch(w, it.Name)
+			score *= 2
+			for _, field := range []string{it.Username, it.URL} {
+				if s, _, fieldOK := fuzzyMatch(w, field); fieldOK && (!ok || s > score) {
+					score, pos, ok = s, nil, true
+				}
+			}
+			if !ok {
+				matched = false
+				break
+			}
+			it.Score += score
+			it.Highlight = append(it.Highlight, pos...)
+		}
+		if !matched {
+			continue
+		}
+		if len(words) > 0 {
+			it.Score += recencyBonus(it.LastUsed, now)
+		}
+		out = append(out, it)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	return out
+}
+
+// recencyBonus is what having been used lastUsed adds to a match's score.
+func recencyBonus(lastUsed, now time.Time) int {
+	if lastUsed.IsZero() {
+		return 0
+	}
+	switch age := now.Sub(lastUsed); {
+	case age < time.Hour:
+		return 12
+	case age < 24*time.Hour:
+		return 8
+	case age < 7*24*time.Hour:
+		return 4
+	case age < 30*24*time.Hour:
+		return 2
+	}
+	return 1
+}
+
+// finderLabel renders an item for the list: the folder path dimmed, the
+// matched letters highlighted and the username after the title.
+func finderLabel(it finderItem) string {
+	hl := make(map[int]bool, len(it.Highlight))
+	for _, i := range it.Highlight {
+		hl[i] = true
+	}
+	style := func(i int) string {
+		switch {
+		case hl[i]:
+			return "[yellow::b]"
+		case i < it.TitleAt:
+			return "[gray]"
+		}
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(entryTypeIcon(it.EntryType) + " ")
+	r := []rune(it.Name)
+	for i := 0; i < len(r); {
+		s := style(i)
+		end := i + 1
+		for end < len(r) && style(end) == s {
+			end++
+		}
+		if s == "" {
+			b.WriteString(tview.Escape(string(r[i:end])))
+		} else {
+			b.WriteString(s + tview.Escape(string(r[i:end])) + "[-::-]")
+		}
+		i = end
+	}
+	if it.Username != "" {
+		b.WriteString("  [gray]" + tview.Escape(it.Username) + "[-]")
+	}
+	return b.String()
+}
+
+func previewFinderItem(index int) {
+	uiFinderPreview.Clear()
+	if index < 0 || index >= len(uiFinderShown) {
+		return
+	}
+	it := uiFinderShown[index]
+	ent, err := uiStore.LoadEntry(it.ID)
+	if err != nil {
+		return
+	}
+	uiFinderPreview.SetText(finderPreview(it, ent))
+	uiFinderPreview.ScrollToBeginning()
+}
+
+// finderPreview describes ent without revealing any secret.
+func finderPreview(it finderItem, ent *Entry) string {
+	var b strings.Builder
+	row := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "[yellow]%s[-] %s\n", label, tview.Escape(value))
+		}
+	}
+	fmt.Fprintf(&b, "[::b]%s %s[::-]\n", entryTypeIcon(ent.Type), tview.Escape(ent.Title))
+	if it.TitleAt > 0 {
+		row("Folder:", string([]rune(it.Name)[:it.TitleAt-1]))
+	}
+	if len(ent.Tags) > 0 {
+		b.WriteString(tagChips(ent.Tags) + "\n")
+	}
+	b.WriteString("\n")
+
+	row("Username:", ent.Username)
+	if ent.Password != "" {
+		row("Password:", "••••••••")
+	}
+	if ent.TotpSecret != "" {
+		row("TOTP:", "configured")
+	}
+	for i, u := range ent.URIs {
+		label := "URL:"
+		if i > 0 {
+			label = "    "
+		}
+		row(label, u.URI)
+	}
+	if n := len([]rune(ent.CardNumber)); n > 4 {
+		row("Card:", "•••• "+string([]rune(ent.CardNumber)[n-4:]))
+	}
+	row("Expiry:", ent.Expiry)
+	row("File:", ent.FileName)
+	for _, f := range ent.Fields {
+		value := f.Value
+		if f.Type == store.FieldHidden || f.Type == store.FieldTOTP {
+			value = "••••••••"
+		}
+		row(f.Name+":", value)
+	}
+	if n := len(ent.Attachments); n > 0 {
+		row("Attached:", fmt.Sprintf("%d file(s)", n))
+	}
+	if notes := strings.TrimSpace(ent.CustomText); notes != "" {
+		fmt.Fprintf(&b, "\n[yellow]Notes:[-]\n%s\n", tview.Escape(notes))
+	}
+	if !it.LastUsed.IsZero() {
+		fmt.Fprintf(&b, "\n[gray]Last used %s[-]", it.LastUsed.Format("2006-01-02 15:04"))
+	}
+	return b.String()
+}
+
+// openFinderItem closes the finder and shows the entry at index in the
+// tree and the view pane, then opens quick copy when quickCopy is set.
+func openFinderItem(index int, quickCopy bool) {
+	if index < 0 || index >= len(uiFinderShown) {
+		return
+	}
+	id := uiFinderShown[index].ID
+	dismissFinder()
+
+	// The entry may be filtered out of the tree by a search.
+	ref := nodeRef{ID: id}
+	if !selectTreeNode(ref) && uiSearchField.GetText() != "" {
+		uiSearchField.SetText("")
+		selectTreeNode(ref)
+	}
+	uiCurrentFolderID = 0
+	uiCurrentSearchID = 0
+	loadEntry(id)
+	_ = uiStore.MarkEntryUsed(id)
+	if quickCopy {
+		showQuickCopy()
+	}
+}
@@ -0,0 +1,76 @@
+package ui
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"passbook/internal/store"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	if _, _, ok := fuzzyMatch("ghb", "GitHub"); !ok {
+		t.Fatalf("expected letters in order to match")
+	}
+	if _, _, ok := fuzzyMatch("hbg", "GitHub"); ok {
+		t.Fatalf("expected letters out of order not to match")
+	}
+	// The best alignment is kept, not the first one found.
+	if _, pos, _ := fuzzyMatch("hub", "shub GitHub"); !reflect.DeepEqual(pos, []int{8, 9, 10}) {
+		t.Fatalf("expected the match at the word start, got %v", pos)
+	}
+	start, _, _ := fuzzyMatch("git", "GitHub")
+	middle, _, _ := fuzzyMatch("git", "Digital")
+	spread, _, _ := fuzzyMatch("git", "Gadget list")
+	if start <= middle || start <= spread {
+		t.Fatalf("unexpected scores: start %d, middle %d, spread %d", start, middle, spread)
+	}
+}
+
+func finderItems() []finderItem {
+	now := time.Now()
+	return []finderItem{
+		newFinderItem(store.EntrySummary{EntryMeta: store.EntryMeta{ID: 1, Title: "Grafana"}, LastUsed: now}, "Work"),
+		newFinderItem(store.EntrySummary{EntryMeta: store.EntryMeta{ID: 2, Title: "GitHub", EntryType: "Login"}, Username: "octocat"}, "Work/Dev"),
+		newFinderItem(store.EntrySummary{EntryMeta: store.EntryMeta{ID: 3, Title: "Bank"}, URL: "https://example.org"}, ""),
+	}
+}
+
+func finderIDs(items []finderItem) []int64 {
+	var ids []int64
+	for _, it := range items {
+		ids = append(ids, it.ID)
+	}
+	return ids
+}
+
+func TestRankFinder(t *testing.T) {
+	now := time.Now()
+	items := finderItems()
+
+	if got := finderIDs(rankFinder(items, "", now)); !reflect.DeepEqual(got, []int64{1, 2, 3}) {
+		t.Fatalf("expected every item in recent order, got %v", got)
+	}
+	if got := finderIDs(rankFinder(items, "gh", now)); !reflect.DeepEqual(got, []int64{2}) {
+		t.Fatalf("expected only GitHub for gh, got %v", got)
+	}
+	// Words may match different fields and must all match.
+	if got := finderIDs(rankFinder(items, "dev octo", now)); !reflect.DeepEqual(got, []int64{2}) {
+		t.Fatalf("expected GitHub for dev octo, got %v", got)
+	}
+	if got := finderIDs(rankFinder(items, "example", now)); !reflect.DeepEqual(got, []int64{3}) {
+		t.Fatalf("expected the URL to be matched, got %v", got)
+	}
+	// Both match "g" at a word start; the recent one wins.
+	if got := finderIDs(rankFinder(items, "g", now)); got[0] != 1 {
+		t.Fatalf("expected the recently used item first, got %v", got)
+	}
+}
+
+func TestFinderLabel(t *testing.T) {
+	it := rankFinder(finderItems(), "gh", time.Now())[0]
+	want := "🔐 [gray]Work/Dev/[-::-][yellow::b]G[-::-]it[yellow::b]H[-::-]ub  [gray]octocat[-]"
+	if got := finderLabel(it); got != want {
+		t.Fatalf("finderLabel = %q, want %q", got, want)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"strings"
 
+	"passbook/internal/store"
+
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 )
@@ -13,8 +15,14 @@ var (
 	uiFolderForm        *tview.Form
 	uiFolderRenameForm  *tview.Form
 	uiFolderDeleteModal *tview.Modal
+
+	// uiFolderParentIDs holds the folder ID of each option in the open
+	// form's Parent drop-down.
+	uiFolderParentIDs []int64
 )
 
+const rootFolderOption = "— (root)"
+
 func isValidFolderName(name string) bool {
 	return name != "" &&
 		!strings.ContainsAny(name, `<>:"/\|?*`) &&
@@ -30,13 +38,14 @@ func folderNameAcceptFunc(text string, ch rune) bool {
 func setupFolderCreate() {
 	uiFolderForm = tview.NewForm()
 	uiFolderForm.AddInputField("Folder Name", "", 0, folderNameAcceptFunc, nil)
+	uiFolderForm.AddDropDown("Parent", nil, 0, nil)
 	uiFolderForm.AddButton("Create", func() {
 		nameField := uiFolderForm.GetFormItemByLabel("Folder Name").(*tview.InputField)
 		name := strings.TrimSpace(nameField.GetText())
 		if !isValidFolderName(name) {
 			return
 		}
-		if _, err := uiStore.CreateFolder(name); err != nil {
+		if _, err := uiStore.CreateFolder(selectedParent(uiFolderForm), name); err != nil {
 			return
 		}
 		refreshTree(uiSearchField.GetText())
@@ -56,8 +65,9 @@ func setupFolderCreate() {
 			uiApp.SetFocus(uiTreeView)
 			return nil
 		case tcell.KeyEnter:
-			if uiApp.GetFocus() != uiFolderForm.GetButton(0) &&
-				uiApp.GetFocus() != uiFolderForm.GetButton(1) {
+			// Enter in the name field creates the folder; elsewhere it
+			// presses a button or opens the Parent drop-down.
+			if uiApp.GetFocus() == uiFolderForm.GetFormItemByLabel("Folder Name") {
 				uiFolderForm.GetButton(0).InputHandler()(tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone), nil)
 				return nil
 			}
@@ -65,26 +75,70 @@ func setupFolderCreate() {
 		return event
 	})
 	enableButtonNav(uiFolderForm)
-	uiPages.AddPage("folder_create", newResponsiveModal(uiFolderForm, 45, 9, 65, 13, 0.45, 0.3), true, false)
+	uiPages.AddPage("folder_create", newResponsiveModal(uiFolderForm, 45, 11, 65, 15, 0.45, 0.35), true, false)
 }
 
+// showFolderCreate opens the new folder form. The new folder goes below
+// the selected folder unless another parent is picked.
 func showFolderCreate() {
 	if uiFolderForm != nil {
 		nameField := uiFolderForm.GetFormItemByLabel("Folder Name").(*tview.InputField)
 		nameField.SetText("")
+		setParentOptions(uiFolderForm, 0, uiCurrentFolderID)
+		uiFolderForm.SetFocus(0)
 	}
 	uiPages.SwitchToPage("folder_create")
 }
 
+// setParentOptions fills form's Parent drop-down with the root and every
+// folder path, leaving out exclude and its subfolders, and selects the
+// option for folder selected.
+func setParentOptions(form *tview.Form, exclude, selected int64) {
+	var excludePath string
+	if exclude != 0 {
+		if f, _ := uiStore.GetFolder(exclude); f != nil {
+			excludePath = f.Path
+		}
+	}
+
+	labels := []string{rootFolderOption}
+	uiFolderParentIDs = []int64{0}
+	current := 0
+	for _, f := range listFolderInfos() {
+		if excludePath != "" && (f.Path == excludePath || strings.HasPrefix(f.Path, excludePath+"/")) {
+			continue
+		}
+		if f.ID == selected {
+			current = len(labels)
+		}
+		labels = append(labels, f.Path)
+		uiFolderParentIDs = append(uiFolderParentIDs, f.ID)
+	}
+
+	parent := form.GetFormItemByLabel("Parent").(*tview.DropDown)
+	parent.SetOptions(labels, nil)
+	parent.SetCurrentOption(current)
+}
+
+// selectedParent returns the folder ID picked in form's Parent drop-down.
+func selectedParent(form *tview.Form) int64 {
+	i, _ := form.GetFormItemByLabel("Parent").(*tview.DropDown).GetCurrentOption()
+	if i < 0 || i >= len(uiFolderParentIDs) {
+		return 0
+	}
+	return uiFolderParentIDs[i]
+}
+
 func setupFolderRename() {
 	uiFolderRenameForm = tview.NewForm()
 	uiFolderRenameForm.AddInputField("Folder Name", "", 0, folderNameAcceptFunc, nil)
-	uiFolderRenameForm.AddButton("Rename", doFolderRename)
+	uiFolderRenameForm.AddDropDown("Parent", nil, 0, nil)
+	uiFolderRenameForm.AddButton("Save", doFolderRename)
 	uiFolderRenameForm.AddButton("Cancel", func() {
 		uiPages.SwitchToPage("main")
 		uiApp.SetFocus(uiTreeView)
 	})
-	uiFolderRenameForm.SetBorder(true).SetTitle(" Rename Folder ")
+	uiFolderRenameForm.SetBorder(true).SetTitle(" Rename / Move Folder ")
 	styleForm(uiFolderRenameForm)
 	uiFolderRenameForm.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		if event.Key() == tcell.KeyEsc {
@@ -95,7 +149,7 @@ func setupFolderRename() {
 		return event
 	})
 	enableButtonNav(uiFolderRenameForm)
-	uiPages.AddPage("folder_rename", newResponsiveModal(uiFolderRenameForm, 45, 9, 65, 13, 0.45, 0.3), true, false)
+	uiPages.AddPage("folder_rename", newResponsiveModal(uiFolderRenameForm, 45, 11, 65, 15, 0.45, 0.35), true, false)
 }
 
 func showFolderRename() {
@@ -108,6 +162,8 @@ func showFolderRename() {
 	}
 	nameField := uiFolderRenameForm.GetFormItemByLabel("Folder Name").(*tview.InputField)
 	nameField.SetText(folder.Name)
+	setParentOptions(uiFolderRenameForm, folder.ID, folder.ParentID)
+	uiFolderRenameForm.SetFocus(0)
 	uiPages.SwitchToPage("folder_rename")
 }
 
@@ -117,10 +173,18 @@ func doFolderRename() {
 	if !isValidFolderName(name) {
 		return
 	}
-	if err := uiStore.RenameFolder(uiCurrentFolderID, name); err != nil {
+	parentID := selectedParent(uiFolderRenameForm)
+	err := uiStore.WithTx(func(tx *store.Store) error {
+		if err := tx.RenameFolder(uiCurrentFolderID, name); err != nil {
+			return err
+		}
+		return tx.MoveFolder(uiCurrentFolderID, parentID)
+	})
+	if err != nil {
 		return
 	}
 	refreshTree(uiSearchField.GetText())
+	selectTreeNode(nodeRef{IsFolder: true, ID: uiCurrentFolderID})
 	uiPages.SwitchToPage("main")
 	uiApp.SetFocus(uiTreeView)
 }
@@ -147,14 +211,22 @@ func showFolderDeleteModal() {
 	if folder == nil {
 		return
 	}
-	count := uiStore.CountEntriesInFolder(uiCurrentFolderID)
+	subfolders, count, err := uiStore.CountFolderContents(uiCurrentFolderID)
+	if err != nil {
+		return
+	}
 
-	if count > 0 {
+	switch {
+	case subfolders > 0:
+		uiFolderDeleteModal.SetText(fmt.Sprintf(
+			"Folder \"%s\" contains %d subfolder(s) and %d item(s) in total.\nAll subfolders and items inside will be permanently deleted.\n\nAre you sure?",
+			folder.Path, subfolders, count))
+	case count > 0:
 		uiFolderDeleteModal.SetText(fmt.Sprintf(
 			"Folder \"%s\" contains %d item(s).\nAll items inside will be permanently deleted.\n\nAre you sure?",
-			folder.Name, count))
-	} else {
-		uiFolderDeleteModal.SetText(fmt.Sprintf("Delete empty folder \"%s\"?", folder.Name))
+			folder.Path, count))
+	default:
+		uiFolderDeleteModal.SetText(fmt.Sprintf("Delete empty folder \"%s\"?", folder.Path))
 	}
 	uiPages.SwitchToPage("folder_delete")
 }
@@ -166,6 +238,6 @@ func doFolderDelete() {
 	_ = uiStore.DeleteFolder(uiCurrentFolderID)
 	uiCurrentFolderID = 0
 	uiCurrentEntryID = 0
-	uiCurrentEnt = nil
+	dropCurrentEntry()
 	refreshTree(uiSearchField.GetText())
 }
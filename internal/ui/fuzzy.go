@@ -0,0 +1,68 @@
+package ui
+
+import "unicode"
+
+// fuzzyMatch reports whether the letters of pattern appear in text in
+// order, ignoring case, fzf style. The score favours letters that follow
+// each other and letters that start a word, and penalises gaps. It also
+// returns the rune positions in text that matched.
+func fuzzyMatch(pattern, text string) (int, []int, bool) {
+	p := []rune(pattern)
+	for i, r := range p {
+		p[i] = unicode.ToLower(r)
+	}
+	if len(p) == 0 {
+		return 0, nil, true
+	}
+	t := []rune(text)
+
+	var best int
+	var bestPos []int
+	for start := range t {
+		if unicode.ToLower(t[start]) != p[0] {
+			continue
+		}
+		pos := []int{start}
+		for j := start + 1; j < len(t) && len(pos) < len(p); j++ {
+			if unicode.ToLower(t[j]) == p[len(pos)] {
+				pos = append(pos, j)
+			}
+		}
+		if len(pos) < len(p) {
+			// A later start cannot match more of the pattern.
+			break
+		}
+		if s := fuzzyScore(t, pos); bestPos == nil || s > best {
+			best, bestPos = s, pos
+		}
+	}
+	return best, bestPos, bestPos != nil
+}
+
+func fuzzyScore(t []rune, pos []int) int {
+	score := 0
+	for k, i := range pos {
+		score++
+		if isWordStart(t, i) {
+			score += 6
+		}
+		if k == 0 {
+			continue
+		}
+		if gap := i - pos[k-1] - 1; gap == 0 {
+			score += 4
+		} else {
+			score -= min(gap, 3)
+		}
+	}
+	return score
+}
+
+// isWordStart reports whether t[i] begins a word: it follows a separator
+// or is an upper-case letter after a lower-case one, as in "GitHub".
+func isWordStart(t []rune, i int) bool {
+	if i == 0 || !isWordRune(t[i-1]) {
+		return true
+	}
+	return unicode.IsLower(t[i-1]) && unicode.IsUpper(t[i])
+}
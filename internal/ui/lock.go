@@ -0,0 +1,154 @@
+package ui
+
+import (
+	"time"
+
+	"passbook/internal/crypto"
+	"passbook/internal/store"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+var (
+	// uiUnlocked is set once the PIN or code has been verified and cleared
+	// again by lockVault.
+	uiUnlocked     bool
+	uiLastActivity time.Time
+
+	// uiUnlockKey is the master password, in locked memory. It is kept
+	// until the PIN or code has been entered, as the PIN may be part of
+	// the key, and after that only while PinUnlock is configured so that
+	// a locked vault can be reopened with the PIN.
+	uiUnlockKey *crypto.Secret
+)
+
+// setupLock watches for input to reset the idle timer and binds Ctrl+L to
+// lock the vault from any screen.
+func setupLock() {
+	uiApp.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		uiLastActivity = time.Now()
+		if event.Key() == tcell.KeyCtrlL && uiUnlocked {
+			lockVault()
+			return nil
+		}
+		return event
+	})
+	uiApp.SetMouseCapture(func(event *tcell.EventMouse, action tview.MouseAction) (*tcell.EventMouse, tview.MouseAction) {
+		if action != tview.MouseMove {
+			uiLastActivity = time.Now()
+		}
+		return event, action
+	})
+}
+
+// checkIdleLock locks the vault once it has been idle for the configured
+// number of minutes.
+func checkIdleLock() {
+	if !uiUnlocked || uiCfg.LockAfterMinutes <= 0 {
+		return
+	}
+	if time.Since(uiLastActivity) >= time.Duration(uiCfg.LockAfterMinutes)*time.Minute {
+		lockVault()
+	}
+}
+
+// lockedTitle goes in front of the verify page's title for a locked vault.
+const lockedTitle = " Vault Locked —"
+
+// lockVault closes the store, drops every decrypted value the UI holds and
+// asks for the PIN or code again when PinUnlock is configured, or for the
+// master password otherwise. Unsaved edits are discarded.
+func lockVault() {
+	if !uiUnlocked {
+		return
+	}
+	pinCfg, _ := uiStore.ReadPinConfig()
+
+	uiUnlocked = false
+	clearVaultState()
+	closeAndCleanupStore(false)
+
+	if uiCfg.PinUnlock && uiUnlockKey != nil && pinCfg != nil && pinCfg.Mode != "" && pinCfg.Mode != "off" {
+		showPinVerify(pinCfg)
+		uiPinVerifyForm.SetTitle(lockedTitle + uiPinVerifyForm.GetTitle())
+		return
+	}
+	replaceSecret(&uiUnlockKey, "")
+	showLogin()
+}
+
+// clearVaultState empties every view and form that may show vault data.
+func clearVaultState() {
+	uiCurrentEntryID, uiCurrentFolderID, uiCurrentSearchID = 0, 0, 0
+	uiEditingEnt = nil
+	dropCurrentEntry()
+	uiShowSensitive, uiShownFields = false, nil
+	uiPendingAttachments, uiPendingFilePaths = nil, nil
+	uiPendingFields, uiPendingTags = nil, nil
+	uiLastGeneratedPass, uiPendingTotp, uiRecoveryKey = "", "", ""
+	uiFinderItems, uiFinderShown = nil, nil
+
+	uiSearchField.SetText("")
+	uiTreeView.GetRoot().ClearChildren()
+	uiViewFlex.Clear()
+	uiRightPages.SetTitle(" Keybindings ")
+	uiRightPages.SwitchToPage("empty")
+	for _, tv := range []*tview.TextView{uiViewTitle, uiViewSubtitle, uiViewPassword, uiViewDetails,
+		uiViewTOTP, uiViewTOTPBar, uiViewCustom, uiViewStatus, uiFinderPreview, uiPassGenPreview, uiBackupCodesView} {
+		tv.Clear()
+	}
+	for _, l := range []*tview.List{uiAttachmentList, uiQuickCopyList, uiHistoryList, uiURLPickerList,
+		uiFinderList, uiAttachList, uiFieldsList} {
+		l.Clear()
+	}
+	uiEditorForm.Clear(true)
+	uiRecoveryKitFlex.Clear()
+	uiTotpSetupFlex.Clear()
+	uiEditorTitleField, uiEditorPasswordField, uiEditorSaveButton = nil, nil, nil
+	uiEditorCardNumber, uiEditorExpiry, uiEditorCVV = nil, nil, nil
+	uiEditorFolderField = nil
+	for i := 0; i < uiFieldForm.GetFormItemCount(); i++ {
+		if input, ok := uiFieldForm.GetFormItem(i).(*tview.InputField); ok {
+			input.SetText("")
+		}
+	}
+	clearChangePwdForm()
+	forgetSecurityCredentials()
+}
+
+// showLogin asks for the master password with an empty form.
+func showLogin() {
+	uiLoginForm.GetFormItem(0).(*tview.InputField).SetText("")
+	uiPages.SwitchToPage("login")
+	uiApp.SetFocus(uiLoginForm)
+}
+
+// replaceSecret wipes the secret kept in *dst and keeps v instead, or
+// nothing if v is empty.
+func replaceSecret(dst **crypto.Secret, v string) {
+	(*dst).Release()
+	*dst = crypto.SecretString(v)
+}
+
+// keepUnlockKey keeps a copy of pwd as the master password, wiping the
+// one kept before.
+func keepUnlockKey(pwd *crypto.Secret) {
+	uiUnlockKey.Release()
+	uiUnlockKey = crypto.NewSecret(pwd.Bytes())
+}
+
+// reopenStore opens the vault with the kept master password and pin,
+// which only counts for a vault whose key includes the PIN.
+func reopenStore(pin string) error {
+	creds, err := uiCfg.Credentials(uiUnlockKey.String(), pin)
+	if err != nil {
+		return err
+	}
+	s, err := store.OpenWith(uiDBPath, creds)
+	if err != nil {
+		return err
+	}
+	uiStore = s
+	return nil
+}
@@ -0,0 +1,198 @@
+package ui
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"passbook/internal/clipboard"
+	"passbook/internal/config"
+	"passbook/internal/crypto"
+	"passbook/internal/store"
+
+	"github.com/rivo/tview"
+)
+
+// unlockTestVault sets up the UI over a new vault holding one login with
+// PIN 123456, and enters the main screen as after a successful login.
+func unlockTestVault(t *testing.T, cfg config.AppConfig) int64 {
+	t.Helper()
+	if uiLoginForm == nil {
+		setupUI()
+		uiApp.SetRoot(uiPages, true)
+	}
+	if uiClipboard == nil {
+		uiClipboard = clipboard.New(clipboard.OSC52(io.Discard), 0)
+	}
+	uiCfg = cfg
+	uiDBPath = filepath.Join(t.TempDir(), "passbook.db")
+	s, err := store.Open(uiDBPath, "testpass")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	uiStore = s
+	t.Cleanup(func() { closeAndCleanupStore(false) })
+
+	pinKey, err := crypto.GeneratePinKey()
+	if err != nil {
+		t.Fatalf("GeneratePinKey: %v", err)
+	}
+	pin := store.PinConfig{Mode: "pin", PinKey: pinKey, PinTag: crypto.ComputePinTag(pinKey, "123456")}
+	if err := s.WritePinConfig(&pin); err != nil {
+		t.Fatalf("WritePinConfig: %v", err)
+	}
+	id, err := s.SaveEntry(0, &store.EntryFull{Type: "Login", Title: "GitHub", Password: "s3cret"})
+	if err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	if cfg.PinUnlock {
+		replaceSecret(&uiUnlockKey, "testpass")
+	}
+	enterMain()
+	loadEntry(id)
+	return id
+}
+
+func TestIdleLockClearsVault(t *testing.T) {
+	unlockTestVault(t, config.AppConfig{LockAfterMinutes: 5})
+
+	checkIdleLock()
+	if uiStore == nil || uiCurrentEnt == nil {
+		t.Fatalf("expected the vault to stay unlocked before the timeout")
+	}
+
+	uiLastActivity = time.Now().Add(-6 * time.Minute)
+	checkIdleLock()
+	if uiStore != nil || uiUnlocked || uiCurrentEnt != nil || uiCurrentEntryID != 0 {
+		t.Fatalf("expected the store closed and the entry dropped")
+	}
+	if uiViewPassword.GetText(false) != "" || len(uiTreeView.GetRoot().GetChildren()) != 0 {
+		t.Fatalf("expected the views to be cleared")
+	}
+	if name, _ := uiPages.GetFrontPage(); name != "login" {
+		t.Fatalf("expected the login page without PIN unlock, got %q", name)
+	}
+}
+
+func TestLockWithPinUnlock(t *testing.T) {
+	id := unlockTestVault(t, config.AppConfig{PinUnlock: true})
+
+	lockVault()
+	if name, _ := uiPages.GetFrontPage(); name != "pin_verify" || uiStore != nil {
+		t.Fatalf("expected a closed store and the PIN page, got %q", name)
+	}
+
+	uiPinVerifyForm.GetFormItem(0).(*tview.InputField).SetText("123456")
+	doVerifyPin()
+	if uiStore == nil || !uiUnlocked {
+		t.Fatalf("expected the PIN to reopen the vault")
+	}
+	if ent, err := uiStore.LoadEntry(id); err != nil || ent.Password != "s3cret" {
+		t.Fatalf("expected the vault to be readable again: %+v %v", ent, err)
+	}
+}
+
+func TestWrongPinCountsAttempts(t *testing.T) {
+	unlockTestVault(t, config.AppConfig{PinUnlock: true, MaxFailedUnlocks: 2, WipeAfterMaxFailures: true})
+	lockVault()
+
+	pinField := uiPinVerifyForm.GetFormItem(0).(*tview.InputField)
+	pinField.SetText("000000")
+	doVerifyPin()
+	if got := uiPinVerifyStatus.GetText(true); got != "Wrong PIN. Attempts left: 1." {
+		t.Fatalf("unexpected status %q", got)
+	}
+
+	pinField.SetText("000000")
+	doVerifyPin()
+	if name, _ := uiPages.GetFrontPage(); name != "login" || store.DBExists(uiDBPath) {
+		t.Fatalf("expected the vault erased and the login page, got %q", name)
+	}
+}
+
+func TestLockWithPINInKey(t *testing.T) {
+	id := unlockTestVault(t, config.AppConfig{PinUnlock: true})
+	uiStore.Close()
+	uiDBPath = filepath.Join(t.TempDir(), "passbook.db")
+	s, err := store.Create(uiDBPath, "testpass", store.CipherParams{
+		KDF: crypto.KDFParams{Memory: 64, Time: 1, Threads: 1}, PageSize: 4096, KDFIter: 1000,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	uiStore = s
+	if err := s.KeyWithPIN("testpass", "123456"); err != nil {
+		t.Fatalf("KeyWithPIN: %v", err)
+	}
+	if id, err = s.SaveEntry(0, &store.EntryFull{Type: "Login", Title: "GitHub", Password: "s3cret"}); err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	lockVault()
+
+	pinField := uiPinVerifyForm.GetFormItem(0).(*tview.InputField)
+	pinField.SetText("000000")
+	doVerifyPin()
+	if uiStore != nil || uiPinVerifyStatus.GetText(true) == "" {
+		t.Fatalf("expected a wrong PIN not to open the vault")
+	}
+
+	pinField.SetText("123456")
+	doVerifyPin()
+	if uiStore == nil || !uiUnlocked {
+		t.Fatalf("expected the PIN to reopen the vault")
+	}
+	if ent, err := uiStore.LoadEntry(id); err != nil || ent.Password != "s3cret" {
+		t.Fatalf("expected the vault to be readable again: %+v %v", ent, err)
+	}
+}
+
+func TestExitWipesSecrets(t *testing.T) {
+	unlockTestVault(t, config.AppConfig{PinUnlock: true})
+	uiLastGeneratedPass = "generated"
+	if !uiUnlockKey.Equal([]byte("testpass")) {
+		t.Fatalf("expected the master password to be kept for PIN unlock")
+	}
+
+	(&AppHandle{}).cleanup()
+	if uiUnlockKey != nil || uiStore != nil || uiCurrentEnt != nil || uiLastGeneratedPass != "" {
+		t.Fatalf("expected the vault state and kept password to be dropped")
+	}
+	if uiViewPassword.GetText(false) != "" {
+		t.Fatalf("expected the views to be cleared")
+	}
+}
+
+func TestNewVaultNeedsConfiguredKeyfile(t *testing.T) {
+	unlockTestVault(t, config.AppConfig{})
+	closeAndCleanupStore(false)
+
+	keyfile := filepath.Join(t.TempDir(), "passbook.key")
+	if err := crypto.GenerateKeyfile(keyfile); err != nil {
+		t.Fatalf("GenerateKeyfile: %v", err)
+	}
+	uiCfg = config.AppConfig{Keyfile: keyfile}
+	uiDBPath = filepath.Join(t.TempDir(), "passbook.db")
+	const pwd = "Tr0ub4dor&3-horse-staple"
+
+	showLogin()
+	goToMain(crypto.SecretString(pwd))
+	if uiStore == nil {
+		t.Fatalf("expected a new vault to be created")
+	}
+	closeAndCleanupStore(false)
+
+	if _, err := store.Open(uiDBPath, pwd); !errors.Is(err, store.ErrKeyfileRequired) {
+		t.Fatalf("expected the new vault to need the keyfile, got %v", err)
+	}
+	creds, err := uiCfg.Credentials(pwd, "")
+	if err != nil {
+		t.Fatalf("Credentials: %v", err)
+	}
+	s, err := store.OpenWith(uiDBPath, creds)
+	if err != nil {
+		t.Fatalf("expected the keyfile to open the vault: %v", err)
+	}
+	s.Close()
+}
@@ -1,8 +1,11 @@
 package ui
 
 import (
-	"os"
+	"errors"
+	"fmt"
+	"time"
 
+	"passbook/internal/crypto"
 	"passbook/internal/store"
 	"passbook/internal/utils"
 
@@ -16,35 +19,83 @@ var (
 	uiLoginStrength *strengthMeter
 )
 
-func goToMain(pwd string) {
-	if pwd == "" {
+// goToMain opens the vault with the master password pwd, or creates it,
+// and goes on to the second factor. pwd stays the caller's to release.
+func goToMain(pwd *crypto.Secret) {
+	if pwd == nil {
 		return
 	}
 
-	dbExisted := store.DBExists(uiDBPath)
+	attempts := store.LoadAttempts(uiDBPath, store.AttemptPassword)
+	if wait := attempts.Wait(uiCfg.MaxFailedUnlocks, time.Now()); wait > 0 {
+		showLoginError("Too many failed attempts. Try again in " + formatWait(wait) + ".")
+		return
+	}
 
-	s, err := store.Open(uiDBPath, pwd)
+	creds, err := uiCfg.Credentials(pwd.String(), "")
 	if err != nil {
-		showLoginError("Wrong password.")
+		showLoginError("Cannot read the keyfile.")
+		return
+	}
+
+	dbExisted := store.DBExists(uiDBPath)
+
+	var s *store.Store
+	if dbExisted {
+		s, err = store.OpenWith(uiDBPath, creds)
+	} else if s, err = store.CreateWith(uiDBPath, creds, uiCfg.CipherParams()); err != nil {
+		showLoginError("Creating vault: " + err.Error())
+		return
+	}
+	switch {
+	case errors.Is(err, store.ErrKeyfileRequired):
+		showLoginError("This vault needs its keyfile; set keyfile in the config or pass --keyfile.")
+		return
+	case errors.Is(err, store.ErrPINRequired):
+		// The PIN is part of the key: whether the password was right shows
+		// once the vault opens with both.
+		keepUnlockKey(pwd)
+		showPinVerify(&store.PinConfig{Mode: "pin"})
+		return
+	case errors.Is(err, store.ErrSchemaTooNew):
+		showLoginError("This vault needs a newer PassBook.")
+		return
+	case errors.Is(err, store.ErrWrongKey):
+		msg := "Wrong password."
+		if creds.Keyfile != nil {
+			msg = "Wrong password or keyfile."
+		}
+		msg, _ = recordUnlockFailure(attempts, msg)
+		showLoginError(msg)
+		return
+	case err != nil:
+		// Not a wrong password, so it does not count as a failed attempt.
+		showLoginError("Cannot open the vault: " + err.Error())
 		return
 	}
+	_ = attempts.Reset()
 	uiStore = s
+	keepUnlockKey(pwd)
 
 	isNewVault := !uiStore.HasEntries() && !uiStore.PinConfigExists()
 
 	if isNewVault {
-		_, level, _ := utils.PasswordStrength(pwd)
+		_, level, _ := utils.PasswordStrength(pwd.String())
 		if level < utils.StrengthGood {
 			closeAndCleanupStore(!dbExisted)
 			showLoginError("Password is too weak.")
 			return
 		}
+		_, upgraded := uiStore.CipherParams()
+		uiOfferRecovery = upgraded && !uiStore.HasRecoveryKey()
 		showPinSetup()
 		return
 	}
 
 	pinCfg, _ := uiStore.ReadPinConfig()
-	if pinCfg != nil && pinCfg.Mode != "" {
+	if pinCfg != nil && pinCfg.Mode == "off" {
+		enterMain()
+	} else if pinCfg != nil && pinCfg.Mode != "" {
 		showPinVerify(pinCfg)
 	} else {
 		showPinSetup()
@@ -57,9 +108,7 @@ func closeAndCleanupStore(removeDB bool) {
 		uiStore = nil
 	}
 	if removeDB {
-		os.Remove(uiDBPath)
-		os.Remove(uiDBPath + "-wal")
-		os.Remove(uiDBPath + "-shm")
+		_ = store.RemoveVault(uiDBPath)
 	}
 }
 
@@ -71,23 +120,18 @@ func setupLogin() {
 		uiLoginStrength.Update(text)
 	})
 	uiLoginStrength.AddTo(uiLoginForm)
-	uiLoginForm.AddButton("Login", func() {
-		uiLoginHasError = false
-		pwd := uiLoginForm.GetFormItem(0).(*tview.InputField).GetText()
-		goToMain(pwd)
-	})
+	uiLoginForm.AddButton("Login", login)
 
 	uiLoginForm.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		if event.Key() == tcell.KeyEnter {
-			uiLoginHasError = false
-			pwd := uiLoginForm.GetFormItem(0).(*tview.InputField).GetText()
-			goToMain(pwd)
+			login()
 			if uiLoginHasError {
 				return nil
 			}
 		}
 		return event
 	})
+	uiLoginForm.AddButton("Recover", showRecover)
 	uiLoginForm.AddButton("Quit", func() { uiApp.Stop() })
 	uiLoginForm.SetBorder(true).SetTitle(" PassBook Login ").SetTitleAlign(tview.AlignCenter)
 	styleForm(uiLoginForm)
@@ -99,6 +143,51 @@ func setupLogin() {
 
 var uiLoginHasError bool
 
+// login tries the master password typed into the login form. The form is
+// emptied once it has been taken, unless it is to be corrected.
+func login() {
+	uiLoginHasError = false
+	field := uiLoginForm.GetFormItem(0).(*tview.InputField)
+	pwd := crypto.SecretString(field.GetText())
+	defer pwd.Release()
+	goToMain(pwd)
+	if !uiLoginHasError {
+		field.SetText("")
+	}
+}
+
+// recordUnlockFailure counts a failed unlock and returns msg followed by
+// what happens next. Once MaxFailedUnlocks is reached with
+// WipeAfterMaxFailures set, the store is closed, the vault erased and
+// erased is true.
+func recordUnlockFailure(a *store.Attempts, msg string) (_ string, erased bool) {
+	now := time.Now()
+	_ = a.Fail(now)
+	limit := uiCfg.MaxFailedUnlocks
+	if a.Exceeded(limit) && uiCfg.WipeAfterMaxFailures {
+		closeAndCleanupStore(false)
+		replaceSecret(&uiUnlockKey, "")
+		if err := store.RemoveVault(uiDBPath); err == nil {
+			return "Too many failed attempts. The vault was erased.", true
+		}
+	}
+	if n := a.Remaining(limit); n > 0 {
+		msg += fmt.Sprintf(" Attempts left: %d.", n)
+	}
+	if wait := a.Wait(limit, now); wait > 0 {
+		msg += " Wait " + formatWait(wait) + "."
+	}
+	return msg, false
+}
+
+// formatWait rounds d up to whole seconds, or to minutes past a minute.
+func formatWait(d time.Duration) string {
+	if d > time.Minute {
+		return fmt.Sprintf("%dm", (d+time.Minute-1)/time.Minute)
+	}
+	return fmt.Sprintf("%ds", (d+time.Second-1)/time.Second)
+}
+
 func showLoginError(msg string) {
 	if uiLoginStrength != nil {
 		for _, tv := range uiLoginStrength.views {
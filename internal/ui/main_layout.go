@@ -12,12 +12,16 @@ var (
 )
 
 func setupMainLayout() {
-	uiSearchField = styleInput(tview.NewInputField().SetLabel("Search: ")).SetPlaceholder("Ctrl+F")
+	uiSearchField = styleInput(tview.NewInputField().SetLabel("Search: ")).SetPlaceholder("press / to search")
 	uiSearchField.SetChangedFunc(func(text string) { refreshTree(text) })
 	uiSearchField.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		if event.Key() == tcell.KeyEnter {
+		switch event.Key() {
+		case tcell.KeyEnter:
 			uiApp.SetFocus(uiTreeView)
 			return nil
+		case tcell.KeyCtrlS:
+			showSaveSearch()
+			return nil
 		}
 		return event
 	})
@@ -26,42 +30,55 @@ func setupMainLayout() {
 	uiTreeView = tview.NewTreeView().SetRoot(root).SetCurrentNode(root)
 	uiTreeView.SetTopLevel(1)
 	uiTreeView.SetBorder(true).SetTitle(" Vault ")
+	uiTreeView.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyRune && event.Rune() == '/' {
+			uiApp.SetFocus(uiSearchField)
+			return nil
+		}
+		return event
+	})
 	uiTreeView.SetChangedFunc(func(node *tview.TreeNode) {
 		ref := node.GetReference()
-		if ref == nil {
+		uiCurrentSearchID = 0
+		nr, ok := ref.(nodeRef)
+		if !ok {
+			if sr, isSearch := ref.(searchRef); isSearch {
+				uiCurrentSearchID = sr.ID
+			}
 			uiCurrentFolderID = 0
 			uiCurrentEntryID = 0
-			uiCurrentEnt = nil
+			dropCurrentEntry()
 			uiRightPages.SetTitle(" Keybindings ")
 			uiRightPages.SwitchToPage("empty")
 			return
 		}
-		nr, ok := ref.(nodeRef)
-		if !ok {
-			return
-		}
 		if !nr.IsFolder {
 			uiCurrentFolderID = 0
 			loadEntry(nr.ID)
 		} else {
 			uiCurrentFolderID = nr.ID
 			uiCurrentEntryID = 0
-			uiCurrentEnt = nil
+			dropCurrentEntry()
 			uiRightPages.SetTitle(" Keybindings ")
 			uiRightPages.SwitchToPage("empty")
 		}
 	})
 	uiTreeView.SetSelectedFunc(func(node *tview.TreeNode) {
-		ref := node.GetReference()
-		if ref == nil {
-			node.SetExpanded(!node.IsExpanded())
-			return
-		}
-		nr, ok := ref.(nodeRef)
-		if !ok {
-			return
-		}
-		if nr.IsFolder {
+		switch ref := node.GetReference().(type) {
+		case nodeRef:
+			if ref.IsFolder {
+				node.SetExpanded(!node.IsExpanded())
+			}
+		case tagRef:
+			if ref.Name == "" {
+				uiTagsExpanded = !uiTagsExpanded
+				node.SetExpanded(uiTagsExpanded)
+				return
+			}
+			// Setting the text refreshes the tree; keep the tag selected.
+			uiSearchField.SetText(toggleTagFilter(uiSearchField.GetText(), ref.Name))
+			selectTreeNode(ref)
+		default:
 			node.SetExpanded(!node.IsExpanded())
 		}
 	})
@@ -85,12 +102,16 @@ func setupMainLayout() {
 	keybindTable := tview.NewTable().SetBorders(false).SetSelectable(false, false)
 	bindings := [][2]string{
 		{"Ctrl+A", "Create new item"},
-		{"Ctrl+E", "Edit item / rename folder"},
-		{"Ctrl+D", "Delete item / folder"},
-		{"Ctrl+N", "Create new folder"},
-		{"Ctrl+F", "Search vault"},
+		{"Ctrl+E", "Edit item / rename or move folder"},
+		{"Ctrl+D", "Delete item / folder / saved search"},
+		{"Ctrl+N", "Create new folder (in the selected one)"},
+		{"Ctrl+F", "Find item (fuzzy, Ctrl+Y copies)"},
+		{"/", "Search vault (user:, url:, type:, tag:)"},
+		{"Ctrl+S", "Save search (in search field)"},
 		{"Ctrl+Y", "Quick copy to clipboard"},
 		{"Ctrl+P", "Change master password"},
+		{"Ctrl+T", "Security settings (2FA)"},
+		{"Ctrl+L", "Lock vault"},
 		{"Ctrl+Q", "Quit"},
 		{"Enter", "Open item / toggle folder"},
 		{"Esc", "Focus tree view"},
@@ -126,11 +147,13 @@ func setupMainLayout() {
 			if uiCurrentFolderID != 0 {
 				showFolderRename()
 			} else if uiCurrentEnt != nil && uiCurrentEntryID != 0 {
-				openEditor(uiCurrentEnt)
+				openEditor(withEntrySecrets(uiCurrentEnt))
 			}
 			return nil
 		case tcell.KeyCtrlD:
-			if uiCurrentFolderID != 0 {
+			if uiCurrentSearchID != 0 {
+				showSavedSearchDeleteModal()
+			} else if uiCurrentFolderID != 0 {
 				showFolderDeleteModal()
 			} else if uiCurrentEntryID != 0 {
 				showDeleteModal()
@@ -140,7 +163,7 @@ func setupMainLayout() {
 			showFolderCreate()
 			return nil
 		case tcell.KeyCtrlF:
-			uiApp.SetFocus(uiSearchField)
+			showFinder()
 			return nil
 		case tcell.KeyCtrlY:
 			showQuickCopy()
@@ -148,6 +171,9 @@ func setupMainLayout() {
 		case tcell.KeyCtrlP:
 			showChangePassword()
 			return nil
+		case tcell.KeyCtrlT:
+			showSecurityUnlock()
+			return nil
 		case tcell.KeyCtrlQ:
 			uiApp.Stop()
 			return nil
@@ -3,6 +3,8 @@ package ui
 import (
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
+
+	"passbook/internal/platform"
 )
 
 var (
@@ -10,6 +12,7 @@ var (
 	uiCollisionModal *tview.Modal
 	uiErrorModal     *tview.Modal
 	uiHistoryList    *tview.List
+	uiURLPickerList  *tview.List
 )
 
 func setupModals() {
@@ -35,6 +38,17 @@ func setupModals() {
 		return event
 	})
 	uiPages.AddPage("history", newResponsiveModal(uiHistoryList, 50, 15, 80, 25, 0.6, 0.65), true, false)
+
+	uiURLPickerList = tview.NewList().ShowSecondaryText(false)
+	uiURLPickerList.SetBorder(true).SetTitle(" Open URL (Esc to close) ")
+	uiURLPickerList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEsc {
+			closeURLPicker()
+			return nil
+		}
+		return event
+	})
+	uiPages.AddPage("url_picker", newResponsiveModal(uiURLPickerList, 50, 8, 90, 16, 0.6, 0.4), true, false)
 }
 
 func showHistory() {
@@ -43,11 +57,33 @@ func showHistory() {
 	}
 	uiHistoryList.Clear()
 	for i := len(uiCurrentEnt.History) - 1; i >= 0; i-- {
-		uiHistoryList.AddItem(uiCurrentEnt.History[i].Password, uiCurrentEnt.History[i].Date, 0, nil)
+		uiHistoryList.AddItem(uiEntrySecrets.history[i].String(), uiCurrentEnt.History[i].Date, 0, nil)
 	}
 	uiPages.SwitchToPage("history")
 }
 
+// showURLPicker lists the current login's URIs; choosing one opens it.
+func showURLPicker() {
+	if uiURLPickerList == nil || uiPages == nil {
+		return
+	}
+	uiURLPickerList.Clear()
+	for _, u := range uiCurrentEnt.URIs {
+		uri := u.URI
+		uiURLPickerList.AddItem(tview.Escape(uri), "", 0, func() {
+			_ = platform.OpenURL(uri)
+			closeURLPicker()
+		})
+	}
+	uiPages.SwitchToPage("url_picker")
+	uiApp.SetFocus(uiURLPickerList)
+}
+
+func closeURLPicker() {
+	uiPages.SwitchToPage("main")
+	uiApp.SetFocus(uiRightPages)
+}
+
 func showDeleteModal() {
 	uiDeleteModal.SetText("Delete " + uiCurrentEnt.Title + "?")
 	uiPages.SwitchToPage("delete")
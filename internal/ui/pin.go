@@ -1,18 +1,18 @@
 package ui
 
 import (
+	"errors"
 	"strings"
 	"time"
 
 	"passbook/internal/crypto"
 	"passbook/internal/store"
+	"passbook/internal/utils"
 
-	"github.com/atotto/clipboard"
 	"github.com/gdamore/tcell/v2"
 	"github.com/pquerna/otp"
 	"github.com/pquerna/otp/totp"
 	"github.com/rivo/tview"
-	qrcode "github.com/skip2/go-qrcode"
 )
 
 var (
@@ -29,6 +29,10 @@ var (
 	uiPinVerifyForm   *tview.Form
 	uiPinVerifyStatus *tview.TextView
 	uiPinConfig       *store.PinConfig
+
+	// uiVerifyMode is the method the verify page asks for: the primary
+	// one, or the other when both are set up and the user switched.
+	uiVerifyMode string
 )
 
 func pinDigitAccept(text string, ch rune) bool {
@@ -56,7 +60,11 @@ func setupPinSetupMenu() {
 
 	uiPinSetupForm.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		if event.Key() == tcell.KeyEsc {
-			uiApp.Stop()
+			if uiSecurityEditing {
+				showSecurity("")
+			} else {
+				uiApp.Stop()
+			}
 			return nil
 		}
 		return event
@@ -72,6 +80,16 @@ func showPinSetup() {
 	uiApp.SetFocus(uiPinSetupForm)
 }
 
+// leavePinSetup goes back from the PIN or authenticator setup, to the
+// security settings if they opened it.
+func leavePinSetup() {
+	if uiSecurityEditing {
+		showSecurity("")
+		return
+	}
+	showPinSetup()
+}
+
 // ── PIN creation ────────────────────────────────────────────────────
 
 func setupPinCreate() {
@@ -90,7 +108,7 @@ func setupPinCreate() {
 	uiPinCreateForm.AddFormItem(uiPinCreateStatus)
 
 	uiPinCreateForm.AddButton("Save", doSavePin)
-	uiPinCreateForm.AddButton("Back", func() { showPinSetup() })
+	uiPinCreateForm.AddButton("Back", leavePinSetup)
 
 	uiPinCreateForm.SetBorder(true).
 		SetTitle(" Create 6-Digit PIN ").
@@ -100,7 +118,7 @@ func setupPinCreate() {
 	uiPinCreateForm.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		switch event.Key() {
 		case tcell.KeyEsc:
-			showPinSetup()
+			leavePinSetup()
 			return nil
 		case tcell.KeyEnter:
 			focused := uiApp.GetFocus()
@@ -141,23 +159,36 @@ func doSavePin() {
 		return
 	}
 
-	pinKey, err := crypto.GeneratePinKey()
-	if err != nil {
-		uiPinCreateStatus.SetText("[red]Failed to generate PIN key.")
+	if uiSecurityEditing {
+		if err := setPIN(pin); err != nil {
+			uiPinCreateStatus.SetText("[red]Failed to save PIN.")
+			return
+		}
+		showSecurity("PIN saved.")
 		return
 	}
 
-	cfg := store.PinConfig{
-		Mode:   "pin",
-		PinKey: pinKey,
-		PinTag: crypto.ComputePinTag(pinKey, pin),
+	err := uiStore.KeyWithPIN(uiUnlockKey.String(), pin)
+	if errors.Is(err, store.ErrNoCipherHeader) {
+		err = writePinTag(&store.PinConfig{Mode: "pin"}, pin)
 	}
-	if err := uiStore.WritePinConfig(&cfg); err != nil {
+	if err != nil {
 		uiPinCreateStatus.SetText("[red]Failed to save PIN.")
 		return
 	}
 
-	enterMain()
+	finishSetup()
+}
+
+// writePinTag saves cfg with a check for pin kept inside the vault, for
+// vaults whose key cannot include the PIN.
+func writePinTag(cfg *store.PinConfig, pin string) error {
+	pinKey, err := crypto.GeneratePinKey()
+	if err != nil {
+		return err
+	}
+	cfg.PinKey, cfg.PinTag = pinKey, crypto.ComputePinTag(pinKey, pin)
+	return uiStore.WritePinConfig(cfg)
 }
 
 // ── TOTP setup ──────────────────────────────────────────────────────
@@ -177,9 +208,11 @@ func setupTotpSetup() {
 			return nil
 		case tcell.KeyCtrlY:
 			if uiPendingTotp != "" {
-				_ = clipboard.WriteAll(uiPendingTotp)
-				if uiTotpSetupStatus != nil {
-					uiTotpSetupStatus.SetText("[green]Secret copied!")
+				err := uiClipboard.CopySensitive(uiPendingTotp, nil)
+				if uiTotpSetupStatus != nil && err != nil {
+					uiTotpSetupStatus.SetText("[red]Copy failed: " + tview.Escape(err.Error()))
+				} else if uiTotpSetupStatus != nil {
+					uiTotpSetupStatus.SetText("[green]Secret copied![-]" + unmarkedWarning())
 				}
 			}
 			return nil
@@ -242,7 +275,7 @@ func showTotpSetup() {
 	uiTotpSetupForm.AddButton("Verify", doSaveTotp)
 	uiTotpSetupForm.AddButton("Back", func() {
 		uiPendingTotp = ""
-		showPinSetup()
+		leavePinSetup()
 	})
 
 	styleForm(uiTotpSetupForm)
@@ -264,23 +297,30 @@ func doSaveTotp() {
 		return
 	}
 
-	if !validateTOTP(code, uiPendingTotp) {
+	if !crypto.ValidateTOTP(code, uiPendingTotp) {
 		uiTotpSetupStatus.SetText("[red]Invalid code. Please try again.")
 		codeItem.(*tview.InputField).SetText("")
 		return
 	}
 
-	cfg := store.PinConfig{
-		Mode:       "totp",
-		TotpSecret: uiPendingTotp,
+	var err error
+	if uiSecurityEditing {
+		err = setTOTP(uiPendingTotp)
+	} else {
+		err = uiStore.WritePinConfig(&store.PinConfig{Mode: "totp", TotpSecret: uiPendingTotp})
 	}
-	if err := uiStore.WritePinConfig(&cfg); err != nil {
+	if err != nil {
 		uiTotpSetupStatus.SetText("[red]Failed to save TOTP config.")
 		return
 	}
 
 	uiPendingTotp = ""
-	enterMain()
+	codes, err := newBackupCodes()
+	if err != nil {
+		finishSetup()
+		return
+	}
+	showBackupCodes(codes)
 }
 
 // ── PIN / TOTP verification ─────────────────────────────────────────
@@ -314,21 +354,29 @@ func setupPinVerify() {
 }
 
 func showPinVerify(cfg *store.PinConfig) {
-	uiPinConfig = cfg
+	showPinVerifyAs(cfg, cfg.Mode)
+}
+
+// showPinVerifyAs asks for the second factor with method mode.
+func showPinVerifyAs(cfg *store.PinConfig, mode string) {
+	uiPinConfig, uiVerifyMode = cfg, mode
 	uiPinVerifyForm.Clear(true)
 
 	var title, label string
-	if cfg.Mode == "pin" {
+	if mode == "pin" {
 		title = " Enter 6-Digit PIN "
 		label = "PIN"
 	} else {
-		title = " Enter Authenticator Code "
+		title = " Enter Authenticator or Backup Code "
 		label = "Code"
 	}
 	uiPinVerifyForm.SetTitle(title)
 
 	uiPinVerifyForm.AddPasswordField(label, "", 12, '*', nil)
 	setPinAcceptance(uiPinVerifyForm, 0)
+	if mode == "totp" {
+		uiPinVerifyForm.GetFormItem(0).(*tview.InputField).SetAcceptanceFunc(backupCodeAccept)
+	}
 
 	uiPinVerifyStatus = tview.NewTextView().SetDynamicColors(true)
 	uiPinVerifyStatus.SetLabel(" ")
@@ -337,6 +385,26 @@ func showPinVerify(cfg *store.PinConfig) {
 	uiPinVerifyForm.AddFormItem(uiPinVerifyStatus)
 
 	uiPinVerifyForm.AddButton("Verify", doVerifyPin)
+	if cfg.HasPIN() && cfg.HasTOTP() {
+		other, name := "pin", "Use PIN"
+		if mode == "pin" {
+			other, name = "totp", "Use Authenticator"
+		}
+		uiPinVerifyForm.AddButton(name, func() {
+			title := uiPinVerifyForm.GetTitle()
+			showPinVerifyAs(cfg, other)
+			if strings.HasPrefix(title, lockedTitle) {
+				uiPinVerifyForm.SetTitle(lockedTitle + uiPinVerifyForm.GetTitle())
+			}
+		})
+	}
+	if uiStore == nil {
+		// The vault was locked; offer the master password instead.
+		uiPinVerifyForm.AddButton("Password", func() {
+			replaceSecret(&uiUnlockKey, "")
+			showLogin()
+		})
+	}
 	uiPinVerifyForm.AddButton("Quit", func() { uiApp.Stop() })
 
 	styleForm(uiPinVerifyForm)
@@ -346,26 +414,67 @@ func showPinVerify(cfg *store.PinConfig) {
 
 func doVerifyPin() {
 	code := uiPinVerifyForm.GetFormItem(0).(*tview.InputField).GetText()
-	if len(code) != 6 {
+	if len(code) != 6 && (uiVerifyMode != "totp" || len(code) < 10) {
 		uiPinVerifyStatus.SetText("[red]Enter a 6-digit code.")
 		return
 	}
 
-	switch uiPinConfig.Mode {
+	attempts := store.LoadAttempts(uiDBPath, store.AttemptPIN)
+	if wait := attempts.Wait(uiCfg.MaxFailedUnlocks, time.Now()); wait > 0 {
+		uiPinVerifyStatus.SetText("[red]Too many failed attempts. Try again in " + formatWait(wait) + ".")
+		return
+	}
+
+	// A PIN without a tag is part of the vault key and is checked by
+	// opening the vault.
+	keyed := uiPinConfig.KeyedPIN()
+
+	var failed string
+	switch uiVerifyMode {
 	case "pin":
-		if !crypto.VerifyPinTag(uiPinConfig.PinKey, code, uiPinConfig.PinTag) {
-			uiPinVerifyStatus.SetText("[red]Wrong PIN.")
-			uiPinVerifyForm.GetFormItem(0).(*tview.InputField).SetText("")
-			return
+		if !keyed && !crypto.VerifyPinTag(uiPinConfig.PinKey, code, uiPinConfig.PinTag) {
+			failed = "Wrong PIN."
 		}
 	case "totp":
-		if !validateTOTP(code, uiPinConfig.TotpSecret) {
-			uiPinVerifyStatus.SetText("[red]Invalid code.")
-			uiPinVerifyForm.GetFormItem(0).(*tview.InputField).SetText("")
+		if !crypto.ValidateTOTP(code, uiPinConfig.TotpSecret) && !useBackupCode(code) {
+			failed = "Invalid code."
+		}
+	}
+	if failed == "" && uiStore == nil {
+		if err := reopenStore(code); err != nil {
+			switch {
+			case !keyed:
+				replaceSecret(&uiUnlockKey, "")
+				showLogin()
+				if errors.Is(err, store.ErrWrongKey) {
+					showLoginError("Vault changed; enter the master password.")
+				} else {
+					showLoginError("Cannot open the vault: " + err.Error())
+				}
+				return
+			case errors.Is(err, store.ErrWrongKey):
+				failed = "Wrong PIN or password."
+			default:
+				uiPinVerifyStatus.SetText("[red]Cannot open the vault: " + err.Error())
+				return
+			}
+		}
+	}
+	if failed != "" {
+		msg, erased := recordUnlockFailure(attempts, failed)
+		if erased {
+			showLogin()
+			showLoginError(msg)
 			return
 		}
+		uiPinVerifyStatus.SetText("[red]" + msg)
+		uiPinVerifyForm.GetFormItem(0).(*tview.InputField).SetText("")
+		return
+	}
+	_ = attempts.Reset()
+	if keyed {
+		_ = store.LoadAttempts(uiDBPath, store.AttemptPassword).Reset()
 	}
-
 	enterMain()
 }
 
@@ -377,6 +486,12 @@ func setPinAcceptance(form *tview.Form, idx int) {
 }
 
 func enterMain() {
+	uiLoginForm.GetFormItem(0).(*tview.InputField).SetText("")
+	if !uiCfg.PinUnlock {
+		replaceSecret(&uiUnlockKey, "")
+	}
+	uiUnlocked = true
+	uiLastActivity = time.Now()
 	refreshTree("")
 	uiPages.SwitchToPage("main")
 	uiApp.SetFocus(uiTreeView)
@@ -386,51 +501,17 @@ func formatTotpSecret(secret string) string {
 	return strings.ToUpper(secret)
 }
 
-func validateTOTP(code, secret string) bool {
-	ok, _ := totp.ValidateCustom(code, secret, time.Now().UTC(), totp.ValidateOpts{
-		Period:    30,
-		Skew:     2,
-		Digits:   otp.DigitsSix,
-		Algorithm: otp.AlgorithmSHA1,
-	})
-	return ok
-}
-
 func renderQRCode(url string) (string, int) {
-	qr, err := qrcode.New(url, qrcode.Low)
-	if err != nil {
-		return "", 0
-	}
-
-	bmp := qr.Bitmap()
-	rows := len(bmp)
-	if rows == 0 {
-		return "", 0
-	}
-	cols := len(bmp[0])
-
-	var buf strings.Builder
-	lines := 0
-
-	for y := 0; y < rows; y += 2 {
-		for x := 0; x < cols; x++ {
-			top := bmp[y][x]
-			bot := y+1 < rows && bmp[y+1][x]
-
-			switch {
-			case top && bot:
-				buf.WriteString("[black:black] [-:-]")
-			case top && !bot:
-				buf.WriteString("[black:white]▀[-:-]")
-			case !top && bot:
-				buf.WriteString("[white:black]▀[-:-]")
-			default:
-				buf.WriteString("[white:white] [-:-]")
-			}
+	return utils.RenderQR(url, func(top, bot bool) string {
+		switch {
+		case top && bot:
+			return "[black:black] [-:-]"
+		case top && !bot:
+			return "[black:white]▀[-:-]"
+		case !top && bot:
+			return "[white:black]▀[-:-]"
+		default:
+			return "[white:white] [-:-]"
 		}
-		buf.WriteString("\n")
-		lines++
-	}
-
-	return buf.String(), lines
+	})
 }
@@ -2,11 +2,8 @@ package ui
 
 import (
 	"strings"
-	"time"
 
-	"github.com/atotto/clipboard"
 	"github.com/gdamore/tcell/v2"
-	"github.com/pquerna/otp/totp"
 	"github.com/rivo/tview"
 )
 
@@ -41,21 +38,19 @@ func showQuickCopy() {
 	case TypeLogin:
 		if uiCurrentEnt.Username != "" {
 			uiQuickCopyList.AddItem("Username", "", 'u', func() {
-				_ = clipboard.WriteAll(uiCurrentEnt.Username)
 				dismissQuickCopy()
-				notifyCopied("Username")
+				copyText(uiCurrentEnt.Username, "Username")
 			})
 		}
-		if uiCurrentEnt.Password != "" {
+		if password := uiEntrySecrets.password; password != nil {
 			uiQuickCopyList.AddItem("Password", "", 'p', func() {
 				dismissQuickCopy()
-				copySensitive(uiCurrentEnt.Password, "Password")
+				copySensitive(password.String(), "Password")
 			})
 		}
-		secret := strings.ReplaceAll(uiCurrentEnt.TotpSecret, " ", "")
-		if secret != "" {
+		if secret := uiEntrySecrets.totp; secret != nil {
 			uiQuickCopyList.AddItem("TOTP Code", "", 't', func() {
-				code, err := totp.GenerateCode(secret, time.Now())
+				code, err := totpCode(secret)
 				if err == nil {
 					dismissQuickCopy()
 					copySensitive(code, "TOTP")
@@ -64,25 +59,24 @@ func showQuickCopy() {
 		}
 
 	case TypeCard:
-		if uiCurrentEnt.CardNumber != "" {
+		if number := uiEntrySecrets.cardNumber; number != nil {
 			uiQuickCopyList.AddItem("Card Number", "", 'c', func() {
 				dismissQuickCopy()
-				copySensitive(uiCurrentEnt.CardNumber, "Card Number")
+				copySensitive(number.String(), "Card Number")
 			})
 		}
-		if uiCurrentEnt.CVV != "" {
+		if cvv := uiEntrySecrets.cvv; cvv != nil {
 			uiQuickCopyList.AddItem("CVV", "", 'v', func() {
 				dismissQuickCopy()
-				copySensitive(uiCurrentEnt.CVV, "CVV")
+				copySensitive(cvv.String(), "CVV")
 			})
 		}
 
 	case TypeNote:
 		if strings.TrimSpace(uiCurrentEnt.CustomText) != "" {
 			uiQuickCopyList.AddItem("Note", "", 'n', func() {
-				_ = clipboard.WriteAll(uiCurrentEnt.CustomText)
 				dismissQuickCopy()
-				notifyCopied("Note")
+				copyText(uiCurrentEnt.CustomText, "Note")
 			})
 		}
 	}
@@ -0,0 +1,322 @@
+package ui
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"passbook/internal/config"
+	"passbook/internal/crypto"
+	"passbook/internal/store"
+	"passbook/internal/utils"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// recoverySheetPath is where Save Sheet writes the recovery sheet, for
+// printing.
+const recoverySheetPath = "~/passbook-recovery-sheet.txt"
+
+var (
+	uiRecoveryKitFlex   *tview.Flex
+	uiRecoveryKitForm   *tview.Form
+	uiRecoveryKitStatus *tview.TextView
+	uiRecoveryKey       string
+
+	uiBackupCodesView *tview.TextView
+	uiBackupCodesForm *tview.Form
+
+	uiRecoverForm     *tview.Form
+	uiRecoverStrength *strengthMeter
+	uiRecoverStatus   *tview.TextView
+
+	// uiOfferRecovery is set while a new vault is being set up, so that a
+	// recovery key is offered once the second factor is chosen.
+	uiOfferRecovery bool
+)
+
+func setupRecovery() {
+	setupRecoveryKit()
+	setupBackupCodes()
+	setupRecover()
+}
+
+// finishSetup follows the PIN or authenticator setup.
+func finishSetup() {
+	if uiSecurityEditing {
+		showSecurity("")
+		return
+	}
+	if uiOfferRecovery {
+		uiOfferRecovery = false
+		showRecoveryOffer()
+		return
+	}
+	enterMain()
+}
+
+// ── Recovery kit ────────────────────────────────────────────────────
+
+func setupRecoveryKit() {
+	uiRecoveryKitFlex = tview.NewFlex().SetDirection(tview.FlexRow)
+	uiRecoveryKitFlex.SetBorder(true).
+		SetTitle(" Recovery Key ").
+		SetTitleAlign(tview.AlignCenter)
+
+	uiRecoveryKitForm = tview.NewForm()
+	uiRecoveryKitForm.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEsc {
+			closeRecoveryKit()
+			return nil
+		}
+		return event
+	})
+	enableButtonNav(uiRecoveryKitForm)
+
+	uiPages.AddPage("recovery_kit",
+		newResponsiveModal(uiRecoveryKitFlex, 55, 12, 75, 44, 0.8, 0.9), true, false)
+}
+
+func showRecoveryOffer() {
+	uiRecoveryKitFlex.Clear()
+	text := tview.NewTextView().SetWordWrap(true)
+	text.SetText("A recovery key opens the vault if you forget the master password " +
+		"or lose your PIN or authenticator app, and lets you set new ones. " +
+		"Create one now? It can also be made later with passbook vault recovery-kit.")
+	uiRecoveryKitFlex.AddItem(text, 4, 0, false)
+
+	uiRecoveryKitForm.Clear(true)
+	uiRecoveryKitStatus = newStatusLine()
+	uiRecoveryKitForm.AddFormItem(uiRecoveryKitStatus)
+	uiRecoveryKitForm.AddButton("Create", doCreateRecovery)
+	uiRecoveryKitForm.AddButton("Skip", closeRecoveryKit)
+	styleForm(uiRecoveryKitForm)
+	uiRecoveryKitFlex.AddItem(uiRecoveryKitForm, 0, 1, true)
+
+	uiPages.SwitchToPage("recovery_kit")
+	uiApp.SetFocus(uiRecoveryKitForm)
+}
+
+func doCreateRecovery() {
+	key, err := uiStore.SetupRecovery(uiUnlockKey.String())
+	if err != nil {
+		uiRecoveryKitStatus.SetText("[red]Failed to create the recovery key.")
+		return
+	}
+	uiRecoveryKey = key
+
+	uiRecoveryKitFlex.Clear()
+	if qrStr, qrLines := renderQRCode(key); qrLines > 0 {
+		qrTV := tview.NewTextView().SetDynamicColors(true)
+		qrTV.SetText(qrStr)
+		uiRecoveryKitFlex.AddItem(qrTV, qrLines, 0, false)
+	}
+	keyTV := tview.NewTextView().SetDynamicColors(true).SetTextAlign(tview.AlignCenter)
+	keyTV.SetText("[yellow]" + key)
+	uiRecoveryKitFlex.AddItem(keyTV, 1, 0, false)
+	hint := tview.NewTextView().SetWordWrap(true)
+	hint.SetText("Write it down or save the sheet and print it. Keep it away from the vault; it is not shown again.")
+	uiRecoveryKitFlex.AddItem(hint, 2, 0, false)
+
+	uiRecoveryKitForm.Clear(true)
+	uiRecoveryKitStatus = newStatusLine()
+	uiRecoveryKitForm.AddFormItem(uiRecoveryKitStatus)
+	uiRecoveryKitForm.AddButton("Save Sheet", doSaveRecoverySheet)
+	uiRecoveryKitForm.AddButton("Done", closeRecoveryKit)
+	styleForm(uiRecoveryKitForm)
+	uiRecoveryKitFlex.AddItem(uiRecoveryKitForm, 0, 1, true)
+	uiApp.SetFocus(uiRecoveryKitForm)
+}
+
+func doSaveRecoverySheet() {
+	path := config.ExpandPath(recoverySheetPath)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		uiRecoveryKitStatus.SetText("[red]Cannot write " + recoverySheetPath + "; does it exist already?")
+		return
+	}
+	_, err = f.WriteString(utils.RecoverySheet(uiRecoveryKey, time.Now()))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		uiRecoveryKitStatus.SetText("[red]Failed to save the sheet.")
+		return
+	}
+	uiRecoveryKitStatus.SetText("[green]Saved to " + recoverySheetPath + "; print it, then delete the file.")
+}
+
+func closeRecoveryKit() {
+	uiRecoveryKey = ""
+	uiRecoveryKitFlex.Clear()
+	enterMain()
+}
+
+// ── Backup codes ────────────────────────────────────────────────────
+
+func setupBackupCodes() {
+	uiBackupCodesView = tview.NewTextView().SetDynamicColors(true)
+
+	uiBackupCodesForm = tview.NewForm()
+	uiBackupCodesForm.AddButton("Continue", finishSetup)
+	styleForm(uiBackupCodesForm)
+	enableButtonNav(uiBackupCodesForm)
+
+	flex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(uiBackupCodesView, 0, 1, false).
+		AddItem(uiBackupCodesForm, 3, 0, true)
+	flex.SetBorder(true).SetTitle(" Backup Codes ").SetTitleAlign(tview.AlignCenter)
+
+	uiPages.AddPage("backup_codes",
+		newResponsiveModal(flex, 50, 20, 70, 24, 0.5, 0.6), true, false)
+}
+
+// showBackupCodes shows new authenticator backup codes once, then goes on
+// with finishSetup.
+func showBackupCodes(codes []string) {
+	var b strings.Builder
+	b.WriteString("Each code stands in for one authenticator code if you lose the app. Write them down; they are not shown again.\n\n")
+	for _, c := range codes {
+		b.WriteString("    [yellow]" + c + "[-]\n")
+	}
+	uiBackupCodesView.SetText(b.String())
+	uiPages.SwitchToPage("backup_codes")
+	uiApp.SetFocus(uiBackupCodesForm)
+}
+
+// newBackupCodes replaces the vault's backup codes and returns the new
+// ones.
+func newBackupCodes() ([]string, error) {
+	codes, err := crypto.GenerateBackupCodes(crypto.BackupCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := uiStore.SetBackupCodes(codes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// useBackupCode uses up code if it is an unused backup code. A locked
+// vault is reopened to look, and closed again if the code is not one.
+func useBackupCode(code string) bool {
+	if len(code) == 6 {
+		return false
+	}
+	reopened := uiStore == nil
+	if reopened && reopenStore("") != nil {
+		return false
+	}
+	ok, _ := uiStore.UseBackupCode(code)
+	if !ok && reopened {
+		closeAndCleanupStore(false)
+	}
+	return ok
+}
+
+func backupCodeAccept(text string, ch rune) bool {
+	return (ch >= '0' && ch <= '9' || ch == '-') && len(text) <= 11
+}
+
+// ── Recovery ────────────────────────────────────────────────────────
+
+func setupRecover() {
+	uiRecoverStrength = newStrengthMeter()
+
+	uiRecoverForm = tview.NewForm()
+	uiRecoverForm.AddInputField("Recovery Key", "", 0, nil, nil)
+	uiRecoverForm.AddPasswordField("New Password", "", 0, '*', func(text string) {
+		uiRecoverStrength.Update(text)
+	})
+	uiRecoverStrength.AddTo(uiRecoverForm)
+	uiRecoverForm.AddPasswordField("Confirm Password", "", 0, '*', nil)
+	uiRecoverStatus = newStatusLine()
+	uiRecoverForm.AddFormItem(uiRecoverStatus)
+
+	uiRecoverForm.AddButton("Reset", doRecover)
+	uiRecoverForm.AddButton("Back", showLogin)
+	uiRecoverForm.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEsc {
+			showLogin()
+			return nil
+		}
+		return event
+	})
+	uiRecoverForm.SetBorder(true).
+		SetTitle(" Recover With Recovery Key ").
+		SetTitleAlign(tview.AlignCenter)
+	styleForm(uiRecoverForm)
+	enableButtonNav(uiRecoverForm)
+
+	uiPages.AddPage("recover",
+		newResponsiveModal(uiRecoverForm, 60, 15, 80, 17, 0.5, 0.4), true, false)
+}
+
+func showRecover() {
+	for i := 0; i < uiRecoverForm.GetFormItemCount(); i++ {
+		if input, ok := uiRecoverForm.GetFormItem(i).(*tview.InputField); ok {
+			input.SetText("")
+		}
+	}
+	uiRecoverStrength.Update("")
+	uiRecoverStatus.SetText("")
+	uiPages.SwitchToPage("recover")
+	uiApp.SetFocus(uiRecoverForm)
+}
+
+// doRecover resets the master password with the recovery key and logs
+// in with the new one, which leads on to setting up a second factor.
+func doRecover() {
+	key := uiRecoverForm.GetFormItemByLabel("Recovery Key").(*tview.InputField).GetText()
+	pwd := uiRecoverForm.GetFormItemByLabel("New Password").(*tview.InputField).GetText()
+	confirm := uiRecoverForm.GetFormItemByLabel("Confirm Password").(*tview.InputField).GetText()
+
+	switch {
+	case key == "" || pwd == "" || confirm == "":
+		uiRecoverStatus.SetText("[red]All fields are required.")
+		return
+	case pwd != confirm:
+		uiRecoverStatus.SetText("[red]Passwords do not match.")
+		return
+	}
+	if _, level, _ := utils.PasswordStrength(pwd); level < utils.StrengthGood {
+		uiRecoverStatus.SetText("[red]Password is too weak.")
+		return
+	}
+
+	err := store.Recover(uiDBPath, key, pwd)
+	switch {
+	case errors.Is(err, store.ErrNoRecoveryKey):
+		uiRecoverStatus.SetText("[red]This vault has no recovery key.")
+		return
+	case errors.Is(err, store.ErrWrongRecoveryKey):
+		uiRecoverStatus.SetText("[red]Wrong recovery key.")
+		return
+	case err != nil:
+		uiRecoverStatus.SetText("[red]Recovery failed: " + err.Error())
+		return
+	}
+
+	_ = store.LoadAttempts(uiDBPath, store.AttemptPassword).Reset()
+	_ = store.LoadAttempts(uiDBPath, store.AttemptPIN).Reset()
+	if uiCfg.Keyfile != "" {
+		// The vault no longer needs a keyfile.
+		uiCfg.Keyfile = ""
+		_ = config.Save(uiCfg)
+	}
+	showLogin()
+	secret := crypto.SecretString(pwd)
+	defer secret.Release()
+	goToMain(secret)
+}
+
+func newStatusLine() *tview.TextView {
+	tv := tview.NewTextView().SetDynamicColors(true)
+	tv.SetLabel(" ")
+	tv.SetSize(1, 0)
+	tv.SetScrollable(false)
+	return tv
+}
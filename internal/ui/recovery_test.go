@@ -0,0 +1,58 @@
+package ui
+
+import (
+	"path/filepath"
+	"testing"
+
+	"passbook/internal/config"
+	"passbook/internal/crypto"
+	"passbook/internal/store"
+
+	"github.com/rivo/tview"
+)
+
+func TestRecoverResetsToSecondFactorSetup(t *testing.T) {
+	if uiLoginForm == nil {
+		setupUI()
+		uiApp.SetRoot(uiPages, true)
+	}
+	uiCfg = config.AppConfig{}
+	uiDBPath = filepath.Join(t.TempDir(), "passbook.db")
+	s, err := store.Create(uiDBPath, "testpass", store.CipherParams{
+		KDF: crypto.KDFParams{Memory: 64, Time: 1, Threads: 1}, PageSize: 4096, KDFIter: 1000,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := s.KeyWithPIN("testpass", "123456"); err != nil {
+		t.Fatalf("KeyWithPIN: %v", err)
+	}
+	key, err := s.SetupRecovery("testpass")
+	if err != nil {
+		t.Fatalf("SetupRecovery: %v", err)
+	}
+	s.Close()
+	t.Cleanup(func() { closeAndCleanupStore(false) })
+
+	const newPassword = "Correct-Horse-Battery-9"
+	fill := func(key string) {
+		showRecover()
+		uiRecoverForm.GetFormItemByLabel("Recovery Key").(*tview.InputField).SetText(key)
+		uiRecoverForm.GetFormItemByLabel("New Password").(*tview.InputField).SetText(newPassword)
+		uiRecoverForm.GetFormItemByLabel("Confirm Password").(*tview.InputField).SetText(newPassword)
+		doRecover()
+	}
+
+	fill("AAAA-AAAA-AAAA-AAAA-AAAA-AAAA-AAAA-AAAA")
+	if got := uiRecoverStatus.GetText(true); got != "Wrong recovery key." {
+		t.Fatalf("unexpected status %q", got)
+	}
+
+	fill(key)
+	if name, _ := uiPages.GetFrontPage(); name != "pin_setup" || uiStore == nil {
+		t.Fatalf("expected the vault open at the second factor setup, got %q", name)
+	}
+	if uiOfferRecovery {
+		t.Fatalf("expected no new recovery key to be offered")
+	}
+}
@@ -0,0 +1,105 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+var (
+	uiCurrentSearchID   int64
+	uiSearchSaveForm    *tview.Form
+	uiSearchDeleteModal *tview.Modal
+)
+
+func setupSavedSearches() {
+	uiSearchSaveForm = tview.NewForm()
+	uiSearchSaveForm.AddInputField("Name", "", 0, nil, nil)
+	uiSearchSaveForm.AddButton("Save", doSaveSearch)
+	uiSearchSaveForm.AddButton("Cancel", func() {
+		uiPages.SwitchToPage("main")
+		uiApp.SetFocus(uiSearchField)
+	})
+	uiSearchSaveForm.SetBorder(true).SetTitle(" Save Search ")
+	styleForm(uiSearchSaveForm)
+	uiSearchSaveForm.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEsc:
+			uiPages.SwitchToPage("main")
+			uiApp.SetFocus(uiSearchField)
+			return nil
+		case tcell.KeyEnter:
+			if uiApp.GetFocus() != uiSearchSaveForm.GetButton(0) &&
+				uiApp.GetFocus() != uiSearchSaveForm.GetButton(1) {
+				doSaveSearch()
+				return nil
+			}
+		}
+		return event
+	})
+	enableButtonNav(uiSearchSaveForm)
+	uiPages.AddPage("search_save", newResponsiveModal(uiSearchSaveForm, 45, 9, 65, 13, 0.45, 0.3), true, false)
+
+	uiSearchDeleteModal = tview.NewModal().
+		AddButtons([]string{"Delete", "Cancel"}).
+		SetDoneFunc(func(index int, label string) {
+			if label == "Delete" {
+				doSavedSearchDelete()
+			}
+			uiPages.SwitchToPage("main")
+			uiApp.SetFocus(uiTreeView)
+		})
+	enableModalButtonNav(uiSearchDeleteModal)
+	uiPages.AddPage("search_delete", uiSearchDeleteModal, true, false)
+}
+
+// showSaveSearch asks for a name under which to save the current search.
+func showSaveSearch() {
+	if uiSearchSaveForm == nil || strings.TrimSpace(uiSearchField.GetText()) == "" {
+		return
+	}
+	nameField := uiSearchSaveForm.GetFormItemByLabel("Name").(*tview.InputField)
+	nameField.SetText("")
+	uiSearchSaveForm.SetFocus(0)
+	uiPages.SwitchToPage("search_save")
+}
+
+func doSaveSearch() {
+	nameField := uiSearchSaveForm.GetFormItemByLabel("Name").(*tview.InputField)
+	name := strings.TrimSpace(nameField.GetText())
+	query := strings.TrimSpace(uiSearchField.GetText())
+	if name == "" || query == "" {
+		return
+	}
+	if _, err := uiStore.SaveSearch(name, query); err != nil {
+		return
+	}
+	// The saved search now shows the same entries as a virtual folder.
+	uiSearchField.SetText("")
+	uiPages.SwitchToPage("main")
+	uiApp.SetFocus(uiTreeView)
+}
+
+func showSavedSearchDeleteModal() {
+	if uiCurrentSearchID == 0 {
+		return
+	}
+	ss, _ := uiStore.GetSavedSearch(uiCurrentSearchID)
+	if ss == nil {
+		return
+	}
+	uiSearchDeleteModal.SetText(fmt.Sprintf(
+		"Delete saved search \"%s\"?\nThe items it shows are not affected.", ss.Name))
+	uiPages.SwitchToPage("search_delete")
+}
+
+func doSavedSearchDelete() {
+	if uiCurrentSearchID == 0 {
+		return
+	}
+	_ = uiStore.DeleteSavedSearch(uiCurrentSearchID)
+	uiCurrentSearchID = 0
+	refreshTree(uiSearchField.GetText())
+}
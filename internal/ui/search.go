@@ -0,0 +1,99 @@
+package ui
+
+import (
+	"strings"
+	"unicode"
+
+	"passbook/internal/store"
+
+	"github.com/rivo/tview"
+)
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
+
+// hasTag reports whether the search text already filters on tag.
+func hasTag(text, tag string) bool {
+	for _, t := range store.ParseSearch(text).Tags {
+		if t == strings.ToLower(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// toggleTagFilter adds "tag:<tag>" to the search text, or removes it when
+// it is already there.
+func toggleTagFilter(text, tag string) string {
+	if !hasTag(text, tag) {
+		return strings.TrimSpace(text + " tag:" + tag)
+	}
+	var kept []string
+	for _, w := range strings.Fields(text) {
+		if name, ok := cutPrefixFold(w, "tag:"); ok && strings.EqualFold(store.NormalizeTag(name), tag) {
+			continue
+		}
+		kept = append(kept, w)
+	}
+	return strings.Join(kept, " ")
+}
+
+// highlightTitle escapes title for a tree node and highlights each word in
+// it that starts with one of words, which are lower-case, the way the
+// search matched it.
+func highlightTitle(title string, words []string) string {
+	var b strings.Builder
+	r := []rune(title)
+	plain := 0
+	for i := 0; i < len(r); {
+		if !isWordRune(r[i]) {
+			i++
+			continue
+		}
+		end := i
+		for end < len(r) && isWordRune(r[end]) {
+			end++
+		}
+		if n := matchedPrefix(strings.ToLower(string(r[i:end])), words); n > 0 {
+			b.WriteString(tview.Escape(string(r[plain:i])))
+			b.WriteString("[yellow::b]" + tview.Escape(string(r[i:i+n])) + "[-::-]")
+			plain = i + n
+		}
+		i = end
+	}
+	b.WriteString(tview.Escape(string(r[plain:])))
+	return b.String()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// matchedPrefix returns the length in runes of the longest of words that
+// word starts with, or 0.
+func matchedPrefix(word string, words []string) int {
+	best := 0
+	for _, w := range words {
+		if n := len([]rune(w)); n > best && strings.HasPrefix(word, w) {
+			best = n
+		}
+	}
+	return best
+}
+
+// resultHint describes where a search result is: its folder path and, when
+// the title is not among the matched fields, the fields that matched.
+func resultHint(r store.SearchResult, folder string) string {
+	var parts []string
+	if folder != "" {
+		parts = append(parts, folder)
+	}
+	if len(r.Fields) > 0 && r.Fields[0] != "title" {
+		parts = append(parts, strings.Join(r.Fields, ", "))
+	}
+	return strings.Join(parts, " · ")
+}
@@ -0,0 +1,46 @@
+package ui
+
+import (
+	"testing"
+
+	"passbook/internal/store"
+)
+
+func TestHighlightTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		words []string
+		want  string
+	}{
+		{"GitHub", nil, "GitHub"},
+		{"GitHub", []string{"git"}, "[yellow::b]Git[-::-]Hub"},
+		{"My git-lab [ci]", []string{"lab", "ci"}, "My git-[yellow::b]lab[-::-] [[yellow::b]ci[-::-]]"},
+		{"Digital", []string{"git"}, "Digital"},
+	}
+	for _, tt := range tests {
+		if got := highlightTitle(tt.title, tt.words); got != tt.want {
+			t.Errorf("highlightTitle(%q, %v) = %q, want %q", tt.title, tt.words, got, tt.want)
+		}
+	}
+}
+
+func TestResultHint(t *testing.T) {
+	r := store.SearchResult{Fields: []string{"username", "notes"}}
+	if got := resultHint(r, "Work/AWS"); got != "Work/AWS · username, notes" {
+		t.Fatalf("unexpected hint %q", got)
+	}
+	r.Fields = []string{"title", "notes"}
+	if got := resultHint(r, ""); got != "" {
+		t.Fatalf("expected no hint for a title match, got %q", got)
+	}
+}
+
+func TestToggleTagFilter(t *testing.T) {
+	text := toggleTagFilter("git", "prod")
+	if text != "git tag:prod" {
+		t.Fatalf("expected tag to be added, got %q", text)
+	}
+	if got := toggleTagFilter(text, "Prod"); got != "git" {
+		t.Fatalf("expected tag to be removed, got %q", got)
+	}
+}
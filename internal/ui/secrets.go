@@ -0,0 +1,98 @@
+package ui
+
+import (
+	"strings"
+	"time"
+
+	"passbook/internal/crypto"
+
+	"github.com/pquerna/otp/totp"
+)
+
+// entrySecrets are the secret values of the open entry, in locked memory.
+// loadEntry moves them out of uiCurrentEnt, so revealing and copying read
+// them from here and only hold a plain string for the call.
+type entrySecrets struct {
+	password   *crypto.Secret
+	cardNumber *crypto.Secret
+	cvv        *crypto.Secret
+	totp       *crypto.Secret
+	// fields holds the values of hidden and TOTP custom fields by index.
+	fields  map[int]*crypto.Secret
+	history []*crypto.Secret
+}
+
+var uiEntrySecrets entrySecrets
+
+// keepEntrySecrets wipes the values kept for the previous entry, moves
+// ent's secret values into uiEntrySecrets and blanks them in ent.
+func keepEntrySecrets(ent *Entry) {
+	releaseEntrySecrets()
+	s := &uiEntrySecrets
+	s.password, ent.Password = crypto.SecretString(ent.Password), ""
+	s.cardNumber, ent.CardNumber = crypto.SecretString(ent.CardNumber), ""
+	s.cvv, ent.CVV = crypto.SecretString(ent.CVV), ""
+	s.totp, ent.TotpSecret = crypto.SecretString(ent.TotpSecret), ""
+
+	ent.Fields = append([]CustomField(nil), ent.Fields...)
+	for i, f := range ent.Fields {
+		if fieldMasked(f) {
+			if s.fields == nil {
+				s.fields = make(map[int]*crypto.Secret)
+			}
+			s.fields[i], ent.Fields[i].Value = crypto.SecretString(f.Value), ""
+		}
+	}
+	ent.History = append([]PasswordHistory(nil), ent.History...)
+	for i, h := range ent.History {
+		s.history = append(s.history, crypto.SecretString(h.Password))
+		ent.History[i].Password = ""
+	}
+}
+
+// withEntrySecrets returns a copy of ent, the open entry, with its secret
+// values put back, for the editor.
+func withEntrySecrets(ent *Entry) *Entry {
+	s := &uiEntrySecrets
+	e := *ent
+	e.Password = s.password.String()
+	e.CardNumber = s.cardNumber.String()
+	e.CVV = s.cvv.String()
+	e.TotpSecret = s.totp.String()
+	e.Fields = append([]CustomField(nil), ent.Fields...)
+	for i, v := range s.fields {
+		e.Fields[i].Value = v.String()
+	}
+	e.History = append([]PasswordHistory(nil), ent.History...)
+	for i, v := range s.history {
+		e.History[i].Password = v.String()
+	}
+	return &e
+}
+
+// totpCode returns the current code for a TOTP secret kept in locked
+// memory.
+func totpCode(secret *crypto.Secret) (string, error) {
+	return totp.GenerateCode(strings.ReplaceAll(secret.String(), " ", ""), time.Now())
+}
+
+// releaseEntrySecrets wipes the values kept for the open entry.
+func releaseEntrySecrets() {
+	s := &uiEntrySecrets
+	for _, v := range []*crypto.Secret{s.password, s.cardNumber, s.cvv, s.totp} {
+		v.Release()
+	}
+	for _, v := range s.fields {
+		v.Release()
+	}
+	for _, v := range s.history {
+		v.Release()
+	}
+	*s = entrySecrets{}
+}
+
+// dropCurrentEntry forgets the open entry and wipes its secret values.
+func dropCurrentEntry() {
+	uiCurrentEnt = nil
+	releaseEntrySecrets()
+}
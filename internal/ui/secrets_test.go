@@ -0,0 +1,82 @@
+package ui
+
+import (
+	"testing"
+
+	"passbook/internal/config"
+
+	"github.com/rivo/tview"
+)
+
+func TestEntrySecretsAreKeptLockedAndWiped(t *testing.T) {
+	unlockTestVault(t, config.AppConfig{})
+
+	password := uiEntrySecrets.password
+	if uiCurrentEnt.Password != "" || password.String() != "s3cret" {
+		t.Fatalf("expected the password moved out of the entry, got %q", uiCurrentEnt.Password)
+	}
+	uiShowSensitive = true
+	updateViewPane()
+	if got := uiViewPassword.GetText(false); got != "s3cret" {
+		t.Fatalf("expected the revealed password, got %q", got)
+	}
+	if ent := withEntrySecrets(uiCurrentEnt); ent.Password != "s3cret" || uiCurrentEnt.Password != "" {
+		t.Fatalf("expected the editor to get the password and the entry to stay blank")
+	}
+
+	lockVault()
+	if uiEntrySecrets.password != nil || password.Len() != 0 {
+		t.Fatalf("expected the password to be wiped on lock")
+	}
+}
+
+func TestEntrySecretsFollowTheOpenEntry(t *testing.T) {
+	id := unlockTestVault(t, config.AppConfig{})
+	ent := &Entry{
+		Type:    string(TypeLogin),
+		Title:   "Mail",
+		Fields:  []CustomField{{Name: "PIN", Value: "0000", Type: "hidden"}, {Name: "Plan", Value: "pro"}},
+		History: []PasswordHistory{{Password: "old", Date: "2024-01-01"}},
+	}
+	other, err := uiStore.SaveEntry(0, ent)
+	if err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	first := uiEntrySecrets.password
+
+	loadEntry(other)
+	if first.Len() != 0 || uiEntrySecrets.password != nil {
+		t.Fatalf("expected the previous entry's password to be wiped")
+	}
+	if uiCurrentEnt.Fields[0].Value != "" || uiCurrentEnt.Fields[1].Value != "pro" || uiCurrentEnt.History[0].Password != "" {
+		t.Fatalf("expected only hidden values blanked, got %+v %+v", uiCurrentEnt.Fields, uiCurrentEnt.History)
+	}
+	if e := withEntrySecrets(uiCurrentEnt); e.Fields[0].Value != "0000" || e.History[0].Password != "old" {
+		t.Fatalf("expected the values put back for the editor, got %+v %+v", e.Fields, e.History)
+	}
+
+	pin := uiEntrySecrets.fields[0]
+	loadEntry(id)
+	dropCurrentEntry()
+	if pin.Len() != 0 || uiEntrySecrets.password != nil {
+		t.Fatalf("expected the kept values wiped when the entry is dropped")
+	}
+}
+
+func TestLoginEmptiesForm(t *testing.T) {
+	unlockTestVault(t, config.AppConfig{})
+	closeAndCleanupStore(false)
+
+	showLogin()
+	field := uiLoginForm.GetFormItem(0).(*tview.InputField)
+	field.SetText("wrong")
+	login()
+	if field.GetText() != "wrong" {
+		t.Fatalf("expected a wrong password to stay for correction")
+	}
+	field.SetText("testpass")
+	login()
+	if uiStore == nil || field.GetText() != "" {
+		t.Fatalf("expected the vault open and the form emptied")
+	}
+}
@@ -0,0 +1,349 @@
+package ui
+
+import (
+	"errors"
+
+	"passbook/internal/crypto"
+	"passbook/internal/store"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+var (
+	uiSecurityUnlockForm   *tview.Form
+	uiSecurityUnlockStatus *tview.TextView
+
+	uiSecurityInfo   *tview.TextView
+	uiSecurityList   *tview.List
+	uiSecurityStatus *tview.TextView
+
+	// uiSecurityPwd and uiSecurityPIN are the master password and PIN
+	// entered to open the security settings, in locked memory; a PIN that
+	// is the only second factor is part of the vault key, so changing the
+	// methods re-keys the vault. uiSecurityPIN stays nil if an
+	// authenticator code was entered instead.
+	uiSecurityPwd *crypto.Secret
+	uiSecurityPIN *crypto.Secret
+
+	// uiSecurityEditing is set while the security settings are open, so
+	// the PIN and authenticator setup pages return to them.
+	uiSecurityEditing bool
+)
+
+func setupSecurity() {
+	uiSecurityUnlockForm = tview.NewForm()
+	uiSecurityUnlockForm.SetBorder(true).
+		SetTitle(" Security Settings ").
+		SetTitleAlign(tview.AlignCenter)
+	uiSecurityUnlockForm.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEsc {
+			closeSecurity()
+			return nil
+		}
+		return event
+	})
+	enableButtonNav(uiSecurityUnlockForm)
+
+	uiPages.AddPage("security_unlock",
+		newResponsiveModal(uiSecurityUnlockForm, 50, 11, 70, 13, 0.5, 0.35), true, false)
+
+	uiSecurityInfo = tview.NewTextView().SetDynamicColors(true).SetWordWrap(true)
+	uiSecurityList = tview.NewList().ShowSecondaryText(false)
+	uiSecurityList.SetHighlightFullLine(true)
+	uiSecurityList.SetMainTextColor(tcell.ColorWhite)
+	uiSecurityList.SetSelectedTextColor(tcell.ColorBlack)
+	uiSecurityList.SetSelectedBackgroundColor(tcell.ColorSkyblue)
+	uiSecurityList.SetShortcutColor(tcell.ColorYellow)
+	uiSecurityList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEsc {
+			closeSecurity()
+			return nil
+		}
+		return event
+	})
+	uiSecurityStatus = tview.NewTextView().SetDynamicColors(true).SetTextAlign(tview.AlignCenter)
+
+	flex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(uiSecurityInfo, 3, 0, false).
+		AddItem(uiSecurityList, 0, 1, true).
+		AddItem(uiSecurityStatus, 1, 0, false)
+	flex.SetBorder(true).SetTitle(" Security Settings ").SetTitleAlign(tview.AlignCenter)
+
+	uiPages.AddPage("security",
+		newResponsiveModal(flex, 50, 16, 70, 20, 0.5, 0.5), true, false)
+}
+
+// showSecurityUnlock asks for the master password and the current second
+// factor again before the security settings open.
+func showSecurityUnlock() {
+	cfg := readSecurityConfig()
+
+	uiSecurityUnlockForm.Clear(true)
+	uiSecurityUnlockForm.AddPasswordField("Master Password", "", 0, '*', nil)
+	switch {
+	case cfg.HasPIN() && cfg.HasTOTP():
+		uiSecurityUnlockForm.AddPasswordField("PIN or Code", "", 12, '*', nil)
+		uiSecurityUnlockForm.GetFormItem(1).(*tview.InputField).SetAcceptanceFunc(backupCodeAccept)
+	case cfg.HasPIN():
+		uiSecurityUnlockForm.AddPasswordField("PIN", "", 12, '*', nil)
+		setPinAcceptance(uiSecurityUnlockForm, 1)
+	case cfg.HasTOTP():
+		uiSecurityUnlockForm.AddPasswordField("Code", "", 12, '*', nil)
+		uiSecurityUnlockForm.GetFormItem(1).(*tview.InputField).SetAcceptanceFunc(backupCodeAccept)
+	}
+	uiSecurityUnlockStatus = newStatusLine()
+	uiSecurityUnlockForm.AddFormItem(uiSecurityUnlockStatus)
+	uiSecurityUnlockForm.AddButton("Continue", doSecurityUnlock)
+	uiSecurityUnlockForm.AddButton("Cancel", closeSecurity)
+	styleForm(uiSecurityUnlockForm)
+
+	uiPages.SwitchToPage("security_unlock")
+	uiApp.SetFocus(uiSecurityUnlockForm)
+}
+
+func doSecurityUnlock() {
+	pwd := uiSecurityUnlockForm.GetFormItem(0).(*tview.InputField).GetText()
+	if pwd == "" {
+		uiSecurityUnlockStatus.SetText("[red]Enter the master password.")
+		return
+	}
+	if err := uiStore.VerifyPassword(pwd); err != nil {
+		uiSecurityUnlockStatus.SetText("[red]Wrong master password.")
+		return
+	}
+
+	cfg := readSecurityConfig()
+	pin := ""
+	if cfg.HasPIN() || cfg.HasTOTP() {
+		code := uiSecurityUnlockForm.GetFormItem(1).(*tview.InputField).GetText()
+		switch {
+		case cfg.HasPIN() && uiStore.CheckPIN(code):
+			pin = code
+		case cfg.HasTOTP() && (crypto.ValidateTOTP(code, cfg.TotpSecret) || useBackupCode(code)):
+		default:
+			uiSecurityUnlockStatus.SetText("[red]Wrong PIN or code.")
+			return
+		}
+	}
+
+	replaceSecret(&uiSecurityPwd, pwd)
+	replaceSecret(&uiSecurityPIN, pin)
+	showSecurity("")
+}
+
+// showSecurity lists what can be changed about the second factor, with
+// msg as the outcome of the last change.
+func showSecurity(msg string) {
+	uiSecurityEditing = true
+	cfg := readSecurityConfig()
+
+	var methods string
+	switch {
+	case cfg.HasPIN() && cfg.HasTOTP():
+		if cfg.Mode == "pin" {
+			methods = "[yellow]PIN[-] (asked first) and [yellow]authenticator app[-]"
+		} else {
+			methods = "[yellow]Authenticator app[-] (asked first) and [yellow]PIN[-]"
+		}
+	case cfg.HasPIN():
+		methods = "[yellow]PIN[-]"
+	case cfg.HasTOTP():
+		methods = "[yellow]Authenticator app[-]"
+	default:
+		methods = "[red]Off[-]; the master password alone opens the vault"
+	}
+	uiSecurityInfo.SetText("Two-factor authentication: " + methods)
+
+	uiSecurityList.Clear()
+	if !cfg.HasPIN() && !cfg.HasTOTP() {
+		uiSecurityList.AddItem("Turn On Two-Factor Authentication", "", 'o', showPinSetup)
+	} else {
+		if cfg.HasPIN() {
+			uiSecurityList.AddItem("Change PIN", "", 'p', showPinCreate)
+		} else {
+			uiSecurityList.AddItem("Add PIN", "", 'p', showPinCreate)
+		}
+		if cfg.HasTOTP() {
+			uiSecurityList.AddItem("New Authenticator App", "", 'a', showTotpSetup)
+			uiSecurityList.AddItem("New Backup Codes", "", 'b', doNewBackupCodes)
+		} else {
+			uiSecurityList.AddItem("Add Authenticator App", "", 'a', showTotpSetup)
+		}
+		if canKeyPIN(cfg) {
+			uiSecurityList.AddItem("Make the PIN Part of the Vault Key", "", 'k', func() {
+				securityChange(keyPIN(), "The PIN is now part of the vault key.")
+			})
+		}
+		if cfg.HasPIN() && cfg.HasTOTP() {
+			if cfg.Mode == "pin" {
+				uiSecurityList.AddItem("Ask for the Authenticator First", "", 'f', func() { securityChange(setPrimary("totp"), "Authenticator app is asked for first.") })
+			} else {
+				uiSecurityList.AddItem("Ask for the PIN First", "", 'f', func() { securityChange(setPrimary("pin"), "PIN is asked for first.") })
+			}
+			uiSecurityList.AddItem("Remove PIN", "", 'r', func() { securityChange(removePIN(), "PIN removed.") })
+			uiSecurityList.AddItem("Remove Authenticator App", "", 'x', func() { securityChange(removeTOTP(), "Authenticator app removed.") })
+		}
+		uiSecurityList.AddItem("Turn Off Two-Factor Authentication", "", 'o', func() {
+			securityChange(turnOff2FA(), "Two-factor authentication is off.")
+		})
+	}
+	uiSecurityList.AddItem("Close", "", 'q', closeSecurity)
+
+	if msg != "" {
+		uiSecurityStatus.SetText("[green]" + msg)
+	} else {
+		uiSecurityStatus.SetText("")
+	}
+	uiPages.SwitchToPage("security")
+	uiApp.SetFocus(uiSecurityList)
+}
+
+// securityChange shows the settings again after a change, with msg or
+// the error.
+func securityChange(err error, msg string) {
+	if err != nil {
+		showSecurity("")
+		uiSecurityStatus.SetText("[red]Failed: " + err.Error())
+		return
+	}
+	showSecurity(msg)
+}
+
+func doNewBackupCodes() {
+	codes, err := newBackupCodes()
+	if err != nil {
+		securityChange(err, "")
+		return
+	}
+	showBackupCodes(codes)
+}
+
+func closeSecurity() {
+	forgetSecurityCredentials()
+	uiPages.SwitchToPage("main")
+	uiApp.SetFocus(uiTreeView)
+}
+
+// forgetSecurityCredentials wipes what was entered to open the security
+// settings.
+func forgetSecurityCredentials() {
+	replaceSecret(&uiSecurityPwd, "")
+	replaceSecret(&uiSecurityPIN, "")
+	uiSecurityEditing = false
+	uiSecurityUnlockForm.Clear(true)
+}
+
+// readSecurityConfig returns the vault's second factor, with Mode "off"
+// if none is set up.
+func readSecurityConfig() *store.PinConfig {
+	cfg, _ := uiStore.ReadPinConfig()
+	if cfg == nil {
+		cfg = &store.PinConfig{Mode: "off"}
+	}
+	return cfg
+}
+
+// ── Changes ─────────────────────────────────────────────────────────
+
+// setPIN sets pin as the vault's PIN. As the only second factor it
+// becomes part of the vault key; next to an authenticator app it is a
+// check kept in the vault, so that either one can unlock it.
+func setPIN(pin string) error {
+	cfg := readSecurityConfig()
+	if cfg.HasTOTP() {
+		if err := writePinTag(cfg, pin); err != nil {
+			return err
+		}
+		replaceSecret(&uiSecurityPIN, pin)
+		return nil
+	}
+	err := uiStore.KeyWithPIN(uiSecurityPwd.String(), pin)
+	if errors.Is(err, store.ErrNoCipherHeader) {
+		err = writePinTag(&store.PinConfig{Mode: "pin"}, pin)
+	}
+	if err != nil {
+		return err
+	}
+	replaceSecret(&uiSecurityPIN, pin)
+	return nil
+}
+
+// setTOTP sets secret as the authenticator app's secret. A PIN that was
+// part of the vault key is taken out of it first, since the app has to
+// be able to unlock the vault without it.
+func setTOTP(secret string) error {
+	if err := uiStore.UnkeyPIN(uiSecurityPwd.String()); err != nil {
+		return err
+	}
+	cfg := readSecurityConfig()
+	cfg.TotpSecret = secret
+	if !cfg.HasPIN() {
+		cfg.Mode = "totp"
+	}
+	return uiStore.WritePinConfig(cfg)
+}
+
+func removePIN() error {
+	if err := uiStore.UnkeyPIN(uiSecurityPwd.String()); err != nil {
+		return err
+	}
+	cfg := readSecurityConfig()
+	cfg.Mode, cfg.PinKey, cfg.PinTag = "totp", nil, ""
+	replaceSecret(&uiSecurityPIN, "")
+	return uiStore.WritePinConfig(cfg)
+}
+
+// removeTOTP leaves the PIN as the only second factor, which then goes
+// back into the vault key if it is known; otherwise it stays a check
+// until it is put in the key from these settings, see keyPIN.
+func removeTOTP() error {
+	cfg := readSecurityConfig()
+	cfg.Mode, cfg.TotpSecret = "pin", ""
+	if err := uiStore.WritePinConfig(cfg); err != nil {
+		return err
+	}
+	if err := uiStore.SetBackupCodes(nil); err != nil {
+		return err
+	}
+	if uiSecurityPIN == nil {
+		return nil
+	}
+	err := uiStore.KeyWithPIN(uiSecurityPwd.String(), uiSecurityPIN.String())
+	if errors.Is(err, store.ErrNoCipherHeader) {
+		return nil
+	}
+	return err
+}
+
+// canKeyPIN reports whether the vault's PIN is its only second factor but
+// still a check kept in the vault, as PINs set up before they were part
+// of the key are, and can be moved into the key: that needs a cipher
+// header and the PIN entered to open the settings.
+func canKeyPIN(cfg *store.PinConfig) bool {
+	_, upgraded := uiStore.CipherParams()
+	return upgraded && uiSecurityPIN != nil && cfg.HasPIN() && !cfg.HasTOTP() && !cfg.KeyedPIN()
+}
+
+// keyPIN re-keys the vault with the PIN as part of the key.
+func keyPIN() error {
+	return uiStore.KeyWithPIN(uiSecurityPwd.String(), uiSecurityPIN.String())
+}
+
+// setPrimary makes mode the method asked for first.
+func setPrimary(mode string) error {
+	cfg := readSecurityConfig()
+	cfg.Mode = mode
+	return uiStore.WritePinConfig(cfg)
+}
+
+func turnOff2FA() error {
+	if err := uiStore.UnkeyPIN(uiSecurityPwd.String()); err != nil {
+		return err
+	}
+	if err := uiStore.WritePinConfig(&store.PinConfig{Mode: "off"}); err != nil {
+		return err
+	}
+	replaceSecret(&uiSecurityPIN, "")
+	return uiStore.SetBackupCodes(nil)
+}
@@ -0,0 +1,148 @@
+package ui
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"passbook/internal/config"
+	"passbook/internal/crypto"
+	"passbook/internal/store"
+
+	"github.com/pquerna/otp/totp"
+	"github.com/rivo/tview"
+)
+
+func TestSecuritySettingsChangeSecondFactor(t *testing.T) {
+	unlockTestVault(t, config.AppConfig{})
+	opensWithoutPIN := func() bool {
+		s, err := store.OpenWith(uiDBPath, store.Credentials{Password: "testpass"})
+		if err == nil {
+			s.Close()
+			return true
+		}
+		if !errors.Is(err, store.ErrPINRequired) {
+			t.Fatalf("OpenWith: %v", err)
+		}
+		return false
+	}
+
+	showSecurityUnlock()
+	uiSecurityUnlockForm.GetFormItem(0).(*tview.InputField).SetText("testpass")
+	uiSecurityUnlockForm.GetFormItem(1).(*tview.InputField).SetText("654321")
+	doSecurityUnlock()
+	if got := uiSecurityUnlockStatus.GetText(true); got != "Wrong PIN or code." {
+		t.Fatalf("unexpected status %q", got)
+	}
+	uiSecurityUnlockForm.GetFormItem(1).(*tview.InputField).SetText("123456")
+	doSecurityUnlock()
+	if name, _ := uiPages.GetFrontPage(); name != "security" {
+		t.Fatalf("expected the security settings, got %q", name)
+	}
+
+	// As the only second factor, a new PIN becomes part of the key.
+	showPinCreate()
+	uiPinCreateForm.GetFormItem(0).(*tview.InputField).SetText("246802")
+	uiPinCreateForm.GetFormItem(1).(*tview.InputField).SetText("246802")
+	doSavePin()
+	if name, _ := uiPages.GetFrontPage(); name != "security" || !readSecurityConfig().KeyedPIN() {
+		t.Fatalf("expected the PIN in the key, on page %q", name)
+	}
+	if opensWithoutPIN() {
+		t.Fatalf("expected the vault to need the PIN")
+	}
+
+	// Adding the authenticator takes it out again, so either can unlock.
+	showTotpSetup()
+	code, err := totp.GenerateCode(uiPendingTotp, time.Now())
+	if err != nil {
+		t.Fatalf("GenerateCode: %v", err)
+	}
+	uiTotpSetupForm.GetFormItemByLabel("Code").(*tview.InputField).SetText(code)
+	doSaveTotp()
+	if name, _ := uiPages.GetFrontPage(); name != "backup_codes" {
+		t.Fatalf("expected the backup codes, got %q", name)
+	}
+	finishSetup()
+	cfg := readSecurityConfig()
+	if cfg.Mode != "pin" || !cfg.HasPIN() || !cfg.HasTOTP() || cfg.KeyedPIN() {
+		t.Fatalf("expected both methods with the PIN first, got %+v", cfg)
+	}
+	if !opensWithoutPIN() || !uiStore.CheckPIN("246802") {
+		t.Fatalf("expected the PIN to be a check in the vault")
+	}
+
+	if err := setPrimary("totp"); err != nil {
+		t.Fatalf("setPrimary: %v", err)
+	}
+	showPinVerify(readSecurityConfig())
+	if uiPinVerifyForm.GetButtonIndex("Use PIN") < 0 {
+		t.Fatalf("expected the verify page to offer the PIN too")
+	}
+
+	// Without the authenticator the known PIN goes back into the key.
+	showSecurity("")
+	if err := removeTOTP(); err != nil {
+		t.Fatalf("removeTOTP: %v", err)
+	}
+	if cfg := readSecurityConfig(); !cfg.KeyedPIN() || opensWithoutPIN() {
+		t.Fatalf("expected the PIN in the key again, got %+v", cfg)
+	}
+	if n, _ := uiStore.BackupCodesLeft(); n != 0 {
+		t.Fatalf("expected the backup codes to be removed, got %d", n)
+	}
+
+	if err := turnOff2FA(); err != nil {
+		t.Fatalf("turnOff2FA: %v", err)
+	}
+	closeSecurity()
+	if !opensWithoutPIN() {
+		t.Fatalf("expected the master password alone to open the vault")
+	}
+
+	closeAndCleanupStore(false)
+	showLogin()
+	goToMain(crypto.SecretString("testpass"))
+	if name, _ := uiPages.GetFrontPage(); name != "main" || uiStore == nil {
+		t.Fatalf("expected the vault to open without a second factor, got %q", name)
+	}
+}
+
+func TestPINCheckMovesIntoKeyOnlyWhenAsked(t *testing.T) {
+	unlockTestVault(t, config.AppConfig{PinUnlock: true})
+	opensWithoutPIN := func() bool {
+		s, err := store.OpenWith(uiDBPath, store.Credentials{Password: "testpass"})
+		if err != nil {
+			return false
+		}
+		s.Close()
+		return true
+	}
+
+	// Unlocking with a PIN that is a check leaves the key alone.
+	lockVault()
+	uiPinVerifyForm.GetFormItem(0).(*tview.InputField).SetText("123456")
+	doVerifyPin()
+	if uiStore == nil || readSecurityConfig().KeyedPIN() || !opensWithoutPIN() {
+		t.Fatalf("expected the unlock not to re-key the vault")
+	}
+
+	showSecurityUnlock()
+	uiSecurityUnlockForm.GetFormItem(0).(*tview.InputField).SetText("testpass")
+	uiSecurityUnlockForm.GetFormItem(1).(*tview.InputField).SetText("123456")
+	doSecurityUnlock()
+	if items := uiSecurityList.FindItems("Make the PIN Part of the Vault Key", "", false, false); len(items) != 1 {
+		t.Fatalf("expected the settings to offer moving the PIN into the key")
+	}
+	securityChange(keyPIN(), "The PIN is now part of the vault key.")
+	if got := uiSecurityStatus.GetText(true); got != "The PIN is now part of the vault key." {
+		t.Fatalf("unexpected status %q", got)
+	}
+	if !readSecurityConfig().KeyedPIN() || opensWithoutPIN() {
+		t.Fatalf("expected the PIN in the key")
+	}
+	if items := uiSecurityList.FindItems("Make the PIN Part of the Vault Key", "", false, false); len(items) != 0 {
+		t.Fatalf("expected the action to be gone once the PIN is in the key")
+	}
+	closeSecurity()
+}
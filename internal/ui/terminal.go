@@ -0,0 +1,50 @@
+package ui
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"sync"
+
+	"passbook/internal/crypto"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// uiTerm is the terminal the OSC 52 clipboard backend writes to.
+var uiTerm = &screenTerm{}
+
+// screenTerm writes to the terminal through the tcell screen, so OSC 52
+// sequences reach the user's terminal even over SSH. Writes are queued
+// onto the UI goroutine to land between draws. Without a screen, before
+// the first draw or after the UI has stopped, they go to stdout.
+type screenTerm struct {
+	mu     sync.Mutex
+	screen tcell.Screen
+}
+
+// attach makes the terminal write through screen, or stdout if nil.
+func (t *screenTerm) attach(screen tcell.Screen) {
+	t.mu.Lock()
+	t.screen = screen
+	t.mu.Unlock()
+}
+
+func (t *screenTerm) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	screen := t.screen
+	t.mu.Unlock()
+	if screen == nil {
+		return os.Stdout.Write(p)
+	}
+	tty, ok := screen.Tty()
+	if !ok {
+		return 0, errors.New("the screen has no terminal to write to")
+	}
+	seq := bytes.Clone(p)
+	uiApp.QueueUpdate(func() {
+		_, _ = tty.Write(seq)
+		crypto.WipeBytes(seq)
+	})
+	return len(p), nil
+}
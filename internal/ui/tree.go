@@ -2,7 +2,6 @@ package ui
 
 import (
 	"fmt"
-	"strings"
 
 	"passbook/internal/store"
 
@@ -15,13 +14,29 @@ type nodeRef struct {
 	ID       int64
 }
 
-func selectTreeNode(ref nodeRef) {
+// searchRef marks the virtual folder of a saved search.
+type searchRef struct {
+	ID int64
+}
+
+// tagRef marks a tag in the tag filter section, or the section itself
+// when Name is empty.
+type tagRef struct {
+	Name string
+}
+
+// uiTagsExpanded keeps the tag section open across tree refreshes.
+var uiTagsExpanded bool
+
+// selectTreeNode moves the cursor to the node whose reference equals ref
+// and reports whether the tree has one.
+func selectTreeNode(ref any) bool {
 	if uiTreeView == nil {
-		return
+		return false
 	}
 	root := uiTreeView.GetRoot()
 	if root == nil {
-		return
+		return false
 	}
 
 	var dfs func(n *tview.TreeNode) *tview.TreeNode
@@ -29,10 +44,12 @@ func selectTreeNode(ref nodeRef) {
 		if n == nil {
 			return nil
 		}
-		if r := n.GetReference(); r != nil {
-			if nr, ok := r.(nodeRef); ok && nr == ref {
-				return n
-			}
+		if r := n.GetReference(); r != nil && r == ref {
+			return n
+		}
+		// Entries also appear under saved searches; prefer their folder.
+		if _, ok := n.GetReference().(searchRef); ok {
+			return nil
 		}
 		for _, ch := range n.GetChildren() {
 			if found := dfs(ch); found != nil {
@@ -42,12 +59,15 @@ func selectTreeNode(ref nodeRef) {
 		return nil
 	}
 
-	if node := dfs(root); node != nil {
-		uiTreeView.SetCurrentNode(node)
-		if uiApp != nil {
-			uiApp.SetFocus(uiTreeView)
-		}
+	node := dfs(root)
+	if node == nil {
+		return false
+	}
+	uiTreeView.SetCurrentNode(node)
+	if uiApp != nil {
+		uiApp.SetFocus(uiTreeView)
 	}
+	return true
 }
 
 func entryTypeIcon(t string) string {
@@ -65,16 +85,17 @@ func entryTypeIcon(t string) string {
 	}
 }
 
+// listFolders returns the path of every folder, parents first.
 func listFolders() []string {
 	folders, err := uiStore.ListFolders()
 	if err != nil {
 		return nil
 	}
-	names := make([]string, len(folders))
+	paths := make([]string, len(folders))
 	for i, f := range folders {
-		names[i] = f.Name
+		paths[i] = f.Path
 	}
-	return names
+	return paths
 }
 
 func listFolderInfos() []store.FolderInfo {
@@ -85,51 +106,158 @@ func listFolderInfos() []store.FolderInfo {
 	return folders
 }
 
-func addItemNodes(parent *tview.TreeNode, folderID int64, filter string) int {
-	entries, err := uiStore.ListEntries(folderID)
-	if err != nil {
-		return 0
-	}
-	count := 0
+// addItemNodes adds a node under parent for each entry and returns how
+// many were added.
+func addItemNodes(parent *tview.TreeNode, entries []store.EntryMeta) int {
 	for _, e := range entries {
-		if filter != "" && !strings.Contains(strings.ToLower(e.Title), strings.ToLower(filter)) {
-			continue
-		}
 		icon := entryTypeIcon(e.EntryType)
-		child := tview.NewTreeNode(fmt.Sprintf("%s %s", icon, e.Title)).
+		child := tview.NewTreeNode(fmt.Sprintf("%s %s", icon, tview.Escape(e.Title))).
 			SetReference(nodeRef{IsFolder: false, ID: e.ID}).
 			SetSelectable(true)
 		parent.AddChild(child)
-		count++
 	}
-	return count
+	return len(entries)
+}
+
+// addResultNodes adds a node under parent for each search result, best
+// first, with the words of the search highlighted in the title. The hint
+// after the title gives the entry's folder and, when the title did not
+// match, the fields that did.
+func addResultNodes(parent *tview.TreeNode, results []store.SearchResult, words []string, folders map[int64]string) int {
+	for _, r := range results {
+		icon := entryTypeIcon(r.EntryType)
+		label := fmt.Sprintf("%s %s", icon, highlightTitle(r.Title, words))
+		if hint := resultHint(r, folders[r.FolderID]); hint != "" {
+			label += " [::d]" + tview.Escape(hint) + "[::-]"
+		}
+		child := tview.NewTreeNode(label).
+			SetReference(nodeRef{IsFolder: false, ID: r.ID}).
+			SetSelectable(true)
+		parent.AddChild(child)
+	}
+	return len(results)
 }
 
+// refreshTree rebuilds the tree for the search text filter. Without a
+// search it shows saved searches as virtual folders, the folder hierarchy
+// with root entries, and the tags. A search replaces the hierarchy with
+// the matching entries, best match first.
 func refreshTree(filter string) {
 	root := uiTreeView.GetRoot()
 	root.ClearChildren()
 
+	q := store.ParseSearch(filter)
 	folders := listFolderInfos()
+	paths := make(map[int64]string, len(folders))
+	for _, f := range folders {
+		paths[f.ID] = f.Path
+	}
+
+	var results []store.SearchResult
+	if !q.Empty() {
+		results, _ = uiStore.Search(q)
+	}
+
+	addSavedSearchNodes(root, q, results, paths)
+	if q.Empty() {
+		addFolderNodes(root, 0, folders)
+	} else {
+		addResultNodes(root, results, q.Words(), paths)
+	}
+
+	addTagNodes(root, filter)
 
+	if uiCurrentEntryID == 0 {
+		uiRightPages.SetTitle(" Keybindings ")
+		uiRightPages.SwitchToPage("empty")
+	}
+}
+
+// addFolderNodes adds the subfolders of folderID under parent, each with
+// its own subfolders and entries, followed by folderID's entries.
+func addFolderNodes(parent *tview.TreeNode, folderID int64, folders []store.FolderInfo) {
 	for _, f := range folders {
-		folderNode := tview.NewTreeNode(fmt.Sprintf("📁 %s", f.Name)).
+		if f.ParentID != folderID || f.ID == folderID {
+			continue
+		}
+		folderNode := tview.NewTreeNode(fmt.Sprintf("📁 %s", tview.Escape(f.Name))).
 			SetReference(nodeRef{IsFolder: true, ID: f.ID}).
 			SetColor(tcell.ColorSkyblue).
 			SetSelectable(true).
 			SetExpanded(true)
+		addFolderNodes(folderNode, f.ID, folders)
+		parent.AddChild(folderNode)
+	}
 
-		count := addItemNodes(folderNode, f.ID, filter)
-		if count > 0 || filter == "" {
-			root.AddChild(folderNode)
-		}
+	entries, _ := uiStore.ListEntries(folderID)
+	addItemNodes(parent, entries)
+}
+
+// addSavedSearchNodes adds a virtual folder per saved search holding its
+// results. While searching, only the entries that are also in current, the
+// results of q, are kept, and saved searches left empty are hidden.
+func addSavedSearchNodes(root *tview.TreeNode, q store.SearchQuery, current []store.SearchResult, folders map[int64]string) {
+	searches, err := uiStore.ListSavedSearches()
+	if err != nil || len(searches) == 0 {
+		return
+	}
+	inCurrent := make(map[int64]bool, len(current))
+	for _, r := range current {
+		inCurrent[r.ID] = true
 	}
 
-	addItemNodes(root, 0, filter)
+	for _, ss := range searches {
+		saved := store.ParseSearch(ss.Query)
+		results, err := uiStore.Search(saved)
+		if err != nil {
+			continue
+		}
+		words := saved.Words()
+		if !q.Empty() {
+			kept := results[:0]
+			for _, r := range results {
+				if inCurrent[r.ID] {
+					kept = append(kept, r)
+				}
+			}
+			results = kept
+			words = q.Words()
+		}
 
-	if uiCurrentEntryID == 0 {
-		uiRightPages.SetTitle(" Keybindings ")
-		uiRightPages.SwitchToPage("empty")
+		node := tview.NewTreeNode(fmt.Sprintf("🔎 %s", tview.Escape(ss.Name))).
+			SetReference(searchRef{ID: ss.ID}).
+			SetColor(tcell.ColorMediumPurple).
+			SetSelectable(true).
+			SetExpanded(!q.Empty())
+		count := addResultNodes(node, results, words, folders)
+		if count > 0 || q.Empty() {
+			root.AddChild(node)
+		}
+	}
+}
+
+// addTagNodes adds the tag filter section. Selecting a tag toggles its
+// "tag:" filter in the search field; tags already filtered on are marked.
+func addTagNodes(root *tview.TreeNode, filter string) {
+	tags, err := uiStore.ListTags()
+	if err != nil || len(tags) == 0 {
+		return
+	}
+	section := tview.NewTreeNode("🏷  Tags").
+		SetReference(tagRef{}).
+		SetColor(tcell.ColorYellow).
+		SetSelectable(true).
+		SetExpanded(uiTagsExpanded)
+	for _, t := range tags {
+		label := fmt.Sprintf("#%s (%d)", t.Name, t.Count)
+		if hasTag(filter, t.Name) {
+			label = "✓ " + label
+		}
+		section.AddChild(tview.NewTreeNode(label).
+			SetReference(tagRef{Name: t.Name}).
+			SetSelectable(true))
 	}
+	root.AddChild(section)
 }
 
 func loadEntry(id int64) {
@@ -138,9 +266,11 @@ func loadEntry(id int64) {
 		return
 	}
 
+	keepEntrySecrets(ent)
 	uiCurrentEnt = ent
 	uiCurrentEntryID = id
 	uiShowSensitive = false
+	uiShownFields = nil
 	updateViewPane()
 	uiRightPages.SetTitle(" " + entryTypeIcon(ent.Type) + " " + ent.Title + " ")
 	uiRightPages.SwitchToPage("content")
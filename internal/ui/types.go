@@ -4,6 +4,7 @@ import "passbook/internal/store"
 
 type Entry = store.EntryFull
 type Attachment = store.AttachmentMeta
+type CustomField = store.CustomField
 type PasswordHistory = store.PasswordHistory
 type EntryType string
 
@@ -5,8 +5,8 @@ import (
 	"strings"
 	"time"
 
-	"github.com/atotto/clipboard"
-	"github.com/pquerna/otp/totp"
+	"passbook/internal/clipboard"
+
 	"github.com/rivo/tview"
 )
 
@@ -32,6 +32,7 @@ func updateViewPane() {
 
 	uiViewTitle.SetText(uiCurrentEnt.Title)
 	uiViewFlex.AddItem(makeRow("Title:", uiViewTitle), 1, 0, false)
+	renderTagsView()
 	uiViewFlex.AddItem(tview.NewTextView().SetText(""), 1, 0, false)
 
 	switch EntryType(uiCurrentEnt.Type) {
@@ -45,6 +46,8 @@ func updateViewPane() {
 		renderFileView()
 	}
 
+	renderFieldsView()
+
 	if len(uiCurrentEnt.Attachments) > 0 {
 		uiViewFlex.AddItem(tview.NewTextView().SetText(""), 1, 0, false)
 		uiViewFlex.AddItem(tview.NewTextView().SetText("[yellow]Attachments:[-]").SetDynamicColors(true), 1, 0, false)
@@ -69,11 +72,7 @@ func updateViewPane() {
 	if strings.TrimSpace(uiCurrentEnt.CustomText) != "" {
 		header := tview.NewTextView().SetText("[yellow]Notes:[-]").SetDynamicColors(true)
 		btnNotesCopy := styleButton(tview.NewButton("cp").SetSelectedFunc(func() {
-			err := clipboard.WriteAll(uiCurrentEnt.CustomText)
-			if err != nil {
-				return
-			}
-			notifyCopied("Notes")
+			copyText(uiCurrentEnt.CustomText, "Notes")
 		}))
 		uiViewFlex.AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
 			AddItem(header, 0, 1, false).
@@ -94,33 +93,64 @@ func deleteEntry() {
 			return
 		}
 		uiCurrentEntryID = 0
-		uiCurrentEnt = nil
+		dropCurrentEntry()
 		refreshTree(uiSearchField.GetText())
 	}
 }
 
 func notifyCopied(item string) {
+	markCurrentUsed()
 	uiViewStatus.SetText(fmt.Sprintf("[green]✓ %s copied![-]", item))
 	go func() { time.Sleep(2 * time.Second); uiApp.QueueUpdateDraw(func() { uiViewStatus.SetText("") }) }()
 }
 
+// copyText copies a value that is not secret.
+func copyText(text, item string) {
+	if err := uiClipboard.Copy(text); err != nil {
+		notifyCopyFailed(err)
+		return
+	}
+	notifyCopied(item)
+}
+
+// notifyCopyFailed shows why a copy did not reach the clipboard.
+func notifyCopyFailed(err error) {
+	uiViewStatus.SetText(fmt.Sprintf("[red]Copy failed: %s[-]", tview.Escape(err.Error())))
+}
+
+// copySensitive copies a secret, which the clipboard manager takes off
+// the clipboard again after the configured time.
 func copySensitive(text, item string) {
-	err := clipboard.WriteAll(text)
+	err := uiClipboard.CopySensitive(text, func() {
+		uiApp.QueueUpdateDraw(func() { uiViewStatus.SetText("[yellow]Clipboard cleared[-]") })
+	})
 	if err != nil {
+		notifyCopyFailed(err)
 		return
 	}
-	uiViewStatus.SetText(fmt.Sprintf("[green]✓ %s copied (clears in 30s)[-]", item))
-	go func() {
-		time.Sleep(30 * time.Second)
-		curr, _ := clipboard.ReadAll()
-		if curr == text {
-			err := clipboard.WriteAll("")
-			if err != nil {
-				return
-			}
-			uiApp.QueueUpdateDraw(func() { uiViewStatus.SetText("[yellow]Clipboard cleared[-]") })
-		}
-	}()
+	markCurrentUsed()
+	if after := uiClipboard.ClearAfter(); after > 0 {
+		uiViewStatus.SetText(fmt.Sprintf("[green]✓ %s copied (clears in %ds)[-]", item, after/time.Second) + unmarkedWarning())
+	} else {
+		uiViewStatus.SetText(fmt.Sprintf("[green]✓ %s copied![-]", item) + unmarkedWarning())
+	}
+}
+
+// unmarkedWarning tells the user, after a sensitive copy through xclip or
+// xsel, that a clipboard manager may keep it in its history.
+func unmarkedWarning() string {
+	if !clipboard.Unmarked(uiClipboard.Backend()) {
+		return ""
+	}
+	return fmt.Sprintf(" [yellow]%s cannot hide it from clipboard history[-]", uiClipboard.Backend().Name())
+}
+
+// markCurrentUsed records a copy from the current entry, which ranks it
+// higher in the finder.
+func markCurrentUsed() {
+	if uiStore != nil && uiCurrentEntryID != 0 {
+		_ = uiStore.MarkEntryUsed(uiCurrentEntryID)
+	}
 }
 
 func drawTOTP() {
@@ -136,9 +166,8 @@ func drawTOTP() {
 	}
 
 	if uiCurrentEnt != nil && EntryType(uiCurrentEnt.Type) == TypeLogin {
-		secret := strings.ReplaceAll(uiCurrentEnt.TotpSecret, " ", "")
-		if secret != "" {
-			code, err := totp.GenerateCode(secret, time.Now())
+		if uiEntrySecrets.totp != nil {
+			code, err := totpCode(uiEntrySecrets.totp)
 			if err == nil {
 				uiViewTOTP.SetText(code)
 				sec := time.Now().Unix() % 30
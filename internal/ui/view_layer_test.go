@@ -18,6 +18,7 @@ func initViewTestState() {
 	uiViewStatus = tview.NewTextView()
 	uiAttachmentList = tview.NewList()
 	uiShowSensitive = false
+	uiShownFields = nil
 }
 
 func TestUpdateViewPaneSetsTitle(t *testing.T) {
@@ -36,6 +37,7 @@ func TestUpdateViewPaneMasksCardNumber(t *testing.T) {
 	resetEditorTestState()
 	initViewTestState()
 	uiCurrentEnt = &Entry{Type: string(TypeCard), Title: "Card", CardNumber: "1234567812345678", Expiry: "12/34", CVV: "123"}
+	keepEntrySecrets(uiCurrentEnt)
 
 	updateViewPane()
 	got := uiViewSubtitle.GetText(false)
@@ -70,3 +72,22 @@ func TestUpdateViewPaneAddsAttachments(t *testing.T) {
 		t.Fatalf("expected attachment list to be populated")
 	}
 }
+
+func TestUpdateViewPaneAddsFields(t *testing.T) {
+	resetEditorTestState()
+	initViewTestState()
+	uiCurrentEnt = &Entry{
+		Type:   string(TypeNote),
+		Title:  "Fields",
+		Fields: []CustomField{{Name: "PIN", Value: "1234", Type: "hidden"}},
+	}
+	keepEntrySecrets(uiCurrentEnt)
+
+	updateViewPane()
+	withFields := uiViewFlex.GetItemCount()
+	uiCurrentEnt.Fields = nil
+	updateViewPane()
+	if withFields-uiViewFlex.GetItemCount() != 3 {
+		t.Fatalf("expected a blank row, a header and one field row")
+	}
+}
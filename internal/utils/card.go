@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ValidateCard checks card number, expiry (MM/YY) and CVV formats.
+// Empty values are allowed so partially filled cards can be saved.
+func ValidateCard(number, expiry, cvv string) error {
+	if number != "" {
+		if len(number) < 13 || len(number) > 19 || !IsDigits(number) {
+			return fmt.Errorf("card number must be 13-19 digits")
+		}
+	}
+
+	if expiry != "" {
+		if len(expiry) != 5 || expiry[2] != '/' {
+			return fmt.Errorf("expiry must be MM/YY")
+		}
+		mm, yy := expiry[:2], expiry[3:]
+		if !IsDigits(mm) || !IsDigits(yy) {
+			return fmt.Errorf("expiry must be MM/YY")
+		}
+		month, _ := strconv.Atoi(mm)
+		if month < 1 || month > 12 {
+			return fmt.Errorf("expiry must be MM/YY")
+		}
+	}
+
+	if cvv != "" {
+		if (len(cvv) != 3 && len(cvv) != 4) || !IsDigits(cvv) {
+			return fmt.Errorf("CVV must be 3 or 4 digits")
+		}
+	}
+
+	return nil
+}
+
+// IsDigits reports whether s is non-empty and consists only of ASCII digits.
+func IsDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// RenderQR draws content as a QR code in text, two rows of modules to a
+// line. cell returns the text for one column of a line, given whether
+// the module above and the one below are dark. It returns the text and
+// its number of lines, or 0 lines if content cannot be encoded.
+func RenderQR(content string, cell func(top, bottom bool) string) (string, int) {
+	qr, err := qrcode.New(content, qrcode.Low)
+	if err != nil {
+		return "", 0
+	}
+
+	bmp := qr.Bitmap()
+	rows := len(bmp)
+	if rows == 0 {
+		return "", 0
+	}
+	cols := len(bmp[0])
+
+	var buf strings.Builder
+	lines := 0
+	for y := 0; y < rows; y += 2 {
+		for x := 0; x < cols; x++ {
+			buf.WriteString(cell(bmp[y][x], y+1 < rows && bmp[y+1][x]))
+		}
+		buf.WriteString("\n")
+		lines++
+	}
+	return buf.String(), lines
+}
+
+// blockCell draws dark modules with block characters, for paper.
+func blockCell(top, bottom bool) string {
+	switch {
+	case top && bottom:
+		return "█"
+	case top:
+		return "▀"
+	case bottom:
+		return "▄"
+	}
+	return " "
+}
+
+// RecoverySheet is the text of a printable recovery sheet for
+// recoveryKey, with the key as a QR code.
+func RecoverySheet(recoveryKey string, created time.Time) string {
+	qr, _ := RenderQR(recoveryKey, blockCell)
+
+	var b strings.Builder
+	b.WriteString("PassBook Recovery Sheet\n")
+	b.WriteString("=======================\n\n")
+	fmt.Fprintf(&b, "Created: %s\n\n", created.Format("2006-01-02"))
+	fmt.Fprintf(&b, "Recovery key:  %s\n\n", recoveryKey)
+	b.WriteString(qr)
+	b.WriteString("\n")
+	b.WriteString("This key opens your vault without the master password, keyfile or\n")
+	b.WriteString("PIN, and lets you choose a new master password and second factor:\n\n")
+	b.WriteString("    passbook vault recover\n\n")
+	b.WriteString("or Recover on the login screen. Anyone holding this sheet and a copy\n")
+	b.WriteString("of the vault can open it: print it, keep it somewhere safe, and do not\n")
+	b.WriteString("keep it next to the vault or on the same computer.\n")
+	return b.String()
+}